@@ -1,15 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"runtime"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/SawitProRecruitment/UserService/generated"
 	"github.com/SawitProRecruitment/UserService/handler"
 	"github.com/SawitProRecruitment/UserService/middleware"
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/breach"
 	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/lockout"
+	"github.com/SawitProRecruitment/UserService/pkg/policy"
+	"github.com/SawitProRecruitment/UserService/pkg/ratelimit"
+	"github.com/SawitProRecruitment/UserService/pkg/revocation"
+	"github.com/SawitProRecruitment/UserService/pkg/sms"
 	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/pkg/totp"
 	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/gommon/log"
@@ -19,9 +35,41 @@ func main() {
 	e := echo.New()
 	server := newServer()
 	e.Logger.SetLevel(log.DEBUG)
+	// the client ip drives the login lockout, so X-Forwarded-For is only trusted behind a proxy
+	e.IPExtractor = echo.ExtractIPDirect()
+	if os.Getenv("BEHIND_PROXY") == "true" {
+		e.IPExtractor = echo.ExtractIPFromXFFHeader()
+	}
 	e.Use(server.middleware.MiddlewareLogger)
+	e.Use(server.middleware.MiddlewareRateLimit)
+	e.Use(server.middleware.MiddlewareRole)
+	e.Use(server.middleware.MiddlewareScope)
 	generated.RegisterHandlers(e, server.handler)
-	e.Logger.Fatal(e.Start(":1323"))
+
+	go func() {
+		err := e.Start(":1323")
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// stop on SIGINT or SIGTERM, the requests in flight and the background work, the exports and
+	// the one-time codes being sent, get SHUTDOWN_TIMEOUT_SECOND to finish
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), server.shutdownTimeout)
+	defer cancel()
+	err := e.Shutdown(ctx)
+	if err != nil {
+		e.Logger.Error("failed shutdown server, err:", err)
+	}
+	// exports still running are left pending, they are failed on the next start
+	err = server.handler.Shutdown(ctx)
+	if err != nil {
+		e.Logger.Error("failed wait background work, err:", err)
+	}
 }
 
 type Server struct {
@@ -30,11 +78,44 @@ type Server struct {
 	repository repository.RepositoryInterface
 	hash       hash.HashMethod
 	token      token.TokenMethod
+	revocation revocation.RevocationMethod
+	authz      authz.AuthzMethod
+	lockout    lockout.LockoutMethod
+	ratelimit  ratelimit.RateLimitMethod
+	sms        sms.Sender
+	totp       totp.TOTPMethod
+	breach     breach.Checker
+	policy     policy.PolicyMethod
+
+	// hashExecutor is hash itself, kept for its metrics
+	hashExecutor *hash.Executor
+	// hashMaxBytes is the most bytes of a password the hash can take, 0 is no limit
+	hashMaxBytes int
+
+	deletionGracePeriod  time.Duration
+	exportAsyncThreshold int
+	exportWorkers        int
+	exportTimeout        time.Duration
+	shutdownTimeout      time.Duration
+	otpTTL               time.Duration
+	otpMaxAttempts       int
+	passwordHistorySize  int
+	mfaChallengeTTL      time.Duration
 }
 
 func newServer() Server {
 	s := Server{}
 
+	// Init Shutdown
+	{
+		timeoutSecond, err := strconv.Atoi(os.Getenv("SHUTDOWN_TIMEOUT_SECOND"))
+		if err != nil || timeoutSecond <= 0 {
+			timeoutSecond = 30
+		}
+		s.shutdownTimeout = time.Duration(timeoutSecond) * time.Second
+		fmt.Println("INIT SHUTDOWN")
+	}
+
 	// Init Repo
 	{
 		dbDsn := os.Getenv("DATABASE_URL")
@@ -55,7 +136,60 @@ func newServer() Server {
 			costInt = 10
 		}
 
-		s.hash = hash.NewHashMethod(costInt)
+		// zero argon2 parameters take the package defaults
+		memoryKiB, _ := strconv.ParseUint(os.Getenv("HASH_ARGON2_MEMORY_KIB"), 10, 32)
+		iterations, _ := strconv.ParseUint(os.Getenv("HASH_ARGON2_ITERATIONS"), 10, 32)
+		parallelism, _ := strconv.ParseUint(os.Getenv("HASH_ARGON2_PARALLELISM"), 10, 8)
+
+		// stored hashes of another algorithm, cost or pepper are upgraded on the next login
+		hashConfig := hash.NewHashConfig{
+			Algorithm: os.Getenv("HASH_ALGORITHM"),
+			Cost:      costInt,
+			Argon2: hash.Argon2Params{
+				Memory:      uint32(memoryKiB),
+				Iterations:  uint32(iterations),
+				Parallelism: uint8(parallelism),
+			},
+			PepperDirectory: os.Getenv("PEPPER_DIRECTORY"),
+		}
+		method, err := hash.NewHashMethod(hashConfig)
+		if err != nil {
+			panic(err)
+		}
+		// the password policy refuses what unpeppered bcrypt can't hash
+		s.hashMaxBytes = hashConfig.MaxValueBytes()
+
+		// at most HASH_WORKERS values are hashed at once, so a login storm can't starve the other
+		// requests, calls beyond HASH_QUEUE_SIZE waiting ones get a 503 with Retry-After
+		workers, err := strconv.Atoi(os.Getenv("HASH_WORKERS"))
+		if err != nil || workers <= 0 {
+			workers = runtime.NumCPU()
+		}
+		queueSize, err := strconv.Atoi(os.Getenv("HASH_QUEUE_SIZE"))
+		if err != nil || queueSize < 0 {
+			queueSize = 64
+		}
+		retryAfterSecond, err := strconv.Atoi(os.Getenv("HASH_RETRY_AFTER_SECOND"))
+		if err != nil || retryAfterSecond <= 0 {
+			retryAfterSecond = 1
+		}
+		executor := hash.NewExecutor(method, hash.NewExecutorConfig{
+			Workers:    workers,
+			QueueSize:  queueSize,
+			RetryAfter: time.Duration(retryAfterSecond) * time.Second,
+		})
+		s.hash = executor
+		s.hashExecutor = executor
+
+		// the executor metrics are served at /metrics/hash, logging them too is off by default
+		metricsSecond, err := strconv.Atoi(os.Getenv("HASH_METRICS_LOG_SECOND"))
+		if err == nil && metricsSecond > 0 {
+			go func() {
+				for range time.Tick(time.Duration(metricsSecond) * time.Second) {
+					fmt.Println("hash executor metrics:", executor.Metrics())
+				}
+			}()
+		}
 		fmt.Println("INIT HASH")
 	}
 
@@ -70,34 +204,405 @@ func newServer() Server {
 		if publicLocation == "" {
 			publicLocation = "./config/public_key.pem"
 		}
+		expMinute, err := strconv.ParseInt(os.Getenv("ACCESS_TOKEN_EXP_MINUTE"), 10, 64)
+		if err != nil || expMinute <= 0 {
+			expMinute = 15
+		}
+
+		refreshExpHour, err := strconv.ParseInt(os.Getenv("REFRESH_TOKEN_EXP_HOUR"), 10, 64)
+		if err != nil || refreshExpHour <= 0 {
+			refreshExpHour = 24 * 30
+		}
+
+		issuer := os.Getenv("TOKEN_ISSUER")
+		if issuer == "" {
+			issuer = "user-service"
+		}
+
+		audience := os.Getenv("TOKEN_AUDIENCE")
+		if audience == "" {
+			audience = "user-service"
+		}
+
+		leewaySecond, err := strconv.ParseInt(os.Getenv("TOKEN_LEEWAY_SECOND"), 10, 64)
+		if err != nil || leewaySecond < 0 {
+			leewaySecond = 30
+		}
+
 		method, err := token.NewTokenMethod(
 			token.NewTokenConfig{
 				PrivateKeyLocation: privateLocation,
 				PublicKeyLocation:  publicLocation,
-				ExpinHour:          24,
+				KeyDirectory:       os.Getenv("KEY_DIRECTORY"),
+				Algorithm:          os.Getenv("TOKEN_ALGORITHM"),
+				ExpinMinute:        expMinute,
+				RefreshExpinHour:   refreshExpHour,
+				Issuer:             issuer,
+				Audience:           audience,
+				LeewayInSecond:     leewaySecond,
 			})
 		if err != nil {
 			panic(err)
 		}
 		s.token = method
+
+		// pick up rotated keys without restarting the process
+		reloadSecond, err := strconv.Atoi(os.Getenv("KEY_RELOAD_SECOND"))
+		if err != nil || reloadSecond <= 0 {
+			reloadSecond = 60
+		}
+		go func() {
+			for range time.Tick(time.Duration(reloadSecond) * time.Second) {
+				if err := method.ReloadKeys(); err != nil {
+					fmt.Println("failed reload token keys, err:", err)
+				}
+			}
+		}()
 		fmt.Println("INIT TOKEN")
 	}
 
+	// Init Revocation
+	{
+		cacheSecond, err := strconv.Atoi(os.Getenv("REVOCATION_CACHE_SECOND"))
+		if err != nil || cacheSecond < 0 {
+			cacheSecond = 30
+		}
+
+		s.revocation = revocation.NewRevocationMethod(revocation.NewRevocationConfig{
+			Repository: s.repository,
+			CacheTTL:   time.Duration(cacheSecond) * time.Second,
+		})
+		fmt.Println("INIT REVOCATION")
+	}
+
+	// Init Authz
+	{
+		s.authz = authz.NewAuthzMethod(authz.NewAuthzConfig{
+			Repository: s.repository,
+		})
+		fmt.Println("INIT AUTHZ")
+	}
+
+	// Init Rate Limit
+	{
+		var store ratelimit.Store
+		switch os.Getenv("RATE_LIMIT_STORE") {
+		case "memory":
+			store = ratelimit.NewMemoryStore()
+		default:
+			store = ratelimit.NewPostgresStore(s.repository)
+
+			// a bucket that is full again is the same as no bucket
+			go func() {
+				for range time.Tick(time.Hour) {
+					err := s.repository.PurgeRateLimitBuckets(context.Background(), time.Now().UTC())
+					if err != nil {
+						fmt.Println("failed purge rate limit buckets, err:", err)
+					}
+				}
+			}()
+		}
+
+		s.ratelimit = ratelimit.NewRateLimitMethod(ratelimit.NewRateLimitConfig{
+			Store: store,
+		})
+		fmt.Println("INIT RATE LIMIT")
+	}
+
 	// Init Middleware
 	{
+		swagger, err := generated.GetSwagger()
+		if err != nil {
+			panic(err)
+		}
+
+		requiredRoles, err := middleware.RequiredRoles(swagger)
+		if err != nil {
+			panic(err)
+		}
+
+		requiredScopes, err := middleware.RequiredScopes(swagger)
+		if err != nil {
+			panic(err)
+		}
+
+		rateLimits, err := middleware.RateLimits(swagger)
+		if err != nil {
+			panic(err)
+		}
+
 		s.middleware = middleware.NewMiddlewareServer(middleware.NewMiddlewareOptions{
-			Token: s.token,
+			Token:          s.token,
+			Revocation:     s.revocation,
+			RateLimit:      s.ratelimit,
+			RequiredRoles:  requiredRoles,
+			RequiredScopes: requiredScopes,
+			RateLimits:     rateLimits,
 		})
 		fmt.Println("INIT MIDDLEWARE")
 	}
 
+	// Init Account Deletion
+	{
+		graceHour, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_HOUR"))
+		if err != nil || graceHour < 0 {
+			graceHour = 24 * 30
+		}
+		s.deletionGracePeriod = time.Duration(graceHour) * time.Hour
+
+		// anonymize accounts whose grace period is over
+		purgeMinute, err := strconv.Atoi(os.Getenv("PURGE_INTERVAL_MINUTE"))
+		if err != nil || purgeMinute <= 0 {
+			purgeMinute = 60
+		}
+		go func() {
+			for range time.Tick(time.Duration(purgeMinute) * time.Minute) {
+				result, err := s.repository.PurgeDeletedUsers(context.Background(), repository.PurgeDeletedUsersInput{
+					RequestedBefore: time.Now().UTC().Add(-s.deletionGracePeriod),
+				})
+				if err != nil {
+					fmt.Println("failed purge deleted users, err:", err)
+					continue
+				}
+				if len(result.UserIDs) > 0 {
+					fmt.Println("purged deleted users:", result.UserIDs)
+				}
+			}
+		}()
+		fmt.Println("INIT ACCOUNT DELETION")
+	}
+
+	// Init Data Export
+	{
+		// exports with more records than this are prepared in the background
+		threshold, err := strconv.Atoi(os.Getenv("EXPORT_ASYNC_THRESHOLD"))
+		if err != nil || threshold < 0 {
+			threshold = 1000
+		}
+		s.exportAsyncThreshold = threshold
+
+		// background exports prepared at once, more are refused with 503 until one is done
+		workers, err := strconv.Atoi(os.Getenv("EXPORT_WORKERS"))
+		if err != nil || workers <= 0 {
+			workers = 2
+		}
+		s.exportWorkers = workers
+
+		// a background export running longer is failed, so is a job pending longer
+		timeoutMinute, err := strconv.Atoi(os.Getenv("EXPORT_TIMEOUT_MINUTE"))
+		if err != nil || timeoutMinute <= 0 {
+			timeoutMinute = 30
+		}
+		s.exportTimeout = time.Duration(timeoutMinute) * time.Minute
+		fmt.Println("INIT DATA EXPORT")
+	}
+
+	// Init Lockout
+	{
+		maxAttempts, err := strconv.Atoi(os.Getenv("LOGIN_MAX_ATTEMPTS"))
+		if err != nil || maxAttempts <= 0 {
+			maxAttempts = 10
+		}
+		ipMaxAttempts, err := strconv.Atoi(os.Getenv("LOGIN_IP_MAX_ATTEMPTS"))
+		if err != nil || ipMaxAttempts <= 0 {
+			ipMaxAttempts = 100
+		}
+		lockoutMinute, err := strconv.Atoi(os.Getenv("LOGIN_LOCKOUT_MINUTE"))
+		if err != nil || lockoutMinute <= 0 {
+			lockoutMinute = 15
+		}
+		// failures older than the window are forgotten
+		windowHour, err := strconv.Atoi(os.Getenv("LOGIN_FAILURE_WINDOW_HOUR"))
+		if err != nil || windowHour <= 0 {
+			windowHour = 24
+		}
+		window := time.Duration(windowHour) * time.Hour
+		lockoutDuration := time.Duration(lockoutMinute) * time.Minute
+
+		var store lockout.Store
+		switch os.Getenv("LOGIN_LOCKOUT_STORE") {
+		case "memory":
+			store = lockout.NewMemoryStore()
+		default:
+			store = lockout.NewPostgresStore(s.repository)
+
+			// forget the counters that can no longer block anything
+			go func() {
+				for range time.Tick(time.Hour) {
+					err := s.repository.PurgeLoginFailures(context.Background(), time.Now().UTC().Add(-window))
+					if err != nil {
+						fmt.Println("failed purge login failures, err:", err)
+					}
+				}
+			}()
+		}
+
+		s.lockout = lockout.NewLockoutMethod(lockout.NewLockoutConfig{
+			Store:  store,
+			Window: window,
+			AccountPolicy: lockout.Policy{
+				BackoffAfter:       3,
+				BackoffBase:        time.Second,
+				LockoutAfter:       maxAttempts,
+				LockoutDuration:    lockoutDuration,
+				MaxLockoutDuration: 24 * time.Hour,
+			},
+			IPPolicy: lockout.Policy{
+				BackoffAfter:       maxAttempts,
+				BackoffBase:        time.Second,
+				LockoutAfter:       ipMaxAttempts,
+				LockoutDuration:    lockoutDuration,
+				MaxLockoutDuration: 24 * time.Hour,
+			},
+		})
+		fmt.Println("INIT LOCKOUT")
+	}
+
+	// Init SMS
+	{
+		// only the stub exists for now, it writes the messages to SMS_OUTBOX_FILE or stdout
+		s.sms = sms.NewStubSender(sms.NewStubConfig{
+			Path: os.Getenv("SMS_OUTBOX_FILE"),
+		})
+
+		ttlMinute, err := strconv.Atoi(os.Getenv("OTP_TTL_MINUTE"))
+		if err != nil || ttlMinute <= 0 {
+			ttlMinute = 10
+		}
+		s.otpTTL = time.Duration(ttlMinute) * time.Minute
+
+		maxAttempts, err := strconv.Atoi(os.Getenv("OTP_MAX_ATTEMPTS"))
+		if err != nil || maxAttempts <= 0 {
+			maxAttempts = 5
+		}
+		s.otpMaxAttempts = maxAttempts
+		fmt.Println("INIT SMS")
+	}
+
+	// Init Password
+	{
+		minLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+		if err != nil || minLength <= 0 {
+			minLength = 6
+		}
+		maxLength, err := strconv.Atoi(os.Getenv("PASSWORD_MAX_LENGTH"))
+		if err != nil || maxLength <= 0 {
+			maxLength = 64
+		}
+		// every character class is required unless turned off
+		require := func(key string) bool {
+			required, err := strconv.ParseBool(os.Getenv(key))
+			return err != nil || required
+		}
+		// a zxcvbn-style score from 0 to 4, see policy.Strength
+		minStrength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_STRENGTH"))
+		if err != nil {
+			minStrength = 2
+		}
+		// previous passwords that can't be set again, besides the current one
+		historySize, err := strconv.Atoi(os.Getenv("PASSWORD_HISTORY_SIZE"))
+		if err != nil || historySize < 0 {
+			historySize = 5
+		}
+		// comma separated, the name and phone number of the user are always banned
+		var bannedWords []string
+		if words := os.Getenv("PASSWORD_BANNED_WORDS"); words != "" {
+			bannedWords = strings.Split(words, ",")
+		}
+
+		s.policy, err = policy.NewPolicyMethod(policy.NewPolicyConfig{
+			MinLength:        minLength,
+			MaxLength:        maxLength,
+			MaxBytes:         s.hashMaxBytes,
+			RequireUppercase: require("PASSWORD_REQUIRE_UPPERCASE"),
+			RequireLowercase: require("PASSWORD_REQUIRE_LOWERCASE"),
+			RequireDigit:     require("PASSWORD_REQUIRE_DIGIT"),
+			RequireSymbol:    require("PASSWORD_REQUIRE_SYMBOL"),
+			MinStrength:      minStrength,
+			BannedWords:      bannedWords,
+			HistorySize:      historySize,
+		})
+		if err != nil {
+			panic(err)
+		}
+		s.passwordHistorySize = s.policy.HistorySize()
+		fmt.Println("INIT PASSWORD")
+	}
+
+	// Init MFA
+	{
+		// the issuer is the account name authenticator apps show
+		issuer := os.Getenv("MFA_ISSUER")
+		if issuer == "" {
+			issuer = "UserService"
+		}
+		// one period before and after the current one is accepted for clock drift
+		s.totp = totp.NewTOTPMethod(totp.NewTOTPConfig{
+			Issuer: issuer,
+			Skew:   1,
+		})
+
+		ttlMinute, err := strconv.Atoi(os.Getenv("MFA_CHALLENGE_TTL_MINUTE"))
+		if err != nil || ttlMinute <= 0 {
+			ttlMinute = 5
+		}
+		s.mfaChallengeTTL = time.Duration(ttlMinute) * time.Minute
+		fmt.Println("INIT MFA")
+	}
+
+	// Init Breach
+	{
+		// a local copy of the Have I Been Pwned ranges, passwords aren't checked when it is unset
+		directory := os.Getenv("BREACHED_PASSWORDS_DIRECTORY")
+		if directory != "" {
+			checker, err := breach.NewFileChecker(breach.NewFileConfig{
+				Directory: directory,
+			})
+			if err != nil {
+				panic(err)
+			}
+			s.breach = checker
+		}
+		fmt.Println("INIT BREACH")
+	}
+
 	// Init Handler
 	{
 		s.handler = handler.NewServer(handler.NewServerOptions{
-			Repository: s.repository,
-			Hash:       s.hash,
-			Token:      s.token,
+			Repository:           s.repository,
+			Hash:                 s.hash,
+			HashExecutor:         s.hashExecutor,
+			Token:                s.token,
+			Revocation:           s.revocation,
+			Authz:                s.authz,
+			Lockout:              s.lockout,
+			SMS:                  s.sms,
+			TOTP:                 s.totp,
+			Breach:               s.breach,
+			Policy:               s.policy,
+			DeletionGracePeriod:  s.deletionGracePeriod,
+			ExportAsyncThreshold: s.exportAsyncThreshold,
+			ExportWorkers:        s.exportWorkers,
+			ExportTimeout:        s.exportTimeout,
+			OTPTTL:               s.otpTTL,
+			OTPMaxAttempts:       s.otpMaxAttempts,
+			PasswordHistorySize:  s.passwordHistorySize,
+			MFAChallengeTTL:      s.mfaChallengeTTL,
 		})
+
+		// jobs of exports interrupted by a restart, here or on another instance, would stay pending forever
+		err := s.handler.FailStaleDataExports(context.Background())
+		if err != nil {
+			fmt.Println("failed fail stale data exports, err:", err)
+		}
+		go func() {
+			for range time.Tick(time.Hour) {
+				err := s.handler.FailStaleDataExports(context.Background())
+				if err != nil {
+					fmt.Println("failed fail stale data exports, err:", err)
+				}
+			}
+		}()
 		fmt.Println("INIT HANDLER")
 	}
 
@@ -33,3 +33,276 @@ CREATE TABLE users_login_history (
     user_id INTEGER REFERENCES users(id),
     login_count INTEGER DEFAULT 1
 );
+
+CREATE TABLE users_refresh_token (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    family_id VARCHAR(64) NOT NULL,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    revoked_at TIMESTAMP
+);
+
+CREATE INDEX users_refresh_token_family_id_idx ON users_refresh_token(family_id);
+
+CREATE TABLE users_revoked_token (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    token_id VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE users_token_revocation (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER UNIQUE NOT NULL REFERENCES users(id),
+    revoked_before TIMESTAMP NOT NULL
+);
+
+/** Internal services allowed to call /oauth/introspect, client_secret is the hex sha-256 of a random secret of at least 32 bytes. */
+CREATE TABLE oauth_client (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    client_id VARCHAR(64) UNIQUE NOT NULL,
+    client_secret VARCHAR(255) NOT NULL,
+    name VARCHAR(60) NOT NULL
+);
+
+CREATE TABLE roles (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    name VARCHAR(30) UNIQUE NOT NULL
+);
+
+INSERT INTO roles(name, created_at) VALUES ('user', CURRENT_TIMESTAMP), ('support', CURRENT_TIMESTAMP), ('admin', CURRENT_TIMESTAMP);
+
+CREATE TABLE users_role (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    role_id INTEGER NOT NULL REFERENCES roles(id),
+    UNIQUE (user_id, role_id)
+);
+
+CREATE TABLE permissions (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    name VARCHAR(60) UNIQUE NOT NULL
+);
+
+INSERT INTO permissions(name, created_at) VALUES
+    ('profile:read', CURRENT_TIMESTAMP),
+    ('profile:write', CURRENT_TIMESTAMP),
+    ('users:read', CURRENT_TIMESTAMP),
+    ('users:write', CURRENT_TIMESTAMP),
+    ('users:impersonate', CURRENT_TIMESTAMP);
+
+CREATE TABLE roles_permission (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    role_id INTEGER NOT NULL REFERENCES roles(id),
+    permission_id INTEGER NOT NULL REFERENCES permissions(id),
+    UNIQUE (role_id, permission_id)
+);
+
+INSERT INTO roles_permission(role_id, permission_id, created_at)
+SELECT roles.id, permissions.id, CURRENT_TIMESTAMP FROM roles JOIN permissions ON
+    (roles.name = 'user' AND permissions.name IN ('profile:read', 'profile:write')) OR
+    (roles.name = 'support' AND permissions.name IN ('users:read')) OR
+    (roles.name = 'admin' AND permissions.name IN ('users:read', 'users:write', 'users:impersonate'));
+
+CREATE TABLE users_permission (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    permission_id INTEGER NOT NULL REFERENCES permissions(id),
+    UNIQUE (user_id, permission_id)
+);
+
+CREATE TABLE users_suspension (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    reason VARCHAR(255) NOT NULL,
+    suspended_by INTEGER NOT NULL REFERENCES users(id),
+    expires_at TIMESTAMP,
+    lifted_at TIMESTAMP,
+    lifted_by INTEGER REFERENCES users(id)
+);
+
+CREATE INDEX users_suspension_user_id_idx ON users_suspension(user_id);
+
+-- deletion_requested_at starts the grace period, the purge job anonymizes the user and sets deleted_at
+ALTER TABLE users
+    ADD COLUMN deletion_requested_at TIMESTAMP,
+    ADD COLUMN deletion_requested_by INTEGER REFERENCES users(id),
+    ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX users_deletion_requested_at_idx ON users(deletion_requested_at) WHERE deleted_at IS NULL;
+
+/** Personal data export jobs, the archive is kept until expires_at. */
+CREATE TABLE users_data_export (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    job_id VARCHAR(64) UNIQUE NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    format VARCHAR(10) NOT NULL,
+    status VARCHAR(10) NOT NULL,
+    archive BYTEA,
+    error_message VARCHAR(255),
+    completed_at TIMESTAMP,
+    expires_at TIMESTAMP
+);
+
+/** One row per login attempt, users_login_history keeps the aggregate login count of successful logins. */
+CREATE TABLE users_login_attempt (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER REFERENCES users(id),
+    phone_number VARCHAR(20) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    user_agent VARCHAR(255) NOT NULL,
+    auth_method VARCHAR(20) NOT NULL,
+    success BOOLEAN NOT NULL,
+    failure_reason VARCHAR(30)
+);
+
+CREATE INDEX users_login_attempt_user_id_idx ON users_login_attempt(user_id, id);
+CREATE INDEX users_login_attempt_phone_number_idx ON users_login_attempt(phone_number, created_at);
+
+/** Failed login counters of the postgres lockout store, key is an account or a client ip. */
+CREATE TABLE login_failure (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    key VARCHAR(80) UNIQUE NOT NULL,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+/** Token buckets of the postgres rate limit store, full_at is when the bucket is full again. */
+CREATE TABLE rate_limit_bucket (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    key VARCHAR(255) UNIQUE NOT NULL,
+    full_at TIMESTAMP NOT NULL
+);
+CREATE INDEX rate_limit_bucket_full_at_idx ON rate_limit_bucket (full_at);
+
+/** One-time codes sent by sms, only the hash of the code is stored and a code is used at most once. */
+CREATE TABLE users_otp (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    purpose VARCHAR(30) NOT NULL,
+    code_hash VARCHAR(255) NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX users_otp_user_id_idx ON users_otp(user_id, purpose);
+
+-- phone_number is the number the code was sent to, a phone verification code applies it to the user
+ALTER TABLE users_otp ADD COLUMN phone_number VARCHAR(13);
+UPDATE users_otp SET phone_number = users.phone_number FROM users WHERE users.id = users_otp.user_id;
+ALTER TABLE users_otp ALTER COLUMN phone_number SET NOT NULL;
+
+-- phone_verified_at is set once the user proves ownership of phone_number with a code sent by sms,
+-- accounts created before verification existed are trusted
+ALTER TABLE users ADD COLUMN phone_verified_at TIMESTAMP;
+UPDATE users SET phone_verified_at = CURRENT_TIMESTAMP;
+
+-- tokens of users whose phone number is not verified yet are limited to profile:read and phone:verify
+INSERT INTO permissions(name, created_at) VALUES ('phone:verify', CURRENT_TIMESTAMP);
+
+INSERT INTO roles_permission(role_id, permission_id, created_at)
+SELECT roles.id, permissions.id, CURRENT_TIMESTAMP FROM roles JOIN permissions ON
+    roles.name = 'user' AND permissions.name = 'phone:verify';
+
+/** Previous password hashes of the users, the most recent ones can't be set again. */
+CREATE TABLE users_password_history (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    password VARCHAR(64) NOT NULL
+);
+
+CREATE INDEX users_password_history_user_id_idx ON users_password_history(user_id);
+
+/** TOTP second factor of the users, enabled_at is set once a first code confirms the enrollment. */
+CREATE TABLE users_mfa (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER UNIQUE NOT NULL REFERENCES users(id),
+    secret VARCHAR(64) NOT NULL,
+    enabled_at TIMESTAMP,
+    -- last_used_step is the time step of the last accepted code, a code can't be replayed
+    last_used_step BIGINT
+);
+
+/** One-time recovery codes of the second factor, only the sha-256 of the code is stored. */
+CREATE TABLE users_mfa_recovery_code (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP
+);
+
+CREATE INDEX users_mfa_recovery_code_user_id_idx ON users_mfa_recovery_code(user_id, code_hash);
+
+/** Challenges given by a password login of a user with a second factor, they are exchanged for tokens at /login/mfa. */
+CREATE TABLE users_mfa_challenge (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP
+);
+
+-- argon2id hashes in PHC format are longer than the 60 characters of bcrypt
+ALTER TABLE users ALTER COLUMN password TYPE VARCHAR(255);
+ALTER TABLE users_password_history ALTER COLUMN password TYPE VARCHAR(255);
+
+-- tokens of users whose phone number is not verified yet can delete the account too
+INSERT INTO permissions(name, created_at) VALUES ('account:delete', CURRENT_TIMESTAMP);
+
+INSERT INTO roles_permission(role_id, permission_id, created_at)
+SELECT roles.id, permissions.id, CURRENT_TIMESTAMP FROM roles JOIN permissions ON
+    roles.name = 'user' AND permissions.name = 'account:delete';
+
+-- pending export jobs older than the export timeout were interrupted by a restart and are failed
+CREATE INDEX users_data_export_pending_idx ON users_data_export(created_at) WHERE status = 'pending';
+
+-- client secrets were stored as password hashes, they are compared by sha-256 now and
+-- clients registered before must be given a new secret
+UPDATE oauth_client SET client_secret = '', updated_at = CURRENT_TIMESTAMP;
+
+-- admins can read the metrics of the password hashing workers at /metrics/hash
+INSERT INTO permissions(name, created_at) VALUES ('metrics:read', CURRENT_TIMESTAMP);
+
+INSERT INTO roles_permission(role_id, permission_id, created_at)
+SELECT roles.id, permissions.id, CURRENT_TIMESTAMP FROM roles JOIN permissions ON
+    roles.name = 'admin' AND permissions.name = 'metrics:read';
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// DeleteMyProfile marks the account of the caller for deletion, it is purged
+// after the grace period unless the user logs in again before that.
+func (s *Server) DeleteMyProfile(ctx echo.Context) error {
+	// get user id from middleware
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return s.requestUserDeletion(ctx, userID, userID)
+}
+
+// DeleteUser marks any account for deletion, for admin.
+func (s *Server) DeleteUser(ctx echo.Context, id int) error {
+	body, code, err := s.checkPermission(ctx, authz.PermissionUsersWrite)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return s.requestUserDeletion(ctx, id, body.UserID)
+}
+
+// requestUserDeletion starts the grace period and logs the user out of every session
+func (s *Server) requestUserDeletion(ctx echo.Context, userID int, requestedBy int) error {
+	result, err := s.Repository.RequestUserDeletion(ctx.Request().Context(), repository.RequestUserDeletionInput{
+		UserID:      userID,
+		RequestedBy: requestedBy,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.JSON(http.StatusNotFound, generated.ErrorResponse{
+				Message: "user not found",
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Revocation.RevokeAllTokens(ctx.Request().Context(), userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Repository.RevokeUserRefreshTokens(ctx.Request().Context(), userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusAccepted, generated.AccountDeletionResponse{
+		PurgeAt: result.RequestedAt.Add(s.DeletionGracePeriod),
+	})
+}
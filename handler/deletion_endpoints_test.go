@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/revocation"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_DeleteMyProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockRevocation := revocation.NewMockRevocationMethod(ctrl)
+	requestedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().RequestUserDeletion(gomock.Any(), repository.RequestUserDeletionInput{
+					UserID:      1,
+					RequestedBy: 1,
+				}).Return(repository.RequestUserDeletionOutput{RequestedAt: requestedAt}, nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeUserRefreshTokens(gomock.Any(), 1).Return(nil)
+			},
+			want: want{
+				code: 202,
+				body: `{"purgeAt":"2024-02-01T03:04:05Z"}`,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository request user deletion",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().RequestUserDeletion(gomock.Any(), gomock.Any()).Return(repository.RequestUserDeletionOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on revocation revoke all tokens",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().RequestUserDeletion(gomock.Any(), gomock.Any()).Return(repository.RequestUserDeletionOutput{RequestedAt: requestedAt}, nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository revoke refresh tokens",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().RequestUserDeletion(gomock.Any(), gomock.Any()).Return(repository.RequestUserDeletionOutput{RequestedAt: requestedAt}, nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeUserRefreshTokens(gomock.Any(), 1).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository:          mockRepo,
+				Revocation:          mockRevocation,
+				DeletionGracePeriod: 30 * 24 * time.Hour,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodDelete, "/my-profile", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.DeleteMyProfile(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("DeleteMyProfile status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("DeleteMyProfile Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_DeleteUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockAuthz := authz.NewMockAuthzMethod(ctrl)
+	mockRevocation := revocation.NewMockRevocationMethod(ctrl)
+	tokenBody := token.TokenBody{
+		UserID: 1,
+		Roles:  []string{"admin"},
+	}
+	requestedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	allowed := func() {
+		mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersWrite).Return(true, nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		id       int
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow",
+			id:   2,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().RequestUserDeletion(gomock.Any(), repository.RequestUserDeletionInput{
+					UserID:      2,
+					RequestedBy: 1,
+				}).Return(repository.RequestUserDeletionOutput{RequestedAt: requestedAt}, nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 2).Return(nil)
+				mockRepo.EXPECT().RevokeUserRefreshTokens(gomock.Any(), 2).Return(nil)
+			},
+			want: want{
+				code: 202,
+				body: `{"purgeAt":"2024-02-01T03:04:05Z"}`,
+			},
+		},
+		{
+			name: "failed flow without permission",
+			id:   2,
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersWrite).Return(false, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"permission users:write is required"}`,
+			},
+		},
+		{
+			name: "failed flow user not found",
+			id:   2,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().RequestUserDeletion(gomock.Any(), gomock.Any()).Return(repository.RequestUserDeletionOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 404,
+				body: `{"message":"user not found"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository:          mockRepo,
+				Revocation:          mockRevocation,
+				Authz:               mockAuthz,
+				DeletionGracePeriod: 30 * 24 * time.Hour,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/users/%d", tt.id), nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.Set("user_id", tokenBody.UserID)
+			ctx.Set("token", tokenBody)
+
+			handler.DeleteUser(ctx, tt.id)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("DeleteUser status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("DeleteUser Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/policy"
 	"github.com/SawitProRecruitment/UserService/pkg/token"
 	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/labstack/echo/v4"
@@ -23,13 +28,26 @@ func (s *Server) RegisterUser(ctx echo.Context) error {
 		})
 	}
 
-	hashPassword, err := s.Hash.HashValue(req.Password)
+	violations := s.Policy.Validate(req.Password, policy.User{
+		FullName:    req.FullName,
+		PhoneNumber: req.PhoneNumber,
+	})
+	if len(violations) > 0 {
+		return passwordPolicyError(ctx, violations)
+	}
+
+	code, err := s.checkBreachedPassword(ctx, req.Password)
 	if err != nil {
-		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+		return ctx.JSON(code, generated.ErrorResponse{
 			Message: err.Error(),
 		})
 	}
 
+	hashPassword, err := s.Hash.HashValue(ctx.Request().Context(), req.Password)
+	if err != nil {
+		return serverError(ctx, err)
+	}
+
 	result, err := s.Repository.RegisterUser(ctx.Request().Context(), repository.RegisterUserInput{
 		FullName:    req.FullName,
 		Password:    string(hashPassword),
@@ -49,6 +67,12 @@ func (s *Server) RegisterUser(ctx echo.Context) error {
 
 	resp.Id = int(result.UserID)
 
+	// the account is created unverified, a failed sms can be sent again with ResendPhoneVerification
+	err = s.sendPhoneVerification(ctx, int(result.UserID), req.PhoneNumber)
+	if err != nil {
+		fmt.Println("failed send phone verification", result.UserID, "err:", err)
+	}
+
 	return ctx.JSON(http.StatusOK, resp)
 }
 
@@ -74,46 +98,48 @@ func validateRegisterRequest(req generated.RegisterRequest) error {
 
 	// validate full name
 	err = validateFullName(req.FullName)
-	if err != nil {
-		return err
-	}
-
-	// validate password
-	err = validatePassword(req.Password)
 
 	return err
 }
 
-func validatePassword(password string) error {
-	// validate password
-	if len(password) < 6 || len(password) > 64 {
-		return fmt.Errorf("password length must be between 6 and 64 characters")
-	}
-
-	// validate password contains at least 1 uppercase, 1 lowercase, 1 number, and 1 symbol
-	var (
-		hasUpper   bool
-		hasLower   bool
-		hasNumber  bool
-		hasSpecial bool
-	)
-	for _, c := range password {
-		switch {
-		case c >= 'A' && c <= 'Z':
-			hasUpper = true
-		case c >= 'a' && c <= 'z':
-			hasLower = true
-		case c >= '0' && c <= '9':
-			hasNumber = true
-		case c == '!' || c == '@' || c == '#' || c == '$' || c == '%' || c == '^' || c == '&':
-			hasSpecial = true
-		}
+// errPasswordPolicy is the answer for a password breaking the password policy, the rules it
+// breaks are listed in the violations of the response
+var errPasswordPolicy = errors.New("password does not meet the password policy")
+
+// passwordPolicyError responds with every rule of the password policy the password breaks
+func passwordPolicyError(ctx echo.Context, violations []policy.Violation) error {
+	list := make([]generated.PasswordViolation, 0, len(violations))
+	for _, violation := range violations {
+		list = append(list, generated.PasswordViolation{
+			Code:    generated.PasswordViolationCode(violation.Code),
+			Message: violation.Message,
+		})
 	}
 
-	if !hasUpper || !hasLower || !hasNumber || !hasSpecial {
-		return fmt.Errorf("password must contain at least 1 uppercase, 1 lowercase, 1 number, and 1 symbol")
+	return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+		Message:    errPasswordPolicy.Error(),
+		Violations: &list,
+	})
+}
+
+// errBreachedPassword is the answer for a password found by the breached password check
+var errBreachedPassword = errors.New("password has appeared in a data breach and is unsafe to use, choose a different password")
+
+// checkBreachedPassword rejects a password known from a data breach, it returns the status
+// code to respond with when the password can't be used. The check is off without Breach.
+func (s *Server) checkBreachedPassword(ctx echo.Context, password string) (int, error) {
+	if s.Breach == nil {
+		return http.StatusOK, nil
 	}
-	return nil
+
+	breached, err := s.Breach.IsBreached(ctx.Request().Context(), password)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if breached {
+		return http.StatusBadRequest, errBreachedPassword
+	}
+	return http.StatusOK, nil
 }
 
 func validatePhoneNumber(phoneNumber string) error {
@@ -138,7 +164,6 @@ func validateFullName(fullName string) error {
 }
 
 func (s *Server) LoginUser(ctx echo.Context) error {
-	var resp generated.LoginResponse
 	var req = generated.LoginRequest{}
 	ctx.Bind(&req)
 
@@ -154,11 +179,30 @@ func (s *Server) LoginUser(ctx echo.Context) error {
 		})
 	}
 
+	// refused before the password is hashed so a locked account costs nothing to attack
+	status, err := s.Lockout.Check(ctx.Request().Context(), req.PhoneNumber, ctx.RealIP())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+	if status.Blocked() {
+		return s.loginBlocked(ctx, 0, req.PhoneNumber, repository.AuthMethodPassword, status)
+	}
+
 	result, err := s.Repository.LoginUser(ctx.Request().Context(), repository.LoginUserInput{
 		PhoneNumber: req.PhoneNumber,
 	})
 	if err != nil {
 		if strings.Contains(err.Error(), "no rows in result set") {
+			s.recordPhoneNumberAttempt(ctx, req.PhoneNumber, repository.AuthMethodPassword, repository.LoginFailureUnknownUser)
+			// unknown phone numbers are counted too so they can't be told apart from locked accounts
+			err = s.Lockout.RecordFailure(ctx.Request().Context(), req.PhoneNumber, ctx.RealIP())
+			if err != nil {
+				return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+					Message: err.Error(),
+				})
+			}
 			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
 				Message: "invalid phone number or password",
 			})
@@ -168,31 +212,140 @@ func (s *Server) LoginUser(ctx echo.Context) error {
 		})
 	}
 
-	val := s.Hash.CompareValue(result.Password, req.Password)
+	val, err := s.Hash.CompareValue(ctx.Request().Context(), result.Password, req.Password)
+	if err != nil {
+		return serverError(ctx, err)
+	}
 	if !val {
+		s.recordLoginAttempt(ctx, int(result.UserID), req.PhoneNumber, repository.AuthMethodPassword, repository.LoginFailureInvalidPassword)
+		err = s.Lockout.RecordFailure(ctx.Request().Context(), req.PhoneNumber, ctx.RealIP())
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
 		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
 			Message: "invalid password",
 		})
 	}
 
+	// hashes made with a previous algorithm or cost are upgraded while the password is known
+	if s.Hash.NeedsRehash(result.Password) {
+		s.rehashPassword(ctx, result, req.Password)
+	}
+
+	return s.completeLogin(ctx, result, repository.AuthMethodPassword)
+}
+
+// completeLogin finishes a login whose credential was checked, it refuses a suspended account
+// and then either asks for the second factor or starts the session
+func (s *Server) completeLogin(ctx echo.Context, result repository.LoginUserOutput, authMethod string) error {
+	var resp generated.LoginResponse
+
 	resp.Id = int(result.UserID)
 
-	// generate token
-	resp.Jwt, err = s.Token.GenerateToken(token.TokenBody{
+	// the credential is checked first so the suspension is only revealed to the account owner
+	suspension, err := s.Repository.GetUserSuspension(ctx.Request().Context(), repository.GetUserSuspensionInput{
 		UserID: int(result.UserID),
 	})
-
 	if err != nil {
 		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
 			Message: err.Error(),
 		})
 	}
 
-	s.Repository.IncrementLoginCount(ctx.Request().Context(), int(result.UserID))
+	if suspension.IsSuspended {
+		s.recordLoginAttempt(ctx, int(result.UserID), result.PhoneNumber, authMethod, repository.LoginFailureSuspended)
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: "account is suspended",
+		})
+	}
+
+	// the tokens wait for the second factor, the challenge is exchanged for them at /login/mfa
+	if result.MFAEnabled {
+		mfaToken, err := s.createMFAChallenge(ctx, int(result.UserID))
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+		mfaRequired := true
+		resp.MfaRequired = &mfaRequired
+		resp.MfaToken = &mfaToken
+		return ctx.JSON(http.StatusOK, resp)
+	}
+
+	code, err := s.startSession(ctx, result, authMethod, &resp)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
 
 	return ctx.JSON(http.StatusOK, resp)
 }
 
+// startSession issues the tokens of a login that passed every check into resp, it returns
+// the status code to respond with when it fails
+func (s *Server) startSession(ctx echo.Context, user repository.LoginUserOutput, authMethod string, resp *generated.LoginResponse) (int, error) {
+	// the failures are only forgotten once every factor passed, a correct password alone
+	// would otherwise reset the count of wrong second factor codes
+	err := s.Lockout.RecordSuccess(ctx.Request().Context(), user.PhoneNumber)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// logging in during the grace period restores the account
+	if user.DeletionRequestedAt != nil {
+		err := s.Repository.CancelUserDeletion(ctx.Request().Context(), int(user.UserID))
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		deletionCancelled := true
+		resp.DeletionCancelled = &deletionCancelled
+	}
+
+	roles, err := s.Repository.GetUserRoles(ctx.Request().Context(), repository.GetUserRolesInput{
+		UserID: int(user.UserID),
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// generate token
+	jwt, err := s.Token.GenerateToken(token.TokenBody{
+		UserID: int(user.UserID),
+		Roles:  roles.Roles,
+		Scopes: tokenScopes(user.PhoneVerifiedAt),
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	resp.Jwt = &jwt
+
+	// start a new refresh token family for this login
+	refreshToken, err := s.Token.GenerateRefreshToken("")
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	err = s.Repository.CreateRefreshToken(ctx.Request().Context(), repository.CreateRefreshTokenInput{
+		UserID:    int(user.UserID),
+		FamilyID:  refreshToken.FamilyID,
+		TokenHash: refreshToken.Hash,
+		ExpiresAt: refreshToken.ExpiresAt,
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	resp.RefreshToken = &refreshToken.Token
+
+	s.recordLoginAttempt(ctx, int(user.UserID), user.PhoneNumber, authMethod, "")
+	s.Repository.IncrementLoginCount(ctx.Request().Context(), int(user.UserID))
+
+	return http.StatusOK, nil
+}
+
 func (s *Server) GetMyProfile(ctx echo.Context) error {
 	// get user id from middleware
 	userID, err := getUserID(ctx)
@@ -213,9 +366,10 @@ func (s *Server) GetMyProfile(ctx echo.Context) error {
 	}
 
 	resp := generated.MyProfileResponse{
-		Id:          result.UserID,
-		Name:        result.FullName,
-		PhoneNumber: result.PhoneNumber,
+		Id:            result.UserID,
+		Name:          result.FullName,
+		PhoneNumber:   result.PhoneNumber,
+		PhoneVerified: result.PhoneVerifiedAt != nil,
 	}
 
 	return ctx.JSON(http.StatusOK, resp)
@@ -240,38 +394,50 @@ func (s *Server) UpdateMyProfile(ctx echo.Context) error {
 		})
 	}
 
-	if repoRequest.Password != "" {
-		hashPassword, err := s.Hash.HashValue(repoRequest.Password)
-		if err != nil {
+	// no code is sent to a phone number that belongs to another account
+	if repoRequest.PhoneNumber != "" {
+		owner, err := s.Repository.LoginUser(ctx.Request().Context(), repository.LoginUserInput{
+			PhoneNumber: repoRequest.PhoneNumber,
+		})
+		if err != nil && !strings.Contains(err.Error(), "no rows in result set") {
 			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
 				Message: err.Error(),
 			})
 		}
-		repoRequest.Password = string(hashPassword)
+		if err == nil && int(owner.UserID) != userID {
+			return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+				Message: "Phone number already registered",
+			})
+		}
 	}
 
+	// a new phone number only takes effect once it is verified with VerifyPhone
 	output, err := s.Repository.UpdateUser(ctx.Request().Context(), repository.UpdateUserInput{
-		UserID:      userID,
-		FullName:    repoRequest.FullName,
-		Password:    repoRequest.Password,
-		PhoneNumber: repoRequest.PhoneNumber,
+		UserID:   userID,
+		FullName: repoRequest.FullName,
 	})
 
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
-			return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
-				Message: "Phone number already registered",
-			})
-		}
 		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
 			Message: err.Error(),
 		})
 	}
 
 	response := generated.MyProfileResponse{
-		Id:          output.UserID,
-		Name:        output.FullName,
-		PhoneNumber: output.PhoneNumber,
+		Id:            output.UserID,
+		Name:          output.FullName,
+		PhoneNumber:   output.PhoneNumber,
+		PhoneVerified: output.PhoneVerifiedAt != nil,
+	}
+
+	if repoRequest.PhoneNumber != "" && repoRequest.PhoneNumber != output.PhoneNumber {
+		err = s.sendPhoneVerification(ctx, userID, repoRequest.PhoneNumber)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+		response.PendingPhoneNumber = &repoRequest.PhoneNumber
 	}
 
 	return ctx.JSON(http.StatusOK, response)
@@ -285,6 +451,33 @@ func getUserID(ctx echo.Context) (int, error) {
 	return userID.(int), nil
 }
 
+func getTokenBody(ctx echo.Context) (token.TokenBody, error) {
+	body, ok := ctx.Get("token").(token.TokenBody)
+	if !ok || body.UserID <= 0 {
+		return token.TokenBody{}, fmt.Errorf("invalid token")
+	}
+	return body, nil
+}
+
+// errorStatus is the status code of an unexpected error, a full hashing queue is only
+// temporary so it is 503 with Retry-After telling when to try again
+func errorStatus(ctx echo.Context, err error) int {
+	var busy *hash.BusyError
+	if errors.As(err, &busy) {
+		retryAfter := int(math.Ceil(busy.RetryAfter.Seconds()))
+		ctx.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// serverError answers an unexpected error with errorStatus
+func serverError(ctx echo.Context, err error) error {
+	return ctx.JSON(errorStatus(ctx, err), generated.ErrorResponse{
+		Message: err.Error(),
+	})
+}
+
 func validateUpdateRequest(req generated.UpdateMyProfileRequest) (repository.UpdateUserInput, error) {
 	var resp repository.UpdateUserInput
 	// validate request
@@ -296,14 +489,6 @@ func validateUpdateRequest(req generated.UpdateMyProfileRequest) (repository.Upd
 		resp.FullName = *req.FullName
 	}
 
-	if req.Password != nil {
-		err := validatePassword(*req.Password)
-		if err != nil {
-			return repository.UpdateUserInput{}, err
-		}
-		resp.Password = *req.Password
-	}
-
 	if req.PhoneNumber != nil {
 		err := validatePhoneNumber(*req.PhoneNumber)
 		if err != nil {
@@ -5,10 +5,16 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/SawitProRecruitment/UserService/pkg/breach"
 	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/lockout"
+	"github.com/SawitProRecruitment/UserService/pkg/policy"
+	"github.com/SawitProRecruitment/UserService/pkg/sms"
 	"github.com/SawitProRecruitment/UserService/pkg/token"
 	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/labstack/echo/v4"
@@ -20,6 +26,13 @@ func TestServer_RegisterUser(t *testing.T) {
 	mockRepo := repository.NewMockRepositoryInterface(ctrl)
 	mockHash := hash.NewMockHashMethod(ctrl)
 	mockToken := token.NewMockTokenMethod(ctrl)
+	mockSMS := sms.NewMockSender(ctrl)
+	mockBreach := breach.NewMockChecker(ctrl)
+	mockPolicy := policy.NewMockPolicyMethod(ctrl)
+	testingUser := policy.User{
+		FullName:    "testing",
+		PhoneNumber: "+628123456789",
+	}
 	type want struct {
 		body string
 		code int
@@ -34,7 +47,9 @@ func TestServer_RegisterUser(t *testing.T) {
 			name: "success flow",
 			body: `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
-				mockHash.EXPECT().HashValue("@Password1").Return([]byte("123456"), nil)
+				mockPolicy.EXPECT().Validate("@Password1", testingUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password1").Return(false, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password1").Return([]byte("123456"), nil)
 				mockRepo.EXPECT().RegisterUser(gomock.Any(), repository.RegisterUserInput{
 					FullName:    "testing",
 					Password:    "123456",
@@ -42,6 +57,32 @@ func TestServer_RegisterUser(t *testing.T) {
 				}).Return(repository.RegisterUserOutput{
 					UserID: 1,
 				}, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Cond(func(x interface{}) bool {
+					input := x.(repository.CreateOTPInput)
+					return input.UserID == 1 && input.Purpose == repository.OTPPurposePhoneVerification &&
+						input.PhoneNumber == "+628123456789" && input.CodeHash == "hash"
+				})).Return(nil)
+				mockSMS.EXPECT().Send(gomock.Any(), "+628123456789", gomock.Any()).Return(nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":1}`,
+			},
+		},
+		{
+			name: "success flow while failed send phone verification",
+			body: `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password1", testingUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password1").Return(false, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password1").Return([]byte("123456"), nil)
+				mockRepo.EXPECT().RegisterUser(gomock.Any(), gomock.Any()).Return(repository.RegisterUserOutput{
+					UserID: 1,
+				}, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Any()).Return(nil)
+				mockSMS.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
 			},
 			want: want{
 				code: 200,
@@ -52,7 +93,9 @@ func TestServer_RegisterUser(t *testing.T) {
 			name: "failed flow",
 			body: `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
-				mockHash.EXPECT().HashValue("@Password1").Return([]byte("123456"), nil)
+				mockPolicy.EXPECT().Validate("@Password1", testingUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password1").Return(false, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password1").Return([]byte("123456"), nil)
 				mockRepo.EXPECT().RegisterUser(gomock.Any(), repository.RegisterUserInput{
 					FullName:    "testing",
 					Password:    "123456",
@@ -64,11 +107,28 @@ func TestServer_RegisterUser(t *testing.T) {
 				body: `{"message":"some error"}`,
 			},
 		},
+		{
+			name: "failed flow hashing queue is full",
+			body: `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password1", testingUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password1").Return(false, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password1").Return(nil, &hash.BusyError{
+					RetryAfter: time.Second,
+				})
+			},
+			want: want{
+				code: 503,
+				body: `{"message":"server is busy, try again later"}`,
+			},
+		},
 		{
 			name: "failed flow duplicate phone number",
 			body: `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
-				mockHash.EXPECT().HashValue("@Password1").Return([]byte("123456"), nil)
+				mockPolicy.EXPECT().Validate("@Password1", testingUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password1").Return(false, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password1").Return([]byte("123456"), nil)
 				mockRepo.EXPECT().RegisterUser(gomock.Any(), repository.RegisterUserInput{
 					FullName:    "testing",
 					Password:    "123456",
@@ -84,7 +144,33 @@ func TestServer_RegisterUser(t *testing.T) {
 			name: "failed flow while hashing password",
 			body: `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
-				mockHash.EXPECT().HashValue("@Password1").Return([]byte(""), fmt.Errorf("some error"))
+				mockPolicy.EXPECT().Validate("@Password1", testingUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password1").Return(false, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password1").Return([]byte(""), fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow breached password",
+			body: `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password1", testingUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password1").Return(true, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"password has appeared in a data breach and is unsafe to use, choose a different password"}`,
+			},
+		},
+		{
+			name: "failed flow while checking breached password",
+			body: `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password1", testingUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password1").Return(false, fmt.Errorf("some error"))
 			},
 			want: want{
 				code: 500,
@@ -128,12 +214,16 @@ func TestServer_RegisterUser(t *testing.T) {
 			},
 		},
 		{
-			name:     "failed invalid request length password",
-			body:     `{"fullName":"testing","password":"@","phoneNumber":"+628123456789"}`,
-			mockFunc: func() {},
+			name: "failed invalid request length password",
+			body: `{"fullName":"testing","password":"@","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@", testingUser).Return([]policy.Violation{
+					{Code: policy.CodeTooShort, Message: "password must be at least 6 characters"},
+				})
+			},
 			want: want{
 				code: 400,
-				body: `{"message":"password length must be between 6 and 64 characters"}`,
+				body: `{"message":"password does not meet the password policy","violations":[{"code":"too_short","message":"password must be at least 6 characters"}]}`,
 			},
 		},
 		{
@@ -155,12 +245,18 @@ func TestServer_RegisterUser(t *testing.T) {
 			},
 		},
 		{
-			name:     "failed invalid request password must contain at least 1 uppercase, 1 lowercase, 1 number, and 1 symbol",
-			body:     `{"fullName":"testing","password":"@password","phoneNumber":"+628123456789"}`,
-			mockFunc: func() {},
+			name: "failed invalid request password breaks several rules of the policy",
+			body: `{"fullName":"testing","password":"@testing","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@testing", testingUser).Return([]policy.Violation{
+					{Code: policy.CodeMissingUppercase, Message: "password must contain an uppercase letter"},
+					{Code: policy.CodeMissingDigit, Message: "password must contain a digit"},
+					{Code: policy.CodeBannedWord, Message: `password must not contain "testing"`},
+				})
+			},
 			want: want{
 				code: 400,
-				body: `{"message":"password must contain at least 1 uppercase, 1 lowercase, 1 number, and 1 symbol"}`,
+				body: `{"message":"password does not meet the password policy","violations":[{"code":"missing_uppercase","message":"password must contain an uppercase letter"},{"code":"missing_digit","message":"password must contain a digit"},{"code":"banned_word","message":"password must not contain \"testing\""}]}`,
 			},
 		},
 	}
@@ -170,6 +266,9 @@ func TestServer_RegisterUser(t *testing.T) {
 				Repository: mockRepo,
 				Hash:       mockHash,
 				Token:      mockToken,
+				SMS:        mockSMS,
+				Breach:     mockBreach,
+				Policy:     mockPolicy,
 			})
 			tt.mockFunc()
 
@@ -192,14 +291,20 @@ func TestServer_RegisterUser(t *testing.T) {
 	}
 }
 
+var mfaTokenPattern = regexp.MustCompile(`"mfaToken":"[^"]+"`)
+
 func TestServer_LoginUser(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	mockRepo := repository.NewMockRepositoryInterface(ctrl)
 	mockHash := hash.NewMockHashMethod(ctrl)
 	mockToken := token.NewMockTokenMethod(ctrl)
+	mockLockout := lockout.NewMockLockoutMethod(ctrl)
+	deletionRequestedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	phoneVerifiedAt := time.Date(2024, 1, 1, 3, 4, 5, 0, time.UTC)
 	type want struct {
-		body string
-		code int
+		body       string
+		code       int
+		retryAfter string
 	}
 	tests := []struct {
 		name     string
@@ -211,19 +316,212 @@ func TestServer_LoginUser(t *testing.T) {
 			name: "success flow",
 			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
 				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
 					PhoneNumber: "+628123456789",
 				}).Return(repository.LoginUserOutput{
-					UserID:   1,
-					Password: "123456",
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 1,
+				}).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), repository.GetUserRolesInput{
+					UserID: 1,
+				}).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockToken.EXPECT().GenerateToken(token.TokenBody{
+					UserID: 1,
+					Roles:  []string{"user"},
+				}).Return("Bearer token", nil)
+				mockToken.EXPECT().GenerateRefreshToken("").Return(token.RefreshToken{
+					Token:    "refresh",
+					Hash:     "hash",
+					FamilyID: "family",
+				}, nil)
+				mockRepo.EXPECT().CreateRefreshToken(gomock.Any(), repository.CreateRefreshTokenInput{
+					UserID:    1,
+					FamilyID:  "family",
+					TokenHash: "hash",
+				}).Return(nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+					IPAddress:   "192.0.2.1",
+					AuthMethod:  repository.AuthMethodPassword,
+					Success:     true,
+				}).Return(nil)
+				mockRepo.EXPECT().IncrementLoginCount(gomock.Any(), 1).Return(nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":1,"jwt":"Bearer token","refreshToken":"refresh"}`,
+			},
+		},
+		{
+			name: "success flow rehashes an outdated password hash",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "bcrypt hash",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "bcrypt hash", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("bcrypt hash").Return(true)
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password1").Return([]byte("argon2id hash"), nil)
+				mockRepo.EXPECT().RehashPassword(gomock.Any(), repository.RehashPasswordInput{
+					UserID:      1,
+					OldPassword: "bcrypt hash",
+					Password:    "argon2id hash",
+				}).Return(nil)
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), gomock.Any()).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
 				}, nil)
-				mockHash.EXPECT().CompareValue("123456", "@Password1").Return(true)
 				mockToken.EXPECT().GenerateToken(gomock.Any()).Return("Bearer token", nil)
+				mockToken.EXPECT().GenerateRefreshToken("").Return(token.RefreshToken{
+					Token:    "refresh",
+					Hash:     "hash",
+					FamilyID: "family",
+				}, nil)
+				mockRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().IncrementLoginCount(gomock.Any(), 1).Return(nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":1,"jwt":"Bearer token","refreshToken":"refresh"}`,
+			},
+		},
+		{
+			name: "failed flow hashing queue is full",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+					Password:    "123456",
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(false, &hash.BusyError{
+					RetryAfter: 1500 * time.Millisecond,
+				})
+			},
+			want: want{
+				code:       503,
+				body:       `{"message":"server is busy, try again later"}`,
+				retryAfter: "2",
+			},
+		},
+		{
+			name: "failed flow hash with an unknown pepper version",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+					Password:    "$pepper$v0$123456",
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "$pepper$v0$123456", "@Password1").Return(false, &hash.UnknownPepperError{Version: "v0"})
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"unknown pepper version v0"}`,
+			},
+		},
+		{
+			name: "success flow with two-factor authentication returns a challenge",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+					MFAEnabled:      true,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().CreateMFAChallenge(gomock.Any(), gomock.Cond(func(x interface{}) bool {
+					input := x.(repository.CreateMFAChallengeInput)
+					return input.UserID == 1 && input.TokenHash != "" && input.ExpiresAt.After(time.Now())
+				})).Return(nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":1,"mfaRequired":true,"mfaToken":"*"}`,
+			},
+		},
+		{
+			name: "failed flow on repository create mfa challenge",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+					Password:    "123456",
+					MFAEnabled:  true,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().CreateMFAChallenge(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "success flow unverified phone number gets restricted scopes",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628123456789",
+				}).Return(repository.LoginUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+					Password:    "123456",
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), gomock.Any()).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockToken.EXPECT().GenerateToken(token.TokenBody{
+					UserID: 1,
+					Roles:  []string{"user"},
+					Scopes: []string{"profile:read", "phone:verify", "account:delete"},
+				}).Return("Bearer token", nil)
+				mockToken.EXPECT().GenerateRefreshToken("").Return(token.RefreshToken{
+					Token:    "refresh",
+					Hash:     "hash",
+					FamilyID: "family",
+				}, nil)
+				mockRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), gomock.Any()).Return(nil)
 				mockRepo.EXPECT().IncrementLoginCount(gomock.Any(), 1).Return(nil)
 			},
 			want: want{
 				code: 200,
-				body: `{"id":1,"jwt":"Bearer token"}`,
+				body: `{"id":1,"jwt":"Bearer token","refreshToken":"refresh"}`,
 			},
 		},
 		{
@@ -248,6 +546,7 @@ func TestServer_LoginUser(t *testing.T) {
 			name: "failed on repository login user",
 			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
 				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
 					PhoneNumber: "+628123456789",
 				}).Return(repository.LoginUserOutput{}, fmt.Errorf("some error"))
@@ -261,9 +560,18 @@ func TestServer_LoginUser(t *testing.T) {
 			name: "failed on repository login user no rows in result set",
 			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
 				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
 					PhoneNumber: "+628123456789",
 				}).Return(repository.LoginUserOutput{}, fmt.Errorf("no rows in result set"))
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:        0,
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodPassword,
+					FailureReason: repository.LoginFailureUnknownUser,
+				}).Return(nil)
+				mockLockout.EXPECT().RecordFailure(gomock.Any(), "+628123456789", "192.0.2.1").Return(nil)
 			},
 			want: want{
 				code: 400,
@@ -274,30 +582,198 @@ func TestServer_LoginUser(t *testing.T) {
 			name: "failed on repository login user invalid password",
 			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
 				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
 					PhoneNumber: "+628123456789",
 				}).Return(repository.LoginUserOutput{
-					UserID:   1,
-					Password: "123456",
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
 				}, nil)
-				mockHash.EXPECT().CompareValue("123456", "@Password1").Return(false)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(false, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:        1,
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodPassword,
+					FailureReason: repository.LoginFailureInvalidPassword,
+				}).Return(nil)
+				mockLockout.EXPECT().RecordFailure(gomock.Any(), "+628123456789", "192.0.2.1").Return(nil)
 			},
 			want: want{
 				code: 400,
 				body: `{"message":"invalid password"}`,
 			},
 		},
+		{
+			name: "failed on suspended account",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628123456789",
+				}).Return(repository.LoginUserOutput{
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 1,
+				}).Return(repository.GetUserSuspensionOutput{
+					IsSuspended: true,
+					Reason:      "spam",
+				}, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:        1,
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodPassword,
+					FailureReason: repository.LoginFailureSuspended,
+				}).Return(nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"account is suspended"}`,
+			},
+		},
+		{
+			name: "failed on repository get user suspension",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628123456789",
+				}).Return(repository.LoginUserOutput{
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "success flow restores account marked for deletion",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628123456789",
+				}).Return(repository.LoginUserOutput{
+					UserID:              1,
+					PhoneNumber:         "+628123456789",
+					Password:            "123456",
+					DeletionRequestedAt: &deletionRequestedAt,
+					PhoneVerifiedAt:     &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().CancelUserDeletion(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), gomock.Any()).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockToken.EXPECT().GenerateToken(gomock.Any()).Return("Bearer token", nil)
+				mockToken.EXPECT().GenerateRefreshToken("").Return(token.RefreshToken{
+					Token:    "refresh",
+					Hash:     "hash",
+					FamilyID: "family",
+				}, nil)
+				mockRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().IncrementLoginCount(gomock.Any(), 1).Return(nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"deletionCancelled":true,"id":1,"jwt":"Bearer token","refreshToken":"refresh"}`,
+			},
+		},
+		{
+			name: "failed on repository cancel user deletion",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+					UserID:              1,
+					PhoneNumber:         "+628123456789",
+					Password:            "123456",
+					DeletionRequestedAt: &deletionRequestedAt,
+					PhoneVerifiedAt:     &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().CancelUserDeletion(gomock.Any(), 1).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed on repository get user roles",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628123456789",
+				}).Return(repository.LoginUserOutput{
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 1,
+				}).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), repository.GetUserRolesInput{
+					UserID: 1,
+				}).Return(repository.GetUserRolesOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
 		{
 			name: "failed on token generate token",
 			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
 				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
 					PhoneNumber: "+628123456789",
 				}).Return(repository.LoginUserOutput{
-					UserID:   1,
-					Password: "123456",
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 1,
+				}).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), repository.GetUserRolesInput{
+					UserID: 1,
+				}).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
 				}, nil)
-				mockHash.EXPECT().CompareValue("123456", "@Password1").Return(true)
 				mockToken.EXPECT().GenerateToken(gomock.Any()).Return("", fmt.Errorf("some error"))
 			},
 			want: want{
@@ -305,13 +781,177 @@ func TestServer_LoginUser(t *testing.T) {
 				body: `{"message":"some error"}`,
 			},
 		},
+		{
+			name: "failed on token generate refresh token",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628123456789",
+				}).Return(repository.LoginUserOutput{
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 1,
+				}).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), repository.GetUserRolesInput{
+					UserID: 1,
+				}).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockToken.EXPECT().GenerateToken(gomock.Any()).Return("Bearer token", nil)
+				mockToken.EXPECT().GenerateRefreshToken("").Return(token.RefreshToken{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed on repository create refresh token",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628123456789",
+				}).Return(repository.LoginUserOutput{
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 1,
+				}).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), repository.GetUserRolesInput{
+					UserID: 1,
+				}).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockToken.EXPECT().GenerateToken(gomock.Any()).Return("Bearer token", nil)
+				mockToken.EXPECT().GenerateRefreshToken("").Return(token.RefreshToken{
+					Token:    "refresh",
+					Hash:     "hash",
+					FamilyID: "family",
+				}, nil)
+				mockRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed on locked account",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{
+					Locked:     true,
+					RetryAfter: 90*time.Second + time.Millisecond,
+				}, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodPassword,
+					FailureReason: repository.LoginFailureLocked,
+				}).Return(nil)
+			},
+			want: want{
+				code:       423,
+				body:       `{"message":"account is temporarily locked"}`,
+				retryAfter: "91",
+			},
+		},
+		{
+			name: "failed on throttled login",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{
+					RetryAfter: 2 * time.Second,
+				}, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodPassword,
+					FailureReason: repository.LoginFailureThrottled,
+				}).Return(nil)
+			},
+			want: want{
+				code:       429,
+				body:       `{"message":"too many failed login attempts"}`,
+				retryAfter: "2",
+			},
+		},
+		{
+			name: "failed on lockout check",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), gomock.Any(), gomock.Any()).Return(lockout.Status{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed on lockout record failure",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), gomock.Any(), gomock.Any()).Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(false, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockLockout.EXPECT().RecordFailure(gomock.Any(), gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed on lockout record success",
+			body: `{"password":"@Password1","phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), gomock.Any(), gomock.Any()).Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					Password:        "123456",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil)
+				mockHash.EXPECT().NeedsRehash("123456").Return(false)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			handler := NewServer(NewServerOptions{
-				Repository: mockRepo,
-				Hash:       mockHash,
-				Token:      mockToken,
+				Repository:      mockRepo,
+				Hash:            mockHash,
+				Token:           mockToken,
+				Lockout:         mockLockout,
+				MFAChallengeTTL: 5 * time.Minute,
 			})
 			tt.mockFunc()
 
@@ -327,9 +967,15 @@ func TestServer_LoginUser(t *testing.T) {
 				t.Fatalf("LoginUser status code got =%d, want %d \n", rec.Code, tt.want.code)
 			}
 
-			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+			// the mfa token is random
+			body := mfaTokenPattern.ReplaceAllString(strings.ReplaceAll(string(rec.Body.Bytes()), "\n", ""), `"mfaToken":"*"`)
+			if !reflect.DeepEqual(tt.want.body, body) {
 				t.Fatalf("LoginUser Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
 			}
+
+			if got := rec.Header().Get("Retry-After"); got != tt.want.retryAfter {
+				t.Fatalf("LoginUser Retry-After got =%s, want %s \n", got, tt.want.retryAfter)
+			}
 		})
 	}
 }
@@ -363,7 +1009,7 @@ func TestServer_GetMyProfile(t *testing.T) {
 			},
 			want: want{
 				code: 200,
-				body: `{"id":1,"name":"testing","phoneNumber":"+628123456789"}`,
+				body: `{"id":1,"name":"testing","phoneNumber":"+628123456789","phoneVerified":false}`,
 			},
 		},
 		{
@@ -425,6 +1071,12 @@ func TestServer_UpdateMyProfile(t *testing.T) {
 	mockRepo := repository.NewMockRepositoryInterface(ctrl)
 	mockHash := hash.NewMockHashMethod(ctrl)
 	mockToken := token.NewMockTokenMethod(ctrl)
+	mockSMS := sms.NewMockSender(ctrl)
+	phoneVerifiedAt := time.Date(2024, 1, 1, 3, 4, 5, 0, time.UTC)
+	owner := repository.LoginUserOutput{
+		UserID:      1,
+		PhoneNumber: "+628123456789",
+	}
 	type want struct {
 		body string
 		code int
@@ -439,49 +1091,77 @@ func TestServer_UpdateMyProfile(t *testing.T) {
 		{
 			name:   "success flow",
 			userID: 1,
-			body:   `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
+			body:   `{"fullName":"testing","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
-				mockHash.EXPECT().HashValue("@Password1").Return([]byte("123456"), nil)
-				mockRepo.EXPECT().UpdateUser(gomock.Any(), repository.UpdateUserInput{
-					UserID:      1,
-					FullName:    "testing",
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
 					PhoneNumber: "+628123456789",
-					Password:    "123456",
+				}).Return(owner, nil)
+				mockRepo.EXPECT().UpdateUser(gomock.Any(), repository.UpdateUserInput{
+					UserID:   1,
+					FullName: "testing",
 				}).Return(repository.UpdateUserOutput{
-					FullName:    "testing",
-					UserID:      1,
-					PhoneNumber: "+628123456789",
+					FullName:        "testing",
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					PhoneVerifiedAt: &phoneVerifiedAt,
 				}, nil)
 			},
 			want: want{
 				code: 200,
-				body: `{"id":1,"name":"testing","phoneNumber":"+628123456789"}`,
+				body: `{"id":1,"name":"testing","phoneNumber":"+628123456789","phoneVerified":true}`,
 			},
 		},
 		{
-			name:     "failed flow invalid password length",
-			userID:   1,
-			body:     `{"fullName":"testing","password":"@","phoneNumber":"+628123456789"}`,
-			mockFunc: func() {},
+			name:   "success flow new phone number waits for verification",
+			userID: 1,
+			body:   `{"phoneNumber":"+628111111111"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628111111111",
+				}).Return(repository.LoginUserOutput{}, fmt.Errorf("sql: no rows in result set"))
+				mockRepo.EXPECT().UpdateUser(gomock.Any(), repository.UpdateUserInput{
+					UserID: 1,
+				}).Return(repository.UpdateUserOutput{
+					FullName:        "testing",
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					PhoneVerifiedAt: &phoneVerifiedAt,
+				}, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Cond(func(x interface{}) bool {
+					input := x.(repository.CreateOTPInput)
+					return input.UserID == 1 && input.Purpose == repository.OTPPurposePhoneVerification &&
+						input.PhoneNumber == "+628111111111"
+				})).Return(nil)
+				mockSMS.EXPECT().Send(gomock.Any(), "+628111111111", gomock.Any()).Return(nil)
+			},
 			want: want{
-				code: 400,
-				body: `{"message":"password length must be between 6 and 64 characters"}`,
+				code: 200,
+				body: `{"id":1,"name":"testing","pendingPhoneNumber":"+628111111111","phoneNumber":"+628123456789","phoneVerified":true}`,
 			},
 		},
 		{
-			name:     "failed flow invalid password contain at least 1 uppercase, 1 lowercase, 1 number, and 1 symbol",
-			userID:   1,
-			body:     `{"fullName":"testing","password":"@password","phoneNumber":"+628123456789"}`,
-			mockFunc: func() {},
+			name:   "failed flow on send phone verification",
+			userID: 1,
+			body:   `{"phoneNumber":"+628111111111"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{}, fmt.Errorf("sql: no rows in result set"))
+				mockRepo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(repository.UpdateUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+				}, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
 			want: want{
-				code: 400,
-				body: `{"message":"password must contain at least 1 uppercase, 1 lowercase, 1 number, and 1 symbol"}`,
+				code: 500,
+				body: `{"message":"some error"}`,
 			},
 		},
 		{
 			name:     "failed flow invalid full name length",
 			userID:   1,
-			body:     `{"fullName":"a","password":"@Password1","phoneNumber":"+628123456789"}`,
+			body:     `{"fullName":"a","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {},
 			want: want{
 				code: 400,
@@ -491,7 +1171,7 @@ func TestServer_UpdateMyProfile(t *testing.T) {
 		{
 			name:     "failed flow invalid phone number length",
 			userID:   1,
-			body:     `{"fullName":"testing","password":"@Password1","phoneNumber":"+621"}`,
+			body:     `{"fullName":"testing","phoneNumber":"+621"}`,
 			mockFunc: func() {},
 			want: want{
 				code: 400,
@@ -501,7 +1181,7 @@ func TestServer_UpdateMyProfile(t *testing.T) {
 		{
 			name:     "failed flow invalid phone number must start with +62",
 			userID:   1,
-			body:     `{"fullName":"testing","password":"@Password1","phoneNumber":"628123456789"}`,
+			body:     `{"fullName":"testing","phoneNumber":"628123456789"}`,
 			mockFunc: func() {},
 			want: want{
 				code: 400,
@@ -511,7 +1191,7 @@ func TestServer_UpdateMyProfile(t *testing.T) {
 		{
 			name:     "failed flow invalid user id",
 			userID:   0,
-			body:     `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
+			body:     `{"fullName":"testing","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {},
 			want: want{
 				code: 403,
@@ -521,14 +1201,12 @@ func TestServer_UpdateMyProfile(t *testing.T) {
 		{
 			name:   "failed flow on repository update user",
 			userID: 1,
-			body:   `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
+			body:   `{"fullName":"testing","phoneNumber":"+628123456789"}`,
 			mockFunc: func() {
-				mockHash.EXPECT().HashValue("@Password1").Return([]byte("123456"), nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(owner, nil)
 				mockRepo.EXPECT().UpdateUser(gomock.Any(), repository.UpdateUserInput{
-					UserID:      1,
-					FullName:    "testing",
-					PhoneNumber: "+628123456789",
-					Password:    "123456",
+					UserID:   1,
+					FullName: "testing",
 				}).Return(repository.UpdateUserOutput{}, fmt.Errorf("some error"))
 			},
 			want: want{
@@ -537,17 +1215,13 @@ func TestServer_UpdateMyProfile(t *testing.T) {
 			},
 		},
 		{
-			name:   "failed flow on repository update user duplicate phone number",
+			name:   "failed flow phone number registered by another user",
 			userID: 1,
-			body:   `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
+			body:   `{"fullName":"testing","phoneNumber":"+628111111111"}`,
 			mockFunc: func() {
-				mockHash.EXPECT().HashValue("@Password1").Return([]byte("123456"), nil)
-				mockRepo.EXPECT().UpdateUser(gomock.Any(), repository.UpdateUserInput{
-					UserID:      1,
-					FullName:    "testing",
-					PhoneNumber: "+628123456789",
-					Password:    "123456",
-				}).Return(repository.UpdateUserOutput{}, fmt.Errorf("duplicate key value violates unique constraint"))
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628111111111",
+				}).Return(repository.LoginUserOutput{UserID: 2}, nil)
 			},
 			want: want{
 				code: 409,
@@ -555,11 +1229,11 @@ func TestServer_UpdateMyProfile(t *testing.T) {
 			},
 		},
 		{
-			name:   "failed flow on repository update user while hashing password",
+			name:   "failed flow on repository login user",
 			userID: 1,
-			body:   `{"fullName":"testing","password":"@Password1","phoneNumber":"+628123456789"}`,
+			body:   `{"phoneNumber":"+628111111111"}`,
 			mockFunc: func() {
-				mockHash.EXPECT().HashValue("@Password1").Return([]byte(""), fmt.Errorf("some error"))
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{}, fmt.Errorf("some error"))
 			},
 			want: want{
 				code: 500,
@@ -573,6 +1247,7 @@ func TestServer_UpdateMyProfile(t *testing.T) {
 				Repository: mockRepo,
 				Hash:       mockHash,
 				Token:      mockToken,
+				SMS:        mockSMS,
 			})
 			tt.mockFunc()
 
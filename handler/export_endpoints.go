@@ -0,0 +1,319 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// dataExportRetention is how long the archive of a background export can be downloaded
+	dataExportRetention = 24 * time.Hour
+	// dataExportRetryAfter is when to ask again while every export worker is busy
+	dataExportRetryAfter = 30 * time.Second
+	// dataExportFailed is the error of a background export that couldn't be prepared
+	dataExportFailed = "failed to prepare the export, please request a new one"
+)
+
+// ExportMyProfile returns everything stored about the caller, exports with more
+// records than ExportAsyncThreshold are prepared in the background instead.
+func (s *Server) ExportMyProfile(ctx echo.Context, params generated.ExportMyProfileParams) error {
+	// get user id from middleware
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	format := repository.DataExportFormatJSON
+	if params.Format != nil {
+		format = string(*params.Format)
+	}
+	if format != repository.DataExportFormatJSON && format != repository.DataExportFormatZip {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "format must be json or zip",
+		})
+	}
+
+	count, err := s.Repository.CountUserRecords(ctx.Request().Context(), userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if count <= s.ExportAsyncThreshold {
+		archive, err := s.buildDataExport(ctx.Request().Context(), userID, format)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+		return sendDataExport(ctx, format, archive)
+	}
+
+	jobID, err := newExportJobID()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	// at most ExportWorkers exports are prepared at once, the slot is taken before the job exists
+	// so a refused request leaves nothing pending behind
+	select {
+	case s.exportSlots <- struct{}{}:
+	default:
+		ctx.Response().Header().Set("Retry-After", strconv.Itoa(int(dataExportRetryAfter.Seconds())))
+		return ctx.JSON(http.StatusServiceUnavailable, generated.ErrorResponse{
+			Message: "too many exports are being prepared, please try again later",
+		})
+	}
+
+	job, err := s.Repository.CreateDataExport(ctx.Request().Context(), repository.CreateDataExportInput{
+		JobID:  jobID,
+		UserID: userID,
+		Format: format,
+	})
+	if err != nil {
+		<-s.exportSlots
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		defer func() { <-s.exportSlots }()
+		s.runDataExport(jobID, userID, format)
+	}()
+
+	return ctx.JSON(http.StatusAccepted, generated.DataExportJobResponse{
+		JobId:     jobID,
+		Format:    generated.DataExportJobResponseFormat(format),
+		Status:    generated.DataExportJobResponseStatus(repository.DataExportStatusPending),
+		CreatedAt: job.CreatedAt,
+	})
+}
+
+// GetMyProfileExport returns the status of a background export of the caller.
+func (s *Server) GetMyProfileExport(ctx echo.Context, jobId string) error {
+	job, code, err := s.getDataExport(ctx, jobId)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	resp := generated.DataExportJobResponse{
+		JobId:       job.JobID,
+		Format:      generated.DataExportJobResponseFormat(job.Format),
+		Status:      generated.DataExportJobResponseStatus(job.Status),
+		CreatedAt:   job.CreatedAt,
+		CompletedAt: job.CompletedAt,
+		ExpiresAt:   job.ExpiresAt,
+	}
+	if job.ErrorMessage != "" {
+		resp.Error = &job.ErrorMessage
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// DownloadMyProfileExport returns the archive of a completed background export of the caller.
+func (s *Server) DownloadMyProfileExport(ctx echo.Context, jobId string) error {
+	job, code, err := s.getDataExport(ctx, jobId)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if job.Status != repository.DataExportStatusCompleted {
+		return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+			Message: fmt.Sprintf("export is %s", job.Status),
+		})
+	}
+
+	return sendDataExport(ctx, job.Format, job.Archive)
+}
+
+// getDataExport returns the export job of the caller with the status code to use on error
+func (s *Server) getDataExport(ctx echo.Context, jobID string) (repository.GetDataExportOutput, int, error) {
+	// get user id from middleware
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return repository.GetDataExportOutput{}, http.StatusForbidden, err
+	}
+
+	job, err := s.Repository.GetDataExport(ctx.Request().Context(), repository.GetDataExportInput{
+		JobID:  jobID,
+		UserID: userID,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return repository.GetDataExportOutput{}, http.StatusNotFound, fmt.Errorf("export not found")
+		}
+		return repository.GetDataExportOutput{}, http.StatusInternalServerError, err
+	}
+
+	return job, 0, nil
+}
+
+// FailStaleDataExports fails the background exports pending for longer than ExportTimeout, a running
+// export gives up by then so they were interrupted by a restart and would stay pending forever
+func (s *Server) FailStaleDataExports(ctx context.Context) error {
+	now := time.Now().UTC()
+	return s.Repository.FailDataExports(ctx, repository.FailDataExportsInput{
+		CreatedBefore: now.Add(-s.ExportTimeout),
+		ErrorMessage:  dataExportFailed,
+		ExpiresAt:     now.Add(dataExportRetention),
+	})
+}
+
+// runDataExport prepares the archive of a background export and stores it on the job, the job
+// outlives the request so it runs on the context of the server, limited to ExportTimeout
+func (s *Server) runDataExport(jobID string, userID int, format string) {
+	ctx, cancel := context.WithCancel(s.backgroundCtx)
+	if s.ExportTimeout > 0 {
+		ctx, cancel = context.WithTimeout(s.backgroundCtx, s.ExportTimeout)
+	}
+	defer cancel()
+
+	archive, err := s.buildDataExport(ctx, userID, format)
+	input := repository.CompleteDataExportInput{
+		JobID:     jobID,
+		Archive:   archive,
+		ExpiresAt: time.Now().UTC().Add(dataExportRetention),
+	}
+	if err != nil {
+		fmt.Println("failed data export", jobID, "err:", err)
+		input.ErrorMessage = dataExportFailed
+	}
+
+	err = s.Repository.CompleteDataExport(ctx, input)
+	if err != nil {
+		fmt.Println("failed complete data export", jobID, "err:", err)
+	}
+}
+
+// buildDataExport collects the data of the user into a json document, zipped when requested
+func (s *Server) buildDataExport(ctx context.Context, userID int, format string) ([]byte, error) {
+	user, err := s.Repository.GetUser(ctx, repository.GetUserInput{
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := s.Repository.GetUserRoles(ctx, repository.GetUserRolesInput{
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.Repository.GetUserRecords(ctx, repository.GetUserRecordsInput{
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	export := generated.DataExport{
+		ExportedAt:      time.Now().UTC(),
+		Profile:         userResponse(user),
+		Roles:           roles.Roles,
+		PhoneVerifiedAt: user.PhoneVerifiedAt,
+		LoginHistory:    make([]generated.LoginAttempt, 0, len(records.LoginAttempts)),
+		Sessions:        make([]generated.SessionEntry, 0, len(records.Sessions)),
+		Suspensions:     make([]generated.SuspensionEntry, 0, len(records.Suspensions)),
+		PasswordChanges: make([]time.Time, 0, len(records.PasswordChanges)),
+	}
+	if records.MFA != nil {
+		export.Mfa = &generated.MfaEnrollment{
+			EnrolledAt: records.MFA.EnrolledAt,
+			EnabledAt:  records.MFA.EnabledAt,
+		}
+	}
+	export.PasswordChanges = append(export.PasswordChanges, records.PasswordChanges...)
+	for _, attempt := range records.LoginAttempts {
+		export.LoginHistory = append(export.LoginHistory, loginAttemptResponse(attempt))
+	}
+	for _, record := range records.Sessions {
+		export.Sessions = append(export.Sessions, generated.SessionEntry{
+			FamilyId:  record.FamilyID,
+			CreatedAt: record.CreatedAt,
+			ExpiresAt: record.ExpiresAt,
+			UsedAt:    record.UsedAt,
+			RevokedAt: record.RevokedAt,
+		})
+	}
+	for _, record := range records.Suspensions {
+		export.Suspensions = append(export.Suspensions, generated.SuspensionEntry{
+			Reason:      record.Reason,
+			SuspendedBy: record.SuspendedBy,
+			CreatedAt:   record.CreatedAt,
+			ExpiresAt:   record.ExpiresAt,
+			LiftedAt:    record.LiftedAt,
+			LiftedBy:    record.LiftedBy,
+		})
+	}
+
+	document, err := json.Marshal(export)
+	if err != nil {
+		return nil, err
+	}
+
+	if format != repository.DataExportFormatZip {
+		return document, nil
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	file, err := archive.Create("export.json")
+	if err != nil {
+		return nil, err
+	}
+	_, err = file.Write(document)
+	if err != nil {
+		return nil, err
+	}
+	err = archive.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func sendDataExport(ctx echo.Context, format string, archive []byte) error {
+	contentType := echo.MIMEApplicationJSON
+	if format == repository.DataExportFormatZip {
+		contentType = "application/zip"
+	}
+	ctx.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="export.%s"`, format))
+	return ctx.Blob(http.StatusOK, contentType, archive)
+}
+
+func newExportJobID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,534 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+var (
+	exportedAtPattern = regexp.MustCompile(`"exportedAt":"[^"]*"`)
+	jobIDPattern      = regexp.MustCompile(`"jobId":"[0-9a-f]{32}"`)
+)
+
+// exportBody returns the json document of the response, unzipped when needed, without the values that change on every run
+func exportBody(t *testing.T, rec *httptest.ResponseRecorder) string {
+	body := rec.Body.Bytes()
+	if rec.Header().Get(echo.HeaderContentType) == "application/zip" {
+		archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+		if err != nil {
+			t.Fatalf("invalid zip archive, err: %s", err)
+		}
+		file, err := archive.Open("export.json")
+		if err != nil {
+			t.Fatalf("missing export.json, err: %s", err)
+		}
+		defer file.Close()
+		body, err = io.ReadAll(file)
+		if err != nil {
+			t.Fatalf("failed read export.json, err: %s", err)
+		}
+	}
+
+	value := strings.ReplaceAll(string(body), "\n", "")
+	value = exportedAtPattern.ReplaceAllString(value, `"exportedAt":""`)
+	return jobIDPattern.ReplaceAllString(value, `"jobId":""`)
+}
+
+func TestServer_ExportMyProfile(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := createdAt.Add(time.Hour)
+	formatZip := generated.ExportMyProfileParamsFormat("zip")
+	invalidFormat := generated.ExportMyProfileParamsFormat("csv")
+	collect := func() {
+		mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{
+			UserID: 1,
+		}).Return(repository.GetUserOutput{
+			UserID:          1,
+			PhoneNumber:     "+628123456789",
+			FullName:        "testing",
+			CreatedAt:       createdAt,
+			PhoneVerifiedAt: &createdAt,
+		}, nil)
+		mockRepo.EXPECT().GetUserRoles(gomock.Any(), repository.GetUserRolesInput{
+			UserID: 1,
+		}).Return(repository.GetUserRolesOutput{
+			Roles: []string{"user"},
+		}, nil)
+		mockRepo.EXPECT().GetUserRecords(gomock.Any(), repository.GetUserRecordsInput{
+			UserID: 1,
+		}).Return(repository.GetUserRecordsOutput{
+			LoginAttempts: []repository.LoginAttempt{
+				{ID: 7, CreatedAt: createdAt, IPAddress: "192.0.2.1", UserAgent: "curl", AuthMethod: repository.AuthMethodPassword, Success: true},
+			},
+			Sessions: []repository.SessionRecord{
+				{FamilyID: "family", CreatedAt: createdAt, ExpiresAt: expiresAt},
+			},
+			Suspensions: []repository.SuspensionRecord{
+				{Reason: "spam", SuspendedBy: 2, CreatedAt: createdAt, LiftedAt: &expiresAt},
+			},
+			MFA:             &repository.MFARecord{EnrolledAt: createdAt, EnabledAt: &expiresAt},
+			PasswordChanges: []time.Time{expiresAt},
+		}, nil)
+	}
+	document := `{"exportedAt":"","loginHistory":[{"authMethod":"password","createdAt":"2024-01-02T03:04:05Z","id":7,"ipAddress":"192.0.2.1","success":true,"userAgent":"curl"}],` +
+		`"mfa":{"enabledAt":"2024-01-02T04:04:05Z","enrolledAt":"2024-01-02T03:04:05Z"},"passwordChanges":["2024-01-02T04:04:05Z"],"phoneVerifiedAt":"2024-01-02T03:04:05Z",` +
+		`"profile":{"createdAt":"2024-01-02T03:04:05Z","id":1,"name":"testing","phoneNumber":"+628123456789"},"roles":["user"],` +
+		`"sessions":[{"createdAt":"2024-01-02T03:04:05Z","expiresAt":"2024-01-02T04:04:05Z","familyId":"family"}],` +
+		`"suspensions":[{"createdAt":"2024-01-02T03:04:05Z","liftedAt":"2024-01-02T04:04:05Z","reason":"spam","suspendedBy":2}]}`
+	type want struct {
+		body        string
+		code        int
+		contentType string
+		retryAfter  string
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		params   generated.ExportMyProfileParams
+		busy     bool
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow json",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().CountUserRecords(gomock.Any(), 1).Return(3, nil)
+				collect()
+			},
+			want: want{
+				code:        200,
+				contentType: echo.MIMEApplicationJSON,
+				body:        document,
+			},
+		},
+		{
+			name:   "success flow zip",
+			userID: 1,
+			params: generated.ExportMyProfileParams{Format: &formatZip},
+			mockFunc: func() {
+				mockRepo.EXPECT().CountUserRecords(gomock.Any(), 1).Return(3, nil)
+				collect()
+			},
+			want: want{
+				code:        200,
+				contentType: "application/zip",
+				body:        document,
+			},
+		},
+		{
+			name:   "success flow large export in background",
+			userID: 1,
+			params: generated.ExportMyProfileParams{Format: &formatZip},
+			mockFunc: func() {
+				mockRepo.EXPECT().CountUserRecords(gomock.Any(), 1).Return(11, nil)
+				mockRepo.EXPECT().CreateDataExport(gomock.Any(), gomock.Cond(func(x any) bool {
+					input := x.(repository.CreateDataExportInput)
+					return len(input.JobID) == 32 && input.UserID == 1 && input.Format == repository.DataExportFormatZip
+				})).Return(repository.CreateDataExportOutput{CreatedAt: createdAt}, nil)
+				collect()
+				mockRepo.EXPECT().CompleteDataExport(gomock.Any(), gomock.Cond(func(x any) bool {
+					input := x.(repository.CompleteDataExportInput)
+					return len(input.Archive) > 0 && input.ErrorMessage == "" && input.ExpiresAt.After(time.Now())
+				})).Return(nil)
+			},
+			want: want{
+				code:        202,
+				contentType: echo.MIMEApplicationJSONCharsetUTF8,
+				body:        `{"createdAt":"2024-01-02T03:04:05Z","format":"zip","jobId":"","status":"pending"}`,
+			},
+		},
+		{
+			name:   "success flow large export failed in background",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().CountUserRecords(gomock.Any(), 1).Return(11, nil)
+				mockRepo.EXPECT().CreateDataExport(gomock.Any(), gomock.Any()).Return(repository.CreateDataExportOutput{CreatedAt: createdAt}, nil)
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{}, fmt.Errorf("some error"))
+				mockRepo.EXPECT().CompleteDataExport(gomock.Any(), gomock.Cond(func(x any) bool {
+					input := x.(repository.CompleteDataExportInput)
+					return input.Archive == nil && input.ErrorMessage != ""
+				})).Return(nil)
+			},
+			want: want{
+				code:        202,
+				contentType: echo.MIMEApplicationJSONCharsetUTF8,
+				body:        `{"createdAt":"2024-01-02T03:04:05Z","format":"json","jobId":"","status":"pending"}`,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			mockFunc: func() {},
+			want: want{
+				code:        403,
+				contentType: echo.MIMEApplicationJSONCharsetUTF8,
+				body:        `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:     "failed flow invalid format",
+			userID:   1,
+			params:   generated.ExportMyProfileParams{Format: &invalidFormat},
+			mockFunc: func() {},
+			want: want{
+				code:        400,
+				contentType: echo.MIMEApplicationJSONCharsetUTF8,
+				body:        `{"message":"format must be json or zip"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository count user records",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().CountUserRecords(gomock.Any(), 1).Return(0, fmt.Errorf("some error"))
+			},
+			want: want{
+				code:        500,
+				contentType: echo.MIMEApplicationJSONCharsetUTF8,
+				body:        `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository get user records",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().CountUserRecords(gomock.Any(), 1).Return(3, nil)
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{UserID: 1}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), gomock.Any()).Return(repository.GetUserRolesOutput{}, nil)
+				mockRepo.EXPECT().GetUserRecords(gomock.Any(), gomock.Any()).Return(repository.GetUserRecordsOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code:        500,
+				contentType: echo.MIMEApplicationJSONCharsetUTF8,
+				body:        `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow every export worker busy",
+			userID: 1,
+			busy:   true,
+			mockFunc: func() {
+				mockRepo.EXPECT().CountUserRecords(gomock.Any(), 1).Return(11, nil)
+			},
+			want: want{
+				code:        503,
+				contentType: echo.MIMEApplicationJSONCharsetUTF8,
+				body:        `{"message":"too many exports are being prepared, please try again later"}`,
+				retryAfter:  "30",
+			},
+		},
+		{
+			name:   "failed flow on repository create data export",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().CountUserRecords(gomock.Any(), 1).Return(11, nil)
+				mockRepo.EXPECT().CreateDataExport(gomock.Any(), gomock.Any()).Return(repository.CreateDataExportOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code:        500,
+				contentType: echo.MIMEApplicationJSONCharsetUTF8,
+				body:        `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository:           mockRepo,
+				ExportAsyncThreshold: 10,
+				ExportWorkers:        1,
+			})
+			if tt.busy {
+				handler.exportSlots <- struct{}{}
+			}
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/my-profile/export", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.ExportMyProfile(ctx, tt.params)
+			handler.background.Wait()
+
+			if !tt.busy && len(handler.exportSlots) != 0 {
+				t.Fatalf("ExportMyProfile export slots got =%d, want 0 \n", len(handler.exportSlots))
+			}
+
+			if got := rec.Header().Get("Retry-After"); got != tt.want.retryAfter {
+				t.Fatalf("ExportMyProfile Retry-After got =%s, want %s \n", got, tt.want.retryAfter)
+			}
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("ExportMyProfile status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if rec.Header().Get(echo.HeaderContentType) != tt.want.contentType {
+				t.Fatalf("ExportMyProfile content type got =%s, want %s \n", rec.Header().Get(echo.HeaderContentType), tt.want.contentType)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, exportBody(t, rec)) {
+				t.Fatalf("ExportMyProfile Response body got =%s, want %s \n", exportBody(t, rec), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_FailStaleDataExports(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	handler := NewServer(NewServerOptions{
+		Repository:    mockRepo,
+		ExportTimeout: time.Hour,
+	})
+
+	mockRepo.EXPECT().FailDataExports(gomock.Any(), gomock.Cond(func(x any) bool {
+		input := x.(repository.FailDataExportsInput)
+		staleAt := time.Now().Add(-time.Hour)
+		return !input.CreatedBefore.After(staleAt) && input.CreatedBefore.After(staleAt.Add(-time.Minute)) &&
+			input.ErrorMessage != "" && input.ExpiresAt.After(time.Now())
+	})).Return(nil)
+
+	if err := handler.FailStaleDataExports(context.Background()); err != nil {
+		t.Fatalf("FailStaleDataExports error got =%v, want nil \n", err)
+	}
+}
+
+func TestServer_GetMyProfileExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := createdAt.Add(dataExportRetention)
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow completed",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetDataExport(gomock.Any(), repository.GetDataExportInput{
+					JobID:  "job",
+					UserID: 1,
+				}).Return(repository.GetDataExportOutput{
+					JobID:       "job",
+					Format:      repository.DataExportFormatZip,
+					Status:      repository.DataExportStatusCompleted,
+					Archive:     []byte("archive"),
+					CreatedAt:   createdAt,
+					CompletedAt: &createdAt,
+					ExpiresAt:   &expiresAt,
+				}, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"completedAt":"2024-01-02T03:04:05Z","createdAt":"2024-01-02T03:04:05Z","expiresAt":"2024-01-03T03:04:05Z","format":"zip","jobId":"job","status":"completed"}`,
+			},
+		},
+		{
+			name:   "success flow failed",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(repository.GetDataExportOutput{
+					JobID:        "job",
+					Format:       repository.DataExportFormatJSON,
+					Status:       repository.DataExportStatusFailed,
+					ErrorMessage: "some error",
+					CreatedAt:    createdAt,
+					CompletedAt:  &createdAt,
+					ExpiresAt:    &expiresAt,
+				}, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"completedAt":"2024-01-02T03:04:05Z","createdAt":"2024-01-02T03:04:05Z","error":"some error","expiresAt":"2024-01-03T03:04:05Z","format":"json","jobId":"job","status":"failed"}`,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:   "failed flow export not found",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(repository.GetDataExportOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 404,
+				body: `{"message":"export not found"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository get data export",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(repository.GetDataExportOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/my-profile/export/job", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.GetMyProfileExport(ctx, "job")
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("GetMyProfileExport status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("GetMyProfileExport Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_DownloadMyProfileExport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	type want struct {
+		body        string
+		code        int
+		disposition string
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetDataExport(gomock.Any(), repository.GetDataExportInput{
+					JobID:  "job",
+					UserID: 1,
+				}).Return(repository.GetDataExportOutput{
+					JobID:   "job",
+					Format:  repository.DataExportFormatJSON,
+					Status:  repository.DataExportStatusCompleted,
+					Archive: []byte(`{"roles":["user"]}`),
+				}, nil)
+			},
+			want: want{
+				code:        200,
+				body:        `{"roles":["user"]}`,
+				disposition: `attachment; filename="export.json"`,
+			},
+		},
+		{
+			name:   "failed flow export is pending",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(repository.GetDataExportOutput{
+					JobID:  "job",
+					Format: repository.DataExportFormatJSON,
+					Status: repository.DataExportStatusPending,
+				}, nil)
+			},
+			want: want{
+				code: 409,
+				body: `{"message":"export is pending"}`,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:   "failed flow export not found",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetDataExport(gomock.Any(), gomock.Any()).Return(repository.GetDataExportOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 404,
+				body: `{"message":"export not found"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/my-profile/export/job/download", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.DownloadMyProfileExport(ctx, "job")
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("DownloadMyProfileExport status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if rec.Header().Get(echo.HeaderContentDisposition) != tt.want.disposition {
+				t.Fatalf("DownloadMyProfileExport content disposition got =%s, want %s \n", rec.Header().Get(echo.HeaderContentDisposition), tt.want.disposition)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("DownloadMyProfileExport Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// IntrospectToken implements RFC 7662 token introspection for internal services,
+// the caller authenticates with its client credentials using HTTP Basic authentication.
+func (s *Server) IntrospectToken(ctx echo.Context) error {
+	clientID, clientSecret, ok := ctx.Request().BasicAuth()
+	if !ok || clientID == "" || clientSecret == "" {
+		return invalidClient(ctx)
+	}
+
+	client, err := s.Repository.GetOAuthClient(ctx.Request().Context(), repository.GetOAuthClientInput{
+		ClientID: clientID,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return invalidClient(ctx)
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashClientSecret(clientSecret)), []byte(client.ClientSecret)) != 1 {
+		return invalidClient(ctx)
+	}
+
+	value := strings.TrimPrefix(ctx.FormValue("token"), "Bearer ")
+	if value == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "token is required",
+		})
+	}
+
+	// token_type_hint is optional, a JWT always has two dots while refresh tokens are opaque
+	var resp generated.IntrospectionResponse
+	if strings.Count(value, ".") == 2 {
+		resp, err = s.introspectAccessToken(ctx, value)
+	} else {
+		resp, err = s.introspectRefreshToken(ctx, value)
+	}
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	ctx.Response().Header().Set("Cache-Control", "no-store")
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) introspectAccessToken(ctx echo.Context, value string) (generated.IntrospectionResponse, error) {
+	body, err := s.Token.ValidateToken(value)
+	if err != nil {
+		return generated.IntrospectionResponse{Active: false}, nil
+	}
+
+	revoked, err := s.Revocation.IsRevoked(ctx.Request().Context(), body)
+	if err != nil {
+		return generated.IntrospectionResponse{}, err
+	}
+
+	if revoked {
+		return generated.IntrospectionResponse{
+			Active:  false,
+			Revoked: &revoked,
+		}, nil
+	}
+
+	sub := strconv.Itoa(body.UserID)
+	exp := int(body.ExpiresAt.Unix())
+	iat := int(body.IssuedAt.Unix())
+	tokenType := "access_token"
+	resp := generated.IntrospectionResponse{
+		Active:    true,
+		Sub:       &sub,
+		Exp:       &exp,
+		Iat:       &iat,
+		TokenType: &tokenType,
+		Revoked:   &revoked,
+	}
+	if len(body.Scopes) > 0 {
+		scope := strings.Join(body.Scopes, " ")
+		resp.Scope = &scope
+	}
+	return resp, nil
+}
+
+func (s *Server) introspectRefreshToken(ctx echo.Context, value string) (generated.IntrospectionResponse, error) {
+	stored, err := s.Repository.GetRefreshToken(ctx.Request().Context(), repository.GetRefreshTokenInput{
+		TokenHash: s.Token.HashRefreshToken(value),
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return generated.IntrospectionResponse{Active: false}, nil
+		}
+		return generated.IntrospectionResponse{}, err
+	}
+
+	revoked := stored.IsRevoked
+	if revoked {
+		return generated.IntrospectionResponse{
+			Active:  false,
+			Revoked: &revoked,
+		}, nil
+	}
+
+	if stored.IsUsed || time.Now().UTC().After(stored.ExpiresAt) {
+		return generated.IntrospectionResponse{Active: false}, nil
+	}
+
+	sub := strconv.Itoa(stored.UserID)
+	exp := int(stored.ExpiresAt.Unix())
+	tokenType := "refresh_token"
+	return generated.IntrospectionResponse{
+		Active:    true,
+		Sub:       &sub,
+		Exp:       &exp,
+		TokenType: &tokenType,
+		Revoked:   &revoked,
+	}, nil
+}
+
+// hashClientSecret hashes the secret the way it is stored in oauth_client. Client secrets are
+// random values of at least 32 bytes, they don't need the password hash and its executor.
+func hashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func invalidClient(ctx echo.Context) error {
+	ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="introspection"`)
+	return ctx.JSON(http.StatusUnauthorized, generated.ErrorResponse{
+		Message: "invalid client credentials",
+	})
+}
@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/revocation"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_IntrospectToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockToken := token.NewMockTokenMethod(ctrl)
+	mockRevocation := revocation.NewMockRevocationMethod(ctrl)
+	issuedAt := time.Unix(1700000000, 0).UTC()
+	expiresAt := time.Unix(1700000900, 0).UTC()
+	refreshExpiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	tokenBody := token.TokenBody{
+		UserID:    1,
+		TokenID:   "jti",
+		IssuedAt:  issuedAt,
+		ExpiresAt: expiresAt,
+	}
+	authenticated := func() {
+		mockRepo.EXPECT().GetOAuthClient(gomock.Any(), repository.GetOAuthClientInput{
+			ClientID: "billing",
+		}).Return(repository.GetOAuthClientOutput{
+			ClientID:     "billing",
+			ClientSecret: hashClientSecret("secret"),
+		}, nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		username string
+		password string
+		token    string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:     "success flow active access token",
+			username: "billing",
+			password: "secret",
+			token:    "a.b.c",
+			mockFunc: func() {
+				authenticated()
+				mockToken.EXPECT().ValidateToken("a.b.c").Return(tokenBody, nil)
+				mockRevocation.EXPECT().IsRevoked(gomock.Any(), tokenBody).Return(false, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"active":true,"exp":1700000900,"iat":1700000000,"revoked":false,"sub":"1","token_type":"access_token"}`,
+			},
+		},
+		{
+			name:     "success flow active scoped access token",
+			username: "billing",
+			password: "secret",
+			token:    "a.b.c",
+			mockFunc: func() {
+				scopedBody := tokenBody
+				scopedBody.Scopes = []string{"profile:read", "profile:write"}
+				authenticated()
+				mockToken.EXPECT().ValidateToken("a.b.c").Return(scopedBody, nil)
+				mockRevocation.EXPECT().IsRevoked(gomock.Any(), scopedBody).Return(false, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"active":true,"exp":1700000900,"iat":1700000000,"revoked":false,"scope":"profile:read profile:write","sub":"1","token_type":"access_token"}`,
+			},
+		},
+		{
+			name:     "success flow invalid access token",
+			username: "billing",
+			password: "secret",
+			token:    "a.b.c",
+			mockFunc: func() {
+				authenticated()
+				mockToken.EXPECT().ValidateToken("a.b.c").Return(token.TokenBody{}, fmt.Errorf("Invalid Token"))
+			},
+			want: want{
+				code: 200,
+				body: `{"active":false}`,
+			},
+		},
+		{
+			name:     "success flow revoked access token",
+			username: "billing",
+			password: "secret",
+			token:    "a.b.c",
+			mockFunc: func() {
+				authenticated()
+				mockToken.EXPECT().ValidateToken("a.b.c").Return(tokenBody, nil)
+				mockRevocation.EXPECT().IsRevoked(gomock.Any(), tokenBody).Return(true, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"active":false,"revoked":true}`,
+			},
+		},
+		{
+			name:     "success flow active refresh token",
+			username: "billing",
+			password: "secret",
+			token:    "refresh",
+			mockFunc: func() {
+				authenticated()
+				mockToken.EXPECT().HashRefreshToken("refresh").Return("hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), repository.GetRefreshTokenInput{
+					TokenHash: "hash",
+				}).Return(repository.GetRefreshTokenOutput{
+					UserID:    2,
+					ExpiresAt: refreshExpiresAt,
+				}, nil)
+			},
+			want: want{
+				code: 200,
+				body: fmt.Sprintf(`{"active":true,"exp":%d,"revoked":false,"sub":"2","token_type":"refresh_token"}`, refreshExpiresAt.Unix()),
+			},
+		},
+		{
+			name:     "success flow used refresh token",
+			username: "billing",
+			password: "secret",
+			token:    "refresh",
+			mockFunc: func() {
+				authenticated()
+				mockToken.EXPECT().HashRefreshToken("refresh").Return("hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					UserID:    2,
+					ExpiresAt: refreshExpiresAt,
+					IsUsed:    true,
+				}, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"active":false}`,
+			},
+		},
+		{
+			name:     "success flow revoked refresh token",
+			username: "billing",
+			password: "secret",
+			token:    "refresh",
+			mockFunc: func() {
+				authenticated()
+				mockToken.EXPECT().HashRefreshToken("refresh").Return("hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					UserID:    2,
+					ExpiresAt: refreshExpiresAt,
+					IsRevoked: true,
+				}, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"active":false,"revoked":true}`,
+			},
+		},
+		{
+			name:     "success flow unknown refresh token",
+			username: "billing",
+			password: "secret",
+			token:    "refresh",
+			mockFunc: func() {
+				authenticated()
+				mockToken.EXPECT().HashRefreshToken("refresh").Return("hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 200,
+				body: `{"active":false}`,
+			},
+		},
+		{
+			name:     "failed flow without client credentials",
+			token:    "a.b.c",
+			mockFunc: func() {},
+			want: want{
+				code: 401,
+				body: `{"message":"invalid client credentials"}`,
+			},
+		},
+		{
+			name:     "failed flow unknown client",
+			username: "billing",
+			password: "secret",
+			token:    "a.b.c",
+			mockFunc: func() {
+				mockRepo.EXPECT().GetOAuthClient(gomock.Any(), gomock.Any()).Return(repository.GetOAuthClientOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 401,
+				body: `{"message":"invalid client credentials"}`,
+			},
+		},
+		{
+			name:     "failed flow wrong client secret",
+			username: "billing",
+			password: "wrong",
+			token:    "a.b.c",
+			mockFunc: func() {
+				mockRepo.EXPECT().GetOAuthClient(gomock.Any(), gomock.Any()).Return(repository.GetOAuthClientOutput{
+					ClientID:     "billing",
+					ClientSecret: hashClientSecret("secret"),
+				}, nil)
+			},
+			want: want{
+				code: 401,
+				body: `{"message":"invalid client credentials"}`,
+			},
+		},
+		{
+			name:     "failed flow client registered with a password hash",
+			username: "billing",
+			password: "secret",
+			token:    "a.b.c",
+			mockFunc: func() {
+				mockRepo.EXPECT().GetOAuthClient(gomock.Any(), gomock.Any()).Return(repository.GetOAuthClientOutput{
+					ClientID:     "billing",
+					ClientSecret: "$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$aGFzaA",
+				}, nil)
+			},
+			want: want{
+				code: 401,
+				body: `{"message":"invalid client credentials"}`,
+			},
+		},
+		{
+			name:     "failed flow without token",
+			username: "billing",
+			password: "secret",
+			mockFunc: func() {
+				authenticated()
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"token is required"}`,
+			},
+		},
+		{
+			name:     "failed flow on revocation check",
+			username: "billing",
+			password: "secret",
+			token:    "a.b.c",
+			mockFunc: func() {
+				authenticated()
+				mockToken.EXPECT().ValidateToken("a.b.c").Return(tokenBody, nil)
+				mockRevocation.EXPECT().IsRevoked(gomock.Any(), tokenBody).Return(false, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Hash:       mockHash,
+				Token:      mockToken,
+				Revocation: mockRevocation,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			form := url.Values{}
+			form.Set("token", tt.token)
+			req := httptest.NewRequest(http.MethodPost, "/oauth/introspect", strings.NewReader(form.Encode()))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			if tt.username != "" {
+				req.SetBasicAuth(tt.username, tt.password)
+			}
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+
+			handler.IntrospectToken(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("IntrospectToken status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("IntrospectToken Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/lockout"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// UnlockUser lifts the temporary lockout of a user and forgets its failed logins.
+func (s *Server) UnlockUser(ctx echo.Context, id int) error {
+	_, code, err := s.checkPermission(ctx, authz.PermissionUsersWrite)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	result, err := s.Repository.GetUser(ctx.Request().Context(), repository.GetUserInput{
+		UserID: id,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.JSON(http.StatusNotFound, generated.ErrorResponse{
+				Message: "user not found",
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Lockout.Unlock(ctx.Request().Context(), result.PhoneNumber)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// loginBlocked refuses a login with 423 when the account is locked and 429 when the
+// caller is only throttled, Retry-After tells when the next attempt is accepted.
+// The attempt belongs to the user when it is known, a login is refused before the phone
+// number is looked up so its attempt is only kept for the phone number.
+func (s *Server) loginBlocked(ctx echo.Context, userID int, phoneNumber string, authMethod string, status lockout.Status) error {
+	retryAfter := int(math.Ceil(status.RetryAfter.Seconds()))
+	ctx.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	failureReason := repository.LoginFailureThrottled
+	if status.Locked {
+		failureReason = repository.LoginFailureLocked
+	}
+	if userID > 0 {
+		s.recordLoginAttempt(ctx, userID, phoneNumber, authMethod, failureReason)
+	} else {
+		s.recordPhoneNumberAttempt(ctx, phoneNumber, authMethod, failureReason)
+	}
+
+	if status.Locked {
+		return ctx.JSON(http.StatusLocked, generated.ErrorResponse{
+			Message: "account is temporarily locked",
+		})
+	}
+
+	return ctx.JSON(http.StatusTooManyRequests, generated.ErrorResponse{
+		Message: "too many failed login attempts",
+	})
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/lockout"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_UnlockUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockAuthz := authz.NewMockAuthzMethod(ctrl)
+	mockLockout := lockout.NewMockLockoutMethod(ctrl)
+	tokenBody := token.TokenBody{
+		UserID: 1,
+		Roles:  []string{"admin"},
+	}
+	allowed := func() {
+		mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersWrite).Return(true, nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		id       int
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow",
+			id:   2,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{
+					UserID: 2,
+				}).Return(repository.GetUserOutput{
+					UserID:      2,
+					PhoneNumber: "+628123456789",
+				}, nil)
+				mockLockout.EXPECT().Unlock(gomock.Any(), "+628123456789").Return(nil)
+			},
+			want: want{
+				code: 204,
+				body: ``,
+			},
+		},
+		{
+			name: "failed flow without permission",
+			id:   2,
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersWrite).Return(false, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"permission users:write is required"}`,
+			},
+		},
+		{
+			name: "failed flow user not found",
+			id:   2,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 404,
+				body: `{"message":"user not found"}`,
+			},
+		},
+		{
+			name: "failed flow on repository get user",
+			id:   2,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow on lockout unlock",
+			id:   2,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{
+					UserID:      2,
+					PhoneNumber: "+628123456789",
+				}, nil)
+				mockLockout.EXPECT().Unlock(gomock.Any(), "+628123456789").Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Authz:      mockAuthz,
+				Lockout:    mockLockout,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/users/%d/unlock", tt.id), nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.Set("user_id", tokenBody.UserID)
+			ctx.Set("token", tokenBody)
+
+			handler.UnlockUser(ctx, tt.id)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("UnlockUser status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("UnlockUser Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultLoginsLimit = 20
+	maxLoginsLimit     = 100
+	// maxUserAgentLength is the size of users_login_attempt.user_agent
+	maxUserAgentLength = 255
+	// maxLoginPhoneNumberLength is the size of users_login_attempt.phone_number
+	maxLoginPhoneNumberLength = 20
+)
+
+// loginsCursor is the last attempt of a page, it is sent to the client as an opaque nextCursor
+type loginsCursor struct {
+	ID int `json:"id"`
+}
+
+// ListMyLogins lists the login attempts of the caller with cursor pagination, newest first.
+func (s *Server) ListMyLogins(ctx echo.Context, params generated.ListMyLoginsParams) error {
+	// get user id from middleware
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	input := repository.ListLoginAttemptsInput{
+		UserID: userID,
+		Limit:  defaultLoginsLimit,
+	}
+	if params.Limit != nil {
+		if *params.Limit < 1 || *params.Limit > maxLoginsLimit {
+			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+				Message: fmt.Sprintf("limit must be between 1 and %d", maxLoginsLimit),
+			})
+		}
+		input.Limit = *params.Limit
+	}
+	if params.Cursor != nil && *params.Cursor != "" {
+		cursor, err := decodeLoginsCursor(*params.Cursor)
+		if err != nil || cursor.ID <= 0 {
+			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+				Message: "invalid cursor",
+			})
+		}
+		input.BeforeID = cursor.ID
+	}
+
+	// one more attempt is asked to know if there is a next page
+	limit := input.Limit
+	input.Limit++
+
+	result, err := s.Repository.ListLoginAttempts(ctx.Request().Context(), input)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	resp := generated.LoginAttemptListResponse{
+		Logins: []generated.LoginAttempt{},
+	}
+	for i, attempt := range result.Attempts {
+		if i == limit {
+			break
+		}
+		resp.Logins = append(resp.Logins, loginAttemptResponse(attempt))
+	}
+
+	if len(result.Attempts) > limit {
+		nextCursor, err := encodeLoginsCursor(loginsCursor{ID: result.Attempts[limit-1].ID})
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+		resp.NextCursor = &nextCursor
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// recordLoginAttempt stores a login attempt of the user, an empty failureReason means the
+// attempt succeeded. A failure to store the attempt must not fail the login, so it is only logged.
+func (s *Server) recordLoginAttempt(ctx echo.Context, userID int, phoneNumber string, authMethod string, failureReason string) {
+	s.storeLoginAttempt(ctx, repository.RecordLoginAttemptInput{
+		UserID:        userID,
+		PhoneNumber:   truncate(phoneNumber, maxLoginPhoneNumberLength),
+		AuthMethod:    authMethod,
+		Success:       failureReason == "",
+		FailureReason: failureReason,
+	})
+}
+
+// recordPhoneNumberAttempt stores a failed login attempt that isn't tied to a user, like one for
+// an unknown phone number. It is only kept for the phone number, so it is in no login history
+// or export and the purge of a deleted user finds it by the phone number.
+func (s *Server) recordPhoneNumberAttempt(ctx echo.Context, phoneNumber string, authMethod string, failureReason string) {
+	s.storeLoginAttempt(ctx, repository.RecordLoginAttemptInput{
+		PhoneNumber:   truncate(phoneNumber, maxLoginPhoneNumberLength),
+		AuthMethod:    authMethod,
+		FailureReason: failureReason,
+	})
+}
+
+func (s *Server) storeLoginAttempt(ctx echo.Context, input repository.RecordLoginAttemptInput) {
+	input.IPAddress = ctx.RealIP()
+	input.UserAgent = truncate(ctx.Request().UserAgent(), maxUserAgentLength)
+	err := s.Repository.RecordLoginAttempt(ctx.Request().Context(), input)
+	if err != nil {
+		fmt.Println("failed record login attempt, err:", err)
+	}
+}
+
+func loginAttemptResponse(attempt repository.LoginAttempt) generated.LoginAttempt {
+	resp := generated.LoginAttempt{
+		Id:         attempt.ID,
+		CreatedAt:  attempt.CreatedAt,
+		IpAddress:  attempt.IPAddress,
+		UserAgent:  attempt.UserAgent,
+		AuthMethod: attempt.AuthMethod,
+		Success:    attempt.Success,
+	}
+	if attempt.FailureReason != "" {
+		failureReason := attempt.FailureReason
+		resp.FailureReason = &failureReason
+	}
+	return resp
+}
+
+// truncate cuts value to at most length bytes without splitting a character
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return strings.ToValidUTF8(value[:length], "")
+}
+
+func encodeLoginsCursor(cursor loginsCursor) (string, error) {
+	value, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+func decodeLoginsCursor(value string) (loginsCursor, error) {
+	var cursor loginsCursor
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return loginsCursor{}, err
+	}
+	err = json.Unmarshal(raw, &cursor)
+	if err != nil {
+		return loginsCursor{}, err
+	}
+	return cursor, nil
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_ListMyLogins(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	limit := 1
+	invalidLimit := 101
+	nextCursor, _ := encodeLoginsCursor(loginsCursor{ID: 9})
+	invalidCursor := "not a cursor"
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		params   generated.ListMyLoginsParams
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow with next page",
+			userID: 1,
+			params: generated.ListMyLoginsParams{Limit: &limit},
+			mockFunc: func() {
+				mockRepo.EXPECT().ListLoginAttempts(gomock.Any(), repository.ListLoginAttemptsInput{
+					UserID: 1,
+					Limit:  2,
+				}).Return(repository.ListLoginAttemptsOutput{
+					Attempts: []repository.LoginAttempt{
+						{ID: 9, CreatedAt: createdAt, IPAddress: "192.0.2.1", UserAgent: "curl", AuthMethod: repository.AuthMethodPassword, Success: true},
+						{ID: 8, CreatedAt: createdAt, IPAddress: "192.0.2.1", UserAgent: "curl", AuthMethod: repository.AuthMethodPassword, FailureReason: repository.LoginFailureInvalidPassword},
+					},
+				}, nil)
+			},
+			want: want{
+				code: 200,
+				body: fmt.Sprintf(`{"logins":[{"authMethod":"password","createdAt":"2024-01-02T03:04:05Z","id":9,"ipAddress":"192.0.2.1","success":true,"userAgent":"curl"}],"nextCursor":"%s"}`, nextCursor),
+			},
+		},
+		{
+			name:   "success flow last page with cursor",
+			userID: 1,
+			params: generated.ListMyLoginsParams{Cursor: &nextCursor},
+			mockFunc: func() {
+				mockRepo.EXPECT().ListLoginAttempts(gomock.Any(), repository.ListLoginAttemptsInput{
+					UserID:   1,
+					BeforeID: 9,
+					Limit:    21,
+				}).Return(repository.ListLoginAttemptsOutput{
+					Attempts: []repository.LoginAttempt{
+						{ID: 8, CreatedAt: createdAt, IPAddress: "192.0.2.1", UserAgent: "curl", AuthMethod: repository.AuthMethodPassword, FailureReason: repository.LoginFailureInvalidPassword},
+					},
+				}, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"logins":[{"authMethod":"password","createdAt":"2024-01-02T03:04:05Z","failureReason":"invalid_password","id":8,"ipAddress":"192.0.2.1","success":false,"userAgent":"curl"}]}`,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:     "failed flow invalid limit",
+			userID:   1,
+			params:   generated.ListMyLoginsParams{Limit: &invalidLimit},
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"limit must be between 1 and 100"}`,
+			},
+		},
+		{
+			name:     "failed flow invalid cursor",
+			userID:   1,
+			params:   generated.ListMyLoginsParams{Cursor: &invalidCursor},
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid cursor"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository list login attempts",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().ListLoginAttempts(gomock.Any(), gomock.Any()).Return(repository.ListLoginAttemptsOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/my-profile/logins", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.ListMyLogins(ctx, tt.params)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("ListMyLogins status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("ListMyLogins Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		length int
+		want   string
+	}{
+		{name: "shorter", value: "curl", length: 10, want: "curl"},
+		{name: "longer", value: "Mozilla/5.0", length: 7, want: "Mozilla"},
+		{name: "does not split a character", value: "abcé", length: 4, want: "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncate(tt.value, tt.length); got != tt.want {
+				t.Errorf("truncate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/labstack/echo/v4"
+)
+
+// GetHashMetrics returns a snapshot of the hash executor metrics, for admin.
+func (s *Server) GetHashMetrics(ctx echo.Context) error {
+	_, code, err := s.checkPermission(ctx, authz.PermissionMetricsRead)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if s.HashExecutor == nil {
+		return ctx.JSON(http.StatusNotFound, generated.ErrorResponse{
+			Message: "passwords are hashed without workers",
+		})
+	}
+
+	metrics := s.HashExecutor.Metrics()
+	return ctx.JSON(http.StatusOK, generated.HashMetricsResponse{
+		Workers:      metrics.Workers,
+		QueueSize:    metrics.QueueSize,
+		Running:      metrics.Running,
+		Queued:       metrics.Queued,
+		Completed:    int64(metrics.Completed),
+		Rejected:     int64(metrics.Rejected),
+		Canceled:     int64(metrics.Canceled),
+		QueueWait:    durationMetricsResponse(metrics.QueueWait),
+		HashDuration: durationMetricsResponse(metrics.HashDuration),
+	})
+}
+
+func durationMetricsResponse(metrics hash.DurationMetrics) generated.HashDurationMetrics {
+	return generated.HashDurationMetrics{
+		Count:  int64(metrics.Count),
+		MeanMs: float32(metrics.Mean()) / float32(time.Millisecond),
+		MaxMs:  float32(metrics.Max) / float32(time.Millisecond),
+	}
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_GetHashMetrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockAuthz := authz.NewMockAuthzMethod(ctrl)
+	executor := hash.NewExecutor(hash.NewMockHashMethod(ctrl), hash.NewExecutorConfig{
+		Workers:   4,
+		QueueSize: 64,
+	})
+	tokenBody := token.TokenBody{
+		UserID: 1,
+		Roles:  []string{"admin"},
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		executor *hash.Executor
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:     "success flow",
+			executor: executor,
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionMetricsRead).Return(true, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"canceled":0,"completed":0,"hashDuration":{"count":0,"maxMs":0,"meanMs":0},"queueSize":64,"queueWait":{"count":0,"maxMs":0,"meanMs":0},"queued":0,"rejected":0,"running":0,"workers":4}`,
+			},
+		},
+		{
+			name:     "failed flow without permission",
+			executor: executor,
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionMetricsRead).Return(false, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"permission metrics:read is required"}`,
+			},
+		},
+		{
+			name:     "failed flow on authz has permission",
+			executor: executor,
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), gomock.Any(), gomock.Any()).Return(false, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow without executor",
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), gomock.Any(), gomock.Any()).Return(true, nil)
+			},
+			want: want{
+				code: 404,
+				body: `{"message":"passwords are hashed without workers"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Authz:        mockAuthz,
+				HashExecutor: tt.executor,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/metrics/hash", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.Set("user_id", tokenBody.UserID)
+			ctx.Set("token", tokenBody)
+
+			handler.GetHashMetrics(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("GetHashMetrics status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("GetHashMetrics Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,457 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// recoveryCodeCount is how many recovery codes are given when TOTP is enabled
+const recoveryCodeCount = 10
+
+var (
+	// errMFANotEnabled is returned when a second factor is checked for a user without an enabled TOTP
+	errMFANotEnabled = errors.New("two-factor authentication is not enabled")
+	// errInvalidMFAToken is the only error a caller gets for a wrong, expired or used challenge
+	errInvalidMFAToken = errors.New("invalid or expired mfa token")
+)
+
+// EnrollTotp starts a TOTP enrollment, the secret is only used once ConfirmTotp gets a first code.
+// Enrolling again before the confirmation replaces the secret.
+func (s *Server) EnrollTotp(ctx echo.Context) error {
+	// get user id from middleware
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	user, err := s.Repository.GetUser(ctx.Request().Context(), repository.GetUserInput{
+		UserID: userID,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	secret, err := s.TOTP.GenerateSecret()
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Repository.CreateTOTP(ctx.Request().Context(), repository.CreateTOTPInput{
+		UserID: userID,
+		Secret: secret,
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPAlreadyEnabled) {
+			return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+				Message: "two-factor authentication is already enabled",
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, generated.TotpEnrollmentResponse{
+		Secret:     secret,
+		OtpauthUri: s.TOTP.URI(secret, user.PhoneNumber),
+	})
+}
+
+// ConfirmTotp enables the enrolled TOTP once the first code matches and returns the recovery codes,
+// they are shown this time only
+func (s *Server) ConfirmTotp(ctx echo.Context) error {
+	var req = generated.TotpCodeRequest{}
+	ctx.Bind(&req)
+
+	// get user id from middleware
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if req.Code == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "code is required",
+		})
+	}
+
+	enrollment, err := s.Repository.GetTOTP(ctx.Request().Context(), userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+				Message: "no pending two-factor enrollment",
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if enrollment.EnabledAt != nil {
+		return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+			Message: "two-factor authentication is already enabled",
+		})
+	}
+
+	user, err := s.Repository.GetUser(ctx.Request().Context(), repository.GetUserInput{
+		UserID: userID,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	status, err := s.Lockout.Check(ctx.Request().Context(), user.PhoneNumber, ctx.RealIP())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+	if status.Blocked() {
+		return s.loginBlocked(ctx, userID, user.PhoneNumber, repository.AuthMethodTOTP, status)
+	}
+
+	step, ok := s.TOTP.Validate(enrollment.Secret, req.Code, time.Now())
+	if !ok {
+		return s.invalidSecondFactor(ctx, userID, user.PhoneNumber)
+	}
+
+	recoveryCodes := make([]string, recoveryCodeCount)
+	recoveryCodeHashes := make([]string, recoveryCodeCount)
+	for i := range recoveryCodes {
+		recoveryCodes[i], err = newRecoveryCode()
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+		recoveryCodeHashes[i] = hashRecoveryCode(recoveryCodes[i])
+	}
+
+	// the confirming step is stored as used so the same code can't complete a login
+	err = s.Repository.EnableTOTP(ctx.Request().Context(), repository.EnableTOTPInput{
+		UserID:             userID,
+		Step:               step,
+		RecoveryCodeHashes: recoveryCodeHashes,
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPAlreadyEnabled) {
+			return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+				Message: "two-factor authentication is already enabled",
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, generated.TotpRecoveryCodesResponse{
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// DisableTotp turns TOTP off and drops the recovery codes, a current TOTP or recovery code is required
+func (s *Server) DisableTotp(ctx echo.Context) error {
+	var req = generated.TotpCodeRequest{}
+	ctx.Bind(&req)
+
+	// get user id from middleware
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if req.Code == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "code is required",
+		})
+	}
+
+	user, err := s.Repository.GetUser(ctx.Request().Context(), repository.GetUserInput{
+		UserID: userID,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	// a stolen access token must not give unlimited guesses of the codes that protect the login
+	status, err := s.Lockout.Check(ctx.Request().Context(), user.PhoneNumber, ctx.RealIP())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+	if status.Blocked() {
+		return s.loginBlocked(ctx, userID, user.PhoneNumber, repository.AuthMethodTOTP, status)
+	}
+
+	_, ok, err := s.verifySecondFactor(ctx, userID, req.Code)
+	if err != nil {
+		if errors.Is(err, errMFANotEnabled) {
+			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+	if !ok {
+		return s.invalidSecondFactor(ctx, userID, user.PhoneNumber)
+	}
+
+	err = s.Repository.DisableTOTP(ctx.Request().Context(), userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// LoginMfa completes a password login of a user with TOTP enabled, the challenge given by LoginUser
+// is exchanged with a TOTP or recovery code for the tokens
+func (s *Server) LoginMfa(ctx echo.Context) error {
+	var resp generated.LoginResponse
+	var req = generated.LoginMfaRequest{}
+	ctx.Bind(&req)
+
+	if req.MfaToken == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "mfa token is required",
+		})
+	}
+
+	if req.Code == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "code is required",
+		})
+	}
+
+	challenge, err := s.Repository.GetMFAChallenge(ctx.Request().Context(), hashMFAToken(req.MfaToken))
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+				Message: errInvalidMFAToken.Error(),
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	// wrong codes count toward the lockout of the account, so it limits the guesses across every
+	// challenge of the account and not only the OTPMaxAttempts of this one
+	status, err := s.Lockout.Check(ctx.Request().Context(), challenge.PhoneNumber, ctx.RealIP())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+	if status.Blocked() {
+		return s.loginBlocked(ctx, challenge.UserID, challenge.PhoneNumber, repository.AuthMethodTOTP, status)
+	}
+
+	// the attempt is counted before checking so a challenge can only be guessed OTPMaxAttempts times
+	err = s.Repository.UseMFAChallengeAttempt(ctx.Request().Context(), repository.UseMFAChallengeAttemptInput{
+		ID:          challenge.ID,
+		MaxAttempts: s.OTPMaxAttempts,
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrOTPAttemptsExceeded) {
+			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+				Message: "too many attempts, log in again",
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	authMethod, ok, err := s.verifySecondFactor(ctx, challenge.UserID, req.Code)
+	if err != nil {
+		// TOTP was disabled since the challenge was given
+		if errors.Is(err, errMFANotEnabled) {
+			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+				Message: errInvalidMFAToken.Error(),
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+	if !ok {
+		return s.invalidSecondFactor(ctx, challenge.UserID, challenge.PhoneNumber)
+	}
+
+	err = s.Repository.ConsumeMFAChallenge(ctx.Request().Context(), challenge.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOTPAlreadyUsed) {
+			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+				Message: errInvalidMFAToken.Error(),
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	user, err := s.Repository.LoginUser(ctx.Request().Context(), repository.LoginUserInput{
+		PhoneNumber: challenge.PhoneNumber,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	// the account can be suspended while the challenge waits for the code
+	suspension, err := s.Repository.GetUserSuspension(ctx.Request().Context(), repository.GetUserSuspensionInput{
+		UserID: challenge.UserID,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+	if suspension.IsSuspended {
+		s.recordLoginAttempt(ctx, challenge.UserID, challenge.PhoneNumber, authMethod, repository.LoginFailureSuspended)
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: "account is suspended",
+		})
+	}
+
+	resp.Id = challenge.UserID
+	code, err := s.startSession(ctx, user, authMethod, &resp)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// invalidSecondFactor refuses a wrong TOTP or recovery code, the failure counts toward the lockout
+// of the account so the codes can't be guessed on another endpoint than /login/mfa. A right code
+// doesn't reset the count outside of a login, the secret being confirmed is known to the caller.
+func (s *Server) invalidSecondFactor(ctx echo.Context, userID int, phoneNumber string) error {
+	s.recordLoginAttempt(ctx, userID, phoneNumber, repository.AuthMethodTOTP, repository.LoginFailureInvalidMFACode)
+	err := s.Lockout.RecordFailure(ctx.Request().Context(), phoneNumber, ctx.RealIP())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+		Message: "invalid code",
+	})
+}
+
+// createMFAChallenge stores a new challenge of the user and returns its token
+func (s *Server) createMFAChallenge(ctx echo.Context, userID int) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	mfaToken := base64.RawURLEncoding.EncodeToString(buf)
+
+	err := s.Repository.CreateMFAChallenge(ctx.Request().Context(), repository.CreateMFAChallengeInput{
+		UserID:    userID,
+		TokenHash: hashMFAToken(mfaToken),
+		ExpiresAt: time.Now().UTC().Add(s.MFAChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return mfaToken, nil
+}
+
+// verifySecondFactor checks the code as a TOTP code first and as a recovery code otherwise, a matching
+// code is used up. It returns the auth method the code matched.
+func (s *Server) verifySecondFactor(ctx echo.Context, userID int, code string) (string, bool, error) {
+	enrollment, err := s.Repository.GetTOTP(ctx.Request().Context(), userID)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return "", false, errMFANotEnabled
+		}
+		return "", false, err
+	}
+	if enrollment.EnabledAt == nil {
+		return "", false, errMFANotEnabled
+	}
+
+	if step, ok := s.TOTP.Validate(enrollment.Secret, code, time.Now()); ok {
+		// a code is only accepted once, a replay within its period is refused
+		err = s.Repository.UseTOTPStep(ctx.Request().Context(), repository.UseTOTPStepInput{
+			UserID: userID,
+			Step:   step,
+		})
+		if err != nil {
+			if errors.Is(err, repository.ErrTOTPStepUsed) {
+				return "", false, nil
+			}
+			return "", false, err
+		}
+		return repository.AuthMethodTOTP, true, nil
+	}
+
+	err = s.Repository.UseRecoveryCode(ctx.Request().Context(), repository.UseRecoveryCodeInput{
+		UserID:   userID,
+		CodeHash: hashRecoveryCode(code),
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrRecoveryCodeInvalid) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	return repository.AuthMethodRecoveryCode, true, nil
+}
+
+// newRecoveryCode returns a random code formatted as xxxxx-xxxxx
+func newRecoveryCode() (string, error) {
+	buf := make([]byte, 5)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(buf)
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode hashes the code the way it is stored, the case and the separator don't matter.
+// The codes are random enough for a fast hash.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashMFAToken(mfaToken string) string {
+	sum := sha256.Sum256([]byte(mfaToken))
+	return hex.EncodeToString(sum[:])
+}
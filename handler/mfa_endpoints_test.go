@@ -0,0 +1,974 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/lockout"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/pkg/totp"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_EnrollTotp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockTOTP := totp.NewMockTOTPMethod(ctrl)
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{
+					UserID: 1,
+				}).Return(repository.GetUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+				}, nil)
+				mockTOTP.EXPECT().GenerateSecret().Return("SECRET", nil)
+				mockRepo.EXPECT().CreateTOTP(gomock.Any(), repository.CreateTOTPInput{
+					UserID: 1,
+					Secret: "SECRET",
+				}).Return(nil)
+				mockTOTP.EXPECT().URI("SECRET", "+628123456789").Return("otpauth://totp/uri")
+			},
+			want: want{
+				code: 200,
+				body: `{"otpauthUri":"otpauth://totp/uri","secret":"SECRET"}`,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:   "failed flow already enabled",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{UserID: 1}, nil)
+				mockTOTP.EXPECT().GenerateSecret().Return("SECRET", nil)
+				mockRepo.EXPECT().CreateTOTP(gomock.Any(), gomock.Any()).Return(repository.ErrTOTPAlreadyEnabled)
+			},
+			want: want{
+				code: 409,
+				body: `{"message":"two-factor authentication is already enabled"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository create totp",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{UserID: 1}, nil)
+				mockTOTP.EXPECT().GenerateSecret().Return("SECRET", nil)
+				mockRepo.EXPECT().CreateTOTP(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				TOTP:       mockTOTP,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/mfa/totp", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.EnrollTotp(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("EnrollTotp status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("EnrollTotp Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_ConfirmTotp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockTOTP := totp.NewMockTOTPMethod(ctrl)
+	mockLockout := lockout.NewMockLockoutMethod(ctrl)
+	enabledAt := time.Date(2024, 1, 1, 3, 4, 5, 0, time.UTC)
+	// the recovery codes are random
+	recoveryCodePattern := regexp.MustCompile(`[0-9a-f]{5}-[0-9a-f]{5}`)
+	unlocked := func() {
+		mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{UserID: 1}).Return(repository.GetUserOutput{
+			UserID:      1,
+			PhoneNumber: "+628123456789",
+		}, nil)
+		mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{
+					Secret: "SECRET",
+				}, nil)
+				unlocked()
+				mockTOTP.EXPECT().Validate("SECRET", "123456", gomock.Any()).Return(int64(42), true)
+				mockRepo.EXPECT().EnableTOTP(gomock.Any(), gomock.Cond(func(x interface{}) bool {
+					input := x.(repository.EnableTOTPInput)
+					return input.UserID == 1 && input.Step == 42 && len(input.RecoveryCodeHashes) == 10
+				})).Return(nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"recoveryCodes":["x"` + strings.Repeat(`,"x"`, 9) + `]}`,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			body:     `{"code":"123456"}`,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:     "failed flow without code",
+			userID:   1,
+			body:     `{}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"code is required"}`,
+			},
+		},
+		{
+			name:   "failed flow without enrollment",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"no pending two-factor enrollment"}`,
+			},
+		},
+		{
+			name:   "failed flow already enabled",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{
+					Secret:    "SECRET",
+					EnabledAt: &enabledAt,
+				}, nil)
+			},
+			want: want{
+				code: 409,
+				body: `{"message":"two-factor authentication is already enabled"}`,
+			},
+		},
+		{
+			name:   "failed flow wrong code",
+			userID: 1,
+			body:   `{"code":"654321"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{Secret: "SECRET"}, nil)
+				unlocked()
+				mockTOTP.EXPECT().Validate("SECRET", "654321", gomock.Any()).Return(int64(0), false)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:        1,
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodTOTP,
+					FailureReason: repository.LoginFailureInvalidMFACode,
+				}).Return(nil)
+				mockLockout.EXPECT().RecordFailure(gomock.Any(), "+628123456789", "192.0.2.1").Return(nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid code"}`,
+			},
+		},
+		{
+			name:   "failed flow locked account",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{Secret: "SECRET"}, nil)
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+				}, nil)
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{
+					Locked:     true,
+					RetryAfter: 90 * time.Second,
+				}, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:        1,
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodTOTP,
+					FailureReason: repository.LoginFailureLocked,
+				}).Return(nil)
+			},
+			want: want{
+				code: 423,
+				body: `{"message":"account is temporarily locked"}`,
+			},
+		},
+		{
+			name:   "failed flow on lockout check",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{Secret: "SECRET"}, nil)
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{UserID: 1}, nil)
+				mockLockout.EXPECT().Check(gomock.Any(), gomock.Any(), gomock.Any()).Return(lockout.Status{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository enable totp",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{Secret: "SECRET"}, nil)
+				unlocked()
+				mockTOTP.EXPECT().Validate("SECRET", "123456", gomock.Any()).Return(int64(42), true)
+				mockRepo.EXPECT().EnableTOTP(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				TOTP:       mockTOTP,
+				Lockout:    mockLockout,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/mfa/totp/confirm", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.ConfirmTotp(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("ConfirmTotp status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			body := recoveryCodePattern.ReplaceAllString(strings.ReplaceAll(string(rec.Body.Bytes()), "\n", ""), "x")
+			if !reflect.DeepEqual(tt.want.body, body) {
+				t.Fatalf("ConfirmTotp Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_DisableTotp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockTOTP := totp.NewMockTOTPMethod(ctrl)
+	mockLockout := lockout.NewMockLockoutMethod(ctrl)
+	enabledAt := time.Date(2024, 1, 1, 3, 4, 5, 0, time.UTC)
+	unlocked := func() {
+		mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{UserID: 1}).Return(repository.GetUserOutput{
+			UserID:      1,
+			PhoneNumber: "+628123456789",
+		}, nil)
+		mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+	}
+	invalidCode := func() {
+		mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+			UserID:        1,
+			PhoneNumber:   "+628123456789",
+			IPAddress:     "192.0.2.1",
+			AuthMethod:    repository.AuthMethodTOTP,
+			FailureReason: repository.LoginFailureInvalidMFACode,
+		}).Return(nil)
+		mockLockout.EXPECT().RecordFailure(gomock.Any(), "+628123456789", "192.0.2.1").Return(nil)
+	}
+	enabled := func() {
+		unlocked()
+		mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{
+			Secret:    "SECRET",
+			EnabledAt: &enabledAt,
+		}, nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow with totp code",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				enabled()
+				mockTOTP.EXPECT().Validate("SECRET", "123456", gomock.Any()).Return(int64(42), true)
+				mockRepo.EXPECT().UseTOTPStep(gomock.Any(), repository.UseTOTPStepInput{
+					UserID: 1,
+					Step:   42,
+				}).Return(nil)
+				mockRepo.EXPECT().DisableTOTP(gomock.Any(), 1).Return(nil)
+			},
+			want: want{
+				code: 204,
+				body: ``,
+			},
+		},
+		{
+			name:   "success flow with recovery code",
+			userID: 1,
+			body:   `{"code":"ABCDE-12345"}`,
+			mockFunc: func() {
+				enabled()
+				mockTOTP.EXPECT().Validate("SECRET", "ABCDE-12345", gomock.Any()).Return(int64(0), false)
+				mockRepo.EXPECT().UseRecoveryCode(gomock.Any(), repository.UseRecoveryCodeInput{
+					UserID:   1,
+					CodeHash: hashRecoveryCode("abcde12345"),
+				}).Return(nil)
+				mockRepo.EXPECT().DisableTOTP(gomock.Any(), 1).Return(nil)
+			},
+			want: want{
+				code: 204,
+				body: ``,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			body:     `{"code":"123456"}`,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:     "failed flow without code",
+			userID:   1,
+			body:     `{}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"code is required"}`,
+			},
+		},
+		{
+			name:   "failed flow not enabled",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				unlocked()
+				mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{Secret: "SECRET"}, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"two-factor authentication is not enabled"}`,
+			},
+		},
+		{
+			name:   "failed flow replayed totp code",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				enabled()
+				mockTOTP.EXPECT().Validate("SECRET", "123456", gomock.Any()).Return(int64(42), true)
+				mockRepo.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Return(repository.ErrTOTPStepUsed)
+				invalidCode()
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid code"}`,
+			},
+		},
+		{
+			name:   "failed flow wrong code",
+			userID: 1,
+			body:   `{"code":"654321"}`,
+			mockFunc: func() {
+				enabled()
+				mockTOTP.EXPECT().Validate("SECRET", "654321", gomock.Any()).Return(int64(0), false)
+				mockRepo.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Any()).Return(repository.ErrRecoveryCodeInvalid)
+				invalidCode()
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid code"}`,
+			},
+		},
+		{
+			name:   "failed flow throttled account",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+				}, nil)
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{
+					RetryAfter: 30 * time.Second,
+				}, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			want: want{
+				code: 429,
+				body: `{"message":"too many failed login attempts"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository disable totp",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				enabled()
+				mockTOTP.EXPECT().Validate("SECRET", "123456", gomock.Any()).Return(int64(42), true)
+				mockRepo.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().DisableTOTP(gomock.Any(), 1).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				TOTP:       mockTOTP,
+				Lockout:    mockLockout,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodDelete, "/mfa/totp", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.DisableTotp(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("DisableTotp status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("DisableTotp Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_LoginMfa(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockToken := token.NewMockTokenMethod(ctrl)
+	mockTOTP := totp.NewMockTOTPMethod(ctrl)
+	mockLockout := lockout.NewMockLockoutMethod(ctrl)
+	enabledAt := time.Date(2024, 1, 1, 3, 4, 5, 0, time.UTC)
+	body := `{"mfaToken":"challenge","code":"123456"}`
+	challenge := func() {
+		mockRepo.EXPECT().GetMFAChallenge(gomock.Any(), hashMFAToken("challenge")).Return(repository.GetMFAChallengeOutput{
+			ID:          3,
+			UserID:      1,
+			PhoneNumber: "+628123456789",
+		}, nil)
+		mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+		mockRepo.EXPECT().UseMFAChallengeAttempt(gomock.Any(), repository.UseMFAChallengeAttemptInput{
+			ID:          3,
+			MaxAttempts: 5,
+		}).Return(nil)
+		mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{
+			Secret:    "SECRET",
+			EnabledAt: &enabledAt,
+		}, nil)
+	}
+	session := func(authMethod string) {
+		mockRepo.EXPECT().ConsumeMFAChallenge(gomock.Any(), 3).Return(nil)
+		mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+			PhoneNumber: "+628123456789",
+		}).Return(repository.LoginUserOutput{
+			UserID:          1,
+			PhoneNumber:     "+628123456789",
+			PhoneVerifiedAt: &enabledAt,
+			MFAEnabled:      true,
+		}, nil)
+		mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+			UserID: 1,
+		}).Return(repository.GetUserSuspensionOutput{}, nil)
+		mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+		mockRepo.EXPECT().GetUserRoles(gomock.Any(), repository.GetUserRolesInput{
+			UserID: 1,
+		}).Return(repository.GetUserRolesOutput{
+			Roles: []string{"user"},
+		}, nil)
+		mockToken.EXPECT().GenerateToken(token.TokenBody{
+			UserID: 1,
+			Roles:  []string{"user"},
+		}).Return("Bearer token", nil)
+		mockToken.EXPECT().GenerateRefreshToken("").Return(token.RefreshToken{
+			Token:    "refresh",
+			Hash:     "hash",
+			FamilyID: "family",
+		}, nil)
+		mockRepo.EXPECT().CreateRefreshToken(gomock.Any(), repository.CreateRefreshTokenInput{
+			UserID:    1,
+			FamilyID:  "family",
+			TokenHash: "hash",
+		}).Return(nil)
+		mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+			UserID:      1,
+			PhoneNumber: "+628123456789",
+			IPAddress:   "192.0.2.1",
+			AuthMethod:  authMethod,
+			Success:     true,
+		}).Return(nil)
+		mockRepo.EXPECT().IncrementLoginCount(gomock.Any(), 1).Return(nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow with totp code",
+			body: body,
+			mockFunc: func() {
+				challenge()
+				mockTOTP.EXPECT().Validate("SECRET", "123456", gomock.Any()).Return(int64(42), true)
+				mockRepo.EXPECT().UseTOTPStep(gomock.Any(), repository.UseTOTPStepInput{
+					UserID: 1,
+					Step:   42,
+				}).Return(nil)
+				session(repository.AuthMethodTOTP)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":1,"jwt":"Bearer token","refreshToken":"refresh"}`,
+			},
+		},
+		{
+			name: "success flow with recovery code",
+			body: `{"mfaToken":"challenge","code":"abcde-12345"}`,
+			mockFunc: func() {
+				challenge()
+				mockTOTP.EXPECT().Validate("SECRET", "abcde-12345", gomock.Any()).Return(int64(0), false)
+				mockRepo.EXPECT().UseRecoveryCode(gomock.Any(), repository.UseRecoveryCodeInput{
+					UserID:   1,
+					CodeHash: hashRecoveryCode("abcde-12345"),
+				}).Return(nil)
+				session(repository.AuthMethodRecoveryCode)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":1,"jwt":"Bearer token","refreshToken":"refresh"}`,
+			},
+		},
+		{
+			name:     "failed flow without mfa token",
+			body:     `{"code":"123456"}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"mfa token is required"}`,
+			},
+		},
+		{
+			name:     "failed flow without code",
+			body:     `{"mfaToken":"challenge"}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"code is required"}`,
+			},
+		},
+		{
+			name: "failed flow unknown or expired challenge",
+			body: body,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetMFAChallenge(gomock.Any(), gomock.Any()).Return(repository.GetMFAChallengeOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired mfa token"}`,
+			},
+		},
+		{
+			name: "failed flow too many attempts",
+			body: body,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetMFAChallenge(gomock.Any(), gomock.Any()).Return(repository.GetMFAChallengeOutput{ID: 3, UserID: 1}, nil)
+				mockLockout.EXPECT().Check(gomock.Any(), gomock.Any(), gomock.Any()).Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().UseMFAChallengeAttempt(gomock.Any(), gomock.Any()).Return(repository.ErrOTPAttemptsExceeded)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"too many attempts, log in again"}`,
+			},
+		},
+		{
+			name: "failed flow locked account",
+			body: body,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetMFAChallenge(gomock.Any(), gomock.Any()).Return(repository.GetMFAChallengeOutput{
+					ID:          3,
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+				}, nil)
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{
+					Locked:     true,
+					RetryAfter: 90 * time.Second,
+				}, nil)
+				// the challenge tells whose account is locked
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:        1,
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodTOTP,
+					FailureReason: repository.LoginFailureLocked,
+				}).Return(nil)
+			},
+			want: want{
+				code: 423,
+				body: `{"message":"account is temporarily locked"}`,
+			},
+		},
+		{
+			name: "failed flow on lockout check",
+			body: body,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetMFAChallenge(gomock.Any(), gomock.Any()).Return(repository.GetMFAChallengeOutput{ID: 3, UserID: 1}, nil)
+				mockLockout.EXPECT().Check(gomock.Any(), gomock.Any(), gomock.Any()).Return(lockout.Status{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow wrong code",
+			body: `{"mfaToken":"challenge","code":"654321"}`,
+			mockFunc: func() {
+				challenge()
+				mockTOTP.EXPECT().Validate("SECRET", "654321", gomock.Any()).Return(int64(0), false)
+				mockRepo.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Any()).Return(repository.ErrRecoveryCodeInvalid)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:        1,
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodTOTP,
+					FailureReason: repository.LoginFailureInvalidMFACode,
+				}).Return(nil)
+				mockLockout.EXPECT().RecordFailure(gomock.Any(), "+628123456789", "192.0.2.1").Return(nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid code"}`,
+			},
+		},
+		{
+			name: "failed flow account suspended since the challenge",
+			body: body,
+			mockFunc: func() {
+				challenge()
+				mockTOTP.EXPECT().Validate("SECRET", "123456", gomock.Any()).Return(int64(42), true)
+				mockRepo.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().ConsumeMFAChallenge(gomock.Any(), 3).Return(nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+					MFAEnabled:  true,
+				}, nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 1,
+				}).Return(repository.GetUserSuspensionOutput{IsSuspended: true}, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:        1,
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodTOTP,
+					FailureReason: repository.LoginFailureSuspended,
+				}).Return(nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"account is suspended"}`,
+			},
+		},
+		{
+			name: "failed flow two-factor authentication disabled since the challenge",
+			body: body,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetMFAChallenge(gomock.Any(), gomock.Any()).Return(repository.GetMFAChallengeOutput{ID: 3, UserID: 1}, nil)
+				mockLockout.EXPECT().Check(gomock.Any(), gomock.Any(), gomock.Any()).Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().UseMFAChallengeAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired mfa token"}`,
+			},
+		},
+		{
+			name: "failed flow challenge used concurrently",
+			body: body,
+			mockFunc: func() {
+				challenge()
+				mockTOTP.EXPECT().Validate("SECRET", "123456", gomock.Any()).Return(int64(42), true)
+				mockRepo.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().ConsumeMFAChallenge(gomock.Any(), 3).Return(repository.ErrOTPAlreadyUsed)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired mfa token"}`,
+			},
+		},
+		{
+			name: "failed flow on repository get mfa challenge",
+			body: body,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetMFAChallenge(gomock.Any(), gomock.Any()).Return(repository.GetMFAChallengeOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository:     mockRepo,
+				Token:          mockToken,
+				TOTP:           mockTOTP,
+				Lockout:        mockLockout,
+				OTPMaxAttempts: 5,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/login/mfa", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+
+			handler.LoginMfa(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("LoginMfa status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("LoginMfa Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+// TestServer_LoginMfaLockout checks that a correct password doesn't forget the wrong second factor
+// codes, so logging in again can't be used to get more guesses at the code
+func TestServer_LoginMfaLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockTOTP := totp.NewMockTOTPMethod(ctrl)
+	enabledAt := time.Date(2024, 1, 1, 3, 4, 5, 0, time.UTC)
+
+	handler := NewServer(NewServerOptions{
+		Repository: mockRepo,
+		Hash:       mockHash,
+		TOTP:       mockTOTP,
+		Lockout: lockout.NewLockoutMethod(lockout.NewLockoutConfig{
+			Store:  lockout.NewMemoryStore(),
+			Window: time.Hour,
+			AccountPolicy: lockout.Policy{
+				LockoutAfter:    3,
+				LockoutDuration: time.Hour,
+			},
+		}),
+		OTPMaxAttempts:  5,
+		MFAChallengeTTL: 5 * time.Minute,
+	})
+
+	mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+		UserID:          1,
+		PhoneNumber:     "+628123456789",
+		Password:        "123456",
+		PhoneVerifiedAt: &enabledAt,
+		MFAEnabled:      true,
+	}, nil).AnyTimes()
+	mockHash.EXPECT().CompareValue(gomock.Any(), "123456", "@Password1").Return(true, nil).AnyTimes()
+	mockHash.EXPECT().NeedsRehash("123456").Return(false).AnyTimes()
+	mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, nil).AnyTimes()
+	mockRepo.EXPECT().CreateMFAChallenge(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockRepo.EXPECT().GetMFAChallenge(gomock.Any(), gomock.Any()).Return(repository.GetMFAChallengeOutput{
+		ID:          3,
+		UserID:      1,
+		PhoneNumber: "+628123456789",
+	}, nil).AnyTimes()
+	mockRepo.EXPECT().UseMFAChallengeAttempt(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{
+		Secret:    "SECRET",
+		EnabledAt: &enabledAt,
+	}, nil).AnyTimes()
+	mockTOTP.EXPECT().Validate("SECRET", "654321", gomock.Any()).Return(int64(0), false).AnyTimes()
+	mockRepo.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Any()).Return(repository.ErrRecoveryCodeInvalid).AnyTimes()
+	mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	call := func(path string, body string, endpoint func(echo.Context) error) int {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		endpoint(e.NewContext(req, rec))
+		return rec.Code
+	}
+
+	// every round logs in with the right password and then guesses a wrong code
+	for i := 0; i < 3; i++ {
+		if code := call("/login", `{"password":"@Password1","phoneNumber":"+628123456789"}`, handler.LoginUser); code != http.StatusOK {
+			t.Fatalf("round %d LoginUser status code got =%d, want %d", i, code, http.StatusOK)
+		}
+		if code := call("/login/mfa", `{"mfaToken":"challenge","code":"654321"}`, handler.LoginMfa); code != http.StatusBadRequest {
+			t.Fatalf("round %d LoginMfa status code got =%d, want %d", i, code, http.StatusBadRequest)
+		}
+	}
+
+	if code := call("/login/mfa", `{"mfaToken":"challenge","code":"654321"}`, handler.LoginMfa); code != http.StatusLocked {
+		t.Fatalf("LoginMfa status code got =%d, want %d", code, http.StatusLocked)
+	}
+	if code := call("/login", `{"password":"@Password1","phoneNumber":"+628123456789"}`, handler.LoginUser); code != http.StatusLocked {
+		t.Fatalf("LoginUser status code got =%d, want %d", code, http.StatusLocked)
+	}
+}
+
+// TestServer_DisableTotpLockout checks that the codes given to disable the second factor count
+// toward the lockout of the account, an access token alone doesn't give more guesses
+func TestServer_DisableTotpLockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockTOTP := totp.NewMockTOTPMethod(ctrl)
+	enabledAt := time.Date(2024, 1, 1, 3, 4, 5, 0, time.UTC)
+
+	handler := NewServer(NewServerOptions{
+		Repository: mockRepo,
+		TOTP:       mockTOTP,
+		Lockout: lockout.NewLockoutMethod(lockout.NewLockoutConfig{
+			Store:  lockout.NewMemoryStore(),
+			Window: time.Hour,
+			AccountPolicy: lockout.Policy{
+				LockoutAfter:    3,
+				LockoutDuration: time.Hour,
+			},
+		}),
+	})
+
+	mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{
+		UserID:      1,
+		PhoneNumber: "+628123456789",
+	}, nil).AnyTimes()
+	mockRepo.EXPECT().GetTOTP(gomock.Any(), 1).Return(repository.GetTOTPOutput{
+		Secret:    "SECRET",
+		EnabledAt: &enabledAt,
+	}, nil).AnyTimes()
+	mockTOTP.EXPECT().Validate("SECRET", "654321", gomock.Any()).Return(int64(0), false).AnyTimes()
+	mockRepo.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Any()).Return(repository.ErrRecoveryCodeInvalid).AnyTimes()
+	mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	call := func() int {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodDelete, "/mfa/totp", strings.NewReader(`{"code":"654321"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		ctx := e.NewContext(req, rec)
+		ctx.Set("user_id", 1)
+		handler.DisableTotp(ctx)
+		return rec.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := call(); code != http.StatusBadRequest {
+			t.Fatalf("round %d DisableTotp status code got =%d, want %d", i, code, http.StatusBadRequest)
+		}
+	}
+
+	if code := call(); code != http.StatusLocked {
+		t.Fatalf("DisableTotp status code got =%d, want %d", code, http.StatusLocked)
+	}
+}
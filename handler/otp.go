@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// otpDigits is the length of the one-time codes sent by sms
+const otpDigits = 6
+
+// errInvalidOTP is the only error a caller gets for a wrong, expired or used code
+var errInvalidOTP = errors.New("invalid or expired code")
+
+// issueOTP sends a new one-time code for the purpose to the user, it replaces the previous code
+// of that purpose. The message must hold a %s for the code.
+func (s *Server) issueOTP(ctx context.Context, userID int, phoneNumber string, purpose string, message string) error {
+	code, err := newOTPCode()
+	if err != nil {
+		return err
+	}
+
+	// only the hash is stored so a database leak doesn't leak usable codes
+	codeHash, err := s.Hash.HashValue(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repository.CreateOTP(ctx, repository.CreateOTPInput{
+		UserID:      userID,
+		Purpose:     purpose,
+		PhoneNumber: phoneNumber,
+		CodeHash:    string(codeHash),
+		ExpiresAt:   time.Now().UTC().Add(s.OTPTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.SMS.Send(ctx, phoneNumber, fmt.Sprintf(message, code))
+}
+
+// issueOTPInBackground issues the code after the response is sent, for the requests answering the
+// same whether the phone number is registered or not. Hashing the code and sending it would
+// otherwise make the answer to a registered number slower and tell that an account exists.
+func (s *Server) issueOTPInBackground(userID int, phoneNumber string, purpose string, message string) {
+	s.background.Add(1)
+	go func() {
+		defer s.background.Done()
+		err := s.issueOTP(s.backgroundCtx, userID, phoneNumber, purpose, message)
+		if err != nil {
+			fmt.Println("failed issue otp", purpose, "err:", err)
+		}
+	}()
+}
+
+// verifyOTP uses up the one-time code of the user for the purpose and returns it, it returns
+// the status code to respond with when the code can't be used
+func (s *Server) verifyOTP(ctx echo.Context, userID int, purpose string, code string) (repository.GetOTPOutput, int, error) {
+	otp, err := s.Repository.GetOTP(ctx.Request().Context(), repository.GetOTPInput{
+		UserID:  userID,
+		Purpose: purpose,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return repository.GetOTPOutput{}, http.StatusBadRequest, errInvalidOTP
+		}
+		return repository.GetOTPOutput{}, http.StatusInternalServerError, err
+	}
+
+	// the attempt is counted before comparing so a code can only be guessed OTPMaxAttempts times
+	err = s.Repository.UseOTPAttempt(ctx.Request().Context(), repository.UseOTPAttemptInput{
+		ID:          otp.ID,
+		MaxAttempts: s.OTPMaxAttempts,
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrOTPAttemptsExceeded) {
+			return repository.GetOTPOutput{}, http.StatusBadRequest, fmt.Errorf("too many attempts, request a new code")
+		}
+		return repository.GetOTPOutput{}, http.StatusInternalServerError, err
+	}
+
+	match, err := s.Hash.CompareValue(ctx.Request().Context(), otp.CodeHash, code)
+	if err != nil {
+		return repository.GetOTPOutput{}, errorStatus(ctx, err), err
+	}
+	if !match {
+		return repository.GetOTPOutput{}, http.StatusBadRequest, errInvalidOTP
+	}
+
+	err = s.Repository.ConsumeOTP(ctx.Request().Context(), otp.ID)
+	if err != nil {
+		if errors.Is(err, repository.ErrOTPAlreadyUsed) {
+			return repository.GetOTPOutput{}, http.StatusBadRequest, errInvalidOTP
+		}
+		return repository.GetOTPOutput{}, http.StatusInternalServerError, err
+	}
+
+	return otp, http.StatusOK, nil
+}
+
+func newOTPCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < otpDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", otpDigits, n), nil
+}
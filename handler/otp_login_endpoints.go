@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// RequestLoginOtp sends a login code to the phone number. It answers the same
+// whether the phone number is registered or not, so it can't be used to find accounts.
+func (s *Server) RequestLoginOtp(ctx echo.Context) error {
+	var req = generated.LoginOtpRequest{}
+	ctx.Bind(&req)
+
+	if req.PhoneNumber == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "phone number is required",
+		})
+	}
+
+	result, err := s.Repository.LoginUser(ctx.Request().Context(), repository.LoginUserInput{
+		PhoneNumber: req.PhoneNumber,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.NoContent(http.StatusAccepted)
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	s.issueOTPInBackground(result.UserID, result.PhoneNumber, repository.OTPPurposeLogin,
+		"Your login code is %s. Do not share it with anyone.")
+
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// VerifyLoginOtp logs in with a code sent by RequestLoginOtp instead of the password, the code
+// is used up so it can't be replayed. Wrong codes count toward the lockout like wrong passwords.
+func (s *Server) VerifyLoginOtp(ctx echo.Context) error {
+	var req = generated.LoginOtpVerifyRequest{}
+	ctx.Bind(&req)
+
+	if req.PhoneNumber == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "phone number is required",
+		})
+	}
+
+	if req.Code == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "code is required",
+		})
+	}
+
+	status, err := s.Lockout.Check(ctx.Request().Context(), req.PhoneNumber, ctx.RealIP())
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+	if status.Blocked() {
+		return s.loginBlocked(ctx, 0, req.PhoneNumber, repository.AuthMethodSMSOTP, status)
+	}
+
+	result, err := s.Repository.LoginUser(ctx.Request().Context(), repository.LoginUserInput{
+		PhoneNumber: req.PhoneNumber,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			s.recordPhoneNumberAttempt(ctx, req.PhoneNumber, repository.AuthMethodSMSOTP, repository.LoginFailureUnknownUser)
+			err = s.Lockout.RecordFailure(ctx.Request().Context(), req.PhoneNumber, ctx.RealIP())
+			if err != nil {
+				return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+					Message: err.Error(),
+				})
+			}
+			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+				Message: errInvalidOTP.Error(),
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	_, code, err := s.verifyOTP(ctx, result.UserID, repository.OTPPurposeLogin, req.Code)
+	if err != nil {
+		if code != http.StatusBadRequest {
+			return ctx.JSON(code, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+		s.recordLoginAttempt(ctx, result.UserID, req.PhoneNumber, repository.AuthMethodSMSOTP, repository.LoginFailureInvalidOTP)
+		lockoutErr := s.Lockout.RecordFailure(ctx.Request().Context(), req.PhoneNumber, ctx.RealIP())
+		if lockoutErr != nil {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: lockoutErr.Error(),
+			})
+		}
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return s.completeLogin(ctx, result, repository.AuthMethodSMSOTP)
+}
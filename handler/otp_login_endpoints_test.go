@@ -0,0 +1,403 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/lockout"
+	"github.com/SawitProRecruitment/UserService/pkg/sms"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_RequestLoginOtp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockSMS := sms.NewMockSender(ctrl)
+	user := repository.LoginUserOutput{
+		UserID:      1,
+		PhoneNumber: "+628123456789",
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow",
+			body: `{"phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628123456789",
+				}).Return(user, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Cond(func(x interface{}) bool {
+					input := x.(repository.CreateOTPInput)
+					return input.UserID == 1 && input.Purpose == repository.OTPPurposeLogin &&
+						input.PhoneNumber == "+628123456789" && input.CodeHash == "hash"
+				})).Return(nil)
+				mockSMS.EXPECT().Send(gomock.Any(), "+628123456789", gomock.Any()).Return(nil)
+			},
+			want: want{
+				code: 202,
+				body: ``,
+			},
+		},
+		{
+			name: "success flow unknown phone number",
+			body: `{"phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 202,
+				body: ``,
+			},
+		},
+		{
+			name:     "failed flow without phone number",
+			body:     `{}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"phone number is required"}`,
+			},
+		},
+		{
+			name: "failed flow on repository login user",
+			body: `{"phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow on sms send answers like success",
+			body: `{"phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(user, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Any()).Return(nil)
+				mockSMS.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 202,
+				body: ``,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Hash:       mockHash,
+				SMS:        mockSMS,
+				OTPTTL:     10 * time.Minute,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/login/otp/request", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+
+			handler.RequestLoginOtp(ctx)
+			handler.background.Wait()
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("RequestLoginOtp status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("RequestLoginOtp Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_VerifyLoginOtp(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockToken := token.NewMockTokenMethod(ctrl)
+	mockLockout := lockout.NewMockLockoutMethod(ctrl)
+	phoneVerifiedAt := time.Date(2024, 1, 1, 3, 4, 5, 0, time.UTC)
+	body := `{"phoneNumber":"+628123456789","code":"123456"}`
+	user := repository.LoginUserOutput{
+		UserID:          1,
+		PhoneNumber:     "+628123456789",
+		PhoneVerifiedAt: &phoneVerifiedAt,
+	}
+	knownUser := func(user repository.LoginUserOutput) {
+		mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{}, nil)
+		mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+			PhoneNumber: "+628123456789",
+		}).Return(user, nil)
+	}
+	validCode := func() {
+		mockRepo.EXPECT().GetOTP(gomock.Any(), repository.GetOTPInput{
+			UserID:  1,
+			Purpose: repository.OTPPurposeLogin,
+		}).Return(repository.GetOTPOutput{
+			ID:       3,
+			CodeHash: "code hash",
+		}, nil)
+		mockRepo.EXPECT().UseOTPAttempt(gomock.Any(), repository.UseOTPAttemptInput{
+			ID:          3,
+			MaxAttempts: 5,
+		}).Return(nil)
+		mockHash.EXPECT().CompareValue(gomock.Any(), "code hash", "123456").Return(true, nil)
+		mockRepo.EXPECT().ConsumeOTP(gomock.Any(), 3).Return(nil)
+		mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+			UserID: 1,
+		}).Return(repository.GetUserSuspensionOutput{}, nil)
+	}
+	type want struct {
+		body       string
+		code       int
+		retryAfter string
+	}
+	tests := []struct {
+		name     string
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow",
+			body: body,
+			mockFunc: func() {
+				knownUser(user)
+				validCode()
+				mockLockout.EXPECT().RecordSuccess(gomock.Any(), "+628123456789").Return(nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), gomock.Any()).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockToken.EXPECT().GenerateToken(token.TokenBody{
+					UserID: 1,
+					Roles:  []string{"user"},
+				}).Return("Bearer token", nil)
+				mockToken.EXPECT().GenerateRefreshToken("").Return(token.RefreshToken{
+					Token:    "refresh",
+					Hash:     "hash",
+					FamilyID: "family",
+				}, nil)
+				mockRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+					IPAddress:   "192.0.2.1",
+					AuthMethod:  repository.AuthMethodSMSOTP,
+					Success:     true,
+				}).Return(nil)
+				mockRepo.EXPECT().IncrementLoginCount(gomock.Any(), 1).Return(nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":1,"jwt":"Bearer token","refreshToken":"refresh"}`,
+			},
+		},
+		{
+			name: "success flow with two-factor authentication returns a challenge",
+			body: body,
+			mockFunc: func() {
+				mfaUser := user
+				mfaUser.MFAEnabled = true
+				knownUser(mfaUser)
+				validCode()
+				mockRepo.EXPECT().CreateMFAChallenge(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":1,"mfaRequired":true,"mfaToken":"*"}`,
+			},
+		},
+		{
+			name:     "failed flow without phone number",
+			body:     `{"code":"123456"}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"phone number is required"}`,
+			},
+		},
+		{
+			name:     "failed flow without code",
+			body:     `{"phoneNumber":"+628123456789"}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"code is required"}`,
+			},
+		},
+		{
+			name: "failed flow locked account",
+			body: body,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), "+628123456789", "192.0.2.1").Return(lockout.Status{
+					Locked:     true,
+					RetryAfter: 90 * time.Second,
+				}, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodSMSOTP,
+					FailureReason: repository.LoginFailureLocked,
+				}).Return(nil)
+			},
+			want: want{
+				code:       423,
+				body:       `{"message":"account is temporarily locked"}`,
+				retryAfter: "90",
+			},
+		},
+		{
+			name: "failed flow unknown phone number",
+			body: body,
+			mockFunc: func() {
+				mockLockout.EXPECT().Check(gomock.Any(), gomock.Any(), gomock.Any()).Return(lockout.Status{}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{}, fmt.Errorf("sql: no rows in result set"))
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodSMSOTP,
+					FailureReason: repository.LoginFailureUnknownUser,
+				}).Return(nil)
+				mockLockout.EXPECT().RecordFailure(gomock.Any(), "+628123456789", "192.0.2.1").Return(nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired code"}`,
+			},
+		},
+		{
+			name: "failed flow wrong code",
+			body: `{"phoneNumber":"+628123456789","code":"654321"}`,
+			mockFunc: func() {
+				knownUser(user)
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(repository.GetOTPOutput{ID: 3, CodeHash: "code hash"}, nil)
+				mockRepo.EXPECT().UseOTPAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "code hash", "654321").Return(false, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:        1,
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodSMSOTP,
+					FailureReason: repository.LoginFailureInvalidOTP,
+				}).Return(nil)
+				mockLockout.EXPECT().RecordFailure(gomock.Any(), "+628123456789", "192.0.2.1").Return(nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired code"}`,
+			},
+		},
+		{
+			name: "failed flow replayed code",
+			body: body,
+			mockFunc: func() {
+				knownUser(user)
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(repository.GetOTPOutput{ID: 3, CodeHash: "code hash"}, nil)
+				mockRepo.EXPECT().UseOTPAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "code hash", "123456").Return(true, nil)
+				mockRepo.EXPECT().ConsumeOTP(gomock.Any(), 3).Return(repository.ErrOTPAlreadyUsed)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockLockout.EXPECT().RecordFailure(gomock.Any(), "+628123456789", "192.0.2.1").Return(nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired code"}`,
+			},
+		},
+		{
+			name: "failed flow suspended account",
+			body: body,
+			mockFunc: func() {
+				knownUser(user)
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(repository.GetOTPOutput{ID: 3, CodeHash: "code hash"}, nil)
+				mockRepo.EXPECT().UseOTPAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "code hash", "123456").Return(true, nil)
+				mockRepo.EXPECT().ConsumeOTP(gomock.Any(), 3).Return(nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{
+					IsSuspended: true,
+				}, nil)
+				mockRepo.EXPECT().RecordLoginAttempt(gomock.Any(), repository.RecordLoginAttemptInput{
+					UserID:        1,
+					PhoneNumber:   "+628123456789",
+					IPAddress:     "192.0.2.1",
+					AuthMethod:    repository.AuthMethodSMSOTP,
+					FailureReason: repository.LoginFailureSuspended,
+				}).Return(nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"account is suspended"}`,
+			},
+		},
+		{
+			name: "failed flow on repository get otp",
+			body: body,
+			mockFunc: func() {
+				knownUser(user)
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(repository.GetOTPOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository:      mockRepo,
+				Hash:            mockHash,
+				Token:           mockToken,
+				Lockout:         mockLockout,
+				OTPMaxAttempts:  5,
+				MFAChallengeTTL: 5 * time.Minute,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/login/otp/verify", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+
+			handler.VerifyLoginOtp(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("VerifyLoginOtp status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			// the mfa token is random
+			body := mfaTokenPattern.ReplaceAllString(strings.ReplaceAll(string(rec.Body.Bytes()), "\n", ""), `"mfaToken":"*"`)
+			if !reflect.DeepEqual(tt.want.body, body) {
+				t.Fatalf("VerifyLoginOtp Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+
+			if got := rec.Header().Get("Retry-After"); got != tt.want.retryAfter {
+				t.Fatalf("VerifyLoginOtp Retry-After got =%s, want %s \n", got, tt.want.retryAfter)
+			}
+		})
+	}
+}
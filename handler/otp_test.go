@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNewOTPCode(t *testing.T) {
+	digits := regexp.MustCompile(`^[0-9]{6}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		code, err := newOTPCode()
+		if err != nil {
+			t.Fatalf("newOTPCode() error = %v", err)
+		}
+		if !digits.MatchString(code) {
+			t.Fatalf("newOTPCode() = %s, want 6 digits", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("newOTPCode() returned the same code every time")
+	}
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/policy"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// ChangeMyPassword sets a new password once the current one is checked. Every access token is revoked
+// and every session but the one of the refresh token sent in the request is ended.
+func (s *Server) ChangeMyPassword(ctx echo.Context) error {
+	var req = generated.ChangePasswordRequest{}
+	ctx.Bind(&req)
+
+	// get user id from middleware
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if req.CurrentPassword == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "current password is required",
+		})
+	}
+
+	user, err := s.Repository.GetUser(ctx.Request().Context(), repository.GetUserInput{
+		UserID: userID,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	credential, err := s.Repository.LoginUser(ctx.Request().Context(), repository.LoginUserInput{
+		PhoneNumber: user.PhoneNumber,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	match, err := s.Hash.CompareValue(ctx.Request().Context(), credential.Password, req.CurrentPassword)
+	if err != nil {
+		return serverError(ctx, err)
+	}
+	if !match {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "current password is incorrect",
+		})
+	}
+
+	// the new password is only checked once the current one is, so a stolen access token
+	// can't be used to probe the password policy
+	violations := s.Policy.Validate(req.NewPassword, policy.User{
+		FullName:    user.FullName,
+		PhoneNumber: user.PhoneNumber,
+	})
+	if len(violations) > 0 {
+		return passwordPolicyError(ctx, violations)
+	}
+
+	code, err := s.checkBreachedPassword(ctx, req.NewPassword)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	reused, err := s.isRecentPassword(ctx, userID, credential.Password, req.NewPassword)
+	if err != nil {
+		return serverError(ctx, err)
+	}
+	if reused {
+		return passwordPolicyError(ctx, []policy.Violation{policy.RecentlyUsed()})
+	}
+
+	hashPassword, err := s.Hash.HashValue(ctx.Request().Context(), req.NewPassword)
+	if err != nil {
+		return serverError(ctx, err)
+	}
+
+	err = s.Repository.ChangePassword(ctx.Request().Context(), repository.ChangePasswordInput{
+		UserID:      userID,
+		Password:    string(hashPassword),
+		HistorySize: s.PasswordHistorySize,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	// the session of the caller is kept when it proves it owns the refresh token
+	keepFamilyID := ""
+	if req.RefreshToken != nil && *req.RefreshToken != "" {
+		stored, err := s.Repository.GetRefreshToken(ctx.Request().Context(), repository.GetRefreshTokenInput{
+			TokenHash: s.Token.HashRefreshToken(*req.RefreshToken),
+		})
+		if err != nil && !strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+		if err == nil && stored.UserID == userID {
+			keepFamilyID = stored.FamilyID
+		}
+	}
+
+	// access tokens can only be revoked all at once, the client gets a new one with its refresh token
+	err = s.Revocation.RevokeAllTokens(ctx.Request().Context(), userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Repository.RevokeOtherRefreshTokens(ctx.Request().Context(), repository.RevokeOtherRefreshTokensInput{
+		UserID:       userID,
+		KeepFamilyID: keepFamilyID,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// isRecentPassword tells whether the password is the current password of the user
+// or one of the last PasswordHistorySize passwords
+func (s *Server) isRecentPassword(ctx echo.Context, userID int, currentHash string, password string) (bool, error) {
+	match, err := s.matchesStoredPassword(ctx, currentHash, password)
+	if err != nil || match {
+		return match, err
+	}
+
+	if s.PasswordHistorySize <= 0 {
+		return false, nil
+	}
+
+	history, err := s.Repository.GetPasswordHistory(ctx.Request().Context(), repository.GetPasswordHistoryInput{
+		UserID: userID,
+		Limit:  s.PasswordHistorySize,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, previous := range history.Passwords {
+		match, err := s.matchesStoredPassword(ctx, previous, password)
+		if err != nil || match {
+			return match, err
+		}
+	}
+	return false, nil
+}
+
+// matchesStoredPassword compares the password with a stored hash for the reuse check. A hash made
+// with a retired pepper can't be checked anymore, it must not keep the user from setting a new
+// password. Checking the current password at the login and in ChangeMyPassword still fails on it.
+func (s *Server) matchesStoredPassword(ctx echo.Context, hashed string, password string) (bool, error) {
+	match, err := s.Hash.CompareValue(ctx.Request().Context(), hashed, password)
+	var unknownPepper *hash.UnknownPepperError
+	if errors.As(err, &unknownPepper) {
+		return false, nil
+	}
+	return match, err
+}
+
+// rehashPassword stores the password hashed with the current algorithm and cost, a failure
+// only delays the upgrade to the next login
+func (s *Server) rehashPassword(ctx echo.Context, user repository.LoginUserOutput, password string) {
+	hashPassword, err := s.Hash.HashValue(ctx.Request().Context(), password)
+	if err != nil {
+		fmt.Println("failed rehash password user", user.UserID, "err:", err)
+		return
+	}
+
+	err = s.Repository.RehashPassword(ctx.Request().Context(), repository.RehashPasswordInput{
+		UserID:      user.UserID,
+		OldPassword: user.Password,
+		Password:    string(hashPassword),
+	})
+	if err != nil {
+		fmt.Println("failed rehash password user", user.UserID, "err:", err)
+	}
+}
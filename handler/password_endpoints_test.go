@@ -0,0 +1,400 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/pkg/breach"
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/policy"
+	"github.com/SawitProRecruitment/UserService/pkg/revocation"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_ChangeMyPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockBreach := breach.NewMockChecker(ctrl)
+	mockPolicy := policy.NewMockPolicyMethod(ctrl)
+	mockToken := token.NewMockTokenMethod(ctrl)
+	mockRevocation := revocation.NewMockRevocationMethod(ctrl)
+	body := `{"currentPassword":"@Password1","newPassword":"@Password2"}`
+	verifiedUser := func() {
+		mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{
+			UserID: 1,
+		}).Return(repository.GetUserOutput{
+			UserID:      1,
+			FullName:    "testing",
+			PhoneNumber: "+628123456789",
+		}, nil)
+		mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+			PhoneNumber: "+628123456789",
+		}).Return(repository.LoginUserOutput{
+			UserID:   1,
+			Password: "current hash",
+		}, nil)
+		mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password1").Return(true, nil)
+	}
+	currentPassword := func() {
+		verifiedUser()
+		mockPolicy.EXPECT().Validate("@Password2", policy.User{
+			FullName:    "testing",
+			PhoneNumber: "+628123456789",
+		}).Return(nil)
+		mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(false, nil)
+	}
+	newPassword := func() {
+		currentPassword()
+		mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(false, nil)
+		mockRepo.EXPECT().GetPasswordHistory(gomock.Any(), repository.GetPasswordHistoryInput{
+			UserID: 1,
+			Limit:  2,
+		}).Return(repository.GetPasswordHistoryOutput{
+			Passwords: []string{"previous hash"},
+		}, nil)
+		mockHash.EXPECT().CompareValue(gomock.Any(), "previous hash", "@Password2").Return(false, nil)
+		mockHash.EXPECT().HashValue(gomock.Any(), "@Password2").Return([]byte("new hash"), nil)
+		mockRepo.EXPECT().ChangePassword(gomock.Any(), repository.ChangePasswordInput{
+			UserID:      1,
+			Password:    "new hash",
+			HistorySize: 2,
+		}).Return(nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				newPassword()
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeOtherRefreshTokens(gomock.Any(), repository.RevokeOtherRefreshTokensInput{
+					UserID: 1,
+				}).Return(nil)
+			},
+			want: want{
+				code: 204,
+				body: ``,
+			},
+		},
+		{
+			name:   "success flow previous password with a retired pepper",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				currentPassword()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(false, nil)
+				mockRepo.EXPECT().GetPasswordHistory(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryOutput{
+					Passwords: []string{"retired hash", "previous hash"},
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "retired hash", "@Password2").Return(false, &hash.UnknownPepperError{Version: "v0"})
+				mockHash.EXPECT().CompareValue(gomock.Any(), "previous hash", "@Password2").Return(false, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password2").Return([]byte("new hash"), nil)
+				mockRepo.EXPECT().ChangePassword(gomock.Any(), gomock.Any()).Return(nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeOtherRefreshTokens(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			want: want{
+				code: 204,
+				body: ``,
+			},
+		},
+		{
+			name:   "failed flow current password with a retired pepper",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+				}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{
+					UserID:   1,
+					Password: "retired hash",
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "retired hash", "@Password1").Return(false, &hash.UnknownPepperError{Version: "v0"})
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"unknown pepper version v0"}`,
+			},
+		},
+		{
+			name:   "success flow keeps the session of the refresh token",
+			userID: 1,
+			body:   `{"currentPassword":"@Password1","newPassword":"@Password2","refreshToken":"refresh"}`,
+			mockFunc: func() {
+				newPassword()
+				mockToken.EXPECT().HashRefreshToken("refresh").Return("refresh hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), repository.GetRefreshTokenInput{
+					TokenHash: "refresh hash",
+				}).Return(repository.GetRefreshTokenOutput{
+					UserID:   1,
+					FamilyID: "family",
+				}, nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeOtherRefreshTokens(gomock.Any(), repository.RevokeOtherRefreshTokensInput{
+					UserID:       1,
+					KeepFamilyID: "family",
+				}).Return(nil)
+			},
+			want: want{
+				code: 204,
+				body: ``,
+			},
+		},
+		{
+			name:   "success flow refresh token of another user is not kept",
+			userID: 1,
+			body:   `{"currentPassword":"@Password1","newPassword":"@Password2","refreshToken":"refresh"}`,
+			mockFunc: func() {
+				newPassword()
+				mockToken.EXPECT().HashRefreshToken("refresh").Return("refresh hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					UserID:   2,
+					FamilyID: "family",
+				}, nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeOtherRefreshTokens(gomock.Any(), repository.RevokeOtherRefreshTokensInput{
+					UserID: 1,
+				}).Return(nil)
+			},
+			want: want{
+				code: 204,
+				body: ``,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			body:     body,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:     "failed flow without current password",
+			userID:   1,
+			body:     `{"newPassword":"@Password2"}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"current password is required"}`,
+			},
+		},
+		{
+			name:   "failed flow invalid new password",
+			userID: 1,
+			body:   `{"currentPassword":"@Password1","newPassword":"@password"}`,
+			mockFunc: func() {
+				verifiedUser()
+				mockPolicy.EXPECT().Validate("@password", policy.User{
+					FullName:    "testing",
+					PhoneNumber: "+628123456789",
+				}).Return([]policy.Violation{
+					{Code: policy.CodeMissingUppercase, Message: "password must contain an uppercase letter"},
+					{Code: policy.CodeMissingDigit, Message: "password must contain a digit"},
+				})
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"password does not meet the password policy","violations":[{"code":"missing_uppercase","message":"password must contain an uppercase letter"},{"code":"missing_digit","message":"password must contain a digit"}]}`,
+			},
+		},
+		{
+			name:   "failed flow breached new password",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				verifiedUser()
+				mockPolicy.EXPECT().Validate("@Password2", gomock.Any()).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(true, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"password has appeared in a data breach and is unsafe to use, choose a different password"}`,
+			},
+		},
+		{
+			name:   "failed flow while checking breached password",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				verifiedUser()
+				mockPolicy.EXPECT().Validate("@Password2", gomock.Any()).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(false, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow wrong current password",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{PhoneNumber: "+628123456789"}, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{Password: "current hash"}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password1").Return(false, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"current password is incorrect"}`,
+			},
+		},
+		{
+			name:   "failed flow new password is the current password",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				currentPassword()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(true, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"password does not meet the password policy","violations":[{"code":"recently_used","message":"new password must differ from the recently used passwords"}]}`,
+			},
+		},
+		{
+			name:   "failed flow new password is a previous password",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				currentPassword()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(false, nil)
+				mockRepo.EXPECT().GetPasswordHistory(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryOutput{
+					Passwords: []string{"previous hash", "older hash"},
+				}, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "previous hash", "@Password2").Return(false, nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "older hash", "@Password2").Return(true, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"password does not meet the password policy","violations":[{"code":"recently_used","message":"new password must differ from the recently used passwords"}]}`,
+			},
+		},
+		{
+			name:   "failed flow on repository get user",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository get password history",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				currentPassword()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(false, nil)
+				mockRepo.EXPECT().GetPasswordHistory(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository change password",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				currentPassword()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(false, nil)
+				mockRepo.EXPECT().GetPasswordHistory(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryOutput{}, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password2").Return([]byte("new hash"), nil)
+				mockRepo.EXPECT().ChangePassword(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on revocation revoke all tokens",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				newPassword()
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository revoke other refresh tokens",
+			userID: 1,
+			body:   body,
+			mockFunc: func() {
+				newPassword()
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeOtherRefreshTokens(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository:          mockRepo,
+				Hash:                mockHash,
+				Token:               mockToken,
+				Revocation:          mockRevocation,
+				Breach:              mockBreach,
+				Policy:              mockPolicy,
+				PasswordHistorySize: 2,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPut, "/my-profile/password", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.ChangeMyPassword(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("ChangeMyPassword status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("ChangeMyPassword Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
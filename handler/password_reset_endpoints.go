@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/policy"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// ForgotPassword sends a password reset code to the phone number. It answers the same
+// whether the phone number is registered or not, so it can't be used to find accounts.
+func (s *Server) ForgotPassword(ctx echo.Context) error {
+	var req = generated.PasswordForgotRequest{}
+	ctx.Bind(&req)
+
+	if req.PhoneNumber == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "phone number is required",
+		})
+	}
+
+	result, err := s.Repository.LoginUser(ctx.Request().Context(), repository.LoginUserInput{
+		PhoneNumber: req.PhoneNumber,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.NoContent(http.StatusAccepted)
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	s.issueOTPInBackground(result.UserID, result.PhoneNumber, repository.OTPPurposePasswordReset,
+		"Your password reset code is %s. Do not share it with anyone.")
+
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+// ResetPassword sets a new password with a code sent by ForgotPassword,
+// every session of the user is ended since the old password may be known to someone else.
+func (s *Server) ResetPassword(ctx echo.Context) error {
+	var req = generated.PasswordResetRequest{}
+	ctx.Bind(&req)
+
+	if req.PhoneNumber == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "phone number is required",
+		})
+	}
+
+	if req.Code == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "code is required",
+		})
+	}
+
+	// the name of the user isn't known before the code is checked, looking it up would tell
+	// whether the phone number is registered
+	violations := s.Policy.Validate(req.NewPassword, policy.User{
+		PhoneNumber: req.PhoneNumber,
+	})
+	if len(violations) > 0 {
+		return passwordPolicyError(ctx, violations)
+	}
+
+	code, err := s.checkBreachedPassword(ctx, req.NewPassword)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	result, err := s.Repository.LoginUser(ctx.Request().Context(), repository.LoginUserInput{
+		PhoneNumber: req.PhoneNumber,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+				Message: errInvalidOTP.Error(),
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	_, code, err = s.verifyOTP(ctx, result.UserID, repository.OTPPurposePasswordReset, req.Code)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	// checked once the code is, comparing earlier would tell anyone knowing the phone number
+	// whether a password was used on the account
+	reused, err := s.isRecentPassword(ctx, result.UserID, result.Password, req.NewPassword)
+	if err != nil {
+		return serverError(ctx, err)
+	}
+	if reused {
+		return passwordPolicyError(ctx, []policy.Violation{policy.RecentlyUsed()})
+	}
+
+	hashPassword, err := s.Hash.HashValue(ctx.Request().Context(), req.NewPassword)
+	if err != nil {
+		return serverError(ctx, err)
+	}
+
+	err = s.Repository.ChangePassword(ctx.Request().Context(), repository.ChangePasswordInput{
+		UserID:      result.UserID,
+		Password:    string(hashPassword),
+		HistorySize: s.PasswordHistorySize,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Revocation.RevokeAllTokens(ctx.Request().Context(), result.UserID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Repository.RevokeUserRefreshTokens(ctx.Request().Context(), result.UserID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	// the code proves the caller owns the phone number, so a lockout no longer applies
+	err = s.Lockout.Unlock(ctx.Request().Context(), result.PhoneNumber)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,489 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/breach"
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/lockout"
+	"github.com/SawitProRecruitment/UserService/pkg/policy"
+	"github.com/SawitProRecruitment/UserService/pkg/revocation"
+	"github.com/SawitProRecruitment/UserService/pkg/sms"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_ForgotPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockSMS := sms.NewMockSender(ctrl)
+	user := repository.LoginUserOutput{
+		UserID:      1,
+		PhoneNumber: "+628123456789",
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow",
+			body: `{"phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+					PhoneNumber: "+628123456789",
+				}).Return(user, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Cond(func(x interface{}) bool {
+					input := x.(repository.CreateOTPInput)
+					return input.UserID == 1 && input.Purpose == repository.OTPPurposePasswordReset &&
+						input.CodeHash == "hash" && time.Until(input.ExpiresAt) > 9*time.Minute
+				})).Return(nil)
+				mockSMS.EXPECT().Send(gomock.Any(), "+628123456789", gomock.Any()).Return(nil)
+			},
+			want: want{
+				code: 202,
+				body: ``,
+			},
+		},
+		{
+			name: "success flow unknown phone number",
+			body: `{"phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 202,
+				body: ``,
+			},
+		},
+		{
+			name:     "failed flow without phone number",
+			body:     `{}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"phone number is required"}`,
+			},
+		},
+		{
+			name: "failed flow on repository login user",
+			body: `{"phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow on repository create otp answers like success",
+			body: `{"phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(user, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 202,
+				body: ``,
+			},
+		},
+		{
+			name: "failed flow on sms send answers like success",
+			body: `{"phoneNumber":"+628123456789"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(user, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Any()).Return(nil)
+				mockSMS.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 202,
+				body: ``,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Hash:       mockHash,
+				SMS:        mockSMS,
+				OTPTTL:     10 * time.Minute,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+
+			handler.ForgotPassword(ctx)
+			handler.background.Wait()
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("ForgotPassword status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("ForgotPassword Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_ResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockBreach := breach.NewMockChecker(ctrl)
+	mockPolicy := policy.NewMockPolicyMethod(ctrl)
+	mockRevocation := revocation.NewMockRevocationMethod(ctrl)
+	mockLockout := lockout.NewMockLockoutMethod(ctrl)
+	user := repository.LoginUserOutput{
+		UserID:      1,
+		PhoneNumber: "+628123456789",
+		Password:    "current hash",
+	}
+	otp := repository.GetOTPOutput{
+		ID:       3,
+		CodeHash: "code hash",
+	}
+	// the name of the user isn't looked up before the code is checked
+	resetUser := policy.User{
+		PhoneNumber: "+628123456789",
+	}
+	body := `{"phoneNumber":"+628123456789","code":"123456","newPassword":"@Password2"}`
+	validCode := func() {
+		mockPolicy.EXPECT().Validate("@Password2", resetUser).Return(nil)
+		mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(false, nil)
+		mockRepo.EXPECT().LoginUser(gomock.Any(), repository.LoginUserInput{
+			PhoneNumber: "+628123456789",
+		}).Return(user, nil)
+		mockRepo.EXPECT().GetOTP(gomock.Any(), repository.GetOTPInput{
+			UserID:  1,
+			Purpose: repository.OTPPurposePasswordReset,
+		}).Return(otp, nil)
+		mockRepo.EXPECT().UseOTPAttempt(gomock.Any(), repository.UseOTPAttemptInput{
+			ID:          3,
+			MaxAttempts: 5,
+		}).Return(nil)
+		mockHash.EXPECT().CompareValue(gomock.Any(), "code hash", "123456").Return(true, nil)
+		mockRepo.EXPECT().ConsumeOTP(gomock.Any(), 3).Return(nil)
+	}
+	history := func() {
+		mockRepo.EXPECT().GetPasswordHistory(gomock.Any(), repository.GetPasswordHistoryInput{
+			UserID: 1,
+			Limit:  5,
+		}).Return(repository.GetPasswordHistoryOutput{
+			Passwords: []string{"previous hash"},
+		}, nil)
+	}
+	notRecent := func() {
+		mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(false, nil)
+		history()
+		mockHash.EXPECT().CompareValue(gomock.Any(), "previous hash", "@Password2").Return(false, nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow",
+			body: body,
+			mockFunc: func() {
+				validCode()
+				notRecent()
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password2").Return([]byte("password hash"), nil)
+				mockRepo.EXPECT().ChangePassword(gomock.Any(), repository.ChangePasswordInput{
+					UserID:      1,
+					Password:    "password hash",
+					HistorySize: 5,
+				}).Return(nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeUserRefreshTokens(gomock.Any(), 1).Return(nil)
+				mockLockout.EXPECT().Unlock(gomock.Any(), "+628123456789").Return(nil)
+			},
+			want: want{
+				code: 204,
+				body: ``,
+			},
+		},
+		{
+			name: "success flow current password hashed with a retired pepper",
+			body: body,
+			mockFunc: func() {
+				validCode()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(false, &hash.UnknownPepperError{Version: "v0"})
+				history()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "previous hash", "@Password2").Return(false, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password2").Return([]byte("password hash"), nil)
+				mockRepo.EXPECT().ChangePassword(gomock.Any(), gomock.Any()).Return(nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeUserRefreshTokens(gomock.Any(), 1).Return(nil)
+				mockLockout.EXPECT().Unlock(gomock.Any(), "+628123456789").Return(nil)
+			},
+			want: want{
+				code: 204,
+				body: ``,
+			},
+		},
+		{
+			name: "failed flow new password is the current password",
+			body: body,
+			mockFunc: func() {
+				validCode()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(true, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"password does not meet the password policy","violations":[{"code":"recently_used","message":"new password must differ from the recently used passwords"}]}`,
+			},
+		},
+		{
+			name: "failed flow new password is a previous password",
+			body: body,
+			mockFunc: func() {
+				validCode()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(false, nil)
+				history()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "previous hash", "@Password2").Return(true, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"password does not meet the password policy","violations":[{"code":"recently_used","message":"new password must differ from the recently used passwords"}]}`,
+			},
+		},
+		{
+			name: "failed flow on repository get password history",
+			body: body,
+			mockFunc: func() {
+				validCode()
+				mockHash.EXPECT().CompareValue(gomock.Any(), "current hash", "@Password2").Return(false, nil)
+				mockRepo.EXPECT().GetPasswordHistory(gomock.Any(), gomock.Any()).Return(repository.GetPasswordHistoryOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:     "failed flow without code",
+			body:     `{"phoneNumber":"+628123456789","newPassword":"@Password2"}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"code is required"}`,
+			},
+		},
+		{
+			name: "failed flow invalid new password",
+			body: `{"phoneNumber":"+628123456789","code":"123456","newPassword":"weak"}`,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("weak", resetUser).Return([]policy.Violation{
+					{Code: policy.CodeTooShort, Message: "password must be at least 6 characters"},
+					{Code: policy.CodeTooWeak, Message: "password is too easy to guess, add more words or characters"},
+				})
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"password does not meet the password policy","violations":[{"code":"too_short","message":"password must be at least 6 characters"},{"code":"too_weak","message":"password is too easy to guess, add more words or characters"}]}`,
+			},
+		},
+		{
+			name: "failed flow breached new password",
+			body: body,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password2", resetUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(true, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"password has appeared in a data breach and is unsafe to use, choose a different password"}`,
+			},
+		},
+		{
+			name: "failed flow while checking breached password",
+			body: body,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password2", resetUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(false, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow unknown phone number",
+			body: body,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password2", resetUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(false, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(repository.LoginUserOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired code"}`,
+			},
+		},
+		{
+			name: "failed flow no active code",
+			body: body,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password2", resetUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(false, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(user, nil)
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(repository.GetOTPOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired code"}`,
+			},
+		},
+		{
+			name: "failed flow too many attempts",
+			body: body,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password2", resetUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(false, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(user, nil)
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(otp, nil)
+				mockRepo.EXPECT().UseOTPAttempt(gomock.Any(), gomock.Any()).Return(repository.ErrOTPAttemptsExceeded)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"too many attempts, request a new code"}`,
+			},
+		},
+		{
+			name: "failed flow wrong code",
+			body: body,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password2", resetUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(false, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(user, nil)
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(otp, nil)
+				mockRepo.EXPECT().UseOTPAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "code hash", "123456").Return(false, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired code"}`,
+			},
+		},
+		{
+			name: "failed flow code used concurrently",
+			body: body,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password2", resetUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(false, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(user, nil)
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(otp, nil)
+				mockRepo.EXPECT().UseOTPAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "code hash", "123456").Return(true, nil)
+				mockRepo.EXPECT().ConsumeOTP(gomock.Any(), 3).Return(repository.ErrOTPAlreadyUsed)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired code"}`,
+			},
+		},
+		{
+			name: "failed flow on repository get otp",
+			body: body,
+			mockFunc: func() {
+				mockPolicy.EXPECT().Validate("@Password2", resetUser).Return(nil)
+				mockBreach.EXPECT().IsBreached(gomock.Any(), "@Password2").Return(false, nil)
+				mockRepo.EXPECT().LoginUser(gomock.Any(), gomock.Any()).Return(user, nil)
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(repository.GetOTPOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow on repository change password",
+			body: body,
+			mockFunc: func() {
+				validCode()
+				notRecent()
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password2").Return([]byte("password hash"), nil)
+				mockRepo.EXPECT().ChangePassword(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow on revocation revoke all tokens",
+			body: body,
+			mockFunc: func() {
+				validCode()
+				notRecent()
+				mockHash.EXPECT().HashValue(gomock.Any(), "@Password2").Return([]byte("password hash"), nil)
+				mockRepo.EXPECT().ChangePassword(gomock.Any(), gomock.Any()).Return(nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository:          mockRepo,
+				Hash:                mockHash,
+				Revocation:          mockRevocation,
+				Breach:              mockBreach,
+				Policy:              mockPolicy,
+				Lockout:             mockLockout,
+				OTPMaxAttempts:      5,
+				PasswordHistorySize: 5,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+
+			handler.ResetPassword(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("ResetPassword status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("ResetPassword Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// unverifiedScopes are the only scopes given to tokens of users who haven't verified their phone number,
+// they can still log out and delete their account
+var unverifiedScopes = []string{authz.PermissionProfileRead, authz.PermissionPhoneVerify, authz.PermissionAccountDelete}
+
+// tokenScopes returns the scopes of a token issued to the user, nil means the token is unrestricted
+func tokenScopes(phoneVerifiedAt *time.Time) []string {
+	if phoneVerifiedAt == nil {
+		return unverifiedScopes
+	}
+	return nil
+}
+
+// sendPhoneVerification sends a code proving ownership of the phone number,
+// the phone number is applied to the user once the code is verified
+func (s *Server) sendPhoneVerification(ctx echo.Context, userID int, phoneNumber string) error {
+	return s.issueOTP(ctx.Request().Context(), userID, phoneNumber, repository.OTPPurposePhoneVerification,
+		"Your phone verification code is %s. Do not share it with anyone.")
+}
+
+// VerifyPhone confirms the phone number the last verification code was sent to. Tokens issued
+// before keep their scopes, the full scopes are given on the next refresh or login.
+func (s *Server) VerifyPhone(ctx echo.Context) error {
+	var req = generated.PhoneVerifyRequest{}
+	ctx.Bind(&req)
+
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if req.Code == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "code is required",
+		})
+	}
+
+	otp, code, err := s.verifyOTP(ctx, userID, repository.OTPPurposePhoneVerification, req.Code)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Repository.VerifyPhoneNumber(ctx.Request().Context(), repository.VerifyPhoneNumberInput{
+		UserID:      userID,
+		PhoneNumber: otp.PhoneNumber,
+	})
+	if err != nil {
+		// someone else registered or verified the number since the code was sent
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+				Message: "Phone number already registered",
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// ResendPhoneVerification sends a new code for the pending phone number change,
+// or for the current phone number when it isn't verified yet.
+func (s *Server) ResendPhoneVerification(ctx echo.Context) error {
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	pending, err := s.Repository.GetOTP(ctx.Request().Context(), repository.GetOTPInput{
+		UserID:  userID,
+		Purpose: repository.OTPPurposePhoneVerification,
+	})
+	if err != nil && !strings.Contains(err.Error(), "no rows in result set") {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	phoneNumber := pending.PhoneNumber
+	if err != nil {
+		user, err := s.Repository.GetUser(ctx.Request().Context(), repository.GetUserInput{
+			UserID: userID,
+		})
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+
+		if user.PhoneVerifiedAt != nil {
+			return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+				Message: "phone number is already verified",
+			})
+		}
+		phoneNumber = user.PhoneNumber
+	}
+
+	err = s.sendPhoneVerification(ctx, userID, phoneNumber)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.NoContent(http.StatusAccepted)
+}
@@ -0,0 +1,330 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/sms"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_VerifyPhone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	otp := repository.GetOTPOutput{
+		ID:          3,
+		PhoneNumber: "+628111111111",
+		CodeHash:    "code hash",
+	}
+	validCode := func() {
+		mockRepo.EXPECT().GetOTP(gomock.Any(), repository.GetOTPInput{
+			UserID:  1,
+			Purpose: repository.OTPPurposePhoneVerification,
+		}).Return(otp, nil)
+		mockRepo.EXPECT().UseOTPAttempt(gomock.Any(), repository.UseOTPAttemptInput{
+			ID:          3,
+			MaxAttempts: 5,
+		}).Return(nil)
+		mockHash.EXPECT().CompareValue(gomock.Any(), "code hash", "123456").Return(true, nil)
+		mockRepo.EXPECT().ConsumeOTP(gomock.Any(), 3).Return(nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				validCode()
+				mockRepo.EXPECT().VerifyPhoneNumber(gomock.Any(), repository.VerifyPhoneNumberInput{
+					UserID:      1,
+					PhoneNumber: "+628111111111",
+				}).Return(nil)
+			},
+			want: want{
+				code: 204,
+				body: ``,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			body:     `{"code":"123456"}`,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:     "failed flow without code",
+			userID:   1,
+			body:     `{}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"code is required"}`,
+			},
+		},
+		{
+			name:   "failed flow without pending code",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(repository.GetOTPOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired code"}`,
+			},
+		},
+		{
+			name:   "failed flow wrong code",
+			userID: 1,
+			body:   `{"code":"654321"}`,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(otp, nil)
+				mockRepo.EXPECT().UseOTPAttempt(gomock.Any(), gomock.Any()).Return(nil)
+				mockHash.EXPECT().CompareValue(gomock.Any(), "code hash", "654321").Return(false, nil)
+			},
+			want: want{
+				code: 400,
+				body: `{"message":"invalid or expired code"}`,
+			},
+		},
+		{
+			name:   "failed flow phone number registered since the code was sent",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				validCode()
+				mockRepo.EXPECT().VerifyPhoneNumber(gomock.Any(), gomock.Any()).Return(fmt.Errorf("duplicate key value violates unique constraint"))
+			},
+			want: want{
+				code: 409,
+				body: `{"message":"Phone number already registered"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository verify phone number",
+			userID: 1,
+			body:   `{"code":"123456"}`,
+			mockFunc: func() {
+				validCode()
+				mockRepo.EXPECT().VerifyPhoneNumber(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository:     mockRepo,
+				Hash:           mockHash,
+				OTPMaxAttempts: 5,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/phone/verify", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.VerifyPhone(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("VerifyPhone status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("VerifyPhone Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_ResendPhoneVerification(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockSMS := sms.NewMockSender(ctrl)
+	verifiedAt := time.Date(2024, 1, 1, 3, 4, 5, 0, time.UTC)
+	noPending := func() {
+		mockRepo.EXPECT().GetOTP(gomock.Any(), repository.GetOTPInput{
+			UserID:  1,
+			Purpose: repository.OTPPurposePhoneVerification,
+		}).Return(repository.GetOTPOutput{}, fmt.Errorf("sql: no rows in result set"))
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow pending phone number change",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(repository.GetOTPOutput{
+					ID:          3,
+					PhoneNumber: "+628111111111",
+				}, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Cond(func(x interface{}) bool {
+					input := x.(repository.CreateOTPInput)
+					return input.UserID == 1 && input.Purpose == repository.OTPPurposePhoneVerification &&
+						input.PhoneNumber == "+628111111111"
+				})).Return(nil)
+				mockSMS.EXPECT().Send(gomock.Any(), "+628111111111", gomock.Any()).Return(nil)
+			},
+			want: want{
+				code: 202,
+				body: ``,
+			},
+		},
+		{
+			name:   "success flow unverified phone number",
+			userID: 1,
+			mockFunc: func() {
+				noPending()
+				mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{
+					UserID: 1,
+				}).Return(repository.GetUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+				}, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Any()).Return(nil)
+				mockSMS.EXPECT().Send(gomock.Any(), "+628123456789", gomock.Any()).Return(nil)
+			},
+			want: want{
+				code: 202,
+				body: ``,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:   "failed flow phone number already verified",
+			userID: 1,
+			mockFunc: func() {
+				noPending()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{
+					UserID:          1,
+					PhoneNumber:     "+628123456789",
+					PhoneVerifiedAt: &verifiedAt,
+				}, nil)
+			},
+			want: want{
+				code: 409,
+				body: `{"message":"phone number is already verified"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository get otp",
+			userID: 1,
+			mockFunc: func() {
+				mockRepo.EXPECT().GetOTP(gomock.Any(), gomock.Any()).Return(repository.GetOTPOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository get user",
+			userID: 1,
+			mockFunc: func() {
+				noPending()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on sms send",
+			userID: 1,
+			mockFunc: func() {
+				noPending()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{
+					UserID:      1,
+					PhoneNumber: "+628123456789",
+				}, nil)
+				mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).Return([]byte("hash"), nil)
+				mockRepo.EXPECT().CreateOTP(gomock.Any(), gomock.Any()).Return(nil)
+				mockSMS.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Hash:       mockHash,
+				SMS:        mockSMS,
+				OTPTTL:     10 * time.Minute,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/phone/verify/resend", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.ResendPhoneVerification(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("ResendPhoneVerification status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("ResendPhoneVerification Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
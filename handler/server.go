@@ -1,8 +1,19 @@
 package handler
 
 import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/breach"
 	hash "github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/lockout"
+	"github.com/SawitProRecruitment/UserService/pkg/policy"
+	"github.com/SawitProRecruitment/UserService/pkg/revocation"
+	"github.com/SawitProRecruitment/UserService/pkg/sms"
 	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/pkg/totp"
 	"github.com/SawitProRecruitment/UserService/repository"
 )
 
@@ -10,18 +21,112 @@ type Server struct {
 	Repository repository.RepositoryInterface
 	Hash       hash.HashMethod
 	Token      token.TokenMethod
+	Revocation revocation.RevocationMethod
+	Authz      authz.AuthzMethod
+	Lockout    lockout.LockoutMethod
+	SMS        sms.Sender
+	TOTP       totp.TOTPMethod
+	// Breach rejects passwords known from data breaches, passwords aren't checked when it is nil
+	Breach breach.Checker
+	// Policy is the rules a new password must follow
+	Policy policy.PolicyMethod
+	// HashExecutor is the executor Hash runs on, GetHashMetrics reports it and answers 404 when it is nil
+	HashExecutor *hash.Executor
+	// DeletionGracePeriod is how long a deleted account can be restored by logging in before it is purged
+	DeletionGracePeriod time.Duration
+	// ExportAsyncThreshold is the number of records above which a data export is prepared in the background
+	ExportAsyncThreshold int
+	// ExportWorkers is how many background exports are prepared at once, more are refused until one is done
+	ExportWorkers int
+	// ExportTimeout is how long a background export may run, a job pending for longer was interrupted
+	ExportTimeout time.Duration
+	// OTPTTL is how long a one-time code sent by sms can be used
+	OTPTTL time.Duration
+	// OTPMaxAttempts is how many times a one-time code can be tried before a new one is needed
+	OTPMaxAttempts int
+	// PasswordHistorySize is how many previous passwords can't be set again, besides the current one
+	PasswordHistorySize int
+	// MFAChallengeTTL is how long the challenge of a password login waits for the second factor
+	MFAChallengeTTL time.Duration
+
+	// background tracks the work that outlives its request, the data exports and the one-time codes
+	background sync.WaitGroup
+	// exportSlots holds a value for every running background export, it has room for ExportWorkers
+	exportSlots chan struct{}
+	// backgroundCtx is the context of the background work, cancelBackground stops it on shutdown
+	backgroundCtx    context.Context
+	cancelBackground context.CancelFunc
 }
 
 type NewServerOptions struct {
-	Repository repository.RepositoryInterface
-	Hash       hash.HashMethod
-	Token      token.TokenMethod
+	Repository           repository.RepositoryInterface
+	Hash                 hash.HashMethod
+	HashExecutor         *hash.Executor
+	Token                token.TokenMethod
+	Revocation           revocation.RevocationMethod
+	Authz                authz.AuthzMethod
+	Lockout              lockout.LockoutMethod
+	SMS                  sms.Sender
+	TOTP                 totp.TOTPMethod
+	Breach               breach.Checker
+	Policy               policy.PolicyMethod
+	DeletionGracePeriod  time.Duration
+	ExportAsyncThreshold int
+	ExportWorkers        int
+	ExportTimeout        time.Duration
+	OTPTTL               time.Duration
+	OTPMaxAttempts       int
+	PasswordHistorySize  int
+	MFAChallengeTTL      time.Duration
 }
 
 func NewServer(opts NewServerOptions) *Server {
+	exportWorkers := opts.ExportWorkers
+	if exportWorkers <= 0 {
+		exportWorkers = 1
+	}
+	backgroundCtx, cancelBackground := context.WithCancel(context.Background())
+
 	return &Server{
-		Repository: opts.Repository,
-		Hash:       opts.Hash,
-		Token:      opts.Token,
+		Repository:           opts.Repository,
+		Hash:                 opts.Hash,
+		HashExecutor:         opts.HashExecutor,
+		Token:                opts.Token,
+		Revocation:           opts.Revocation,
+		Authz:                opts.Authz,
+		Lockout:              opts.Lockout,
+		SMS:                  opts.SMS,
+		TOTP:                 opts.TOTP,
+		Breach:               opts.Breach,
+		Policy:               opts.Policy,
+		DeletionGracePeriod:  opts.DeletionGracePeriod,
+		ExportAsyncThreshold: opts.ExportAsyncThreshold,
+		ExportWorkers:        exportWorkers,
+		ExportTimeout:        opts.ExportTimeout,
+		OTPTTL:               opts.OTPTTL,
+		OTPMaxAttempts:       opts.OTPMaxAttempts,
+		PasswordHistorySize:  opts.PasswordHistorySize,
+		MFAChallengeTTL:      opts.MFAChallengeTTL,
+		exportSlots:          make(chan struct{}, exportWorkers),
+		backgroundCtx:        backgroundCtx,
+		cancelBackground:     cancelBackground,
+	}
+}
+
+// Shutdown waits for the background work to finish. When ctx is done first the remaining work is
+// canceled, the jobs of the canceled exports stay pending until FailStaleDataExports fails them.
+func (s *Server) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.cancelBackground()
+		return ctx.Err()
 	}
 }
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestServer_Shutdown(t *testing.T) {
+	handler := NewServer(NewServerOptions{})
+
+	// background work that doesn't finish in time is canceled
+	handler.background.Add(1)
+	go func() {
+		defer handler.background.Done()
+		<-handler.backgroundCtx.Done()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := handler.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown error got =%v, want %v \n", err, context.DeadlineExceeded)
+	}
+
+	// the canceled work is done, nothing is left to wait for
+	if err := handler.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown error got =%v, want nil \n", err)
+	}
+}
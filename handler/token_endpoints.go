@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+func (s *Server) RefreshToken(ctx echo.Context) error {
+	var resp generated.RefreshTokenResponse
+	var req = generated.RefreshTokenRequest{}
+	ctx.Bind(&req)
+
+	if req.RefreshToken == "" {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "refresh token is required",
+		})
+	}
+
+	stored, err := s.Repository.GetRefreshToken(ctx.Request().Context(), repository.GetRefreshTokenInput{
+		TokenHash: s.Token.HashRefreshToken(req.RefreshToken),
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+				Message: "invalid refresh token",
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if stored.IsRevoked {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: "invalid refresh token",
+		})
+	}
+
+	// a used token being presented again means it has been stolen,
+	// so the whole family is revoked and the user has to login again
+	if stored.IsUsed {
+		return s.revokeRefreshTokenFamily(ctx, stored.FamilyID)
+	}
+
+	if time.Now().UTC().After(stored.ExpiresAt) {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: "refresh token expired",
+		})
+	}
+
+	suspension, err := s.Repository.GetUserSuspension(ctx.Request().Context(), repository.GetUserSuspensionInput{
+		UserID: stored.UserID,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if suspension.IsSuspended {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: "account is suspended",
+		})
+	}
+
+	// roles are read again so a granted or removed role is applied on the next refresh
+	roles, err := s.Repository.GetUserRoles(ctx.Request().Context(), repository.GetUserRolesInput{
+		UserID: stored.UserID,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	// the phone verification is read again so a verified user gets unrestricted tokens
+	user, err := s.Repository.GetUser(ctx.Request().Context(), repository.GetUserInput{
+		UserID: stored.UserID,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	refreshToken, err := s.Token.GenerateRefreshToken(stored.FamilyID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Repository.RotateRefreshToken(ctx.Request().Context(), repository.RotateRefreshTokenInput{
+		OldTokenID: stored.ID,
+		NewToken: repository.CreateRefreshTokenInput{
+			UserID:    stored.UserID,
+			FamilyID:  refreshToken.FamilyID,
+			TokenHash: refreshToken.Hash,
+			ExpiresAt: refreshToken.ExpiresAt,
+		},
+	})
+	if err != nil {
+		// another request rotated the same token first
+		if errors.Is(err, repository.ErrRefreshTokenAlreadyUsed) {
+			return s.revokeRefreshTokenFamily(ctx, stored.FamilyID)
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	resp.Jwt, err = s.Token.GenerateToken(token.TokenBody{
+		UserID: stored.UserID,
+		Roles:  roles.Roles,
+		Scopes: tokenScopes(user.PhoneVerifiedAt),
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	resp.Id = stored.UserID
+	resp.RefreshToken = refreshToken.Token
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// CreateScopedToken mints an access token limited to the requested scopes,
+// every scope must be a permission the caller token can use.
+func (s *Server) CreateScopedToken(ctx echo.Context) error {
+	var resp generated.ScopedTokenResponse
+	var req = generated.ScopedTokenRequest{}
+	ctx.Bind(&req)
+
+	// get token from middleware
+	body, err := getTokenBody(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if len(req.Scopes) == 0 {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: "scopes is required",
+		})
+	}
+
+	permissions, err := s.Authz.Permissions(ctx.Request().Context(), body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	granted := map[string]bool{}
+	for _, permission := range permissions {
+		granted[permission] = true
+	}
+
+	scopes := []string{}
+	seen := map[string]bool{}
+	for _, scope := range req.Scopes {
+		if !granted[scope] {
+			return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+				Message: fmt.Sprintf("scope %s is not granted", scope),
+			})
+		}
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+
+	resp.Jwt, err = s.Token.GenerateToken(token.TokenBody{
+		UserID: body.UserID,
+		Roles:  body.Roles,
+		Scopes: scopes,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	resp.Id = body.UserID
+	resp.Scope = strings.Join(scopes, " ")
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) revokeRefreshTokenFamily(ctx echo.Context, familyID string) error {
+	err := s.Repository.RevokeRefreshTokenFamily(ctx.Request().Context(), familyID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+		Message: "refresh token reuse detected, please login again",
+	})
+}
+
+func (s *Server) Logout(ctx echo.Context) error {
+	var req = generated.LogoutRequest{}
+	ctx.Bind(&req)
+
+	// get token from middleware
+	body, err := getTokenBody(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Revocation.RevokeToken(ctx.Request().Context(), body)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	if req.RefreshToken != nil && *req.RefreshToken != "" {
+		stored, err := s.Repository.GetRefreshToken(ctx.Request().Context(), repository.GetRefreshTokenInput{
+			TokenHash: s.Token.HashRefreshToken(*req.RefreshToken),
+		})
+		if err != nil && !strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+
+		// only the owner of the refresh token may revoke it
+		if err == nil && stored.UserID == body.UserID {
+			err = s.Repository.RevokeRefreshTokenFamily(ctx.Request().Context(), stored.FamilyID)
+			if err != nil {
+				return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+					Message: err.Error(),
+				})
+			}
+		}
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) LogoutAll(ctx echo.Context) error {
+	// get user id from middleware
+	userID, err := getUserID(ctx)
+	if err != nil {
+		return ctx.JSON(http.StatusForbidden, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Revocation.RevokeAllTokens(ctx.Request().Context(), userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Repository.RevokeUserRefreshTokens(ctx.Request().Context(), userID)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) GetJwks(ctx echo.Context) error {
+	jwks := s.Token.JWKS()
+
+	resp := generated.JwksResponse{
+		Keys: make([]generated.JsonWebKey, 0, len(jwks.Keys)),
+	}
+	for _, key := range jwks.Keys {
+		resp.Keys = append(resp.Keys, generated.JsonWebKey{
+			Alg: generated.JsonWebKeyAlg(key.Alg),
+			Crv: optionalString(key.Crv),
+			E:   optionalString(key.E),
+			Kid: key.Kid,
+			Kty: generated.JsonWebKeyKty(key.Kty),
+			N:   optionalString(key.N),
+			Use: key.Use,
+			X:   optionalString(key.X),
+			Y:   optionalString(key.Y),
+		})
+	}
+
+	// let other services cache the keys, but not longer than a rotation takes to propagate
+	ctx.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// optionalString returns nil for an empty value so it is omitted from the response
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,821 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/hash"
+	"github.com/SawitProRecruitment/UserService/pkg/revocation"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_RefreshToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockToken := token.NewMockTokenMethod(ctrl)
+	expiresAt := time.Now().UTC().Add(time.Hour)
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), repository.GetRefreshTokenInput{
+					TokenHash: "old-hash",
+				}).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					UserID:    2,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+				}, nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 2,
+				}).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), repository.GetUserRolesInput{
+					UserID: 2,
+				}).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{
+					UserID: 2,
+				}).Return(repository.GetUserOutput{
+					UserID:          2,
+					PhoneVerifiedAt: &expiresAt,
+				}, nil)
+				mockToken.EXPECT().GenerateRefreshToken("family").Return(token.RefreshToken{
+					Token:     "new",
+					Hash:      "new-hash",
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+				}, nil)
+				mockRepo.EXPECT().RotateRefreshToken(gomock.Any(), repository.RotateRefreshTokenInput{
+					OldTokenID: 1,
+					NewToken: repository.CreateRefreshTokenInput{
+						UserID:    2,
+						FamilyID:  "family",
+						TokenHash: "new-hash",
+						ExpiresAt: expiresAt,
+					},
+				}).Return(nil)
+				mockToken.EXPECT().GenerateToken(token.TokenBody{UserID: 2, Roles: []string{"user"}}).Return("Bearer token", nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":2,"jwt":"Bearer token","refreshToken":"new"}`,
+			},
+		},
+		{
+			name:     "failed flow empty refresh token",
+			body:     `{"refreshToken":""}`,
+			mockFunc: func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"refresh token is required"}`,
+			},
+		},
+		{
+			name: "failed flow unknown refresh token",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid refresh token"}`,
+			},
+		},
+		{
+			name: "failed flow on repository get refresh token",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow revoked refresh token",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+					IsRevoked: true,
+				}, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid refresh token"}`,
+			},
+		},
+		{
+			name: "failed flow reused refresh token revokes family",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+					IsUsed:    true,
+				}, nil)
+				mockRepo.EXPECT().RevokeRefreshTokenFamily(gomock.Any(), "family").Return(nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"refresh token reuse detected, please login again"}`,
+			},
+		},
+		{
+			name: "failed flow expired refresh token",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					FamilyID:  "family",
+					ExpiresAt: time.Now().UTC().Add(-time.Hour),
+				}, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"refresh token expired"}`,
+			},
+		},
+		{
+			name: "failed flow suspended account",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					UserID:    2,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+				}, nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{
+					IsSuspended: true,
+				}, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"account is suspended"}`,
+			},
+		},
+		{
+			name: "failed flow on repository get user suspension",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					UserID:    2,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+				}, nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow on repository get user roles",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					UserID:    2,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+				}, nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 2,
+				}).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), gomock.Any()).Return(repository.GetUserRolesOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "success flow unverified phone number gets restricted scopes",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					UserID:    2,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+				}, nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), gomock.Any()).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{UserID: 2}, nil)
+				mockToken.EXPECT().GenerateRefreshToken("family").Return(token.RefreshToken{Token: "new", FamilyID: "family"}, nil)
+				mockRepo.EXPECT().RotateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+				mockToken.EXPECT().GenerateToken(token.TokenBody{
+					UserID: 2,
+					Roles:  []string{"user"},
+					Scopes: []string{"profile:read", "phone:verify", "account:delete"},
+				}).Return("Bearer token", nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":2,"jwt":"Bearer token","refreshToken":"new"}`,
+			},
+		},
+		{
+			name: "failed flow on repository get user",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					UserID:    2,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+				}, nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), gomock.Any()).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), gomock.Any()).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow concurrent rotation revokes family",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					UserID:    2,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+				}, nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 2,
+				}).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), repository.GetUserRolesInput{
+					UserID: 2,
+				}).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{
+					UserID: 2,
+				}).Return(repository.GetUserOutput{
+					UserID:          2,
+					PhoneVerifiedAt: &expiresAt,
+				}, nil)
+				mockToken.EXPECT().GenerateRefreshToken("family").Return(token.RefreshToken{FamilyID: "family"}, nil)
+				mockRepo.EXPECT().RotateRefreshToken(gomock.Any(), gomock.Any()).Return(repository.ErrRefreshTokenAlreadyUsed)
+				mockRepo.EXPECT().RevokeRefreshTokenFamily(gomock.Any(), "family").Return(nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"refresh token reuse detected, please login again"}`,
+			},
+		},
+		{
+			name: "failed flow on repository revoke family",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+					IsUsed:    true,
+				}, nil)
+				mockRepo.EXPECT().RevokeRefreshTokenFamily(gomock.Any(), "family").Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow on token generate token",
+			body: `{"refreshToken":"old"}`,
+			mockFunc: func() {
+				mockToken.EXPECT().HashRefreshToken("old").Return("old-hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					ID:        1,
+					UserID:    2,
+					FamilyID:  "family",
+					ExpiresAt: expiresAt,
+				}, nil)
+				mockRepo.EXPECT().GetUserSuspension(gomock.Any(), repository.GetUserSuspensionInput{
+					UserID: 2,
+				}).Return(repository.GetUserSuspensionOutput{}, nil)
+				mockRepo.EXPECT().GetUserRoles(gomock.Any(), repository.GetUserRolesInput{
+					UserID: 2,
+				}).Return(repository.GetUserRolesOutput{
+					Roles: []string{"user"},
+				}, nil)
+				mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{
+					UserID: 2,
+				}).Return(repository.GetUserOutput{
+					UserID:          2,
+					PhoneVerifiedAt: &expiresAt,
+				}, nil)
+				mockToken.EXPECT().GenerateRefreshToken("family").Return(token.RefreshToken{FamilyID: "family"}, nil)
+				mockRepo.EXPECT().RotateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+				mockToken.EXPECT().GenerateToken(token.TokenBody{UserID: 2, Roles: []string{"user"}}).Return("", fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Hash:       mockHash,
+				Token:      mockToken,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			ctx := e.NewContext(req, rec)
+
+			handler.RefreshToken(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("RefreshToken status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("RefreshToken Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_CreateScopedToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockToken := token.NewMockTokenMethod(ctrl)
+	mockAuthz := authz.NewMockAuthzMethod(ctrl)
+	tokenBody := token.TokenBody{
+		UserID:  1,
+		TokenID: "jti",
+		Roles:   []string{"user"},
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name      string
+		body      string
+		tokenBody token.TokenBody
+		mockFunc  func()
+		want      want
+	}{
+		{
+			name:      "success flow",
+			body:      `{"scopes":["profile:read","profile:read"]}`,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockAuthz.EXPECT().Permissions(gomock.Any(), tokenBody).Return([]string{"profile:read", "profile:write"}, nil)
+				mockToken.EXPECT().GenerateToken(token.TokenBody{
+					UserID: 1,
+					Roles:  []string{"user"},
+					Scopes: []string{"profile:read"},
+				}).Return("Bearer token", nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"id":1,"jwt":"Bearer token","scope":"profile:read"}`,
+			},
+		},
+		{
+			name:     "failed flow invalid token",
+			body:     `{"scopes":["profile:read"]}`,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:      "failed flow empty scopes",
+			body:      `{"scopes":[]}`,
+			tokenBody: tokenBody,
+			mockFunc:  func() {},
+			want: want{
+				code: 400,
+				body: `{"message":"scopes is required"}`,
+			},
+		},
+		{
+			name:      "failed flow scope not granted",
+			body:      `{"scopes":["profile:read","users:read"]}`,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockAuthz.EXPECT().Permissions(gomock.Any(), tokenBody).Return([]string{"profile:read", "profile:write"}, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"scope users:read is not granted"}`,
+			},
+		},
+		{
+			name:      "failed flow on authz permissions",
+			body:      `{"scopes":["profile:read"]}`,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockAuthz.EXPECT().Permissions(gomock.Any(), tokenBody).Return(nil, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:      "failed flow on token generate token",
+			body:      `{"scopes":["profile:read"]}`,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockAuthz.EXPECT().Permissions(gomock.Any(), tokenBody).Return([]string{"profile:read"}, nil)
+				mockToken.EXPECT().GenerateToken(gomock.Any()).Return("", fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Token: mockToken,
+				Authz: mockAuthz,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/token/scoped", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			ctx := e.NewContext(req, rec)
+			if tt.tokenBody.UserID > 0 {
+				ctx.Set("user_id", tt.tokenBody.UserID)
+				ctx.Set("token", tt.tokenBody)
+			}
+
+			handler.CreateScopedToken(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("CreateScopedToken status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("CreateScopedToken Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_Logout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockToken := token.NewMockTokenMethod(ctrl)
+	mockRevocation := revocation.NewMockRevocationMethod(ctrl)
+	tokenBody := token.TokenBody{
+		UserID:  1,
+		TokenID: "jti",
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name      string
+		body      string
+		tokenBody token.TokenBody
+		mockFunc  func()
+		want      want
+	}{
+		{
+			name:      "success flow",
+			body:      `{}`,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockRevocation.EXPECT().RevokeToken(gomock.Any(), tokenBody).Return(nil)
+			},
+			want: want{
+				code: 204,
+			},
+		},
+		{
+			name:      "success flow with refresh token",
+			body:      `{"refreshToken":"refresh"}`,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockRevocation.EXPECT().RevokeToken(gomock.Any(), tokenBody).Return(nil)
+				mockToken.EXPECT().HashRefreshToken("refresh").Return("hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), repository.GetRefreshTokenInput{
+					TokenHash: "hash",
+				}).Return(repository.GetRefreshTokenOutput{
+					UserID:   1,
+					FamilyID: "family",
+				}, nil)
+				mockRepo.EXPECT().RevokeRefreshTokenFamily(gomock.Any(), "family").Return(nil)
+			},
+			want: want{
+				code: 204,
+			},
+		},
+		{
+			name:      "success flow with refresh token of another user",
+			body:      `{"refreshToken":"refresh"}`,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockRevocation.EXPECT().RevokeToken(gomock.Any(), tokenBody).Return(nil)
+				mockToken.EXPECT().HashRefreshToken("refresh").Return("hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{
+					UserID:   2,
+					FamilyID: "family",
+				}, nil)
+			},
+			want: want{
+				code: 204,
+			},
+		},
+		{
+			name:     "failed flow invalid token",
+			body:     `{}`,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:      "failed flow on revocation revoke token",
+			body:      `{}`,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockRevocation.EXPECT().RevokeToken(gomock.Any(), tokenBody).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:      "failed flow on repository get refresh token",
+			body:      `{"refreshToken":"refresh"}`,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockRevocation.EXPECT().RevokeToken(gomock.Any(), tokenBody).Return(nil)
+				mockToken.EXPECT().HashRefreshToken("refresh").Return("hash")
+				mockRepo.EXPECT().GetRefreshToken(gomock.Any(), gomock.Any()).Return(repository.GetRefreshTokenOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Hash:       mockHash,
+				Token:      mockToken,
+				Revocation: mockRevocation,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			ctx := e.NewContext(req, rec)
+			if tt.tokenBody.UserID > 0 {
+				ctx.Set("user_id", tt.tokenBody.UserID)
+				ctx.Set("token", tt.tokenBody)
+			}
+
+			handler.Logout(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("Logout status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("Logout Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_LogoutAll(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockHash := hash.NewMockHashMethod(ctrl)
+	mockToken := token.NewMockTokenMethod(ctrl)
+	mockRevocation := revocation.NewMockRevocationMethod(ctrl)
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		userID   int
+		mockFunc func()
+		want     want
+	}{
+		{
+			name:   "success flow",
+			userID: 1,
+			mockFunc: func() {
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeUserRefreshTokens(gomock.Any(), 1).Return(nil)
+			},
+			want: want{
+				code: 204,
+			},
+		},
+		{
+			name:     "failed flow invalid user id",
+			userID:   0,
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:   "failed flow on revocation revoke all tokens",
+			userID: 1,
+			mockFunc: func() {
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:   "failed flow on repository revoke refresh tokens",
+			userID: 1,
+			mockFunc: func() {
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 1).Return(nil)
+				mockRepo.EXPECT().RevokeUserRefreshTokens(gomock.Any(), 1).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Hash:       mockHash,
+				Token:      mockToken,
+				Revocation: mockRevocation,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/logout-all", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.userID > 0 {
+				ctx.Set("user_id", tt.userID)
+			}
+
+			handler.LogoutAll(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("LogoutAll status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("LogoutAll Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_GetJwks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockToken := token.NewMockTokenMethod(ctrl)
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow",
+			mockFunc: func() {
+				mockToken.EXPECT().JWKS().Return(token.JSONWebKeySet{
+					Keys: []token.JSONWebKey{
+						{Kty: "RSA", Use: "sig", Kid: "kid", Alg: "RS256", N: "n", E: "AQAB"},
+					},
+				})
+			},
+			want: want{
+				code: 200,
+				body: `{"keys":[{"alg":"RS256","e":"AQAB","kid":"kid","kty":"RSA","n":"n","use":"sig"}]}`,
+			},
+		},
+		{
+			name: "success flow with EC key",
+			mockFunc: func() {
+				mockToken.EXPECT().JWKS().Return(token.JSONWebKeySet{
+					Keys: []token.JSONWebKey{
+						{Kty: "EC", Use: "sig", Kid: "kid", Alg: "ES256", Crv: "P-256", X: "x", Y: "y"},
+					},
+				})
+			},
+			want: want{
+				code: 200,
+				body: `{"keys":[{"alg":"ES256","crv":"P-256","kid":"kid","kty":"EC","use":"sig","x":"x","y":"y"}]}`,
+			},
+		},
+		{
+			name: "success flow without keys",
+			mockFunc: func() {
+				mockToken.EXPECT().JWKS().Return(token.JSONWebKeySet{})
+			},
+			want: want{
+				code: 200,
+				body: `{"keys":[]}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Token: mockToken,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+
+			handler.GetJwks(ctx)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("GetJwks status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("GetJwks Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
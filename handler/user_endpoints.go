@@ -0,0 +1,343 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultUsersLimit = 20
+	maxUsersLimit     = 100
+)
+
+// userSortColumns maps the sort query parameter to the repository sort column
+var userSortColumns = map[generated.ListUsersParamsSort]string{
+	"id":        repository.UserSortID,
+	"createdAt": repository.UserSortCreatedAt,
+	"fullName":  repository.UserSortFullName,
+}
+
+// usersCursor is the last user of a page, it is sent to the client as an opaque nextCursor
+type usersCursor struct {
+	Sort      generated.ListUsersParamsSort  `json:"s"`
+	Order     generated.ListUsersParamsOrder `json:"o"`
+	UserID    int                            `json:"id"`
+	CreatedAt time.Time                      `json:"c"`
+	FullName  string                         `json:"n"`
+}
+
+// ListUsers lists users with cursor pagination for admin and support.
+func (s *Server) ListUsers(ctx echo.Context, params generated.ListUsersParams) error {
+	_, code, err := s.checkPermission(ctx, authz.PermissionUsersRead)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	input, cursor, err := validateListUsersParams(params)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	// one more user is asked to know if there is a next page
+	limit := input.Limit
+	input.Limit++
+
+	result, err := s.Repository.ListUsers(ctx.Request().Context(), input)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	resp := generated.UserListResponse{
+		Users: []generated.UserResponse{},
+	}
+	for i, user := range result.Users {
+		if i == limit {
+			break
+		}
+		resp.Users = append(resp.Users, userResponse(user))
+	}
+
+	if len(result.Users) > limit {
+		last := result.Users[limit-1]
+		cursor.UserID = last.UserID
+		cursor.CreatedAt = last.CreatedAt
+		cursor.FullName = last.FullName
+		nextCursor, err := encodeUsersCursor(cursor)
+		if err != nil {
+			return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+		resp.NextCursor = &nextCursor
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// GetUserById returns any user for admin and support.
+func (s *Server) GetUserById(ctx echo.Context, id int) error {
+	_, code, err := s.checkPermission(ctx, authz.PermissionUsersRead)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	result, err := s.Repository.GetUser(ctx.Request().Context(), repository.GetUserInput{
+		UserID: id,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.JSON(http.StatusNotFound, generated.ErrorResponse{
+				Message: "user not found",
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, userResponse(result))
+}
+
+// SuspendUser suspends a user until it is reactivated or the suspension expires,
+// every token of the user is revoked so the suspension applies immediately.
+func (s *Server) SuspendUser(ctx echo.Context, id int) error {
+	var req = generated.SuspendUserRequest{}
+	ctx.Bind(&req)
+
+	body, code, err := s.checkPermission(ctx, authz.PermissionUsersWrite)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = validateSuspendRequest(req, body.UserID, id)
+	if err != nil {
+		return ctx.JSON(http.StatusBadRequest, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	_, err = s.Repository.GetUser(ctx.Request().Context(), repository.GetUserInput{
+		UserID: id,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows in result set") {
+			return ctx.JSON(http.StatusNotFound, generated.ErrorResponse{
+				Message: "user not found",
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Repository.SuspendUser(ctx.Request().Context(), repository.SuspendUserInput{
+		UserID:      id,
+		Reason:      strings.TrimSpace(req.Reason),
+		SuspendedBy: body.UserID,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Revocation.RevokeAllTokens(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Repository.RevokeUserRefreshTokens(ctx.Request().Context(), id)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// ReactivateUser lifts the active suspension of a user.
+func (s *Server) ReactivateUser(ctx echo.Context, id int) error {
+	body, code, err := s.checkPermission(ctx, authz.PermissionUsersWrite)
+	if err != nil {
+		return ctx.JSON(code, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	err = s.Repository.ReactivateUser(ctx.Request().Context(), repository.ReactivateUserInput{
+		UserID:        id,
+		ReactivatedBy: body.UserID,
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotSuspended) {
+			return ctx.JSON(http.StatusConflict, generated.ErrorResponse{
+				Message: err.Error(),
+			})
+		}
+		return ctx.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+			Message: err.Error(),
+		})
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// checkPermission is func to check the caller token can use the permission,
+// it returns the status code to respond with when it can't
+func (s *Server) checkPermission(ctx echo.Context, permission string) (token.TokenBody, int, error) {
+	body, err := getTokenBody(ctx)
+	if err != nil {
+		return token.TokenBody{}, http.StatusForbidden, err
+	}
+
+	allowed, err := s.Authz.HasPermission(ctx.Request().Context(), body, permission)
+	if err != nil {
+		return token.TokenBody{}, http.StatusInternalServerError, err
+	}
+
+	if !allowed {
+		return token.TokenBody{}, http.StatusForbidden, fmt.Errorf("permission %s is required", permission)
+	}
+
+	return body, http.StatusOK, nil
+}
+
+func validateListUsersParams(params generated.ListUsersParams) (repository.ListUsersInput, usersCursor, error) {
+	input := repository.ListUsersInput{
+		Limit:       defaultUsersLimit,
+		CreatedFrom: params.CreatedFrom,
+		CreatedTo:   params.CreatedTo,
+	}
+	if params.Limit != nil {
+		if *params.Limit < 1 || *params.Limit > maxUsersLimit {
+			return repository.ListUsersInput{}, usersCursor{}, fmt.Errorf("limit must be between 1 and %d", maxUsersLimit)
+		}
+		input.Limit = *params.Limit
+	}
+
+	cursor := usersCursor{
+		Sort:  "createdAt",
+		Order: "desc",
+	}
+	if params.Sort != nil {
+		cursor.Sort = *params.Sort
+	}
+	if params.Order != nil {
+		cursor.Order = *params.Order
+	}
+
+	column, ok := userSortColumns[cursor.Sort]
+	if !ok {
+		return repository.ListUsersInput{}, usersCursor{}, fmt.Errorf("sort must be one of id, createdAt or fullName")
+	}
+	input.SortBy = column
+
+	switch cursor.Order {
+	case "asc":
+	case "desc":
+		input.Descending = true
+	default:
+		return repository.ListUsersInput{}, usersCursor{}, fmt.Errorf("order must be asc or desc")
+	}
+
+	if params.PhonePrefix != nil {
+		input.PhonePrefix = *params.PhonePrefix
+	}
+	if params.Name != nil {
+		input.Name = *params.Name
+	}
+
+	if input.CreatedFrom != nil && input.CreatedTo != nil && !input.CreatedFrom.Before(*input.CreatedTo) {
+		return repository.ListUsersInput{}, usersCursor{}, fmt.Errorf("createdFrom must be before createdTo")
+	}
+
+	if params.Cursor != nil && *params.Cursor != "" {
+		after, err := decodeUsersCursor(*params.Cursor)
+		// a cursor only makes sense with the sort and order it was created with
+		if err != nil || after.Sort != cursor.Sort || after.Order != cursor.Order {
+			return repository.ListUsersInput{}, usersCursor{}, fmt.Errorf("invalid cursor")
+		}
+		input.After = &repository.ListUsersCursor{
+			UserID:    after.UserID,
+			CreatedAt: after.CreatedAt,
+			FullName:  after.FullName,
+		}
+	}
+
+	return input, cursor, nil
+}
+
+func validateSuspendRequest(req generated.SuspendUserRequest, callerID int, userID int) error {
+	if callerID == userID {
+		return fmt.Errorf("cannot suspend yourself")
+	}
+
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if len(reason) > 255 {
+		return fmt.Errorf("reason must be at most 255 characters")
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return fmt.Errorf("expiresAt must be in the future")
+	}
+
+	return nil
+}
+
+func encodeUsersCursor(cursor usersCursor) (string, error) {
+	value, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+func decodeUsersCursor(value string) (usersCursor, error) {
+	var cursor usersCursor
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return usersCursor{}, err
+	}
+	err = json.Unmarshal(raw, &cursor)
+	if err != nil {
+		return usersCursor{}, err
+	}
+	return cursor, nil
+}
+
+func userResponse(user repository.GetUserOutput) generated.UserResponse {
+	return generated.UserResponse{
+		Id:          user.UserID,
+		Name:        user.FullName,
+		PhoneNumber: user.PhoneNumber,
+		CreatedAt:   user.CreatedAt,
+	}
+}
@@ -0,0 +1,604 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/revocation"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_ListUsers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockAuthz := authz.NewMockAuthzMethod(ctrl)
+	tokenBody := token.TokenBody{
+		UserID: 1,
+		Roles:  []string{"admin"},
+	}
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	limit := 1
+	invalidLimit := 101
+	sortByName := generated.ListUsersParamsSort("fullName")
+	invalidSort := generated.ListUsersParamsSort("password")
+	orderAsc := generated.ListUsersParamsOrder("asc")
+	phonePrefix := "+6281"
+	name := "doe"
+	nextCursor, _ := encodeUsersCursor(usersCursor{Sort: "fullName", Order: "asc", UserID: 2, CreatedAt: createdAt, FullName: "Jane Doe"})
+	otherCursor, _ := encodeUsersCursor(usersCursor{Sort: "createdAt", Order: "desc", UserID: 2})
+	invalidCursor := "not a cursor"
+	allowed := func() {
+		mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersRead).Return(true, nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name      string
+		params    generated.ListUsersParams
+		tokenBody token.TokenBody
+		mockFunc  func()
+		want      want
+	}{
+		{
+			name:      "success flow with next page",
+			params:    generated.ListUsersParams{Limit: &limit, Sort: &sortByName, Order: &orderAsc, PhonePrefix: &phonePrefix, Name: &name},
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().ListUsers(gomock.Any(), repository.ListUsersInput{
+					PhonePrefix: "+6281",
+					Name:        "doe",
+					SortBy:      repository.UserSortFullName,
+					Limit:       2,
+				}).Return(repository.ListUsersOutput{
+					Users: []repository.GetUserOutput{
+						{UserID: 2, PhoneNumber: "+6281234567891", FullName: "Jane Doe", CreatedAt: createdAt},
+						{UserID: 1, PhoneNumber: "+6281234567890", FullName: "John Doe", CreatedAt: createdAt},
+					},
+				}, nil)
+			},
+			want: want{
+				code: 200,
+				body: fmt.Sprintf(`{"nextCursor":"%s","users":[{"createdAt":"2024-01-02T03:04:05Z","id":2,"name":"Jane Doe","phoneNumber":"+6281234567891"}]}`, nextCursor),
+			},
+		},
+		{
+			name:      "success flow last page with cursor",
+			params:    generated.ListUsersParams{Sort: &sortByName, Order: &orderAsc, Cursor: &nextCursor},
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().ListUsers(gomock.Any(), repository.ListUsersInput{
+					SortBy: repository.UserSortFullName,
+					After: &repository.ListUsersCursor{
+						UserID:    2,
+						CreatedAt: createdAt,
+						FullName:  "Jane Doe",
+					},
+					Limit: 21,
+				}).Return(repository.ListUsersOutput{
+					Users: []repository.GetUserOutput{
+						{UserID: 1, PhoneNumber: "+6281234567890", FullName: "John Doe", CreatedAt: createdAt},
+					},
+				}, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"users":[{"createdAt":"2024-01-02T03:04:05Z","id":1,"name":"John Doe","phoneNumber":"+6281234567890"}]}`,
+			},
+		},
+		{
+			name:     "failed flow invalid token",
+			mockFunc: func() {},
+			want: want{
+				code: 403,
+				body: `{"message":"invalid token"}`,
+			},
+		},
+		{
+			name:      "failed flow without permission",
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersRead).Return(false, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"permission users:read is required"}`,
+			},
+		},
+		{
+			name:      "failed flow on authz has permission",
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersRead).Return(false, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name:      "failed flow invalid limit",
+			params:    generated.ListUsersParams{Limit: &invalidLimit},
+			tokenBody: tokenBody,
+			mockFunc:  allowed,
+			want: want{
+				code: 400,
+				body: `{"message":"limit must be between 1 and 100"}`,
+			},
+		},
+		{
+			name:      "failed flow invalid sort",
+			params:    generated.ListUsersParams{Sort: &invalidSort},
+			tokenBody: tokenBody,
+			mockFunc:  allowed,
+			want: want{
+				code: 400,
+				body: `{"message":"sort must be one of id, createdAt or fullName"}`,
+			},
+		},
+		{
+			name:      "failed flow invalid created range",
+			params:    generated.ListUsersParams{CreatedFrom: &createdAt, CreatedTo: &createdAt},
+			tokenBody: tokenBody,
+			mockFunc:  allowed,
+			want: want{
+				code: 400,
+				body: `{"message":"createdFrom must be before createdTo"}`,
+			},
+		},
+		{
+			name:      "failed flow invalid cursor",
+			params:    generated.ListUsersParams{Cursor: &invalidCursor},
+			tokenBody: tokenBody,
+			mockFunc:  allowed,
+			want: want{
+				code: 400,
+				body: `{"message":"invalid cursor"}`,
+			},
+		},
+		{
+			name:      "failed flow cursor of another sort",
+			params:    generated.ListUsersParams{Sort: &sortByName, Order: &orderAsc, Cursor: &otherCursor},
+			tokenBody: tokenBody,
+			mockFunc:  allowed,
+			want: want{
+				code: 400,
+				body: `{"message":"invalid cursor"}`,
+			},
+		},
+		{
+			name:      "failed flow on repository list users",
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().ListUsers(gomock.Any(), gomock.Any()).Return(repository.ListUsersOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Authz:      mockAuthz,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			if tt.tokenBody.UserID > 0 {
+				ctx.Set("user_id", tt.tokenBody.UserID)
+				ctx.Set("token", tt.tokenBody)
+			}
+
+			handler.ListUsers(ctx, tt.params)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("ListUsers status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("ListUsers Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_GetUserById(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockAuthz := authz.NewMockAuthzMethod(ctrl)
+	tokenBody := token.TokenBody{
+		UserID: 1,
+		Roles:  []string{"support"},
+	}
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	allowed := func() {
+		mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersRead).Return(true, nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name      string
+		id        int
+		tokenBody token.TokenBody
+		mockFunc  func()
+		want      want
+	}{
+		{
+			name:      "success flow",
+			id:        2,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{
+					UserID: 2,
+				}).Return(repository.GetUserOutput{
+					UserID:      2,
+					PhoneNumber: "+6281234567891",
+					FullName:    "Jane Doe",
+					CreatedAt:   createdAt,
+				}, nil)
+			},
+			want: want{
+				code: 200,
+				body: `{"createdAt":"2024-01-02T03:04:05Z","id":2,"name":"Jane Doe","phoneNumber":"+6281234567891"}`,
+			},
+		},
+		{
+			name:      "failed flow without permission",
+			id:        2,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersRead).Return(false, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"permission users:read is required"}`,
+			},
+		},
+		{
+			name:      "failed flow user not found",
+			id:        2,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 404,
+				body: `{"message":"user not found"}`,
+			},
+		},
+		{
+			name:      "failed flow on repository get user",
+			id:        2,
+			tokenBody: tokenBody,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{}, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Authz:      mockAuthz,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d", tt.id), nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.Set("user_id", tt.tokenBody.UserID)
+			ctx.Set("token", tt.tokenBody)
+
+			handler.GetUserById(ctx, tt.id)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("GetUserById status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("GetUserById Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_SuspendUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockAuthz := authz.NewMockAuthzMethod(ctrl)
+	mockRevocation := revocation.NewMockRevocationMethod(ctrl)
+	tokenBody := token.TokenBody{
+		UserID: 1,
+		Roles:  []string{"admin"},
+	}
+	expiresAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	allowed := func() {
+		mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersWrite).Return(true, nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		id       int
+		body     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow",
+			id:   2,
+			body: fmt.Sprintf(`{"reason":" spam ","expiresAt":"%s"}`, expiresAt.Format(time.RFC3339)),
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), repository.GetUserInput{
+					UserID: 2,
+				}).Return(repository.GetUserOutput{UserID: 2}, nil)
+				mockRepo.EXPECT().SuspendUser(gomock.Any(), repository.SuspendUserInput{
+					UserID:      2,
+					Reason:      "spam",
+					SuspendedBy: 1,
+					ExpiresAt:   &expiresAt,
+				}).Return(nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 2).Return(nil)
+				mockRepo.EXPECT().RevokeUserRefreshTokens(gomock.Any(), 2).Return(nil)
+			},
+			want: want{
+				code: 204,
+			},
+		},
+		{
+			name: "failed flow without permission",
+			id:   2,
+			body: `{"reason":"spam"}`,
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersWrite).Return(false, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"permission users:write is required"}`,
+			},
+		},
+		{
+			name:     "failed flow suspend yourself",
+			id:       1,
+			body:     `{"reason":"spam"}`,
+			mockFunc: allowed,
+			want: want{
+				code: 400,
+				body: `{"message":"cannot suspend yourself"}`,
+			},
+		},
+		{
+			name:     "failed flow empty reason",
+			id:       2,
+			body:     `{"reason":" "}`,
+			mockFunc: allowed,
+			want: want{
+				code: 400,
+				body: `{"message":"reason is required"}`,
+			},
+		},
+		{
+			name:     "failed flow expiry in the past",
+			id:       2,
+			body:     `{"reason":"spam","expiresAt":"2020-01-01T00:00:00Z"}`,
+			mockFunc: allowed,
+			want: want{
+				code: 400,
+				body: `{"message":"expiresAt must be in the future"}`,
+			},
+		},
+		{
+			name: "failed flow user not found",
+			id:   2,
+			body: `{"reason":"spam"}`,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{}, fmt.Errorf("sql: no rows in result set"))
+			},
+			want: want{
+				code: 404,
+				body: `{"message":"user not found"}`,
+			},
+		},
+		{
+			name: "failed flow on repository suspend user",
+			id:   2,
+			body: `{"reason":"spam"}`,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{UserID: 2}, nil)
+				mockRepo.EXPECT().SuspendUser(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow on revocation revoke all tokens",
+			id:   2,
+			body: `{"reason":"spam"}`,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{UserID: 2}, nil)
+				mockRepo.EXPECT().SuspendUser(gomock.Any(), gomock.Any()).Return(nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 2).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+		{
+			name: "failed flow on repository revoke refresh tokens",
+			id:   2,
+			body: `{"reason":"spam"}`,
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(repository.GetUserOutput{UserID: 2}, nil)
+				mockRepo.EXPECT().SuspendUser(gomock.Any(), gomock.Any()).Return(nil)
+				mockRevocation.EXPECT().RevokeAllTokens(gomock.Any(), 2).Return(nil)
+				mockRepo.EXPECT().RevokeUserRefreshTokens(gomock.Any(), 2).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Revocation: mockRevocation,
+				Authz:      mockAuthz,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/users/%d/suspend", tt.id), strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.Set("user_id", tokenBody.UserID)
+			ctx.Set("token", tokenBody)
+
+			handler.SuspendUser(ctx, tt.id)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("SuspendUser status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("SuspendUser Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
+
+func TestServer_ReactivateUser(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	mockAuthz := authz.NewMockAuthzMethod(ctrl)
+	tokenBody := token.TokenBody{
+		UserID: 1,
+		Roles:  []string{"admin"},
+	}
+	allowed := func() {
+		mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersWrite).Return(true, nil)
+	}
+	type want struct {
+		body string
+		code int
+	}
+	tests := []struct {
+		name     string
+		mockFunc func()
+		want     want
+	}{
+		{
+			name: "success flow",
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().ReactivateUser(gomock.Any(), repository.ReactivateUserInput{
+					UserID:        2,
+					ReactivatedBy: 1,
+				}).Return(nil)
+			},
+			want: want{
+				code: 204,
+			},
+		},
+		{
+			name: "failed flow without permission",
+			mockFunc: func() {
+				mockAuthz.EXPECT().HasPermission(gomock.Any(), tokenBody, authz.PermissionUsersWrite).Return(false, nil)
+			},
+			want: want{
+				code: 403,
+				body: `{"message":"permission users:write is required"}`,
+			},
+		},
+		{
+			name: "failed flow user not suspended",
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().ReactivateUser(gomock.Any(), gomock.Any()).Return(repository.ErrUserNotSuspended)
+			},
+			want: want{
+				code: 409,
+				body: `{"message":"user is not suspended"}`,
+			},
+		},
+		{
+			name: "failed flow on repository reactivate user",
+			mockFunc: func() {
+				allowed()
+				mockRepo.EXPECT().ReactivateUser(gomock.Any(), gomock.Any()).Return(fmt.Errorf("some error"))
+			},
+			want: want{
+				code: 500,
+				body: `{"message":"some error"}`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewServer(NewServerOptions{
+				Repository: mockRepo,
+				Authz:      mockAuthz,
+			})
+			tt.mockFunc()
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/users/2/reactivate", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.Set("user_id", tokenBody.UserID)
+			ctx.Set("token", tokenBody)
+
+			handler.ReactivateUser(ctx, 2)
+
+			if rec.Code != tt.want.code {
+				t.Fatalf("ReactivateUser status code got =%d, want %d \n", rec.Code, tt.want.code)
+			}
+
+			if !reflect.DeepEqual(tt.want.body, strings.ReplaceAll(string(rec.Body.Bytes()), "\n", "")) {
+				t.Fatalf("ReactivateUser Response body got =%s, want %s \n", string(rec.Body.Bytes()), tt.want.body)
+			}
+		})
+	}
+}
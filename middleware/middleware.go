@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/ratelimit"
+	"github.com/SawitProRecruitment/UserService/pkg/revocation"
 	"github.com/SawitProRecruitment/UserService/pkg/token"
 	"github.com/labstack/echo/v4"
 )
@@ -16,8 +18,16 @@ func (s *Server) MiddlewareLogger(next echo.HandlerFunc) echo.HandlerFunc {
 		fmt.Println("Request received:", c.Request().Method, c.Request().URL.Path)
 
 		var excludePaths = map[string]bool{
-			"/login":    true,
-			"/register": true,
+			"/login":                 true,
+			"/login/mfa":             true,
+			"/login/otp/request":     true,
+			"/login/otp/verify":      true,
+			"/register":              true,
+			"/password/forgot":       true,
+			"/password/reset":        true,
+			"/token/refresh":         true,
+			"/.well-known/jwks.json": true,
+			"/oauth/introspect":      true,
 		}
 
 		if !excludePaths[c.Request().URL.Path] {
@@ -38,7 +48,22 @@ func (s *Server) MiddlewareLogger(next echo.HandlerFunc) echo.HandlerFunc {
 				})
 			}
 
+			revoked, err := s.Revocation.IsRevoked(c.Request().Context(), body)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+					Message: err.Error(),
+				})
+			}
+
+			if revoked {
+				return c.JSON(http.StatusForbidden, generated.ErrorResponse{
+					Message: "Token has been revoked",
+				})
+			}
+
 			c.Set("user_id", body.UserID)
+			c.Set("scopes", body.Scopes)
+			c.Set("token", body)
 			return next(c)
 		}
 		// Call the next middleware or handler
@@ -47,15 +72,31 @@ func (s *Server) MiddlewareLogger(next echo.HandlerFunc) echo.HandlerFunc {
 }
 
 type NewMiddlewareOptions struct {
-	Token token.TokenMethod
+	Token      token.TokenMethod
+	Revocation revocation.RevocationMethod
+	RateLimit  ratelimit.RateLimitMethod
+	// RequiredRoles, RequiredScopes and RateLimits are keyed by http method and echo route path, e.g. "GET /users/:id"
+	RequiredRoles  map[string][]string
+	RequiredScopes map[string][]string
+	RateLimits     map[string][]ratelimit.Policy
 }
 
 type Server struct {
-	Token token.TokenMethod
+	Token          token.TokenMethod
+	Revocation     revocation.RevocationMethod
+	RateLimit      ratelimit.RateLimitMethod
+	RequiredRoles  map[string][]string
+	RequiredScopes map[string][]string
+	RateLimits     map[string][]ratelimit.Policy
 }
 
 func NewMiddlewareServer(opt NewMiddlewareOptions) *Server {
 	return &Server{
-		Token: opt.Token,
+		Token:          opt.Token,
+		Revocation:     opt.Revocation,
+		RateLimit:      opt.RateLimit,
+		RequiredRoles:  opt.RequiredRoles,
+		RequiredScopes: opt.RequiredScopes,
+		RateLimits:     opt.RateLimits,
 	}
 }
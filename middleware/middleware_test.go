@@ -6,6 +6,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/SawitProRecruitment/UserService/pkg/revocation"
 	"github.com/SawitProRecruitment/UserService/pkg/token"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/mock/gomock"
@@ -15,8 +16,10 @@ func TestServer_MiddlewareLogger(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mockToken := token.NewMockTokenMethod(ctrl)
+	mockRevocation := revocation.NewMockRevocationMethod(ctrl)
 	mid := NewMiddlewareServer(NewMiddlewareOptions{
-		Token: mockToken,
+		Token:      mockToken,
+		Revocation: mockRevocation,
 	})
 	e := echo.New()
 	e.Use(mid.MiddlewareLogger)
@@ -46,12 +49,41 @@ func TestServer_MiddlewareLogger(t *testing.T) {
 				mockToken.EXPECT().ValidateToken("eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9").Return(token.TokenBody{
 					UserID: 1,
 				}, nil)
+				mockRevocation.EXPECT().IsRevoked(gomock.Any(), token.TokenBody{
+					UserID: 1,
+				}).Return(false, nil)
 			},
 			want: want{
 				code:   http.StatusOK,
 				userID: "1",
 			},
 		},
+		{
+			name:  "revoked token",
+			token: "Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9",
+			mockFunc: func() {
+				mockToken.EXPECT().ValidateToken("eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9").Return(token.TokenBody{
+					UserID: 1,
+				}, nil)
+				mockRevocation.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(true, nil)
+			},
+			want: want{
+				code: http.StatusForbidden,
+			},
+		},
+		{
+			name:  "error check revocation",
+			token: "Bearer eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9",
+			mockFunc: func() {
+				mockToken.EXPECT().ValidateToken("eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9").Return(token.TokenBody{
+					UserID: 1,
+				}, nil)
+				mockRevocation.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, fmt.Errorf("some error"))
+			},
+			want: want{
+				code: http.StatusInternalServerError,
+			},
+		},
 		{
 			name:  "invalid token",
 			token: "Bearer",
@@ -0,0 +1,198 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/ratelimit"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+)
+
+// rateLimitExtension is the operation extension in api.yml listing the rate limit policies of it
+const rateLimitExtension = "x-rate-limit"
+
+// maxPhoneNumberBodyBytes bounds the body read to find the phone number, the requests keyed by
+// phone number are small json bodies and the body is read before any handler refuses it
+const maxPhoneNumberBodyBytes = 4 << 10
+
+// errRequestBodyTooLarge is returned by requestPhoneNumber for a body over maxPhoneNumberBodyBytes
+var errRequestBodyTooLarge = errors.New("request body too large")
+
+// MiddlewareRateLimit is func to refuse requests once a rate limit policy of the route is used up,
+// it must run after MiddlewareLogger so policies keyed by user can see the caller
+func (s *Server) MiddlewareRateLimit(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		route := routeKey(c.Request().Method, c.Path())
+		policies := s.RateLimits[route]
+		if len(policies) == 0 {
+			return next(c)
+		}
+
+		// the most restrictive policy is the one reported in the headers
+		var reported ratelimit.Result
+		for i, policy := range policies {
+			caller, err := rateLimitCaller(c, policy.Key)
+			if errors.Is(err, errRequestBodyTooLarge) {
+				return c.JSON(http.StatusRequestEntityTooLarge, generated.ErrorResponse{
+					Message: err.Error(),
+				})
+			}
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, generated.ErrorResponse{
+					Message: err.Error(),
+				})
+			}
+
+			result, err := s.RateLimit.Allow(c.Request().Context(), fmt.Sprintf("%s|%s:%s", route, policy.Key, caller), policy)
+			if err != nil {
+				return c.JSON(http.StatusInternalServerError, generated.ErrorResponse{
+					Message: err.Error(),
+				})
+			}
+
+			if !result.Allowed {
+				setRateLimitHeaders(c, result)
+				c.Response().Header().Set("Retry-After", strconv.Itoa(seconds(result.RetryAfter)))
+				return c.JSON(http.StatusTooManyRequests, generated.ErrorResponse{
+					Message: "Too many requests",
+				})
+			}
+
+			if i == 0 || result.Remaining < reported.Remaining {
+				reported = result
+			}
+		}
+
+		setRateLimitHeaders(c, reported)
+		return next(c)
+	}
+}
+
+// RateLimits is func to read x-rate-limit of every operation in the spec,
+// the result is keyed by http method and echo route path
+func RateLimits(swagger *openapi3.T) (map[string][]ratelimit.Policy, error) {
+	limits := map[string][]ratelimit.Policy{}
+	for path, item := range swagger.Paths {
+		for method, operation := range item.Operations() {
+			value, ok := operation.Extensions[rateLimitExtension]
+			if !ok {
+				continue
+			}
+
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s of %s %s must be a list", rateLimitExtension, method, path)
+			}
+
+			policies := make([]ratelimit.Policy, 0, len(list))
+			for _, item := range list {
+				policy, err := rateLimitPolicy(item)
+				if err != nil {
+					return nil, fmt.Errorf("%s of %s %s: %w", rateLimitExtension, method, path, err)
+				}
+				policies = append(policies, policy)
+			}
+
+			limits[routeKey(method, pathParam.ReplaceAllString(path, ":$1"))] = policies
+		}
+	}
+	return limits, nil
+}
+
+// rateLimitPolicy is func to read one policy, e.g. {key: ip, limit: 5, period: 1m}
+func rateLimitPolicy(item interface{}) (ratelimit.Policy, error) {
+	fields, ok := item.(map[string]interface{})
+	if !ok {
+		return ratelimit.Policy{}, fmt.Errorf("policy must be an object")
+	}
+
+	key, _ := fields["key"].(string)
+	if key != ratelimit.KeyIP && key != ratelimit.KeyUser && key != ratelimit.KeyPhoneNumber {
+		return ratelimit.Policy{}, fmt.Errorf("key must be %s, %s or %s", ratelimit.KeyIP, ratelimit.KeyUser, ratelimit.KeyPhoneNumber)
+	}
+
+	limit, ok := fields["limit"].(float64)
+	if !ok || limit < 1 || limit != math.Trunc(limit) {
+		return ratelimit.Policy{}, fmt.Errorf("limit must be a positive integer")
+	}
+
+	period, _ := fields["period"].(string)
+	duration, err := time.ParseDuration(period)
+	if err != nil || duration <= 0 {
+		return ratelimit.Policy{}, fmt.Errorf("period must be a positive duration")
+	}
+
+	return ratelimit.Policy{
+		Key:    key,
+		Limit:  int(limit),
+		Period: duration,
+	}, nil
+}
+
+// rateLimitCaller is func to tell who the bucket of a policy belongs to,
+// requests without a user or a phone number fall back to the client ip
+func rateLimitCaller(c echo.Context, key string) (string, error) {
+	switch key {
+	case ratelimit.KeyUser:
+		if userID, ok := c.Get("user_id").(int); ok {
+			return strconv.Itoa(userID), nil
+		}
+	case ratelimit.KeyPhoneNumber:
+		phoneNumber, err := requestPhoneNumber(c)
+		if err != nil {
+			return "", err
+		}
+		if phoneNumber != "" {
+			return phoneNumber, nil
+		}
+	}
+	return c.RealIP(), nil
+}
+
+// requestPhoneNumber is func to read phoneNumber from the json body of at most maxPhoneNumberBodyBytes,
+// the body is put back so the handler can still bind it
+func requestPhoneNumber(c echo.Context) (string, error) {
+	req := c.Request()
+	if req.Body == nil {
+		return "", nil
+	}
+
+	raw, err := io.ReadAll(http.MaxBytesReader(c.Response(), req.Body, maxPhoneNumberBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return "", errRequestBodyTooLarge
+		}
+		return "", fmt.Errorf("invalid request body")
+	}
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+
+	var body struct {
+		PhoneNumber string `json:"phoneNumber"`
+	}
+	// a body that is not json is left for the handler to refuse
+	json.Unmarshal(raw, &body)
+
+	return body.PhoneNumber, nil
+}
+
+func setRateLimitHeaders(c echo.Context, result ratelimit.Result) {
+	header := c.Response().Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+}
+
+// seconds is func to round a duration up to whole seconds for the headers
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
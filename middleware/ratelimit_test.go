@@ -0,0 +1,289 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/ratelimit"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/mock/gomock"
+)
+
+func TestServer_MiddlewareRateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRateLimit := ratelimit.NewMockRateLimitMethod(ctrl)
+	ipPolicy := ratelimit.Policy{Key: ratelimit.KeyIP, Limit: 30, Period: time.Minute}
+	phonePolicy := ratelimit.Policy{Key: ratelimit.KeyPhoneNumber, Limit: 5, Period: time.Minute}
+	userPolicy := ratelimit.Policy{Key: ratelimit.KeyUser, Limit: 600, Period: time.Minute}
+	mid := NewMiddlewareServer(NewMiddlewareOptions{
+		RateLimit: mockRateLimit,
+		RateLimits: map[string][]ratelimit.Policy{
+			"POST /login":     {ipPolicy, phonePolicy},
+			"GET /my-profile": {userPolicy},
+		},
+	})
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.Request().Header.Get("Authorization") != "" {
+				c.Set("user_id", 1)
+			}
+			return next(c)
+		}
+	})
+	e.Use(mid.MiddlewareRateLimit)
+	e.POST("/login", func(c echo.Context) error {
+		// the handler must still be able to read the body
+		body, _ := io.ReadAll(c.Request().Body)
+		return c.String(http.StatusOK, string(body))
+	})
+	e.GET("/my-profile", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, nil)
+	})
+	e.GET("/jwks", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, nil)
+	})
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		auth        bool
+		mockFunc    func()
+		wantCode    int
+		wantHeaders map[string]string
+	}{
+		{
+			name:     "success on route without policies",
+			method:   http.MethodGet,
+			path:     "/jwks",
+			mockFunc: func() {},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit": "",
+			},
+		},
+		{
+			name:   "success reports the most restrictive policy",
+			method: http.MethodPost,
+			path:   "/login",
+			body:   `{"phoneNumber":"+628123456789","password":"@Password1"}`,
+			mockFunc: func() {
+				mockRateLimit.EXPECT().Allow(gomock.Any(), "POST /login|ip:192.0.2.1", ipPolicy).Return(ratelimit.Result{
+					Allowed: true, Limit: 30, Remaining: 29, Reset: 2 * time.Second,
+				}, nil)
+				mockRateLimit.EXPECT().Allow(gomock.Any(), "POST /login|phone_number:+628123456789", phonePolicy).Return(ratelimit.Result{
+					Allowed: true, Limit: 5, Remaining: 3, Reset: 36 * time.Second,
+				}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit":     "5",
+				"RateLimit-Remaining": "3",
+				"RateLimit-Reset":     "36",
+			},
+		},
+		{
+			name:   "success phone number falls back to ip",
+			method: http.MethodPost,
+			path:   "/login",
+			body:   `not json`,
+			mockFunc: func() {
+				mockRateLimit.EXPECT().Allow(gomock.Any(), "POST /login|ip:192.0.2.1", ipPolicy).Return(ratelimit.Result{
+					Allowed: true, Limit: 30, Remaining: 29,
+				}, nil)
+				mockRateLimit.EXPECT().Allow(gomock.Any(), "POST /login|phone_number:192.0.2.1", phonePolicy).Return(ratelimit.Result{
+					Allowed: true, Limit: 5, Remaining: 4,
+				}, nil)
+			},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:   "success keyed by user",
+			method: http.MethodGet,
+			path:   "/my-profile",
+			auth:   true,
+			mockFunc: func() {
+				mockRateLimit.EXPECT().Allow(gomock.Any(), "GET /my-profile|user:1", userPolicy).Return(ratelimit.Result{
+					Allowed: true, Limit: 600, Remaining: 599, Reset: 100 * time.Millisecond,
+				}, nil)
+			},
+			wantCode: http.StatusOK,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit":     "600",
+				"RateLimit-Remaining": "599",
+				"RateLimit-Reset":     "1",
+			},
+		},
+		{
+			name:   "failed bucket empty",
+			method: http.MethodPost,
+			path:   "/login",
+			body:   `{"phoneNumber":"+628123456789","password":"@Password1"}`,
+			mockFunc: func() {
+				mockRateLimit.EXPECT().Allow(gomock.Any(), gomock.Any(), ipPolicy).Return(ratelimit.Result{
+					Allowed: true, Limit: 30, Remaining: 29,
+				}, nil)
+				mockRateLimit.EXPECT().Allow(gomock.Any(), gomock.Any(), phonePolicy).Return(ratelimit.Result{
+					Limit: 5, Remaining: 0, Reset: time.Minute, RetryAfter: 11500 * time.Millisecond,
+				}, nil)
+			},
+			wantCode: http.StatusTooManyRequests,
+			wantHeaders: map[string]string{
+				"RateLimit-Limit":     "5",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "60",
+				"Retry-After":         "12",
+			},
+		},
+		{
+			name:   "failed body too large to read the phone number",
+			method: http.MethodPost,
+			path:   "/login",
+			body:   `{"phoneNumber":"+628123456789","password":"` + strings.Repeat("a", 5000) + `"}`,
+			mockFunc: func() {
+				mockRateLimit.EXPECT().Allow(gomock.Any(), gomock.Any(), ipPolicy).Return(ratelimit.Result{
+					Allowed: true, Limit: 30, Remaining: 29,
+				}, nil)
+			},
+			wantCode: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:   "failed on rate limit allow",
+			method: http.MethodGet,
+			path:   "/my-profile",
+			auth:   true,
+			mockFunc: func() {
+				mockRateLimit.EXPECT().Allow(gomock.Any(), gomock.Any(), gomock.Any()).Return(ratelimit.Result{}, fmt.Errorf("some error"))
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mockFunc()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.auth {
+				req.Header.Set("Authorization", "Bearer token")
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Server.MiddlewareRateLimit() = %v, want %v", w.Code, tt.wantCode)
+			}
+			if tt.wantCode == http.StatusOK && tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("Server.MiddlewareRateLimit() body = %v, want %v", w.Body.String(), tt.body)
+			}
+			for name, want := range tt.wantHeaders {
+				if got := w.Header().Get(name); got != want {
+					t.Errorf("Server.MiddlewareRateLimit() header %s = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	operation := func(extensions map[string]interface{}) *openapi3.Operation {
+		return &openapi3.Operation{Extensions: extensions}
+	}
+	policy := func(key string, limit float64, period string) map[string]interface{} {
+		return map[string]interface{}{"key": key, "limit": limit, "period": period}
+	}
+	tests := []struct {
+		name    string
+		paths   openapi3.Paths
+		want    map[string][]ratelimit.Policy
+		wantErr bool
+	}{
+		{
+			name: "success",
+			paths: openapi3.Paths{
+				"/login": &openapi3.PathItem{
+					Post: operation(map[string]interface{}{
+						"x-rate-limit": []interface{}{policy("ip", 30, "1m"), policy("phone_number", 5, "1m")},
+					}),
+				},
+				"/users/{id}": &openapi3.PathItem{
+					Get: operation(map[string]interface{}{
+						"x-rate-limit": []interface{}{policy("user", 600, "1m")},
+					}),
+					Delete: operation(nil),
+				},
+			},
+			want: map[string][]ratelimit.Policy{
+				"POST /login": {
+					{Key: ratelimit.KeyIP, Limit: 30, Period: time.Minute},
+					{Key: ratelimit.KeyPhoneNumber, Limit: 5, Period: time.Minute},
+				},
+				"GET /users/:id": {
+					{Key: ratelimit.KeyUser, Limit: 600, Period: time.Minute},
+				},
+			},
+		},
+		{
+			name: "failed policies is not a list",
+			paths: openapi3.Paths{
+				"/login": &openapi3.PathItem{
+					Post: operation(map[string]interface{}{
+						"x-rate-limit": policy("ip", 30, "1m"),
+					}),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "failed unknown key",
+			paths: openapi3.Paths{
+				"/login": &openapi3.PathItem{
+					Post: operation(map[string]interface{}{
+						"x-rate-limit": []interface{}{policy("email", 30, "1m")},
+					}),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "failed limit is not a positive integer",
+			paths: openapi3.Paths{
+				"/login": &openapi3.PathItem{
+					Post: operation(map[string]interface{}{
+						"x-rate-limit": []interface{}{policy("ip", 0.5, "1m")},
+					}),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "failed invalid period",
+			paths: openapi3.Paths{
+				"/login": &openapi3.PathItem{
+					Post: operation(map[string]interface{}{
+						"x-rate-limit": []interface{}{policy("ip", 30, "a minute")},
+					}),
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RateLimits(&openapi3.T{Paths: tt.paths})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RateLimits() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RateLimits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+)
+
+// requiredRolesExtension is the operation extension in api.yml listing the roles allowed to call it
+const requiredRolesExtension = "x-required-roles"
+
+var pathParam = regexp.MustCompile(`{([^}]+)}`)
+
+// MiddlewareRole is func to reject callers that have none of the roles required by the route,
+// it must run after MiddlewareLogger which puts the token body in the context
+func (s *Server) MiddlewareRole(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		required := s.RequiredRoles[routeKey(c.Request().Method, c.Path())]
+		if len(required) == 0 {
+			return next(c)
+		}
+
+		body, ok := c.Get("token").(token.TokenBody)
+		if !ok || !containsAny(body.Roles, required) {
+			return c.JSON(http.StatusForbidden, generated.ErrorResponse{
+				Message: "Insufficient role",
+			})
+		}
+
+		return next(c)
+	}
+}
+
+// RequiredRoles is func to read x-required-roles of every operation in the spec,
+// the result is keyed by http method and echo route path
+func RequiredRoles(swagger *openapi3.T) (map[string][]string, error) {
+	return operationLists(swagger, requiredRolesExtension)
+}
+
+// operationLists is func to read an extension holding a list of strings from every operation in the spec
+func operationLists(swagger *openapi3.T, extension string) (map[string][]string, error) {
+	lists := map[string][]string{}
+	for path, item := range swagger.Paths {
+		for method, operation := range item.Operations() {
+			value, ok := operation.Extensions[extension]
+			if !ok {
+				continue
+			}
+
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s of %s %s must be a list", extension, method, path)
+			}
+
+			values := make([]string, 0, len(list))
+			for _, item := range list {
+				name, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s of %s %s must only contain strings", extension, method, path)
+				}
+				values = append(values, name)
+			}
+
+			lists[routeKey(method, pathParam.ReplaceAllString(path, ":$1"))] = values
+		}
+	}
+	return lists, nil
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
+
+func containsAny(values []string, required []string) bool {
+	for _, value := range values {
+		for _, name := range required {
+			if value == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+)
+
+func TestServer_MiddlewareRole(t *testing.T) {
+	mid := NewMiddlewareServer(NewMiddlewareOptions{
+		RequiredRoles: map[string][]string{
+			"GET /users/:id": {"admin", "support"},
+		},
+	})
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if roles, ok := c.Request().Header["Roles"]; ok {
+				c.Set("token", token.TokenBody{UserID: 1, Roles: roles})
+			}
+			return next(c)
+		}
+	})
+	e.Use(mid.MiddlewareRole)
+	e.GET("/users/:id", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, nil)
+	})
+	e.GET("/my-profile", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, nil)
+	})
+	tests := []struct {
+		name     string
+		path     string
+		roles    []string
+		wantCode int
+	}{
+		{
+			name:     "success with required role",
+			path:     "/users/2",
+			roles:    []string{"user", "support"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "success on route without required roles",
+			path:     "/my-profile",
+			roles:    []string{"user"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "failed without required role",
+			path:     "/users/2",
+			roles:    []string{"user"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "failed without token",
+			path:     "/users/2",
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for _, role := range tt.roles {
+				req.Header.Add("Roles", role)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Server.MiddlewareRole() = %v, want %v", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRequiredRoles(t *testing.T) {
+	operation := func(extensions map[string]interface{}) *openapi3.Operation {
+		return &openapi3.Operation{Extensions: extensions}
+	}
+	tests := []struct {
+		name    string
+		paths   openapi3.Paths
+		want    map[string][]string
+		wantErr bool
+	}{
+		{
+			name: "success",
+			paths: openapi3.Paths{
+				"/users/{id}": &openapi3.PathItem{
+					Get: operation(map[string]interface{}{
+						"x-required-roles": []interface{}{"admin", "support"},
+					}),
+					Delete: operation(map[string]interface{}{
+						"x-required-roles": []interface{}{"admin"},
+					}),
+				},
+				"/my-profile": &openapi3.PathItem{
+					Get: operation(nil),
+				},
+			},
+			want: map[string][]string{
+				"GET /users/:id":    {"admin", "support"},
+				"DELETE /users/:id": {"admin"},
+			},
+		},
+		{
+			name: "failed roles is not a list",
+			paths: openapi3.Paths{
+				"/users": &openapi3.PathItem{
+					Get: operation(map[string]interface{}{
+						"x-required-roles": "admin",
+					}),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "failed role is not a string",
+			paths: openapi3.Paths{
+				"/users": &openapi3.PathItem{
+					Get: operation(map[string]interface{}{
+						"x-required-roles": []interface{}{1},
+					}),
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RequiredRoles(&openapi3.T{Paths: tt.paths})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RequiredRoles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RequiredRoles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
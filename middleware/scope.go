@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+)
+
+// requiredScopesExtension is the operation extension in api.yml listing the scopes that allow a scoped token to call it
+const requiredScopesExtension = "x-required-scopes"
+
+// anyScope in x-required-scopes lets every scoped token call the operation, it is for the routes
+// a token holder must always reach, like logging out to revoke the token
+const anyScope = "*"
+
+// MiddlewareScope is func to limit scoped tokens to the routes listing one of their scopes,
+// tokens without scopes are not limited here, it must run after MiddlewareLogger
+func (s *Server) MiddlewareScope(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		body, ok := c.Get("token").(token.TokenBody)
+		if !ok || len(body.Scopes) == 0 {
+			return next(c)
+		}
+
+		// routes without x-required-scopes can't be called with a scoped token
+		required := s.RequiredScopes[routeKey(c.Request().Method, c.Path())]
+		if !containsAny(required, []string{anyScope}) && !containsAny(body.Scopes, required) {
+			return c.JSON(http.StatusForbidden, generated.ErrorResponse{
+				Message: "Insufficient scope",
+			})
+		}
+
+		return next(c)
+	}
+}
+
+// RequiredScopes is func to read x-required-scopes of every operation in the spec,
+// the result is keyed by http method and echo route path
+func RequiredScopes(swagger *openapi3.T) (map[string][]string, error) {
+	return operationLists(swagger, requiredScopesExtension)
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/pkg/authz"
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+)
+
+func TestServer_MiddlewareScope(t *testing.T) {
+	mid := NewMiddlewareServer(NewMiddlewareOptions{
+		RequiredScopes: map[string][]string{
+			"GET /my-profile": {"profile:read"},
+			"POST /logout":    {"*"},
+		},
+	})
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("token", token.TokenBody{UserID: 1, Scopes: c.Request().Header["Scopes"]})
+			return next(c)
+		}
+	})
+	e.Use(mid.MiddlewareScope)
+	e.GET("/my-profile", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, nil)
+	})
+	e.POST("/logout-all", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, nil)
+	})
+	e.POST("/logout", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, nil)
+	})
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		scopes   []string
+		wantCode int
+	}{
+		{
+			name:     "success without scopes",
+			method:   http.MethodPost,
+			path:     "/logout-all",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "success with required scope",
+			method:   http.MethodGet,
+			path:     "/my-profile",
+			scopes:   []string{"profile:read"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "failed without required scope",
+			method:   http.MethodGet,
+			path:     "/my-profile",
+			scopes:   []string{"profile:write"},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "success with any scope on route open to every scoped token",
+			method:   http.MethodPost,
+			path:     "/logout",
+			scopes:   []string{"users:read"},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "failed on route without required scopes",
+			method:   http.MethodPost,
+			path:     "/logout-all",
+			scopes:   []string{"profile:read"},
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for _, scope := range tt.scopes {
+				req.Header.Add("Scopes", scope)
+			}
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Server.MiddlewareScope() = %v, want %v", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+// TestServer_MiddlewareScopeUnverifiedToken checks with the scopes of api.yml that a user who hasn't
+// verified the phone number yet can still log out and delete the account
+func TestServer_MiddlewareScopeUnverifiedToken(t *testing.T) {
+	swagger, err := generated.GetSwagger()
+	if err != nil {
+		t.Fatalf("GetSwagger() error = %v", err)
+	}
+	requiredScopes, err := RequiredScopes(swagger)
+	if err != nil {
+		t.Fatalf("RequiredScopes() error = %v", err)
+	}
+
+	mid := NewMiddlewareServer(NewMiddlewareOptions{
+		RequiredScopes: requiredScopes,
+	})
+	e := echo.New()
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Set("token", token.TokenBody{UserID: 1, Scopes: []string{
+				authz.PermissionProfileRead, authz.PermissionPhoneVerify, authz.PermissionAccountDelete,
+			}})
+			return next(c)
+		}
+	})
+	e.Use(mid.MiddlewareScope)
+	ok := func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}
+	e.POST("/logout", ok)
+	e.POST("/logout-all", ok)
+	e.DELETE("/my-profile", ok)
+	e.PUT("/my-profile", ok)
+
+	tests := []struct {
+		method   string
+		path     string
+		wantCode int
+	}{
+		{method: http.MethodPost, path: "/logout", wantCode: http.StatusNoContent},
+		{method: http.MethodPost, path: "/logout-all", wantCode: http.StatusNoContent},
+		{method: http.MethodDelete, path: "/my-profile", wantCode: http.StatusNoContent},
+		{method: http.MethodPut, path: "/my-profile", wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			e.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Server.MiddlewareScope() = %v, want %v", w.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestRequiredScopes(t *testing.T) {
+	swagger := &openapi3.T{
+		Paths: openapi3.Paths{
+			"/my-profile": &openapi3.PathItem{
+				Get: &openapi3.Operation{Extensions: map[string]interface{}{
+					"x-required-scopes": []interface{}{"profile:read"},
+				}},
+				Put: &openapi3.Operation{Extensions: map[string]interface{}{
+					"x-required-roles": []interface{}{"user"},
+				}},
+			},
+		},
+	}
+
+	got, err := RequiredScopes(swagger)
+	if err != nil {
+		t.Fatalf("RequiredScopes() error = %v", err)
+	}
+	want := map[string][]string{
+		"GET /my-profile": {"profile:read"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RequiredScopes() = %v, want %v", got, want)
+	}
+}
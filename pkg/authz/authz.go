@@ -0,0 +1,97 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// Permissions that can be granted to roles and users, they are also the scopes a token can be limited to
+const (
+	PermissionProfileRead      = "profile:read"
+	PermissionProfileWrite     = "profile:write"
+	PermissionUsersRead        = "users:read"
+	PermissionUsersWrite       = "users:write"
+	PermissionUsersImpersonate = "users:impersonate"
+	PermissionPhoneVerify      = "phone:verify"
+	PermissionAccountDelete    = "account:delete"
+	PermissionMetricsRead      = "metrics:read"
+)
+
+// AuthzConfig is list dependencies of Authz Package
+type AuthzConfig struct {
+	repository repository.RepositoryInterface
+}
+
+// AuthzMethod is list method for Authz Package
+type AuthzMethod interface {
+	Permissions(ctx context.Context, body token.TokenBody) ([]string, error)
+	HasPermission(ctx context.Context, body token.TokenBody, permission string) (bool, error)
+}
+
+type NewAuthzConfig struct {
+	Repository repository.RepositoryInterface
+}
+
+// NewAuthzMethod func to create AuthzMethod interface
+func NewAuthzMethod(cfg NewAuthzConfig) AuthzMethod {
+	return &AuthzConfig{
+		repository: cfg.Repository,
+	}
+}
+
+// Permissions func to list the permissions the token can use, which are the permissions
+// granted to the user through its roles and directly, narrowed down to the token scopes
+func (a *AuthzConfig) Permissions(ctx context.Context, body token.TokenBody) ([]string, error) {
+	granted, err := a.repository.GetUserPermissions(ctx, repository.GetUserPermissionsInput{
+		UserID: body.UserID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body.Scopes) == 0 {
+		return granted.Permissions, nil
+	}
+
+	permissions := []string{}
+	for _, permission := range granted.Permissions {
+		if InScope(body, permission) {
+			permissions = append(permissions, permission)
+		}
+	}
+	return permissions, nil
+}
+
+// HasPermission func to check if the token can use the permission
+func (a *AuthzConfig) HasPermission(ctx context.Context, body token.TokenBody, permission string) (bool, error) {
+	// a scoped token is rejected without asking postgres
+	if !InScope(body, permission) {
+		return false, nil
+	}
+
+	permissions, err := a.Permissions(ctx, body)
+	if err != nil {
+		return false, err
+	}
+
+	return contains(permissions, permission), nil
+}
+
+// InScope func to check if the token scopes allow the permission, a token without scopes allows everything
+func InScope(body token.TokenBody, permission string) bool {
+	if len(body.Scopes) == 0 {
+		return true
+	}
+	return contains(body.Scopes, permission)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/authz/authz.go
+//
+// Generated by this command:
+//
+//	mockgen -source=pkg/authz/authz.go -destination=pkg/authz/authz_mock.go -package=authz
+//
+
+// Package authz is a generated GoMock package.
+package authz
+
+import (
+	context "context"
+	reflect "reflect"
+
+	token "github.com/SawitProRecruitment/UserService/pkg/token"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockAuthzMethod is a mock of AuthzMethod interface.
+type MockAuthzMethod struct {
+	ctrl     *gomock.Controller
+	recorder *MockAuthzMethodMockRecorder
+}
+
+// MockAuthzMethodMockRecorder is the mock recorder for MockAuthzMethod.
+type MockAuthzMethodMockRecorder struct {
+	mock *MockAuthzMethod
+}
+
+// NewMockAuthzMethod creates a new mock instance.
+func NewMockAuthzMethod(ctrl *gomock.Controller) *MockAuthzMethod {
+	mock := &MockAuthzMethod{ctrl: ctrl}
+	mock.recorder = &MockAuthzMethodMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAuthzMethod) EXPECT() *MockAuthzMethodMockRecorder {
+	return m.recorder
+}
+
+// HasPermission mocks base method.
+func (m *MockAuthzMethod) HasPermission(ctx context.Context, body token.TokenBody, permission string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasPermission", ctx, body, permission)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasPermission indicates an expected call of HasPermission.
+func (mr *MockAuthzMethodMockRecorder) HasPermission(ctx, body, permission any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasPermission", reflect.TypeOf((*MockAuthzMethod)(nil).HasPermission), ctx, body, permission)
+}
+
+// Permissions mocks base method.
+func (m *MockAuthzMethod) Permissions(ctx context.Context, body token.TokenBody) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Permissions", ctx, body)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Permissions indicates an expected call of Permissions.
+func (mr *MockAuthzMethodMockRecorder) Permissions(ctx, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Permissions", reflect.TypeOf((*MockAuthzMethod)(nil).Permissions), ctx, body)
+}
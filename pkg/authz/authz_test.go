@@ -0,0 +1,147 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"go.uber.org/mock/gomock"
+)
+
+func TestAuthzConfig_HasPermission(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       token.TokenBody
+		permission string
+		mockFunc   func(mockRepo *repository.MockRepositoryInterface)
+		want       bool
+		wantErr    bool
+	}{
+		{
+			name:       "granted",
+			body:       token.TokenBody{UserID: 1},
+			permission: PermissionUsersRead,
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().GetUserPermissions(gomock.Any(), repository.GetUserPermissionsInput{
+					UserID: 1,
+				}).Return(repository.GetUserPermissionsOutput{
+					Permissions: []string{PermissionProfileRead, PermissionUsersRead},
+				}, nil)
+			},
+			want: true,
+		},
+		{
+			name:       "not granted",
+			body:       token.TokenBody{UserID: 1},
+			permission: PermissionUsersWrite,
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().GetUserPermissions(gomock.Any(), gomock.Any()).Return(repository.GetUserPermissionsOutput{
+					Permissions: []string{PermissionUsersRead},
+				}, nil)
+			},
+			want: false,
+		},
+		{
+			name:       "granted and in scope",
+			body:       token.TokenBody{UserID: 1, Scopes: []string{PermissionProfileRead}},
+			permission: PermissionProfileRead,
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().GetUserPermissions(gomock.Any(), gomock.Any()).Return(repository.GetUserPermissionsOutput{
+					Permissions: []string{PermissionProfileRead, PermissionProfileWrite},
+				}, nil)
+			},
+			want: true,
+		},
+		{
+			name:       "in scope but no longer granted",
+			body:       token.TokenBody{UserID: 1, Scopes: []string{PermissionUsersRead}},
+			permission: PermissionUsersRead,
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().GetUserPermissions(gomock.Any(), gomock.Any()).Return(repository.GetUserPermissionsOutput{
+					Permissions: []string{PermissionProfileRead},
+				}, nil)
+			},
+			want: false,
+		},
+		{
+			name:       "out of scope",
+			body:       token.TokenBody{UserID: 1, Scopes: []string{PermissionProfileRead}},
+			permission: PermissionProfileWrite,
+			mockFunc:   func(mockRepo *repository.MockRepositoryInterface) {},
+			want:       false,
+		},
+		{
+			name:       "error get user permissions",
+			body:       token.TokenBody{UserID: 1},
+			permission: PermissionUsersRead,
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().GetUserPermissions(gomock.Any(), gomock.Any()).Return(repository.GetUserPermissionsOutput{}, fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := repository.NewMockRepositoryInterface(ctrl)
+			tt.mockFunc(mockRepo)
+			a := NewAuthzMethod(NewAuthzConfig{
+				Repository: mockRepo,
+			})
+
+			got, err := a.HasPermission(context.Background(), tt.body, tt.permission)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("AuthzConfig.HasPermission() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("AuthzConfig.HasPermission() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthzConfig_Permissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    token.TokenBody
+		granted []string
+		want    []string
+	}{
+		{
+			name:    "without scopes",
+			body:    token.TokenBody{UserID: 1},
+			granted: []string{PermissionProfileRead, PermissionUsersRead},
+			want:    []string{PermissionProfileRead, PermissionUsersRead},
+		},
+		{
+			name:    "narrowed to scopes",
+			body:    token.TokenBody{UserID: 1, Scopes: []string{PermissionProfileRead, PermissionUsersWrite}},
+			granted: []string{PermissionProfileRead, PermissionUsersRead},
+			want:    []string{PermissionProfileRead},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := repository.NewMockRepositoryInterface(ctrl)
+			mockRepo.EXPECT().GetUserPermissions(gomock.Any(), gomock.Any()).Return(repository.GetUserPermissionsOutput{
+				Permissions: tt.granted,
+			}, nil)
+			a := NewAuthzMethod(NewAuthzConfig{
+				Repository: mockRepo,
+			})
+
+			got, err := a.Permissions(context.Background(), tt.body)
+			if err != nil {
+				t.Fatalf("AuthzConfig.Permissions() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AuthzConfig.Permissions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
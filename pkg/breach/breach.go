@@ -0,0 +1,101 @@
+package breach
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// prefixLength is the number of hex characters of the SHA-1 used to look up a range,
+// only these leave the checker so the password can't be told from the lookup
+const prefixLength = 5
+
+// Checker is list method to tell whether a password is known from a data breach, the file
+// Checker works offline and a range API client can implement it as well
+type Checker interface {
+	IsBreached(ctx context.Context, password string) (bool, error)
+}
+
+// FileConfig is list dependencies of the file Checker
+type FileConfig struct {
+	directory string
+}
+
+type NewFileConfig struct {
+	// Directory holds a <PREFIX>.txt file per SHA-1 prefix of 5 upper-case hex characters, in the
+	// format of the Have I Been Pwned range API: one SUFFIX:COUNT line per password, where SUFFIX
+	// is the remaining 35 hex characters of the SHA-1. A missing file is an empty range.
+	Directory string
+}
+
+// NewFileChecker func to create a Checker that looks the passwords up in a local copy of the ranges
+func NewFileChecker(cfg NewFileConfig) (Checker, error) {
+	info, err := os.Stat(cfg.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed read breached password directory %s, err: %s", cfg.Directory, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("breached password directory %s is not a directory", cfg.Directory)
+	}
+
+	return &FileConfig{
+		directory: cfg.Directory,
+	}, nil
+}
+
+// IsBreached func to look the password up in the range file of its prefix
+func (f *FileConfig) IsBreached(ctx context.Context, password string) (bool, error) {
+	prefix, suffix := splitHash(password)
+
+	file, err := os.Open(filepath.Join(f.directory, prefix+".txt"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	return rangeContains(file, suffix)
+}
+
+// splitHash returns the prefix used to look up the range of the password and the suffix searched in it
+func splitHash(password string) (string, string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return hash[:prefixLength], hash[prefixLength:]
+}
+
+// rangeContains tells whether the suffix is listed in the range, entries with a zero count
+// are the padding the range API may add and don't count
+func rangeContains(r io.Reader, suffix string) (bool, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		entry, count, ok := strings.Cut(line, ":")
+		if !ok {
+			return false, fmt.Errorf("invalid range line %q", line)
+		}
+		if !strings.EqualFold(entry, suffix) {
+			continue
+		}
+
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return false, fmt.Errorf("invalid range line %q", line)
+		}
+		return n > 0, nil
+	}
+	return false, scanner.Err()
+}
@@ -0,0 +1,118 @@
+package breach
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewFileChecker(t *testing.T) {
+	directory := t.TempDir()
+	file := filepath.Join(directory, "5BAA6.txt")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		directory string
+		wantErr   bool
+	}{
+		{
+			name:      "success flow",
+			directory: directory,
+		},
+		{
+			name:      "error flow missing directory",
+			directory: filepath.Join(directory, "missing"),
+			wantErr:   true,
+		},
+		{
+			name:      "error flow not a directory",
+			directory: file,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewFileChecker(NewFileConfig{
+				Directory: tt.directory,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewFileChecker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileConfig_IsBreached(t *testing.T) {
+	directory := t.TempDir()
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	// SHA-1 of "P@ssw0rd" is 21BD12DC183F740EE76F27B78EB39C8AD972A757
+	// SHA-1 of "letmein" is B7A875FC1EA228B9061041B7CEC4BD3C52AB3CE3
+	// SHA-1 of "password!!!" is AAEA999B0A1B2E3C1F5BC5158BDEF65A4F32F04B
+	ranges := map[string]string{
+		"5BAA6.txt": "003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9659365\r\n",
+		"21BD1.txt": "2dc183f740ee76f27b78eb39c8ad972a757:0\n",
+		"B7A87.txt": "not a range\n",
+		"AAEA9.txt": "003D68EB55068C33ACE09247EE4C639306B:3\n",
+	}
+	for name, content := range ranges {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checker, err := NewFileChecker(NewFileConfig{
+		Directory: directory,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name:     "breached password",
+			password: "password",
+			want:     true,
+		},
+		{
+			name:     "password in a range file but not listed",
+			password: "password!!!",
+			want:     false,
+		},
+		{
+			name:     "padding entry is not breached",
+			password: "P@ssw0rd",
+			want:     false,
+		},
+		{
+			name:     "missing range file",
+			password: "correct horse battery staple",
+			want:     false,
+		},
+		{
+			name:     "invalid range file",
+			password: "letmein",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checker.IsBreached(context.Background(), tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FileConfig.IsBreached() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("FileConfig.IsBreached() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package hash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2idPrefix starts every PHC-format Argon2id hash
+const argon2idPrefix = "$argon2id$"
+
+// Argon2Params are the cost parameters of Argon2id, they are stored in every hash
+type Argon2Params struct {
+	// Memory is the memory used by one hash in KiB
+	Memory uint32
+	// Iterations is the number of passes over the memory
+	Iterations uint32
+	// Parallelism is the number of lanes, and of threads used
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params is the second recommended option of RFC 9106 with 64 MiB of memory
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func (p Argon2Params) withDefaults() Argon2Params {
+	if p.Memory == 0 {
+		p.Memory = DefaultArgon2Params.Memory
+	}
+	if p.Iterations == 0 {
+		p.Iterations = DefaultArgon2Params.Iterations
+	}
+	if p.Parallelism == 0 {
+		p.Parallelism = DefaultArgon2Params.Parallelism
+	}
+	if p.SaltLength == 0 {
+		p.SaltLength = DefaultArgon2Params.SaltLength
+	}
+	if p.KeyLength == 0 {
+		p.KeyLength = DefaultArgon2Params.KeyLength
+	}
+	return p
+}
+
+// hashArgon2id hashes the value with a random salt and encodes it as
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+func hashArgon2id(value string, params Argon2Params) ([]byte, error) {
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key := argon2.IDKey([]byte(value), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	encoded := fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	return []byte(encoded), nil
+}
+
+// compareArgon2id hashes the password with the parameters and salt of the hash and compares the keys
+func compareArgon2id(hash string, password string) bool {
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return false
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+func decodeArgon2id(hash string) (Argon2Params, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2Params{}, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+	// argon2 panics on zero iterations or lanes
+	if params.Iterations == 0 || params.Parallelism == 0 {
+		return Argon2Params{}, nil, nil, fmt.Errorf("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2Params{}, nil, nil, err
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
@@ -0,0 +1,201 @@
+package hash
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// BusyError is returned by Executor when every worker is busy and the queue is full
+type BusyError struct {
+	// RetryAfter is how long the caller should wait before trying again
+	RetryAfter time.Duration
+}
+
+func (e *BusyError) Error() string {
+	return "server is busy, try again later"
+}
+
+// DurationMetrics summarizes the durations of one step of hashing
+type DurationMetrics struct {
+	Count uint64
+	Total time.Duration
+	Max   time.Duration
+}
+
+// Mean is the average duration, it is zero before the first observation
+func (m DurationMetrics) Mean() time.Duration {
+	if m.Count == 0 {
+		return 0
+	}
+	return m.Total / time.Duration(m.Count)
+}
+
+func (m *DurationMetrics) observe(d time.Duration) {
+	m.Count++
+	m.Total += d
+	if d > m.Max {
+		m.Max = d
+	}
+}
+
+// ExecutorMetrics is a snapshot of the state of an Executor
+type ExecutorMetrics struct {
+	Workers   int
+	QueueSize int
+	// Running is the number of values being hashed and Queued the number of calls waiting for a worker
+	Running int
+	Queued  int
+	// Completed counts the calls that were hashed, Rejected the calls turned away with BusyError
+	// and Canceled the calls whose context ended while they were queued
+	Completed uint64
+	Rejected  uint64
+	Canceled  uint64
+	// QueueWait is the time from the call until a worker was free
+	QueueWait DurationMetrics
+	// HashDuration is the time spent hashing or comparing
+	HashDuration DurationMetrics
+}
+
+func (m ExecutorMetrics) String() string {
+	return fmt.Sprintf("workers=%d queue_size=%d running=%d queued=%d completed=%d rejected=%d canceled=%d "+
+		"queue_wait_mean=%s queue_wait_max=%s hash_duration_mean=%s hash_duration_max=%s",
+		m.Workers, m.QueueSize, m.Running, m.Queued, m.Completed, m.Rejected, m.Canceled,
+		m.QueueWait.Mean(), m.QueueWait.Max, m.HashDuration.Mean(), m.HashDuration.Max)
+}
+
+// Executor is a HashMethod running the hashing of another HashMethod on a bounded number of workers,
+// so a burst of logins can't take every cpu, or with Argon2id every Argon2Params.Memory, of the server
+type Executor struct {
+	method     HashMethod
+	slots      chan struct{}
+	queueSize  int
+	retryAfter time.Duration
+
+	mu      sync.Mutex
+	metrics ExecutorMetrics
+}
+
+type NewExecutorConfig struct {
+	// Workers is the number of values hashed at the same time, default is runtime.NumCPU()
+	Workers int
+	// QueueSize is the number of calls waiting for a worker, calls beyond it get BusyError at once
+	QueueSize int
+	// RetryAfter is given to the callers turned away, default is one second
+	RetryAfter time.Duration
+}
+
+// NewExecutor func to wrap a HashMethod in an Executor
+func NewExecutor(method HashMethod, cfg NewExecutorConfig) *Executor {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	queueSize := cfg.QueueSize
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	retryAfter := cfg.RetryAfter
+	if retryAfter <= 0 {
+		retryAfter = time.Second
+	}
+
+	return &Executor{
+		method:     method,
+		slots:      make(chan struct{}, workers),
+		queueSize:  queueSize,
+		retryAfter: retryAfter,
+		metrics: ExecutorMetrics{
+			Workers:   workers,
+			QueueSize: queueSize,
+		},
+	}
+}
+
+// HashValue func to hash value on a worker
+func (e *Executor) HashValue(ctx context.Context, value string) ([]byte, error) {
+	var hash []byte
+	err := e.run(ctx, func() error {
+		var err error
+		hash, err = e.method.HashValue(ctx, value)
+		return err
+	})
+	return hash, err
+}
+
+// CompareValue func to compare hashed value with password on a worker
+func (e *Executor) CompareValue(ctx context.Context, hash string, password string) (bool, error) {
+	var match bool
+	err := e.run(ctx, func() error {
+		var err error
+		match, err = e.method.CompareValue(ctx, hash, password)
+		return err
+	})
+	return match, err
+}
+
+// NeedsRehash func to tell whether the hash should be hashed again, it only parses the hash so it isn't queued
+func (e *Executor) NeedsRehash(hash string) bool {
+	return e.method.NeedsRehash(hash)
+}
+
+// Metrics func to get a snapshot of the executor metrics
+func (e *Executor) Metrics() ExecutorMetrics {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.metrics
+}
+
+// run waits for a free worker and runs fn on it, it gives up when the queue is full or the context ends
+func (e *Executor) run(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	select {
+	case e.slots <- struct{}{}:
+	default:
+		e.mu.Lock()
+		if e.metrics.Queued >= e.queueSize {
+			e.metrics.Rejected++
+			e.mu.Unlock()
+			return &BusyError{RetryAfter: e.retryAfter}
+		}
+		e.metrics.Queued++
+		e.mu.Unlock()
+
+		select {
+		case e.slots <- struct{}{}:
+			e.mu.Lock()
+			e.metrics.Queued--
+			e.mu.Unlock()
+		case <-ctx.Done():
+			e.mu.Lock()
+			e.metrics.Queued--
+			e.metrics.Canceled++
+			e.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	defer func() { <-e.slots }()
+
+	hashStart := time.Now()
+	e.mu.Lock()
+	e.metrics.Running++
+	e.metrics.QueueWait.observe(hashStart.Sub(start))
+	e.mu.Unlock()
+
+	err := fn()
+
+	e.mu.Lock()
+	e.metrics.Running--
+	e.metrics.Completed++
+	e.metrics.HashDuration.observe(time.Since(hashStart))
+	e.mu.Unlock()
+	return err
+}
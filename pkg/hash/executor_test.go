@@ -0,0 +1,151 @@
+package hash
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/mock/gomock"
+)
+
+// waitForMetrics polls the executor until cond holds, the calls under test run on other goroutines
+func waitForMetrics(t *testing.T, e *Executor, cond func(ExecutorMetrics) bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond(e.Metrics()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Executor.Metrics() = %+v, condition not reached", e.Metrics())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestExecutor_Delegates(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockHash := NewMockHashMethod(ctrl)
+	mockHash.EXPECT().HashValue(gomock.Any(), "password").Return([]byte("hash"), nil)
+	mockHash.EXPECT().CompareValue(gomock.Any(), "hash", "password").Return(true, nil)
+	mockHash.EXPECT().NeedsRehash("hash").Return(true)
+
+	e := NewExecutor(mockHash, NewExecutorConfig{Workers: 2, QueueSize: 1})
+
+	got, err := e.HashValue(context.Background(), "password")
+	if err != nil || string(got) != "hash" {
+		t.Errorf("Executor.HashValue() = %s, %v", got, err)
+	}
+
+	match, err := e.CompareValue(context.Background(), "hash", "password")
+	if err != nil || !match {
+		t.Errorf("Executor.CompareValue() = %v, %v", match, err)
+	}
+
+	if !e.NeedsRehash("hash") {
+		t.Errorf("Executor.NeedsRehash() = false, want true")
+	}
+
+	metrics := e.Metrics()
+	if metrics.Workers != 2 || metrics.QueueSize != 1 || metrics.Completed != 2 ||
+		metrics.HashDuration.Count != 2 || metrics.QueueWait.Count != 2 || metrics.Running != 0 {
+		t.Errorf("Executor.Metrics() = %+v", metrics)
+	}
+}
+
+func TestExecutor_QueueFull(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := make(chan struct{})
+	mockHash := NewMockHashMethod(ctrl)
+	mockHash.EXPECT().HashValue(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, value string) ([]byte, error) {
+		<-release
+		return []byte("hash"), nil
+	}).Times(2)
+
+	e := NewExecutor(mockHash, NewExecutorConfig{Workers: 1, QueueSize: 1, RetryAfter: 3 * time.Second})
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := e.HashValue(context.Background(), "running")
+		errs <- err
+	}()
+	waitForMetrics(t, e, func(m ExecutorMetrics) bool { return m.Running == 1 })
+
+	go func() {
+		_, err := e.HashValue(context.Background(), "queued")
+		errs <- err
+	}()
+	waitForMetrics(t, e, func(m ExecutorMetrics) bool { return m.Queued == 1 })
+
+	_, err := e.HashValue(context.Background(), "rejected")
+	var busy *BusyError
+	if !errors.As(err, &busy) || busy.RetryAfter != 3*time.Second {
+		t.Fatalf("Executor.HashValue() error = %v, want BusyError", err)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Executor.HashValue() error = %v", err)
+		}
+	}
+
+	metrics := e.Metrics()
+	if metrics.Completed != 2 || metrics.Rejected != 1 || metrics.Queued != 0 || metrics.Running != 0 {
+		t.Errorf("Executor.Metrics() = %+v", metrics)
+	}
+	if metrics.QueueWait.Max <= 0 {
+		t.Errorf("Executor.Metrics() QueueWait = %+v, want the wait of the queued call", metrics.QueueWait)
+	}
+}
+
+func TestExecutor_Canceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	release := make(chan struct{})
+	mockHash := NewMockHashMethod(ctrl)
+	mockHash.EXPECT().CompareValue(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, hash, password string) (bool, error) {
+		<-release
+		return true, nil
+	}).Times(1)
+
+	e := NewExecutor(mockHash, NewExecutorConfig{Workers: 1, QueueSize: 1})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.CompareValue(context.Background(), "hash", "running")
+		done <- err
+	}()
+	waitForMetrics(t, e, func(m ExecutorMetrics) bool { return m.Running == 1 })
+
+	// the context ends while waiting for the worker
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := make(chan error, 1)
+	go func() {
+		_, err := e.CompareValue(ctx, "hash", "queued")
+		queued <- err
+	}()
+	waitForMetrics(t, e, func(m ExecutorMetrics) bool { return m.Queued == 1 })
+	cancel()
+	if err := <-queued; !errors.Is(err, context.Canceled) {
+		t.Errorf("Executor.CompareValue() error = %v, want context.Canceled", err)
+	}
+
+	// the context ended before the call
+	if _, err := e.CompareValue(ctx, "hash", "late"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Executor.CompareValue() error = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Executor.CompareValue() error = %v", err)
+	}
+
+	metrics := e.Metrics()
+	if metrics.Completed != 1 || metrics.Canceled != 1 || metrics.Queued != 0 {
+		t.Errorf("Executor.Metrics() = %+v", metrics)
+	}
+}
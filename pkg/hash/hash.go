@@ -1,33 +1,151 @@
 package hash
 
 import (
+	"context"
+	"fmt"
+	"strings"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	// AlgorithmArgon2id is Argon2id (RFC 9106) with PHC-format encoded hashes, it is the default algorithm
+	AlgorithmArgon2id = "argon2id"
+	// AlgorithmBcrypt is bcrypt, it only uses the first 72 bytes of a value
+	AlgorithmBcrypt = "bcrypt"
+	// BcryptMaxBytes is the most bytes of a value bcrypt takes, longer values fail to hash
+	BcryptMaxBytes = 72
+)
+
 // HashConfig is list dependencies of Hash Package
 type HashConfig struct {
-	cost int
+	algorithm string
+	cost      int
+	argon2    Argon2Params
+	pepper    pepperSet
 }
 
 // HashMethod is list method for Hash Package
 type HashMethod interface {
-	HashValue(string) ([]byte, error)
-	CompareValue(string, string) bool
+	HashValue(context.Context, string) ([]byte, error)
+	CompareValue(context.Context, string, string) (bool, error)
+	NeedsRehash(string) bool
+}
+
+type NewHashConfig struct {
+	// Algorithm is AlgorithmArgon2id or AlgorithmBcrypt, default is AlgorithmArgon2id
+	Algorithm string
+	// Cost is the bcrypt cost, default is bcrypt.DefaultCost
+	Cost int
+	// Argon2 are the Argon2id parameters, each zero field takes its DefaultArgon2Params value
+	Argon2 Argon2Params
+	// PepperDirectory is the directory of the peppers, see loadPepperDirectory, peppering is off when empty
+	PepperDirectory string
+}
+
+// MaxValueBytes is the most bytes of a value the configuration can hash, 0 when there is no limit.
+// A peppered value is hashed as its fixed size HMAC, so only unpeppered bcrypt is limited.
+func (cfg NewHashConfig) MaxValueBytes() int {
+	if cfg.Algorithm == AlgorithmBcrypt && cfg.PepperDirectory == "" {
+		return BcryptMaxBytes
+	}
+	return 0
 }
 
 // NewHashMethod func to create HashMethod interface
-func NewHashMethod(cost int) HashMethod {
+func NewHashMethod(cfg NewHashConfig) (HashMethod, error) {
+	algorithm := cfg.Algorithm
+	if algorithm == "" {
+		algorithm = AlgorithmArgon2id
+	}
+	if algorithm != AlgorithmArgon2id && algorithm != AlgorithmBcrypt {
+		return nil, fmt.Errorf("unsupported hash algorithm %q", cfg.Algorithm)
+	}
+
+	cost := cfg.Cost
+	if cost == 0 {
+		cost = bcrypt.DefaultCost
+	}
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+
+	var pepper pepperSet
+	if cfg.PepperDirectory != "" {
+		var err error
+		pepper, err = loadPepperDirectory(cfg.PepperDirectory)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &HashConfig{
-		cost: cost,
+		algorithm: algorithm,
+		cost:      cost,
+		argon2:    cfg.Argon2.withDefaults(),
+		pepper:    pepper,
+	}, nil
+}
+
+// HashValue func to hash value with the configured algorithm, the value is peppered
+// first with the active pepper when peppers are configured
+func (h *HashConfig) HashValue(ctx context.Context, value string) ([]byte, error) {
+	if !h.pepper.enabled() {
+		return h.hashValue(value)
+	}
+
+	hash, err := h.hashValue(pepperValue(h.pepper.peppers[h.pepper.active], value))
+	if err != nil {
+		return nil, err
 	}
+	return append([]byte(pepperPrefix+h.pepper.active), hash...), nil
 }
 
-// HashValue func to hash value
-func (h *HashConfig) HashValue(value string) ([]byte, error) {
-	return bcrypt.GenerateFromPassword([]byte(value), h.cost)
+func (h *HashConfig) hashValue(value string) ([]byte, error) {
+	if h.algorithm == AlgorithmBcrypt {
+		return bcrypt.GenerateFromPassword([]byte(value), h.cost)
+	}
+	return hashArgon2id(value, h.argon2)
 }
 
-// CompareValue func to hashed value with password
-func (h *HashConfig) CompareValue(hash string, password string) bool {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+// CompareValue func to hashed value with password, the algorithm and pepper version are taken
+// from the hash so values hashed before a configuration change still match. A pepper version that
+// isn't configured is an error, the password may well be right and the pepper is what is missing.
+func (h *HashConfig) CompareValue(ctx context.Context, hash string, password string) (bool, error) {
+	version, hash := splitPepper(hash)
+	if version != "" {
+		pepper, ok := h.pepper.peppers[version]
+		if !ok {
+			return false, &UnknownPepperError{Version: version}
+		}
+		password = pepperValue(pepper, password)
+	}
+
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		return compareArgon2id(hash, password), nil
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, nil
+}
+
+// NeedsRehash func to tell whether the hash was made with another algorithm or other parameters
+// than the configured ones or with another pepper than the active one, the value should then
+// be hashed again once it is known
+func (h *HashConfig) NeedsRehash(hash string) bool {
+	version, hash := splitPepper(hash)
+	pepperOutdated := version != h.pepper.active
+
+	if strings.HasPrefix(hash, argon2idPrefix) {
+		params, _, _, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+		return pepperOutdated || h.algorithm != AlgorithmArgon2id || params != h.argon2
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		// not a hash made by this package, e.g. the blank password of a purged user
+		return false
+	}
+	return pepperOutdated || h.algorithm != AlgorithmBcrypt || cost != h.cost
 }
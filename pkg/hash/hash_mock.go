@@ -10,6 +10,7 @@
 package hash
 
 import (
+	context "context"
 	reflect "reflect"
 
 	gomock "go.uber.org/mock/gomock"
@@ -39,30 +40,45 @@ func (m *MockHashMethod) EXPECT() *MockHashMethodMockRecorder {
 }
 
 // CompareValue mocks base method.
-func (m *MockHashMethod) CompareValue(arg0, arg1 string) bool {
+func (m *MockHashMethod) CompareValue(arg0 context.Context, arg1, arg2 string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CompareValue", arg0, arg1)
+	ret := m.ctrl.Call(m, "CompareValue", arg0, arg1, arg2)
 	ret0, _ := ret[0].(bool)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // CompareValue indicates an expected call of CompareValue.
-func (mr *MockHashMethodMockRecorder) CompareValue(arg0, arg1 any) *gomock.Call {
+func (mr *MockHashMethodMockRecorder) CompareValue(arg0, arg1, arg2 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareValue", reflect.TypeOf((*MockHashMethod)(nil).CompareValue), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareValue", reflect.TypeOf((*MockHashMethod)(nil).CompareValue), arg0, arg1, arg2)
 }
 
 // HashValue mocks base method.
-func (m *MockHashMethod) HashValue(arg0 string) ([]byte, error) {
+func (m *MockHashMethod) HashValue(arg0 context.Context, arg1 string) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "HashValue", arg0)
+	ret := m.ctrl.Call(m, "HashValue", arg0, arg1)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // HashValue indicates an expected call of HashValue.
-func (mr *MockHashMethodMockRecorder) HashValue(arg0 any) *gomock.Call {
+func (mr *MockHashMethodMockRecorder) HashValue(arg0, arg1 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashValue", reflect.TypeOf((*MockHashMethod)(nil).HashValue), arg0, arg1)
+}
+
+// NeedsRehash mocks base method.
+func (m *MockHashMethod) NeedsRehash(arg0 string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NeedsRehash", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// NeedsRehash indicates an expected call of NeedsRehash.
+func (mr *MockHashMethodMockRecorder) NeedsRehash(arg0 any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashValue", reflect.TypeOf((*MockHashMethod)(nil).HashValue), arg0)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NeedsRehash", reflect.TypeOf((*MockHashMethod)(nil).NeedsRehash), arg0)
 }
@@ -1,32 +1,102 @@
 package hash
 
 import (
+	"context"
 	"reflect"
+	"strings"
 	"testing"
 )
 
+// testArgon2Params keeps the tests fast, the defaults use 64 MiB per hash
+var testArgon2Params = Argon2Params{
+	Memory:      64,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
 func TestNewHashMethod(t *testing.T) {
-	type args struct {
-		cost int
-	}
 	tests := []struct {
-		name string
-		args args
-		want HashMethod
+		name    string
+		cfg     NewHashConfig
+		want    HashMethod
+		wantErr bool
 	}{
 		{
-			name: "success flow",
-			args: args{
-				cost: 10,
+			name: "success flow default argon2id",
+			cfg: NewHashConfig{
+				Cost: 10,
 			},
 			want: &HashConfig{
-				cost: 10,
+				algorithm: AlgorithmArgon2id,
+				cost:      10,
+				argon2:    DefaultArgon2Params,
 			},
 		},
+		{
+			name: "success flow bcrypt",
+			cfg: NewHashConfig{
+				Algorithm: AlgorithmBcrypt,
+				Cost:      12,
+			},
+			want: &HashConfig{
+				algorithm: AlgorithmBcrypt,
+				cost:      12,
+				argon2:    DefaultArgon2Params,
+			},
+		},
+		{
+			name: "success flow partial argon2 parameters",
+			cfg: NewHashConfig{
+				Algorithm: AlgorithmArgon2id,
+				Argon2: Argon2Params{
+					Memory: 19 * 1024,
+				},
+			},
+			want: &HashConfig{
+				algorithm: AlgorithmArgon2id,
+				cost:      10,
+				argon2: Argon2Params{
+					Memory:      19 * 1024,
+					Iterations:  3,
+					Parallelism: 4,
+					SaltLength:  16,
+					KeyLength:   32,
+				},
+			},
+		},
+		{
+			name: "error flow unknown algorithm",
+			cfg: NewHashConfig{
+				Algorithm: "md5",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error flow missing pepper directory",
+			cfg: NewHashConfig{
+				PepperDirectory: "./testdata/missing",
+			},
+			wantErr: true,
+		},
+		{
+			name: "error flow invalid bcrypt cost",
+			cfg: NewHashConfig{
+				Algorithm: AlgorithmBcrypt,
+				Cost:      40,
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := NewHashMethod(tt.args.cost); !reflect.DeepEqual(got, tt.want) {
+			got, err := NewHashMethod(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewHashMethod() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("NewHashMethod() = %v, want %v", got, tt.want)
 			}
 		})
@@ -48,7 +118,8 @@ func TestHashConfig_HashValue(t *testing.T) {
 		{
 			name: "success flow",
 			h: &HashConfig{
-				cost: 10,
+				algorithm: AlgorithmBcrypt,
+				cost:      10,
 			},
 			args: args{
 				password: "password",
@@ -60,7 +131,8 @@ func TestHashConfig_HashValue(t *testing.T) {
 		{
 			name: "error compare flow",
 			h: &HashConfig{
-				cost: 10,
+				algorithm: AlgorithmBcrypt,
+				cost:      10,
 			},
 			args: args{
 				password: "password",
@@ -69,16 +141,69 @@ func TestHashConfig_HashValue(t *testing.T) {
 			sameValue: false,
 			wantErr:   false,
 		},
+		{
+			name: "success flow argon2id",
+			h: &HashConfig{
+				algorithm: AlgorithmArgon2id,
+				argon2:    testArgon2Params,
+			},
+			args: args{
+				password: "password",
+				check:    "password",
+			},
+			sameValue: true,
+			wantErr:   false,
+		},
+		{
+			name: "success flow argon2id beyond the 72 bytes of bcrypt",
+			h: &HashConfig{
+				algorithm: AlgorithmArgon2id,
+				argon2:    testArgon2Params,
+			},
+			args: args{
+				password: strings.Repeat("kata sandi ", 8) + "1",
+				check:    strings.Repeat("kata sandi ", 8) + "1",
+			},
+			sameValue: true,
+			wantErr:   false,
+		},
+		{
+			name: "error compare flow argon2id beyond the 72 bytes of bcrypt",
+			h: &HashConfig{
+				algorithm: AlgorithmArgon2id,
+				argon2:    testArgon2Params,
+			},
+			args: args{
+				password: strings.Repeat("kata sandi ", 8) + "1",
+				check:    strings.Repeat("kata sandi ", 8) + "2",
+			},
+			sameValue: false,
+			wantErr:   false,
+		},
+		{
+			name: "error flow bcrypt beyond 72 bytes",
+			h: &HashConfig{
+				algorithm: AlgorithmBcrypt,
+				cost:      10,
+			},
+			args: args{
+				password: strings.Repeat("kata sandi ", 8),
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := tt.h.HashValue(tt.args.password)
+			got, err := tt.h.HashValue(context.Background(), tt.args.password)
 			if (err != nil) != tt.wantErr {
-				t.Errorf("HashConfig.HashValue() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("HashConfig.HashValue(context.Background(), ) error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
 				return
 			}
 
-			validation := tt.h.CompareValue(string(got), tt.args.check)
+			validation, _ := tt.h.CompareValue(context.Background(), string(got), tt.args.check)
 			if validation != tt.sameValue {
 				t.Errorf("HashConfig.CompareValue() validation = %v, sameValue %v", validation, tt.sameValue)
 				return
@@ -87,3 +212,281 @@ func TestHashConfig_HashValue(t *testing.T) {
 		})
 	}
 }
+
+func TestHashConfig_CompareValue(t *testing.T) {
+	bcryptHasher := &HashConfig{algorithm: AlgorithmBcrypt, cost: 4}
+	argon2Hasher := &HashConfig{algorithm: AlgorithmArgon2id, argon2: testArgon2Params}
+	bcryptHash, _ := bcryptHasher.HashValue(context.Background(), "password")
+	argon2Hash, _ := argon2Hasher.HashValue(context.Background(), "password")
+	tests := []struct {
+		name string
+		h    *HashConfig
+		hash string
+		want bool
+	}{
+		{
+			name: "success flow bcrypt hash with argon2id configured",
+			h:    argon2Hasher,
+			hash: string(bcryptHash),
+			want: true,
+		},
+		{
+			name: "success flow argon2id hash with bcrypt configured",
+			h:    bcryptHasher,
+			hash: string(argon2Hash),
+			want: true,
+		},
+		{
+			name: "error flow malformed argon2id hash",
+			h:    argon2Hasher,
+			hash: "$argon2id$v=19$m=64,t=0,p=1$c2FsdA$a2V5",
+			want: false,
+		},
+		{
+			name: "error flow empty hash",
+			h:    argon2Hasher,
+			hash: "",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := tt.h.CompareValue(context.Background(), tt.hash, "password"); got != tt.want {
+				t.Errorf("HashConfig.CompareValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashConfig_NeedsRehash(t *testing.T) {
+	bcryptHasher := &HashConfig{algorithm: AlgorithmBcrypt, cost: 4}
+	argon2Hasher := &HashConfig{algorithm: AlgorithmArgon2id, argon2: testArgon2Params}
+	bcryptHash, _ := bcryptHasher.HashValue(context.Background(), "password")
+	argon2Hash, _ := argon2Hasher.HashValue(context.Background(), "password")
+	stronger := testArgon2Params
+	stronger.Iterations = 2
+	tests := []struct {
+		name string
+		h    *HashConfig
+		hash string
+		want bool
+	}{
+		{
+			name: "current bcrypt hash",
+			h:    bcryptHasher,
+			hash: string(bcryptHash),
+			want: false,
+		},
+		{
+			name: "bcrypt hash with another cost",
+			h:    &HashConfig{algorithm: AlgorithmBcrypt, cost: 5},
+			hash: string(bcryptHash),
+			want: true,
+		},
+		{
+			name: "bcrypt hash with argon2id configured",
+			h:    argon2Hasher,
+			hash: string(bcryptHash),
+			want: true,
+		},
+		{
+			name: "current argon2id hash",
+			h:    argon2Hasher,
+			hash: string(argon2Hash),
+			want: false,
+		},
+		{
+			name: "argon2id hash with other parameters",
+			h:    &HashConfig{algorithm: AlgorithmArgon2id, argon2: stronger},
+			hash: string(argon2Hash),
+			want: true,
+		},
+		{
+			name: "argon2id hash with bcrypt configured",
+			h:    bcryptHasher,
+			hash: string(argon2Hash),
+			want: true,
+		},
+		{
+			name: "unknown hash",
+			h:    argon2Hasher,
+			hash: "",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.h.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("HashConfig.NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewHashConfig_MaxValueBytes(t *testing.T) {
+	// 64 characters of 3 bytes each, as many as the password policy allows
+	password := strings.Repeat("密", 64)
+	v1 := []byte(strings.Repeat("1", minPepperLength))
+	tests := []struct {
+		name    string
+		cfg     NewHashConfig
+		h       *HashConfig
+		want    int
+		wantErr bool
+	}{
+		{
+			name:    "unpeppered bcrypt",
+			cfg:     NewHashConfig{Algorithm: AlgorithmBcrypt},
+			h:       &HashConfig{algorithm: AlgorithmBcrypt, cost: 4},
+			want:    BcryptMaxBytes,
+			wantErr: true,
+		},
+		{
+			name: "peppered bcrypt",
+			cfg:  NewHashConfig{Algorithm: AlgorithmBcrypt, PepperDirectory: "peppers"},
+			h: &HashConfig{
+				algorithm: AlgorithmBcrypt,
+				cost:      4,
+				pepper:    pepperSet{active: "v1", peppers: map[string][]byte{"v1": v1}},
+			},
+			want: 0,
+		},
+		{
+			name: "argon2id",
+			cfg:  NewHashConfig{},
+			h:    &HashConfig{algorithm: AlgorithmArgon2id, argon2: testArgon2Params},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.MaxValueBytes(); got != tt.want {
+				t.Errorf("NewHashConfig.MaxValueBytes() = %v, want %v", got, tt.want)
+			}
+			// a password over the limit can't be hashed, the policy must refuse it first
+			_, err := tt.h.HashValue(context.Background(), password)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HashConfig.HashValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHashConfig_Pepper(t *testing.T) {
+	v1 := []byte(strings.Repeat("1", minPepperLength))
+	v2 := []byte(strings.Repeat("2", minPepperLength))
+	unpeppered := &HashConfig{algorithm: AlgorithmArgon2id, argon2: testArgon2Params}
+	peppered := &HashConfig{
+		algorithm: AlgorithmArgon2id,
+		argon2:    testArgon2Params,
+		pepper:    pepperSet{active: "v1", peppers: map[string][]byte{"v1": v1}},
+	}
+	rotated := &HashConfig{
+		algorithm: AlgorithmArgon2id,
+		argon2:    testArgon2Params,
+		pepper:    pepperSet{active: "v2", peppers: map[string][]byte{"v1": v1, "v2": v2}},
+	}
+	retired := &HashConfig{
+		algorithm: AlgorithmArgon2id,
+		argon2:    testArgon2Params,
+		pepper:    pepperSet{active: "v2", peppers: map[string][]byte{"v2": v2}},
+	}
+	bcryptPeppered := &HashConfig{
+		algorithm: AlgorithmBcrypt,
+		cost:      4,
+		pepper:    pepperSet{active: "v1", peppers: map[string][]byte{"v1": v1}},
+	}
+
+	unpepperedHash, _ := unpeppered.HashValue(context.Background(), "password")
+	pepperedHash, _ := peppered.HashValue(context.Background(), "password")
+	bcryptHash, _ := bcryptPeppered.HashValue(context.Background(), "password")
+	if !strings.HasPrefix(string(pepperedHash), "$pepper$v1$argon2id$") {
+		t.Fatalf("HashConfig.HashValue(context.Background(), ) = %s, want the pepper version prefix", pepperedHash)
+	}
+	if !strings.HasPrefix(string(bcryptHash), "$pepper$v1$2a$") {
+		t.Fatalf("HashConfig.HashValue(context.Background(), ) = %s, want the pepper version prefix", bcryptHash)
+	}
+
+	tests := []struct {
+		name            string
+		h               *HashConfig
+		hash            string
+		password        string
+		wantMatch       bool
+		wantNeedsRehash bool
+		wantErr         bool
+	}{
+		{
+			name:            "peppered hash with the active pepper",
+			h:               peppered,
+			hash:            string(pepperedHash),
+			password:        "password",
+			wantMatch:       true,
+			wantNeedsRehash: false,
+		},
+		{
+			name:            "peppered hash with a wrong password",
+			h:               peppered,
+			hash:            string(pepperedHash),
+			password:        "password#1",
+			wantMatch:       false,
+			wantNeedsRehash: false,
+		},
+		{
+			name:            "peppered bcrypt hash",
+			h:               bcryptPeppered,
+			hash:            string(bcryptHash),
+			password:        "password",
+			wantMatch:       true,
+			wantNeedsRehash: false,
+		},
+		{
+			name:            "peppered hash after rotation",
+			h:               rotated,
+			hash:            string(pepperedHash),
+			password:        "password",
+			wantMatch:       true,
+			wantNeedsRehash: true,
+		},
+		{
+			name:            "peppered hash with a removed pepper",
+			h:               retired,
+			hash:            string(pepperedHash),
+			password:        "password",
+			wantMatch:       false,
+			wantNeedsRehash: true,
+			wantErr:         true,
+		},
+		{
+			name:            "peppered hash without peppers",
+			h:               unpeppered,
+			hash:            string(pepperedHash),
+			password:        "password",
+			wantMatch:       false,
+			wantNeedsRehash: true,
+			wantErr:         true,
+		},
+		{
+			name:            "unpeppered hash with peppers",
+			h:               rotated,
+			hash:            string(unpepperedHash),
+			password:        "password",
+			wantMatch:       true,
+			wantNeedsRehash: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.h.CompareValue(context.Background(), tt.hash, tt.password)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("HashConfig.CompareValue() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.wantMatch {
+				t.Errorf("HashConfig.CompareValue() = %v, want %v", got, tt.wantMatch)
+			}
+			if got := tt.h.NeedsRehash(tt.hash); got != tt.wantNeedsRehash {
+				t.Errorf("HashConfig.NeedsRehash() = %v, want %v", got, tt.wantNeedsRehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package hash
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// pepperPrefix starts every peppered hash, it is followed by the pepper version and the inner hash
+	// e.g. $pepper$2024-01$argon2id$v=19$...
+	pepperPrefix = "$pepper$"
+	// pepperSuffix is the file suffix of a pepper, the file name is the version
+	pepperSuffix = ".pepper"
+	// activePepperFile is the file holding the version used for hashing new values
+	activePepperFile = "active"
+	// minPepperLength is the minimum length of a pepper in bytes
+	minPepperLength = 32
+)
+
+// pepperVersionPattern keeps the version parseable inside the hash
+var pepperVersionPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// pepperSet is the loaded peppers, a zero pepperSet disables peppering
+type pepperSet struct {
+	active  string
+	peppers map[string][]byte
+}
+
+func (p pepperSet) enabled() bool {
+	return p.active != ""
+}
+
+// UnknownPepperError is returned by CompareValue for a hash made with a pepper that isn't configured
+type UnknownPepperError struct {
+	Version string
+}
+
+func (e *UnknownPepperError) Error() string {
+	return "unknown pepper version " + e.Version
+}
+
+// loadPepperDirectory loads every pepper in the directory
+// <version>.pepper holds a secret of at least 32 bytes, surrounding whitespace is ignored
+// active contains the version used for hashing, it can be omitted when there is only one pepper
+// retired peppers must be kept until no stored password uses them, comparing with a hash of a
+// missing pepper is an UnknownPepperError
+func loadPepperDirectory(directory string) (pepperSet, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return pepperSet{}, fmt.Errorf("failed read pepper directory %s, err: %s", directory, err)
+	}
+
+	peppers := pepperSet{
+		peppers: map[string][]byte{},
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, pepperSuffix) {
+			continue
+		}
+
+		version := strings.TrimSuffix(name, pepperSuffix)
+		if !pepperVersionPattern.MatchString(version) {
+			return pepperSet{}, fmt.Errorf("invalid pepper version %s, use up to 32 letters, digits, - or _", version)
+		}
+
+		content, err := os.ReadFile(filepath.Join(directory, name))
+		if err != nil {
+			return pepperSet{}, fmt.Errorf("failed read pepper file %s, err: %s", name, err)
+		}
+
+		pepper := []byte(strings.TrimSpace(string(content)))
+		if len(pepper) < minPepperLength {
+			return pepperSet{}, fmt.Errorf("pepper %s must be at least %d bytes", name, minPepperLength)
+		}
+		peppers.peppers[version] = pepper
+	}
+
+	active, err := os.ReadFile(filepath.Join(directory, activePepperFile))
+	switch {
+	case err == nil:
+		peppers.active = strings.TrimSpace(string(active))
+	case os.IsNotExist(err) && len(peppers.peppers) == 1:
+		for version := range peppers.peppers {
+			peppers.active = version
+		}
+	default:
+		return pepperSet{}, fmt.Errorf("failed read active pepper version in %s, err: %s", directory, err)
+	}
+
+	if _, ok := peppers.peppers[peppers.active]; !ok {
+		return pepperSet{}, fmt.Errorf("active pepper %s has no pepper file in %s", peppers.active, directory)
+	}
+
+	return peppers, nil
+}
+
+// pepperValue is the HMAC-SHA256 of the value, base64 encoded so it stays below the 72 bytes of bcrypt
+func pepperValue(pepper []byte, value string) string {
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte(value))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// splitPepper returns the pepper version and the inner hash, the version is empty for unpeppered hashes
+func splitPepper(hash string) (string, string) {
+	if !strings.HasPrefix(hash, pepperPrefix) {
+		return "", hash
+	}
+
+	rest := strings.TrimPrefix(hash, pepperPrefix)
+	index := strings.Index(rest, "$")
+	if index <= 0 {
+		return "", hash
+	}
+	return rest[:index], rest[index:]
+}
@@ -0,0 +1,144 @@
+package hash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePepper(t *testing.T, directory, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(directory, name), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPepperDirectory(t *testing.T) {
+	secret := strings.Repeat("s", minPepperLength)
+	tests := []struct {
+		name         string
+		prepare      func(t *testing.T, directory string)
+		wantActive   string
+		wantVersions []string
+		wantErr      bool
+	}{
+		{
+			name: "single pepper is active",
+			prepare: func(t *testing.T, directory string) {
+				writePepper(t, directory, "2024-01.pepper", secret+"\n")
+			},
+			wantActive:   "2024-01",
+			wantVersions: []string{"2024-01"},
+		},
+		{
+			name: "active file selects pepper",
+			prepare: func(t *testing.T, directory string) {
+				writePepper(t, directory, "2024-01.pepper", secret)
+				writePepper(t, directory, "2024-02.pepper", secret+"2")
+				writePepper(t, directory, "README", "not a pepper")
+				writePepper(t, directory, "active", "2024-02\n")
+			},
+			wantActive:   "2024-02",
+			wantVersions: []string{"2024-01", "2024-02"},
+		},
+		{
+			name: "several peppers without active file",
+			prepare: func(t *testing.T, directory string) {
+				writePepper(t, directory, "2024-01.pepper", secret)
+				writePepper(t, directory, "2024-02.pepper", secret)
+			},
+			wantErr: true,
+		},
+		{
+			name: "active pepper missing",
+			prepare: func(t *testing.T, directory string) {
+				writePepper(t, directory, "2024-01.pepper", secret)
+				writePepper(t, directory, "active", "2024-02")
+			},
+			wantErr: true,
+		},
+		{
+			name: "pepper too short",
+			prepare: func(t *testing.T, directory string) {
+				writePepper(t, directory, "2024-01.pepper", "short")
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid version",
+			prepare: func(t *testing.T, directory string) {
+				writePepper(t, directory, "2024.01.pepper", secret)
+			},
+			wantErr: true,
+		},
+		{
+			name:    "empty directory",
+			prepare: func(t *testing.T, directory string) {},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := t.TempDir()
+			tt.prepare(t, directory)
+
+			got, err := loadPepperDirectory(directory)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadPepperDirectory() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.active != tt.wantActive {
+				t.Errorf("loadPepperDirectory() active = %v, want %v", got.active, tt.wantActive)
+			}
+			if len(got.peppers) != len(tt.wantVersions) {
+				t.Errorf("loadPepperDirectory() peppers = %v, want %v", len(got.peppers), len(tt.wantVersions))
+			}
+			for _, version := range tt.wantVersions {
+				if len(got.peppers[version]) < minPepperLength {
+					t.Errorf("loadPepperDirectory() pepper %s = %q", version, got.peppers[version])
+				}
+			}
+		})
+	}
+}
+
+func TestSplitPepper(t *testing.T) {
+	tests := []struct {
+		name        string
+		hash        string
+		wantVersion string
+		wantHash    string
+	}{
+		{
+			name:        "peppered hash",
+			hash:        "$pepper$2024-01$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5",
+			wantVersion: "2024-01",
+			wantHash:    "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$a2V5",
+		},
+		{
+			name:        "unpeppered hash",
+			hash:        "$2a$10$abcdefghijklmnopqrstuv",
+			wantVersion: "",
+			wantHash:    "$2a$10$abcdefghijklmnopqrstuv",
+		},
+		{
+			name:        "missing version",
+			hash:        "$pepper$$2a$10$abcdefghijklmnopqrstuv",
+			wantVersion: "",
+			wantHash:    "$pepper$$2a$10$abcdefghijklmnopqrstuv",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, hash := splitPepper(tt.hash)
+			if version != tt.wantVersion || hash != tt.wantHash {
+				t.Errorf("splitPepper() = %v, %v, want %v, %v", version, hash, tt.wantVersion, tt.wantHash)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Policy decides how long a key is blocked after failed logins. After BackoffAfter
+// failures every new failure blocks the key for BackoffBase, doubled on each failure.
+// After LockoutAfter failures the key is locked for LockoutDuration, also doubled on
+// each failure up to MaxLockoutDuration. Failures older than the window are forgotten.
+type Policy struct {
+	BackoffAfter       int
+	BackoffBase        time.Duration
+	LockoutAfter       int
+	LockoutDuration    time.Duration
+	MaxLockoutDuration time.Duration
+}
+
+// Status is the result of a lockout check
+type Status struct {
+	// Locked is set when the account itself is locked, otherwise the caller is only throttled
+	Locked bool
+	// RetryAfter is how long the caller must wait, it is 0 when the login is allowed
+	RetryAfter time.Duration
+}
+
+// Blocked tells whether the login must be refused
+func (s Status) Blocked() bool {
+	return s.RetryAfter > 0
+}
+
+// LockoutConfig is list dependencies of Lockout Package
+type LockoutConfig struct {
+	store         Store
+	window        time.Duration
+	accountPolicy Policy
+	ipPolicy      Policy
+	now           func() time.Time
+}
+
+// LockoutMethod is list method for Lockout Package
+type LockoutMethod interface {
+	Check(ctx context.Context, account string, ip string) (Status, error)
+	RecordFailure(ctx context.Context, account string, ip string) error
+	RecordSuccess(ctx context.Context, account string) error
+	Unlock(ctx context.Context, account string) error
+}
+
+type NewLockoutConfig struct {
+	Store Store
+	// Window is how long a failure is remembered
+	Window time.Duration
+	// AccountPolicy applies to the phone number being logged in to
+	AccountPolicy Policy
+	// IPPolicy applies to the client ip, it only ever throttles and never reports a locked account
+	IPPolicy Policy
+}
+
+// NewLockoutMethod func to create LockoutMethod interface
+func NewLockoutMethod(cfg NewLockoutConfig) LockoutMethod {
+	return &LockoutConfig{
+		store:         cfg.Store,
+		window:        cfg.Window,
+		accountPolicy: cfg.AccountPolicy,
+		ipPolicy:      cfg.IPPolicy,
+		now: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+// Check func to tell whether a login to the account from the ip must be refused,
+// a locked account wins over a throttled ip since the account lock lasts longer
+func (l *LockoutConfig) Check(ctx context.Context, account string, ip string) (Status, error) {
+	now := l.now()
+
+	counter, err := l.store.Get(ctx, accountKey(account))
+	if err != nil {
+		return Status{}, err
+	}
+	if counter.LockedUntil.After(now) {
+		return Status{
+			Locked:     counter.Failures >= l.accountPolicy.LockoutAfter,
+			RetryAfter: counter.LockedUntil.Sub(now),
+		}, nil
+	}
+
+	counter, err = l.store.Get(ctx, ipKey(ip))
+	if err != nil {
+		return Status{}, err
+	}
+	if counter.LockedUntil.After(now) {
+		return Status{
+			RetryAfter: counter.LockedUntil.Sub(now),
+		}, nil
+	}
+
+	return Status{}, nil
+}
+
+// RecordFailure func to count a failed login to the account from the ip
+func (l *LockoutConfig) RecordFailure(ctx context.Context, account string, ip string) error {
+	err := l.addFailure(ctx, accountKey(account), l.accountPolicy)
+	if err != nil {
+		return err
+	}
+	return l.addFailure(ctx, ipKey(ip), l.ipPolicy)
+}
+
+// RecordSuccess func to forget the failures of the account after a successful login,
+// the ip keeps its failures so one valid account does not reset an attack from it
+func (l *LockoutConfig) RecordSuccess(ctx context.Context, account string) error {
+	return l.store.Reset(ctx, accountKey(account))
+}
+
+// Unlock func to lift the lock of the account and forget its failures
+func (l *LockoutConfig) Unlock(ctx context.Context, account string) error {
+	return l.store.Reset(ctx, accountKey(account))
+}
+
+func (l *LockoutConfig) addFailure(ctx context.Context, key string, policy Policy) error {
+	now := l.now()
+	counter, err := l.store.AddFailure(ctx, key, now, l.window)
+	if err != nil {
+		return err
+	}
+
+	delay := policy.delay(counter.Failures)
+	if delay <= 0 {
+		return nil
+	}
+
+	return l.store.Lock(ctx, key, now.Add(delay))
+}
+
+// delay is how long the key is blocked after its nth failure
+func (p Policy) delay(failures int) time.Duration {
+	switch {
+	case p.LockoutAfter > 0 && failures >= p.LockoutAfter:
+		return backoff(p.LockoutDuration, failures-p.LockoutAfter, p.MaxLockoutDuration)
+	case p.BackoffAfter > 0 && failures >= p.BackoffAfter:
+		return backoff(p.BackoffBase, failures-p.BackoffAfter, p.LockoutDuration)
+	}
+	return 0
+}
+
+// maxBackoffSteps keeps an uncapped backoff from overflowing
+const maxBackoffSteps = 30
+
+// backoff doubles base for every step, capped at max when max is set
+func backoff(base time.Duration, step int, max time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < step && i < maxBackoffSteps; i++ {
+		delay *= 2
+		if max > 0 && delay >= max {
+			return max
+		}
+	}
+	if max > 0 && delay > max {
+		return max
+	}
+	return delay
+}
+
+func accountKey(account string) string {
+	return "account:" + account
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
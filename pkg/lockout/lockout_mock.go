@@ -0,0 +1,97 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/lockout/lockout.go
+//
+// Generated by this command:
+//
+//	mockgen -source=pkg/lockout/lockout.go -destination=pkg/lockout/lockout_mock.go -package=lockout
+//
+
+// Package lockout is a generated GoMock package.
+package lockout
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockLockoutMethod is a mock of LockoutMethod interface.
+type MockLockoutMethod struct {
+	ctrl     *gomock.Controller
+	recorder *MockLockoutMethodMockRecorder
+}
+
+// MockLockoutMethodMockRecorder is the mock recorder for MockLockoutMethod.
+type MockLockoutMethodMockRecorder struct {
+	mock *MockLockoutMethod
+}
+
+// NewMockLockoutMethod creates a new mock instance.
+func NewMockLockoutMethod(ctrl *gomock.Controller) *MockLockoutMethod {
+	mock := &MockLockoutMethod{ctrl: ctrl}
+	mock.recorder = &MockLockoutMethodMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLockoutMethod) EXPECT() *MockLockoutMethodMockRecorder {
+	return m.recorder
+}
+
+// Check mocks base method.
+func (m *MockLockoutMethod) Check(ctx context.Context, account, ip string) (Status, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Check", ctx, account, ip)
+	ret0, _ := ret[0].(Status)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Check indicates an expected call of Check.
+func (mr *MockLockoutMethodMockRecorder) Check(ctx, account, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Check", reflect.TypeOf((*MockLockoutMethod)(nil).Check), ctx, account, ip)
+}
+
+// RecordFailure mocks base method.
+func (m *MockLockoutMethod) RecordFailure(ctx context.Context, account, ip string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordFailure", ctx, account, ip)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordFailure indicates an expected call of RecordFailure.
+func (mr *MockLockoutMethodMockRecorder) RecordFailure(ctx, account, ip any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordFailure", reflect.TypeOf((*MockLockoutMethod)(nil).RecordFailure), ctx, account, ip)
+}
+
+// RecordSuccess mocks base method.
+func (m *MockLockoutMethod) RecordSuccess(ctx context.Context, account string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordSuccess", ctx, account)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordSuccess indicates an expected call of RecordSuccess.
+func (mr *MockLockoutMethodMockRecorder) RecordSuccess(ctx, account any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordSuccess", reflect.TypeOf((*MockLockoutMethod)(nil).RecordSuccess), ctx, account)
+}
+
+// Unlock mocks base method.
+func (m *MockLockoutMethod) Unlock(ctx context.Context, account string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlock", ctx, account)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlock indicates an expected call of Unlock.
+func (mr *MockLockoutMethodMockRecorder) Unlock(ctx, account any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlock", reflect.TypeOf((*MockLockoutMethod)(nil).Unlock), ctx, account)
+}
@@ -0,0 +1,189 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPolicy_delay(t *testing.T) {
+	policy := Policy{
+		BackoffAfter:       3,
+		BackoffBase:        time.Second,
+		LockoutAfter:       5,
+		LockoutDuration:    15 * time.Minute,
+		MaxLockoutDuration: time.Hour,
+	}
+	tests := []struct {
+		name     string
+		failures int
+		want     time.Duration
+	}{
+		{
+			name:     "below backoff",
+			failures: 2,
+			want:     0,
+		},
+		{
+			name:     "first backoff",
+			failures: 3,
+			want:     time.Second,
+		},
+		{
+			name:     "doubled backoff",
+			failures: 4,
+			want:     2 * time.Second,
+		},
+		{
+			name:     "first lockout",
+			failures: 5,
+			want:     15 * time.Minute,
+		},
+		{
+			name:     "doubled lockout",
+			failures: 6,
+			want:     30 * time.Minute,
+		},
+		{
+			name:     "lockout capped",
+			failures: 50,
+			want:     time.Hour,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.delay(tt.failures); got != tt.want {
+				t.Errorf("delay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockoutConfig_Check(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	policy := Policy{
+		BackoffAfter:    2,
+		BackoffBase:     time.Second,
+		LockoutAfter:    3,
+		LockoutDuration: 15 * time.Minute,
+	}
+	tests := []struct {
+		name    string
+		prepare func(l LockoutMethod)
+		want    Status
+	}{
+		{
+			name:    "no failures",
+			prepare: func(l LockoutMethod) {},
+			want:    Status{},
+		},
+		{
+			name: "below backoff",
+			prepare: func(l LockoutMethod) {
+				l.RecordFailure(context.Background(), "+628123456789", "192.0.2.1")
+			},
+			want: Status{},
+		},
+		{
+			name: "account throttled",
+			prepare: func(l LockoutMethod) {
+				l.RecordFailure(context.Background(), "+628123456789", "192.0.2.1")
+				l.RecordFailure(context.Background(), "+628123456789", "192.0.2.1")
+			},
+			want: Status{
+				RetryAfter: time.Second,
+			},
+		},
+		{
+			name: "account locked",
+			prepare: func(l LockoutMethod) {
+				for i := 0; i < 3; i++ {
+					l.RecordFailure(context.Background(), "+628123456789", "192.0.2.1")
+				}
+			},
+			want: Status{
+				Locked:     true,
+				RetryAfter: 15 * time.Minute,
+			},
+		},
+		{
+			name: "ip throttled by other accounts",
+			prepare: func(l LockoutMethod) {
+				l.RecordFailure(context.Background(), "+628111111111", "192.0.2.1")
+				l.RecordFailure(context.Background(), "+628222222222", "192.0.2.1")
+				l.RecordFailure(context.Background(), "+628333333333", "192.0.2.1")
+			},
+			want: Status{
+				RetryAfter: 15 * time.Minute,
+			},
+		},
+		{
+			name: "success resets account",
+			prepare: func(l LockoutMethod) {
+				l.RecordFailure(context.Background(), "+628123456789", "192.0.2.2")
+				l.RecordFailure(context.Background(), "+628123456789", "192.0.2.2")
+				l.RecordSuccess(context.Background(), "+628123456789")
+			},
+			want: Status{},
+		},
+		{
+			name: "unlock lifts lock",
+			prepare: func(l LockoutMethod) {
+				for i := 0; i < 3; i++ {
+					l.RecordFailure(context.Background(), "+628123456789", "192.0.2.2")
+				}
+				l.Unlock(context.Background(), "+628123456789")
+			},
+			want: Status{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &LockoutConfig{
+				store:         NewMemoryStore(),
+				window:        time.Hour,
+				accountPolicy: policy,
+				ipPolicy:      policy,
+				now: func() time.Time {
+					return now
+				},
+			}
+			tt.prepare(l)
+
+			got, err := l.Check(context.Background(), "+628123456789", "192.0.2.1")
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Check() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockoutConfig_CheckExpired(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	l := &LockoutConfig{
+		store:  NewMemoryStore(),
+		window: time.Hour,
+		accountPolicy: Policy{
+			LockoutAfter:    1,
+			LockoutDuration: time.Minute,
+		},
+		now: func() time.Time {
+			return now
+		},
+	}
+
+	l.RecordFailure(context.Background(), "+628123456789", "192.0.2.1")
+	got, _ := l.Check(context.Background(), "+628123456789", "192.0.2.1")
+	if !got.Blocked() || !got.Locked {
+		t.Fatalf("Check() = %+v, want locked", got)
+	}
+
+	now = now.Add(time.Minute)
+	got, _ = l.Check(context.Background(), "+628123456789", "192.0.2.1")
+	if got.Blocked() {
+		t.Fatalf("Check() after lock expired = %+v, want allowed", got)
+	}
+}
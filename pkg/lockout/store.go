@@ -0,0 +1,166 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// Counter is the failed login state of a key
+type Counter struct {
+	Failures      int
+	LastFailureAt time.Time
+	// LockedUntil is zero when the key is not blocked
+	LockedUntil time.Time
+}
+
+// Store keeps the failed login counters, it must be shared by every instance
+// of the service for the limits to hold across instances
+type Store interface {
+	// Get returns the counter of the key, a zero Counter when there is none
+	Get(ctx context.Context, key string) (Counter, error)
+	// AddFailure counts a failure at now atomically, failures older than window are forgotten first
+	AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Counter, error)
+	// Lock blocks the key until the given time
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset forgets the failures and the lock of the key
+	Reset(ctx context.Context, key string) error
+}
+
+// memoryStore keeps the counters in the process, it is meant for a single instance and for tests
+type memoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]memoryCounter
+	nextSweep time.Time
+}
+
+type memoryCounter struct {
+	Counter
+	expiresAt time.Time
+}
+
+// NewMemoryStore func to create a Store that lives in memory
+func NewMemoryStore() Store {
+	return &memoryStore{
+		counters: map[string]memoryCounter{},
+	}
+}
+
+func (m *memoryStore) Get(ctx context.Context, key string) (Counter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.counters[key].Counter, nil
+}
+
+func (m *memoryStore) AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Counter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.After(m.nextSweep) {
+		m.evictExpired(now)
+		m.nextSweep = now.Add(window)
+	}
+
+	counter := m.counters[key]
+	if counter.LastFailureAt.Before(now.Add(-window)) {
+		counter.Failures = 0
+	}
+	counter.Failures++
+	counter.LastFailureAt = now
+	counter.expiresAt = now.Add(window)
+	if counter.LockedUntil.After(counter.expiresAt) {
+		counter.expiresAt = counter.LockedUntil
+	}
+	m.counters[key] = counter
+
+	return counter.Counter, nil
+}
+
+func (m *memoryStore) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counter := m.counters[key]
+	counter.LockedUntil = until
+	if until.After(counter.expiresAt) {
+		counter.expiresAt = until
+	}
+	m.counters[key] = counter
+
+	return nil
+}
+
+func (m *memoryStore) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.counters, key)
+
+	return nil
+}
+
+// evictExpired removes counters that are no longer useful, caller must hold the lock
+func (m *memoryStore) evictExpired(now time.Time) {
+	for key, counter := range m.counters {
+		if !now.Before(counter.expiresAt) {
+			delete(m.counters, key)
+		}
+	}
+}
+
+// postgresStore keeps the counters in the login_failure table through the repository
+type postgresStore struct {
+	repository repository.RepositoryInterface
+}
+
+// NewPostgresStore func to create a Store backed by postgres, shared by every instance
+func NewPostgresStore(repo repository.RepositoryInterface) Store {
+	return &postgresStore{
+		repository: repo,
+	}
+}
+
+func (p *postgresStore) Get(ctx context.Context, key string) (Counter, error) {
+	output, err := p.repository.GetLoginFailure(ctx, key)
+	if err != nil {
+		return Counter{}, err
+	}
+	return counter(output), nil
+}
+
+func (p *postgresStore) AddFailure(ctx context.Context, key string, now time.Time, window time.Duration) (Counter, error) {
+	output, err := p.repository.AddLoginFailure(ctx, repository.AddLoginFailureInput{
+		Key:         key,
+		FailedAt:    now,
+		WindowStart: now.Add(-window),
+	})
+	if err != nil {
+		return Counter{}, err
+	}
+	return counter(output), nil
+}
+
+func (p *postgresStore) Lock(ctx context.Context, key string, until time.Time) error {
+	return p.repository.LockLogin(ctx, repository.LockLoginInput{
+		Key:         key,
+		LockedUntil: until,
+	})
+}
+
+func (p *postgresStore) Reset(ctx context.Context, key string) error {
+	return p.repository.ResetLoginFailures(ctx, key)
+}
+
+func counter(output repository.LoginFailure) Counter {
+	counter := Counter{
+		Failures:      output.Failures,
+		LastFailureAt: output.LastFailureAt,
+	}
+	if output.LockedUntil != nil {
+		counter.LockedUntil = *output.LockedUntil
+	}
+	return counter
+}
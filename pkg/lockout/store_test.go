@@ -0,0 +1,117 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"go.uber.org/mock/gomock"
+)
+
+func TestMemoryStore_AddFailure(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := NewMemoryStore()
+
+	store.AddFailure(context.Background(), "key", now, time.Hour)
+	got, _ := store.AddFailure(context.Background(), "key", now.Add(time.Minute), time.Hour)
+	if got.Failures != 2 {
+		t.Fatalf("AddFailure() failures = %d, want 2", got.Failures)
+	}
+
+	// a failure past the window starts a new count
+	got, _ = store.AddFailure(context.Background(), "key", now.Add(2*time.Hour), time.Hour)
+	if got.Failures != 1 {
+		t.Fatalf("AddFailure() after window failures = %d, want 1", got.Failures)
+	}
+
+	err := store.Lock(context.Background(), "key", now.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	got, _ = store.Get(context.Background(), "key")
+	if !got.LockedUntil.Equal(now.Add(3 * time.Hour)) {
+		t.Fatalf("Get() locked until = %v, want %v", got.LockedUntil, now.Add(3*time.Hour))
+	}
+
+	store.Reset(context.Background(), "key")
+	got, _ = store.Get(context.Background(), "key")
+	if got != (Counter{}) {
+		t.Fatalf("Get() after reset = %+v, want zero", got)
+	}
+}
+
+func TestMemoryStore_evictExpired(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := NewMemoryStore().(*memoryStore)
+
+	store.AddFailure(context.Background(), "expired", now, time.Hour)
+	store.AddFailure(context.Background(), "locked", now, time.Hour)
+	store.Lock(context.Background(), "locked", now.Add(3*time.Hour))
+
+	// the next failure after the window sweeps the expired counters
+	store.AddFailure(context.Background(), "other", now.Add(2*time.Hour), time.Hour)
+
+	if _, ok := store.counters["expired"]; ok {
+		t.Fatalf("evictExpired() kept an expired counter")
+	}
+	if _, ok := store.counters["locked"]; !ok {
+		t.Fatalf("evictExpired() removed a locked counter")
+	}
+}
+
+func TestPostgresStore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	lockedUntil := now.Add(time.Minute)
+	store := NewPostgresStore(mockRepo)
+
+	mockRepo.EXPECT().AddLoginFailure(gomock.Any(), repository.AddLoginFailureInput{
+		Key:         "key",
+		FailedAt:    now,
+		WindowStart: now.Add(-time.Hour),
+	}).Return(repository.LoginFailure{
+		Failures:      2,
+		LastFailureAt: now,
+		LockedUntil:   &lockedUntil,
+	}, nil)
+	got, err := store.AddFailure(context.Background(), "key", now, time.Hour)
+	if err != nil {
+		t.Fatalf("AddFailure() error = %v", err)
+	}
+	want := Counter{
+		Failures:      2,
+		LastFailureAt: now,
+		LockedUntil:   lockedUntil,
+	}
+	if got != want {
+		t.Fatalf("AddFailure() = %+v, want %+v", got, want)
+	}
+
+	mockRepo.EXPECT().GetLoginFailure(gomock.Any(), "key").Return(repository.LoginFailure{}, nil)
+	got, err = store.Get(context.Background(), "key")
+	if err != nil || got != (Counter{}) {
+		t.Fatalf("Get() = %+v, %v, want zero", got, err)
+	}
+
+	mockRepo.EXPECT().GetLoginFailure(gomock.Any(), "key").Return(repository.LoginFailure{}, fmt.Errorf("some error"))
+	_, err = store.Get(context.Background(), "key")
+	if err == nil {
+		t.Fatalf("Get() error = nil, want error")
+	}
+
+	mockRepo.EXPECT().LockLogin(gomock.Any(), repository.LockLoginInput{
+		Key:         "key",
+		LockedUntil: lockedUntil,
+	}).Return(nil)
+	if err := store.Lock(context.Background(), "key", lockedUntil); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	mockRepo.EXPECT().ResetLoginFailures(gomock.Any(), "key").Return(nil)
+	if err := store.Reset(context.Background(), "key"); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+}
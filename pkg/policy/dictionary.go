@@ -0,0 +1,97 @@
+package policy
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// minWordLength is the length of the shortest word looked for in a password
+	minWordLength = 3
+	// maxWordLength is the length of the longest word looked for in a password
+	maxWordLength = 32
+)
+
+// commonPasswords are the passwords and words tried first, most common first
+var commonPasswords = []string{
+	"123456", "password", "12345678", "qwerty", "123456789", "12345", "1234", "111111",
+	"1234567", "dragon", "123123", "baseball", "abc123", "football", "monkey", "letmein",
+	"696969", "shadow", "master", "666666", "qwertyuiop", "123321", "mustang", "1234567890",
+	"michael", "654321", "superman", "1qaz2wsx", "7777777", "121212", "000000", "qazwsx",
+	"123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm", "asdfgh", "hunter",
+	"buster", "soccer", "harley", "batman", "andrew", "tigger", "sunshine", "iloveyou",
+	"charlie", "robert", "thomas", "hockey", "ranger", "daniel", "starwars",
+	"klaster", "112233", "george", "computer", "michelle", "jessica", "pepper", "zxcvbn",
+	"555555", "131313", "freedom", "777777", "pass", "maggie", "159753", "aaaaaa",
+	"ginger", "princess", "joshua", "cheese", "amanda", "summer", "love", "ashley",
+	"nicole", "chelsea", "biteme", "matthew", "access", "yankees", "987654321", "dallas",
+	"austin", "thunder", "taylor", "matrix", "admin", "welcome", "login", "secret",
+	"hello", "whatever", "passw0rd", "password1", "changeme", "default", "guest", "root",
+	"user", "test", "qwerty123", "samsung", "google", "apple", "orange", "banana",
+	"chocolate", "flower", "angel", "lovely", "winter", "spring", "autumn", "purple",
+	"internet", "business", "family", "friends", "forever", "money", "jesus", "blessed",
+	// common in Indonesia, where the service is used
+	"indonesia", "jakarta", "bandung", "surabaya", "sayang", "sayangku", "cinta", "cintaku",
+	"rahasia", "bismillah", "alhamdulillah", "katasandi", "sandi", "kunci", "merdeka",
+	"garuda", "persib", "persija", "kucing", "sawit",
+}
+
+// dictionary ranks the words, lower ranks are guessed first
+type dictionary struct {
+	// ranks is keyed by the folded words
+	ranks map[string]int
+	// words are the lower-case words as they are, they aren't l33t variations
+	words map[string]bool
+}
+
+// newDictionary ranks the user inputs before the common passwords, they are what an attacker
+// targeting the user tries first
+func newDictionary(userInputs []string) dictionary {
+	dict := dictionary{
+		ranks: map[string]int{},
+		words: map[string]bool{},
+	}
+	add := func(word string) {
+		word = strings.ToLower(word)
+		folded := foldString(word)
+		if len([]rune(folded)) < minWordLength {
+			return
+		}
+		dict.words[word] = true
+		if _, ok := dict.ranks[folded]; !ok {
+			dict.ranks[folded] = len(dict.ranks) + 1
+		}
+	}
+
+	for _, input := range userInputs {
+		add(input)
+	}
+	for _, word := range commonPasswords {
+		add(word)
+	}
+	return dict
+}
+
+// fold maps a rune to the letter it stands for, so P@ssw0rd and password match. Runes that
+// are often swapped for each other, like l, i and 1, all map to the same letter.
+func fold(c rune) rune {
+	switch c = unicode.ToLower(c); c {
+	case '@', '4':
+		return 'a'
+	case '3':
+		return 'e'
+	case '1', '!', '|', 'l':
+		return 'i'
+	case '0':
+		return 'o'
+	case '$', '5':
+		return 's'
+	case '7', '+':
+		return 't'
+	}
+	return c
+}
+
+func foldString(s string) string {
+	return strings.Map(fold, s)
+}
@@ -0,0 +1,262 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Violation codes, they are stable so clients can show their own message
+const (
+	CodeTooShort         = "too_short"
+	CodeTooLong          = "too_long"
+	CodeMissingUppercase = "missing_uppercase"
+	CodeMissingLowercase = "missing_lowercase"
+	CodeMissingDigit     = "missing_digit"
+	CodeMissingSymbol    = "missing_symbol"
+	CodeTooWeak          = "too_weak"
+	CodeBannedWord       = "banned_word"
+	CodeRecentlyUsed     = "recently_used"
+)
+
+const (
+	// minUserWordLength is the length of the shortest part of a name that is banned,
+	// shorter parts are too common to ban
+	minUserWordLength = 3
+	// minPhoneDigits is the fewest national digits of a phone number that are banned
+	minPhoneDigits = 6
+)
+
+// Violation is a rule of the policy the password breaks
+type Violation struct {
+	Code    string
+	Message string
+}
+
+// User is what is known of the user the password is for, it is banned from the password
+type User struct {
+	FullName    string
+	PhoneNumber string
+}
+
+// PolicyConfig is list dependencies of Policy Package
+type PolicyConfig struct {
+	minLength        int
+	maxLength        int
+	maxBytes         int
+	requireUppercase bool
+	requireLowercase bool
+	requireDigit     bool
+	requireSymbol    bool
+	minStrength      int
+	bannedWords      []string
+	historySize      int
+}
+
+// PolicyMethod is list method for Policy Package
+type PolicyMethod interface {
+	Validate(password string, user User) []Violation
+	HistorySize() int
+}
+
+type NewPolicyConfig struct {
+	// MinLength is the fewest characters of a password, at least 1
+	MinLength int
+	// MaxLength is the most characters of a password, at least MinLength
+	MaxLength int
+	// MaxBytes is the most bytes of a password, the hash may take fewer bytes than MaxLength
+	// multibyte characters, 0 is no limit
+	MaxBytes int
+	// RequireUppercase requires an upper-case letter
+	RequireUppercase bool
+	// RequireLowercase requires a lower-case letter
+	RequireLowercase bool
+	// RequireDigit requires a digit
+	RequireDigit bool
+	// RequireSymbol requires a symbol, any Unicode punctuation or symbol counts
+	RequireSymbol bool
+	// MinStrength is the lowest Strength score accepted, from StrengthMin to StrengthMax
+	MinStrength int
+	// BannedWords can't appear in a password in any case, l33t substitutions of them only lower
+	// the strength, the name and phone number of the user are always banned
+	BannedWords []string
+	// HistorySize is how many previous passwords can't be set again, besides the current one
+	HistorySize int
+}
+
+// NewPolicyMethod func to create PolicyMethod interface
+func NewPolicyMethod(cfg NewPolicyConfig) (PolicyMethod, error) {
+	if cfg.MinLength < 1 {
+		return nil, fmt.Errorf("password min length must be at least 1")
+	}
+	if cfg.MaxLength < cfg.MinLength {
+		return nil, fmt.Errorf("password max length must be at least the min length %d", cfg.MinLength)
+	}
+	if cfg.MaxBytes < 0 {
+		return nil, fmt.Errorf("password max bytes must not be negative")
+	}
+	if cfg.MinStrength < StrengthMin || cfg.MinStrength > StrengthMax {
+		return nil, fmt.Errorf("password min strength must be between %d and %d", StrengthMin, StrengthMax)
+	}
+	if cfg.HistorySize < 0 {
+		return nil, fmt.Errorf("password history size must not be negative")
+	}
+
+	var bannedWords []string
+	for _, word := range cfg.BannedWords {
+		word = strings.TrimSpace(word)
+		if word != "" {
+			bannedWords = append(bannedWords, word)
+		}
+	}
+
+	return &PolicyConfig{
+		minLength:        cfg.MinLength,
+		maxLength:        cfg.MaxLength,
+		maxBytes:         cfg.MaxBytes,
+		requireUppercase: cfg.RequireUppercase,
+		requireLowercase: cfg.RequireLowercase,
+		requireDigit:     cfg.RequireDigit,
+		requireSymbol:    cfg.RequireSymbol,
+		minStrength:      cfg.MinStrength,
+		bannedWords:      bannedWords,
+		historySize:      cfg.HistorySize,
+	}, nil
+}
+
+// Validate func to list every rule of the policy the password breaks, none when it is accepted
+func (p *PolicyConfig) Validate(password string, user User) []Violation {
+	var violations []Violation
+
+	length := len([]rune(password))
+	if length < p.minLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooShort,
+			Message: fmt.Sprintf("password must be at least %d characters", p.minLength),
+		})
+	}
+	if length > p.maxLength {
+		violations = append(violations, Violation{
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("password must be at most %d characters", p.maxLength),
+		})
+	} else if p.maxBytes > 0 && len(password) > p.maxBytes {
+		violations = append(violations, Violation{
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("password must be at most %d bytes, use fewer accented letters, symbols or emoji", p.maxBytes),
+		})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			hasSymbol = true
+		}
+	}
+	if p.requireUppercase && !hasUpper {
+		violations = append(violations, Violation{
+			Code:    CodeMissingUppercase,
+			Message: "password must contain an uppercase letter",
+		})
+	}
+	if p.requireLowercase && !hasLower {
+		violations = append(violations, Violation{
+			Code:    CodeMissingLowercase,
+			Message: "password must contain a lowercase letter",
+		})
+	}
+	if p.requireDigit && !hasDigit {
+		violations = append(violations, Violation{
+			Code:    CodeMissingDigit,
+			Message: "password must contain a digit",
+		})
+	}
+	if p.requireSymbol && !hasSymbol {
+		violations = append(violations, Violation{
+			Code:    CodeMissingSymbol,
+			Message: "password must contain a symbol",
+		})
+	}
+
+	// only the case is folded, fold maps l, 1, ! and | to the same letter so short words like the
+	// name Ali would ban every password with all or a1l, l33t variants are left to the strength
+	userWords := userInputs(user)
+	lowered := strings.ToLower(password)
+	for _, word := range append(append([]string{}, p.bannedWords...), userWords...) {
+		if strings.Contains(lowered, strings.ToLower(word)) {
+			violations = append(violations, Violation{
+				Code:    CodeBannedWord,
+				Message: fmt.Sprintf("password must not contain %q", word),
+			})
+		}
+	}
+
+	if p.minStrength > StrengthMin {
+		inputs := append(append([]string{}, userWords...), p.bannedWords...)
+		if Strength(password, inputs...) < p.minStrength {
+			violations = append(violations, Violation{
+				Code:    CodeTooWeak,
+				Message: "password is too easy to guess, add more words or characters",
+			})
+		}
+	}
+
+	return violations
+}
+
+// HistorySize func to get how many previous passwords can't be set again, besides the current one
+func (p *PolicyConfig) HistorySize() int {
+	return p.historySize
+}
+
+// RecentlyUsed is the violation of a password that is the current one or one of the history
+func RecentlyUsed() Violation {
+	return Violation{
+		Code:    CodeRecentlyUsed,
+		Message: "new password must differ from the recently used passwords",
+	}
+}
+
+// userInputs are the parts of the name and the national digits of the phone number of the user,
+// a password containing them is guessed first by someone who knows the user
+func userInputs(user User) []string {
+	var inputs []string
+	seen := map[string]bool{}
+	for _, part := range strings.FieldsFunc(user.FullName, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	}) {
+		part = strings.ToLower(part)
+		if len([]rune(part)) >= minUserWordLength && !seen[part] {
+			seen[part] = true
+			inputs = append(inputs, part)
+		}
+	}
+
+	if digits := nationalDigits(user.PhoneNumber); len(digits) >= minPhoneDigits {
+		inputs = append(inputs, digits)
+	}
+	return inputs
+}
+
+// nationalDigits strips the phone number down to its digits without the +62 country code
+// or the 0 trunk prefix, so every way of writing the number is found
+func nationalDigits(phoneNumber string) string {
+	digits := strings.Map(func(c rune) rune {
+		if c >= '0' && c <= '9' {
+			return c
+		}
+		return -1
+	}, phoneNumber)
+
+	if strings.HasPrefix(digits, "62") {
+		return digits[2:]
+	}
+	return strings.TrimPrefix(digits, "0")
+}
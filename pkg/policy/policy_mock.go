@@ -0,0 +1,67 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/policy/policy.go
+//
+// Generated by this command:
+//
+//	mockgen -source=pkg/policy/policy.go -destination=pkg/policy/policy_mock.go -package=policy
+//
+
+// Package policy is a generated GoMock package.
+package policy
+
+import (
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockPolicyMethod is a mock of PolicyMethod interface.
+type MockPolicyMethod struct {
+	ctrl     *gomock.Controller
+	recorder *MockPolicyMethodMockRecorder
+}
+
+// MockPolicyMethodMockRecorder is the mock recorder for MockPolicyMethod.
+type MockPolicyMethodMockRecorder struct {
+	mock *MockPolicyMethod
+}
+
+// NewMockPolicyMethod creates a new mock instance.
+func NewMockPolicyMethod(ctrl *gomock.Controller) *MockPolicyMethod {
+	mock := &MockPolicyMethod{ctrl: ctrl}
+	mock.recorder = &MockPolicyMethodMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPolicyMethod) EXPECT() *MockPolicyMethodMockRecorder {
+	return m.recorder
+}
+
+// HistorySize mocks base method.
+func (m *MockPolicyMethod) HistorySize() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HistorySize")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// HistorySize indicates an expected call of HistorySize.
+func (mr *MockPolicyMethodMockRecorder) HistorySize() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HistorySize", reflect.TypeOf((*MockPolicyMethod)(nil).HistorySize))
+}
+
+// Validate mocks base method.
+func (m *MockPolicyMethod) Validate(password string, user User) []Violation {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate", password, user)
+	ret0, _ := ret[0].([]Violation)
+	return ret0
+}
+
+// Validate indicates an expected call of Validate.
+func (mr *MockPolicyMethodMockRecorder) Validate(password, user any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockPolicyMethod)(nil).Validate), password, user)
+}
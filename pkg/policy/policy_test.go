@@ -0,0 +1,252 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testPolicyConfig() NewPolicyConfig {
+	return NewPolicyConfig{
+		MinLength:        8,
+		MaxLength:        20,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		MinStrength:      2,
+		BannedWords:      []string{"sawit", " "},
+		HistorySize:      3,
+	}
+}
+
+func TestNewPolicyMethod(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  func(cfg *NewPolicyConfig)
+		wantErr bool
+	}{
+		{
+			name:   "success flow",
+			update: func(cfg *NewPolicyConfig) {},
+		},
+		{
+			name:    "error flow min length",
+			update:  func(cfg *NewPolicyConfig) { cfg.MinLength = 0 },
+			wantErr: true,
+		},
+		{
+			name:    "error flow max length below min length",
+			update:  func(cfg *NewPolicyConfig) { cfg.MaxLength = 7 },
+			wantErr: true,
+		},
+		{
+			name:    "error flow min strength",
+			update:  func(cfg *NewPolicyConfig) { cfg.MinStrength = StrengthMax + 1 },
+			wantErr: true,
+		},
+		{
+			name:    "error flow max bytes",
+			update:  func(cfg *NewPolicyConfig) { cfg.MaxBytes = -1 },
+			wantErr: true,
+		},
+		{
+			name:    "error flow history size",
+			update:  func(cfg *NewPolicyConfig) { cfg.HistorySize = -1 },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testPolicyConfig()
+			tt.update(&cfg)
+			got, err := NewPolicyMethod(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewPolicyMethod() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got.HistorySize() != 3 {
+				t.Errorf("PolicyConfig.HistorySize() = %d, want 3", got.HistorySize())
+			}
+		})
+	}
+}
+
+func TestPolicyConfig_Validate(t *testing.T) {
+	p, err := NewPolicyMethod(testPolicyConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := User{
+		FullName:    "Budi Santoso",
+		PhoneNumber: "+6281234567890",
+	}
+
+	codes := func(violations []Violation) []string {
+		var got []string
+		for _, v := range violations {
+			got = append(got, v.Code)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{
+			name:     "accepted password",
+			password: "xK9#mQ2$vLp",
+		},
+		{
+			name:     "unicode punctuation is a symbol",
+			password: "xK9¿mQ2vLpw",
+		},
+		{
+			name:     "unicode letters count in runes",
+			password: "Ünïcödé9#x",
+		},
+		{
+			name:     "too short",
+			password: "hT7!kq",
+			want:     []string{CodeTooShort, CodeTooWeak},
+		},
+		{
+			name:     "too long",
+			password: "xK9#mQ2$vLpxK9#mQ2$vLp",
+			want:     []string{CodeTooLong},
+		},
+		{
+			name:     "missing character classes",
+			password: "zqmvkwtrhxpl",
+			want:     []string{CodeMissingUppercase, CodeMissingDigit, CodeMissingSymbol},
+		},
+		{
+			name:     "missing lowercase",
+			password: "ZQMVKWTR9#X",
+			want:     []string{CodeMissingLowercase},
+		},
+		{
+			name:     "common password is too weak",
+			password: "P@ssw0rd123!",
+			want:     []string{CodeTooWeak},
+		},
+		{
+			name:     "configured banned word in another case",
+			password: "SaWiTQx9#mQz",
+			want:     []string{CodeBannedWord},
+		},
+		{
+			name:     "configured banned word with l33t is only scored",
+			password: "$4w1tQx9#mQz",
+			want:     nil,
+		},
+		{
+			name:     "name of the user",
+			password: "Xq9#BUDIzmKw",
+			want:     []string{CodeBannedWord},
+		},
+		{
+			name:     "phone number of the user",
+			password: "Xq#081234567890",
+			want:     []string{CodeBannedWord, CodeTooWeak},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := codes(p.Validate(tt.password, user)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PolicyConfig.Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyConfig_ValidateBannedWordMessage(t *testing.T) {
+	p, err := NewPolicyMethod(NewPolicyConfig{
+		MinLength: 1,
+		MaxLength: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := p.Validate("santoso", User{FullName: "Budi Santoso"})
+	want := []Violation{{Code: CodeBannedWord, Message: `password must not contain "santoso"`}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PolicyConfig.Validate() = %v, want %v", got, want)
+	}
+}
+
+func TestPolicyConfig_ValidateMaxBytes(t *testing.T) {
+	// 64 characters of 3 bytes each, within MaxLength but longer than bcrypt takes
+	password := strings.Repeat("密", 64)
+
+	limited, err := NewPolicyMethod(NewPolicyConfig{
+		MinLength: 1,
+		MaxLength: 64,
+		MaxBytes:  72,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := limited.Validate(password, User{})
+	want := []Violation{{Code: CodeTooLong, Message: "password must be at most 72 bytes, use fewer accented letters, symbols or emoji"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PolicyConfig.Validate() = %v, want %v", got, want)
+	}
+
+	unlimited, err := NewPolicyMethod(NewPolicyConfig{
+		MinLength: 1,
+		MaxLength: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unlimited.Validate(password, User{}); len(got) != 0 {
+		t.Errorf("PolicyConfig.Validate() = %v, want none", got)
+	}
+}
+
+func TestPolicyConfig_ValidateShortName(t *testing.T) {
+	p, err := NewPolicyMethod(NewPolicyConfig{
+		MinLength: 1,
+		MaxLength: 64,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// l and 1 fold to the same letter as i for the strength, the name Ali must not ban all or a1l
+	user := User{FullName: "Ali Rahman"}
+	for _, password := range []string{"Tall#Tree9x", "Ba1l#Room7q", "ALL#in9Zqx"} {
+		if got := p.Validate(password, user); len(got) != 0 {
+			t.Errorf("PolicyConfig.Validate(%q) = %v, want none", password, got)
+		}
+	}
+
+	got := p.Validate("xALIx#9q", user)
+	want := []Violation{{Code: CodeBannedWord, Message: `password must not contain "ali"`}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PolicyConfig.Validate() = %v, want %v", got, want)
+	}
+}
+
+func TestNationalDigits(t *testing.T) {
+	tests := []struct {
+		phoneNumber string
+		want        string
+	}{
+		{phoneNumber: "+6281234567890", want: "81234567890"},
+		{phoneNumber: "081234567890", want: "81234567890"},
+		{phoneNumber: "0812-3456-7890", want: "81234567890"},
+		{phoneNumber: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phoneNumber, func(t *testing.T) {
+			if got := nationalDigits(tt.phoneNumber); got != tt.want {
+				t.Errorf("nationalDigits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
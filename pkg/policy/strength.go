@@ -0,0 +1,357 @@
+package policy
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// StrengthMin is the score of a password guessed in under a thousand guesses
+	StrengthMin = 0
+	// StrengthMax is the score of a password needing at least ten billion guesses
+	StrengthMax = 4
+
+	// bruteforceCardinality is the guesses per character no pattern explains, as in zxcvbn
+	bruteforceCardinality = 10
+	// maxStrengthRunes bounds the work of Strength, the runes after it are not looked at
+	maxStrengthRunes = 100
+	// minYearSpace is the fewest years an attacker tries around the current one
+	minYearSpace = 20
+)
+
+// keyboardRows are the adjacent keys people walk along, on a qwerty layout
+var keyboardRows = []string{
+	"`1234567890-=",
+	"~!@#$%^&*()_+",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"qazwsxedcrfvtgbyhnujmikolp",
+}
+
+// match is a part of the password explained by a pattern, runes i to j included
+type match struct {
+	i       int
+	j       int
+	guesses float64
+}
+
+// Strength scores how hard the password is to guess from 0 to 4, in the style of zxcvbn: the
+// password is split into the dictionary words, keyboard walks, sequences, repeats and dates that
+// are cheapest for an attacker to guess, the rest is guessed character by character. The user
+// inputs, like the name of the user, are guessed first.
+func Strength(password string, userInputs ...string) int {
+	runes := []rune(password)
+	if len(runes) > maxStrengthRunes {
+		runes = runes[:maxStrengthRunes]
+	}
+
+	e := &estimator{
+		dict:  newDictionary(userInputs),
+		cache: map[string]float64{},
+	}
+	return score(e.guesses(runes))
+}
+
+func score(guesses float64) int {
+	switch {
+	case guesses < 1e3+5:
+		return 0
+	case guesses < 1e6+5:
+		return 1
+	case guesses < 1e8+5:
+		return 2
+	case guesses < 1e10+5:
+		return 3
+	default:
+		return StrengthMax
+	}
+}
+
+// estimator keeps the guesses of the blocks of repeats, a long repeat has many blocks in common
+type estimator struct {
+	dict  dictionary
+	cache map[string]float64
+}
+
+// guesses finds the cheapest split of the password into matches and brute-forced runs,
+// a split into l parts costs l! times the product of their guesses since their order is unknown
+func (e *estimator) guesses(password []rune) float64 {
+	if guesses, ok := e.cache[string(password)]; ok {
+		return guesses
+	}
+
+	n := len(password)
+	if n == 0 {
+		return 1
+	}
+
+	endingAt := make([][]match, n)
+	for _, m := range e.findMatches(password) {
+		endingAt[m.j] = append(endingAt[m.j], m)
+	}
+
+	// best[l][k] is the lowest log10 product of guesses covering runes 0 to k-1 in l parts
+	best := make([][]float64, n+1)
+	for l := range best {
+		best[l] = make([]float64, n+1)
+		for k := range best[l] {
+			best[l][k] = math.Inf(1)
+		}
+	}
+	best[0][0] = 0
+
+	for k := 1; k <= n; k++ {
+		for l := 1; l <= k; l++ {
+			for i := 0; i < k; i++ {
+				// a brute-forced run from i to k-1
+				bruteforce := float64(k-i) * math.Log10(bruteforceCardinality)
+				if v := best[l-1][i] + bruteforce; v < best[l][k] {
+					best[l][k] = v
+				}
+			}
+			for _, m := range endingAt[k-1] {
+				if v := best[l-1][m.i] + math.Log10(m.guesses); v < best[l][k] {
+					best[l][k] = v
+				}
+			}
+		}
+	}
+
+	guesses := math.Inf(1)
+	for l := 1; l <= n; l++ {
+		if math.IsInf(best[l][n], 1) {
+			continue
+		}
+		lgamma, _ := math.Lgamma(float64(l + 1))
+		if v := best[l][n] + lgamma/math.Ln10; v < guesses {
+			guesses = v
+		}
+	}
+	e.cache[string(password)] = math.Pow(10, guesses)
+	return e.cache[string(password)]
+}
+
+func (e *estimator) findMatches(password []rune) []match {
+	var matches []match
+	matches = append(matches, dictionaryMatches(password, e.dict)...)
+	matches = append(matches, keyboardMatches(password)...)
+	matches = append(matches, sequenceMatches(password)...)
+	matches = append(matches, e.repeatMatches(password)...)
+	matches = append(matches, dateMatches(password)...)
+	return matches
+}
+
+// dictionaryMatches finds the words of the dictionary, also reversed and with l33t substitutions
+func dictionaryMatches(password []rune, dict dictionary) []match {
+	folded := make([]rune, len(password))
+	for i, c := range password {
+		folded[i] = fold(c)
+	}
+
+	var matches []match
+	for i := range password {
+		for j := i + minWordLength - 1; j < len(password) && j-i < maxWordLength; j++ {
+			word := password[i : j+1]
+			variations := uppercaseVariations(word)
+			if !dict.words[strings.ToLower(string(word))] {
+				variations *= l33tVariations(word)
+			}
+
+			folded := string(folded[i : j+1])
+			if rank, ok := dict.ranks[folded]; ok {
+				matches = append(matches, match{i: i, j: j, guesses: float64(rank) * variations})
+			}
+			if rank, ok := dict.ranks[reverse(folded)]; ok {
+				matches = append(matches, match{i: i, j: j, guesses: 2 * float64(rank) * variations})
+			}
+		}
+	}
+	return matches
+}
+
+// uppercaseVariations is how many capitalizations an attacker tries before this one
+func uppercaseVariations(word []rune) float64 {
+	var upper, lower int
+	for _, c := range word {
+		switch {
+		case unicode.IsUpper(c):
+			upper++
+		case unicode.IsLower(c):
+			lower++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 1
+	case lower == 0 || (upper == 1 && unicode.IsUpper(word[0])) || (upper == 1 && unicode.IsUpper(word[len(word)-1])):
+		return 2
+	default:
+		return math.Pow(2, float64(min(upper, lower)))
+	}
+}
+
+// l33tVariations is how many substitutions an attacker tries before this one
+func l33tVariations(word []rune) float64 {
+	substitutions := 0
+	for _, c := range word {
+		if !unicode.IsLetter(c) && unicode.IsLetter(fold(c)) {
+			substitutions++
+		}
+	}
+	if substitutions == 0 {
+		return 1
+	}
+	return math.Pow(2, float64(min(substitutions, 4)))
+}
+
+// keyboardMatches finds runs of at least four adjacent keys in a row
+func keyboardMatches(password []rune) []match {
+	lower := []rune(strings.ToLower(string(password)))
+	if len(lower) != len(password) {
+		return nil
+	}
+
+	var matches []match
+	for i := range lower {
+		for j := i + 3; j < len(lower); j++ {
+			walk := string(lower[i : j+1])
+			found := false
+			for _, row := range keyboardRows {
+				if strings.Contains(row, walk) || strings.Contains(row, reverse(walk)) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				break
+			}
+			// any of about 40 keys to start from, and the direction
+			matches = append(matches, match{i: i, j: j, guesses: 40 * 2 * float64(j-i+1)})
+		}
+	}
+	return matches
+}
+
+// sequenceMatches finds runs of at least three runes with the same small step, like abc or 9753
+func sequenceMatches(password []rune) []match {
+	var matches []match
+	for i := 0; i+2 < len(password); i++ {
+		delta := password[i+1] - password[i]
+		if delta == 0 || delta > 5 || delta < -5 {
+			continue
+		}
+
+		j := i + 1
+		for j+1 < len(password) && password[j+1]-password[j] == delta {
+			j++
+		}
+		if j-i < 2 {
+			continue
+		}
+
+		var base float64
+		switch c := unicode.ToLower(password[i]); {
+		case c == 'a' || c == 'z' || c == '0' || c == '1' || c == '9':
+			base = 4
+		case unicode.IsDigit(c):
+			base = 10
+		default:
+			base = 26
+		}
+		if delta < 0 {
+			base *= 2
+		}
+		for k := i + 2; k <= j; k++ {
+			matches = append(matches, match{i: i, j: k, guesses: base * float64(k-i+1)})
+		}
+	}
+	return matches
+}
+
+// repeatMatches finds a block repeated right after itself, like aaa or abcabc
+func (e *estimator) repeatMatches(password []rune) []match {
+	var matches []match
+	for i := range password {
+		for size := 1; i+2*size <= len(password); size++ {
+			block := password[i : i+size]
+			count := 1
+			for i+(count+1)*size <= len(password) && string(password[i+count*size:i+(count+1)*size]) == string(block) {
+				count++
+			}
+			if count < 2 || (size == 1 && count < 3) {
+				continue
+			}
+
+			guesses := e.guesses(block) * float64(count)
+			matches = append(matches, match{i: i, j: i + count*size - 1, guesses: guesses})
+		}
+	}
+	return matches
+}
+
+// dateMatches finds years and dates written as ddmmyyyy or yyyymmdd
+func dateMatches(password []rune) []match {
+	referenceYear := time.Now().Year()
+	yearGuesses := func(year int) float64 {
+		return math.Max(math.Abs(float64(year-referenceYear)), minYearSpace)
+	}
+
+	var matches []match
+	for i := range password {
+		if i+4 <= len(password) {
+			if year, ok := atoi(password[i : i+4]); ok && year >= 1900 && year <= 2099 {
+				matches = append(matches, match{i: i, j: i + 3, guesses: yearGuesses(year)})
+			}
+		}
+		if i+8 <= len(password) {
+			digits := password[i : i+8]
+			day, okDay := atoi(digits[0:2])
+			month, okMonth := atoi(digits[2:4])
+			year, okYear := atoi(digits[4:8])
+			if okDay && okMonth && okYear && validDate(year, month, day) {
+				matches = append(matches, match{i: i, j: i + 7, guesses: 365 * yearGuesses(year)})
+			}
+			year, okYear = atoi(digits[0:4])
+			month, okMonth = atoi(digits[4:6])
+			day, okDay = atoi(digits[6:8])
+			if okDay && okMonth && okYear && validDate(year, month, day) {
+				matches = append(matches, match{i: i, j: i + 7, guesses: 365 * yearGuesses(year)})
+			}
+		}
+	}
+	return matches
+}
+
+func validDate(year, month, day int) bool {
+	return year >= 1900 && year <= 2099 && month >= 1 && month <= 12 && day >= 1 && day <= 31
+}
+
+func atoi(digits []rune) (int, bool) {
+	n := 0
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStrength(t *testing.T) {
+	tests := []struct {
+		name       string
+		password   string
+		userInputs []string
+		want       int
+	}{
+		{
+			name:     "empty",
+			password: "",
+			want:     0,
+		},
+		{
+			name:     "common password",
+			password: "password",
+			want:     0,
+		},
+		{
+			name:     "l33t common password",
+			password: "P@ssw0rd!",
+			want:     0,
+		},
+		{
+			name:     "keyboard walk",
+			password: "qwertyuiop",
+			want:     0,
+		},
+		{
+			name:     "repeat",
+			password: "abcabcabcabc",
+			want:     0,
+		},
+		{
+			name:     "date",
+			password: "19901231",
+			want:     1,
+		},
+		{
+			name:     "random characters",
+			password: "xK9#mQ2$vL",
+			want:     3,
+		},
+		{
+			name:     "passphrase",
+			password: "correct horse battery staple",
+			want:     4,
+		},
+		{
+			name:       "user input is guessed first",
+			password:   "budisantoso",
+			userInputs: []string{"budi", "santoso"},
+			want:       0,
+		},
+		{
+			name:     "long password is cut",
+			password: strings.Repeat("xK9#mQ2$vL", 100),
+			want:     4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Strength(tt.password, tt.userInputs...); got != tt.want {
+				t.Errorf("Strength() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Kinds of caller a policy is keyed by.
+const (
+	KeyIP          = "ip"
+	KeyUser        = "user"
+	KeyPhoneNumber = "phone_number"
+)
+
+// Policy is a token bucket holding Limit tokens that refills completely over Period,
+// every request takes one token and is refused when the bucket is empty
+type Policy struct {
+	// Key is the kind of caller the bucket belongs to, one of KeyIP, KeyUser or KeyPhoneNumber
+	Key    string
+	Limit  int
+	Period time.Duration
+}
+
+// interval is the time the bucket takes to refill one token
+func (p Policy) interval() time.Duration {
+	return p.Period / time.Duration(p.Limit)
+}
+
+// Result is the state of a bucket after a request, it feeds the RateLimit-* response headers
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long the bucket takes to be full again
+	Reset time.Duration
+	// RetryAfter is how long the caller must wait for the next token, it is 0 when allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitConfig is list dependencies of RateLimit Package
+type RateLimitConfig struct {
+	store Store
+	now   func() time.Time
+}
+
+// RateLimitMethod is list method for RateLimit Package
+type RateLimitMethod interface {
+	Allow(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+type NewRateLimitConfig struct {
+	Store Store
+}
+
+// NewRateLimitMethod func to create RateLimitMethod interface
+func NewRateLimitMethod(cfg NewRateLimitConfig) RateLimitMethod {
+	return &RateLimitConfig{
+		store: cfg.Store,
+		now: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+// Allow func to take a token from the bucket of the key, key must already tell the
+// policy apart from the others since buckets of the same key are shared
+func (r *RateLimitConfig) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	now := r.now()
+	interval := policy.interval()
+
+	allowed, fullAt, err := r.store.Take(ctx, key, now, interval, policy.Period)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// the bucket is missing one token for every interval until it is full
+	reset := fullAt.Sub(now)
+	if reset < 0 {
+		reset = 0
+	}
+	missing := int((reset + interval - 1) / interval)
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: policy.Limit - missing,
+		Reset:     reset,
+	}
+	if result.Remaining < 0 {
+		result.Remaining = 0
+	}
+	if !allowed {
+		result.RetryAfter = reset - policy.Period + interval
+	}
+
+	return result, nil
+}
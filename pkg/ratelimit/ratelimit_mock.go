@@ -0,0 +1,55 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/ratelimit/ratelimit.go
+//
+// Generated by this command:
+//
+//	mockgen -source=pkg/ratelimit/ratelimit.go -destination=pkg/ratelimit/ratelimit_mock.go -package=ratelimit
+//
+
+// Package ratelimit is a generated GoMock package.
+package ratelimit
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockRateLimitMethod is a mock of RateLimitMethod interface.
+type MockRateLimitMethod struct {
+	ctrl     *gomock.Controller
+	recorder *MockRateLimitMethodMockRecorder
+}
+
+// MockRateLimitMethodMockRecorder is the mock recorder for MockRateLimitMethod.
+type MockRateLimitMethodMockRecorder struct {
+	mock *MockRateLimitMethod
+}
+
+// NewMockRateLimitMethod creates a new mock instance.
+func NewMockRateLimitMethod(ctrl *gomock.Controller) *MockRateLimitMethod {
+	mock := &MockRateLimitMethod{ctrl: ctrl}
+	mock.recorder = &MockRateLimitMethodMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRateLimitMethod) EXPECT() *MockRateLimitMethodMockRecorder {
+	return m.recorder
+}
+
+// Allow mocks base method.
+func (m *MockRateLimitMethod) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Allow", ctx, key, policy)
+	ret0, _ := ret[0].(Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Allow indicates an expected call of Allow.
+func (mr *MockRateLimitMethodMockRecorder) Allow(ctx, key, policy any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Allow", reflect.TypeOf((*MockRateLimitMethod)(nil).Allow), ctx, key, policy)
+}
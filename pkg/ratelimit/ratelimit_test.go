@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRateLimitConfig_Allow(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	policy := Policy{
+		Key:    KeyIP,
+		Limit:  3,
+		Period: time.Minute,
+	}
+	tests := []struct {
+		name    string
+		prepare func(r *RateLimitConfig)
+		want    Result
+	}{
+		{
+			name:    "first request",
+			prepare: func(r *RateLimitConfig) {},
+			want: Result{
+				Allowed:   true,
+				Limit:     3,
+				Remaining: 2,
+				Reset:     20 * time.Second,
+			},
+		},
+		{
+			name: "last token",
+			prepare: func(r *RateLimitConfig) {
+				r.Allow(context.Background(), "key", policy)
+				r.Allow(context.Background(), "key", policy)
+			},
+			want: Result{
+				Allowed:   true,
+				Limit:     3,
+				Remaining: 0,
+				Reset:     time.Minute,
+			},
+		},
+		{
+			name: "bucket empty",
+			prepare: func(r *RateLimitConfig) {
+				for i := 0; i < 3; i++ {
+					r.Allow(context.Background(), "key", policy)
+				}
+			},
+			want: Result{
+				Limit:      3,
+				Remaining:  0,
+				Reset:      time.Minute,
+				RetryAfter: 20 * time.Second,
+			},
+		},
+		{
+			name: "bucket refilled",
+			prepare: func(r *RateLimitConfig) {
+				for i := 0; i < 3; i++ {
+					r.Allow(context.Background(), "key", policy)
+				}
+				now = now.Add(30 * time.Second)
+			},
+			want: Result{
+				Allowed:   true,
+				Limit:     3,
+				Remaining: 0,
+				Reset:     50 * time.Second,
+			},
+		},
+		{
+			name: "other key has its own bucket",
+			prepare: func(r *RateLimitConfig) {
+				for i := 0; i < 3; i++ {
+					r.Allow(context.Background(), "other", policy)
+				}
+			},
+			want: Result{
+				Allowed:   true,
+				Limit:     3,
+				Remaining: 2,
+				Reset:     20 * time.Second,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			r := &RateLimitConfig{
+				store: NewMemoryStore(),
+				now: func() time.Time {
+					return now
+				},
+			}
+			tt.prepare(r)
+
+			got, err := r.Allow(context.Background(), "key", policy)
+			if err != nil {
+				t.Fatalf("Allow() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Allow() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresStore_Take(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := NewPostgresStore(mockRepo)
+
+	mockRepo.EXPECT().TakeRateLimitToken(gomock.Any(), repository.TakeRateLimitTokenInput{
+		Key:      "key",
+		Now:      now,
+		Interval: 20 * time.Second,
+		Period:   time.Minute,
+	}).Return(repository.TakeRateLimitTokenOutput{
+		Allowed: true,
+		FullAt:  now.Add(20 * time.Second),
+	}, nil)
+	allowed, fullAt, err := store.Take(context.Background(), "key", now, 20*time.Second, time.Minute)
+	if err != nil || !allowed || !fullAt.Equal(now.Add(20*time.Second)) {
+		t.Fatalf("Take() = %v, %v, %v", allowed, fullAt, err)
+	}
+
+	mockRepo.EXPECT().TakeRateLimitToken(gomock.Any(), gomock.Any()).Return(repository.TakeRateLimitTokenOutput{}, fmt.Errorf("some error"))
+	_, _, err = store.Take(context.Background(), "key", now, 20*time.Second, time.Minute)
+	if err == nil {
+		t.Fatalf("Take() error = nil, want error")
+	}
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// Store keeps the token buckets, it must be shared by every instance of the service
+// for the limits to hold across instances. A bucket is kept as the time it is full
+// again, taking a token pushes that time by one interval.
+type Store interface {
+	// Take takes a token atomically unless that pushes the bucket past now + period,
+	// it returns whether the token was taken and when the bucket is full again
+	Take(ctx context.Context, key string, now time.Time, interval time.Duration, period time.Duration) (bool, time.Time, error)
+}
+
+// memoryStore keeps the buckets in the process, it is meant for a single instance and for tests
+type memoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]time.Time
+	nextSweep time.Time
+}
+
+// sweepInterval is how often the memory store forgets the full buckets
+const sweepInterval = time.Minute
+
+// NewMemoryStore func to create a Store that lives in memory
+func NewMemoryStore() Store {
+	return &memoryStore{
+		buckets: map[string]time.Time{},
+	}
+}
+
+func (m *memoryStore) Take(ctx context.Context, key string, now time.Time, interval time.Duration, period time.Duration) (bool, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// a full bucket is the same as no bucket
+	if now.After(m.nextSweep) {
+		for key, fullAt := range m.buckets {
+			if !fullAt.After(now) {
+				delete(m.buckets, key)
+			}
+		}
+		m.nextSweep = now.Add(sweepInterval)
+	}
+
+	fullAt := m.buckets[key]
+	if fullAt.Before(now) {
+		fullAt = now
+	}
+
+	next := fullAt.Add(interval)
+	if next.After(now.Add(period)) {
+		return false, fullAt, nil
+	}
+
+	m.buckets[key] = next
+	return true, next, nil
+}
+
+// postgresStore keeps the buckets in the rate_limit_bucket table through the repository
+type postgresStore struct {
+	repository repository.RepositoryInterface
+}
+
+// NewPostgresStore func to create a Store backed by postgres, shared by every instance
+func NewPostgresStore(repo repository.RepositoryInterface) Store {
+	return &postgresStore{
+		repository: repo,
+	}
+}
+
+func (p *postgresStore) Take(ctx context.Context, key string, now time.Time, interval time.Duration, period time.Duration) (bool, time.Time, error) {
+	output, err := p.repository.TakeRateLimitToken(ctx, repository.TakeRateLimitTokenInput{
+		Key:      key,
+		Now:      now,
+		Interval: interval,
+		Period:   period,
+	})
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	return output.Allowed, output.FullAt, nil
+}
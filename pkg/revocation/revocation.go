@@ -0,0 +1,154 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// RevocationConfig is list dependencies of Revocation Package
+type RevocationConfig struct {
+	repository repository.RepositoryInterface
+	cacheTTL   time.Duration
+
+	mu        sync.RWMutex
+	entries   map[string]cacheEntry
+	users     map[int]time.Time
+	nextSweep time.Time
+}
+
+// cacheEntry is the last known revocation state of a token
+type cacheEntry struct {
+	revoked     bool
+	cachedUntil time.Time
+}
+
+// RevocationMethod is list method for Revocation Package
+type RevocationMethod interface {
+	RevokeToken(ctx context.Context, body token.TokenBody) error
+	RevokeAllTokens(ctx context.Context, userID int) error
+	IsRevoked(ctx context.Context, body token.TokenBody) (bool, error)
+}
+
+type NewRevocationConfig struct {
+	Repository repository.RepositoryInterface
+	// CacheTTL is how long a "not revoked" answer is trusted before asking postgres again,
+	// it bounds how late a revocation done by another instance is noticed.
+	CacheTTL time.Duration
+}
+
+// NewRevocationMethod func to create RevocationMethod interface
+func NewRevocationMethod(cfg NewRevocationConfig) RevocationMethod {
+	return &RevocationConfig{
+		repository: cfg.Repository,
+		cacheTTL:   cfg.CacheTTL,
+		entries:    map[string]cacheEntry{},
+		users:      map[int]time.Time{},
+	}
+}
+
+// RevokeToken func to revoke a single access token until it expires
+func (r *RevocationConfig) RevokeToken(ctx context.Context, body token.TokenBody) error {
+	err := r.repository.RevokeToken(ctx, repository.RevokeTokenInput{
+		UserID:    body.UserID,
+		TokenID:   body.TokenID,
+		ExpiresAt: body.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.entries[body.TokenID] = cacheEntry{
+		revoked:     true,
+		cachedUntil: body.ExpiresAt,
+	}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// RevokeAllTokens func to revoke every access token issued to the user until now
+func (r *RevocationConfig) RevokeAllTokens(ctx context.Context, userID int) error {
+	// iat has microsecond precision like the postgres timestamp, tokens issued at this instant are
+	// revoked too and the ones issued right after, like the tokens of a new login, are not
+	revokedBefore := time.Now().UTC().Truncate(time.Microsecond)
+	err := r.repository.RevokeUserTokens(ctx, repository.RevokeUserTokensInput{
+		UserID:        userID,
+		RevokedBefore: revokedBefore,
+	})
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.users[userID] = revokedBefore
+	r.mu.Unlock()
+
+	return nil
+}
+
+// IsRevoked func to check whether the token was revoked, directly or by revoking all tokens of the user
+func (r *RevocationConfig) IsRevoked(ctx context.Context, body token.TokenBody) (bool, error) {
+	now := time.Now().UTC()
+
+	r.mu.RLock()
+	revokedBefore, hasUser := r.users[body.UserID]
+	entry, hasEntry := r.entries[body.TokenID]
+	r.mu.RUnlock()
+
+	if hasUser && revokedBy(body, revokedBefore) {
+		return true, nil
+	}
+
+	if hasEntry && now.Before(entry.cachedUntil) {
+		return entry.revoked, nil
+	}
+
+	output, err := r.repository.GetTokenRevocation(ctx, repository.GetTokenRevocationInput{
+		UserID:  body.UserID,
+		TokenID: body.TokenID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	revoked := output.IsTokenRevoked || revokedBy(body, output.RevokedBefore)
+	entry = cacheEntry{
+		revoked:     revoked,
+		cachedUntil: now.Add(r.cacheTTL),
+	}
+	// a revoked token stays revoked, keep it until it expires anyway
+	if revoked {
+		entry.cachedUntil = body.ExpiresAt
+	}
+
+	r.mu.Lock()
+	if now.After(r.nextSweep) {
+		r.evictExpired(now)
+		r.nextSweep = now.Add(r.cacheTTL)
+	}
+	r.entries[body.TokenID] = entry
+	r.mu.Unlock()
+
+	return revoked, nil
+}
+
+// revokedBy tells whether revoking every token of the user at revokedBefore revoked the token,
+// a token issued at the same instant is included. Tokens with a whole second iat, issued before
+// iat had a fraction, are revoked for the whole second.
+func revokedBy(body token.TokenBody, revokedBefore time.Time) bool {
+	return !revokedBefore.IsZero() && !body.IssuedAt.After(revokedBefore)
+}
+
+// evictExpired removes cache entries that are no longer useful, caller must hold the lock
+func (r *RevocationConfig) evictExpired(now time.Time) {
+	for tokenID, entry := range r.entries {
+		if !now.Before(entry.cachedUntil) {
+			delete(r.entries, tokenID)
+		}
+	}
+}
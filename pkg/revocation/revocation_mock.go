@@ -0,0 +1,84 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/revocation/revocation.go
+//
+// Generated by this command:
+//
+//	mockgen -source=pkg/revocation/revocation.go -destination=pkg/revocation/revocation_mock.go -package=revocation
+//
+
+// Package revocation is a generated GoMock package.
+package revocation
+
+import (
+	context "context"
+	reflect "reflect"
+
+	token "github.com/SawitProRecruitment/UserService/pkg/token"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockRevocationMethod is a mock of RevocationMethod interface.
+type MockRevocationMethod struct {
+	ctrl     *gomock.Controller
+	recorder *MockRevocationMethodMockRecorder
+}
+
+// MockRevocationMethodMockRecorder is the mock recorder for MockRevocationMethod.
+type MockRevocationMethodMockRecorder struct {
+	mock *MockRevocationMethod
+}
+
+// NewMockRevocationMethod creates a new mock instance.
+func NewMockRevocationMethod(ctrl *gomock.Controller) *MockRevocationMethod {
+	mock := &MockRevocationMethod{ctrl: ctrl}
+	mock.recorder = &MockRevocationMethodMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRevocationMethod) EXPECT() *MockRevocationMethodMockRecorder {
+	return m.recorder
+}
+
+// IsRevoked mocks base method.
+func (m *MockRevocationMethod) IsRevoked(ctx context.Context, body token.TokenBody) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsRevoked", ctx, body)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsRevoked indicates an expected call of IsRevoked.
+func (mr *MockRevocationMethodMockRecorder) IsRevoked(ctx, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsRevoked", reflect.TypeOf((*MockRevocationMethod)(nil).IsRevoked), ctx, body)
+}
+
+// RevokeAllTokens mocks base method.
+func (m *MockRevocationMethod) RevokeAllTokens(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeAllTokens", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeAllTokens indicates an expected call of RevokeAllTokens.
+func (mr *MockRevocationMethodMockRecorder) RevokeAllTokens(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeAllTokens", reflect.TypeOf((*MockRevocationMethod)(nil).RevokeAllTokens), ctx, userID)
+}
+
+// RevokeToken mocks base method.
+func (m *MockRevocationMethod) RevokeToken(ctx context.Context, body token.TokenBody) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", ctx, body)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockRevocationMethodMockRecorder) RevokeToken(ctx, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockRevocationMethod)(nil).RevokeToken), ctx, body)
+}
@@ -0,0 +1,182 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/pkg/token"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRevocationConfig_IsRevoked(t *testing.T) {
+	now := time.Now().UTC()
+	body := token.TokenBody{
+		UserID:    1,
+		TokenID:   "jti",
+		IssuedAt:  now.Add(-time.Minute),
+		ExpiresAt: now.Add(time.Minute),
+	}
+	tests := []struct {
+		name     string
+		mockFunc func(mockRepo *repository.MockRepositoryInterface)
+		prepare  func(r RevocationMethod)
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name: "not revoked",
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().GetTokenRevocation(gomock.Any(), repository.GetTokenRevocationInput{
+					UserID:  1,
+					TokenID: "jti",
+				}).Return(repository.GetTokenRevocationOutput{}, nil).Times(1)
+			},
+			prepare: func(r RevocationMethod) {
+				// second call must be served from cache
+				r.IsRevoked(context.Background(), body)
+			},
+			want: false,
+		},
+		{
+			name: "revoked token id",
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().GetTokenRevocation(gomock.Any(), gomock.Any()).Return(repository.GetTokenRevocationOutput{
+					IsTokenRevoked: true,
+				}, nil)
+			},
+			prepare: func(r RevocationMethod) {},
+			want:    true,
+		},
+		{
+			name: "revoked by revoke all",
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().GetTokenRevocation(gomock.Any(), gomock.Any()).Return(repository.GetTokenRevocationOutput{
+					RevokedBefore: now,
+				}, nil)
+			},
+			prepare: func(r RevocationMethod) {},
+			want:    true,
+		},
+		{
+			name: "revoked locally without asking repository",
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().RevokeToken(gomock.Any(), repository.RevokeTokenInput{
+					UserID:    1,
+					TokenID:   "jti",
+					ExpiresAt: body.ExpiresAt,
+				}).Return(nil)
+			},
+			prepare: func(r RevocationMethod) {
+				r.RevokeToken(context.Background(), body)
+			},
+			want: true,
+		},
+		{
+			name: "revoked locally by revoke all",
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			prepare: func(r RevocationMethod) {
+				r.RevokeAllTokens(context.Background(), 1)
+			},
+			want: true,
+		},
+		{
+			name: "error on repository",
+			mockFunc: func(mockRepo *repository.MockRepositoryInterface) {
+				mockRepo.EXPECT().GetTokenRevocation(gomock.Any(), gomock.Any()).Return(repository.GetTokenRevocationOutput{}, fmt.Errorf("some error"))
+			},
+			prepare: func(r RevocationMethod) {},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			mockRepo := repository.NewMockRepositoryInterface(ctrl)
+			tt.mockFunc(mockRepo)
+
+			r := NewRevocationMethod(NewRevocationConfig{
+				Repository: mockRepo,
+				CacheTTL:   time.Minute,
+			})
+			tt.prepare(r)
+
+			got, err := r.IsRevoked(context.Background(), body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RevocationConfig.IsRevoked() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("RevocationConfig.IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevocationConfig_RevokeAllTokens_SameSecond(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockRepo := repository.NewMockRepositoryInterface(ctrl)
+	var revokedBefore time.Time
+	mockRepo.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, input repository.RevokeUserTokensInput) error {
+			revokedBefore = input.RevokedBefore
+			return nil
+		})
+
+	r := NewRevocationMethod(NewRevocationConfig{
+		Repository: mockRepo,
+		CacheTTL:   time.Minute,
+	})
+	if err := r.RevokeAllTokens(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		want     bool
+	}{
+		{
+			name:     "issued at the revocation",
+			issuedAt: revokedBefore,
+			want:     true,
+		},
+		{
+			name:     "issued earlier in the same second",
+			issuedAt: revokedBefore.Add(-time.Microsecond),
+			want:     true,
+		},
+		{
+			name:     "issued later in the same second",
+			issuedAt: revokedBefore.Add(time.Microsecond),
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := token.TokenBody{
+				UserID:    1,
+				TokenID:   "jti",
+				IssuedAt:  tt.issuedAt,
+				ExpiresAt: tt.issuedAt.Add(time.Minute),
+			}
+			if !tt.want {
+				mockRepo.EXPECT().GetTokenRevocation(gomock.Any(), gomock.Any()).Return(repository.GetTokenRevocationOutput{
+					RevokedBefore: revokedBefore,
+				}, nil)
+			}
+
+			got, err := r.IsRevoked(context.Background(), body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("RevocationConfig.IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
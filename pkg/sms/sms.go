@@ -0,0 +1,58 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sender is list method to deliver text messages, a real provider implements it in production
+type Sender interface {
+	Send(ctx context.Context, phoneNumber string, message string) error
+}
+
+// StubConfig is list dependencies of the stub Sender
+type StubConfig struct {
+	mu  sync.Mutex
+	out io.Writer
+	// path is the outbox file, messages go to out when it is empty
+	path string
+}
+
+type NewStubConfig struct {
+	// Path is the file the messages are appended to, they are printed to stdout when it is empty
+	Path string
+}
+
+// NewStubSender func to create a Sender that writes the messages to a file or stdout instead of
+// sending them, it is meant for local use only since codes end up in plain text
+func NewStubSender(cfg NewStubConfig) Sender {
+	return &StubConfig{
+		out:  os.Stdout,
+		path: cfg.Path,
+	}
+}
+
+// Send func to write the message with its recipient
+func (s *StubConfig) Send(ctx context.Context, phoneNumber string, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	line := fmt.Sprintf("%s SMS to %s: %s\n", time.Now().UTC().Format(time.RFC3339), phoneNumber, message)
+	if s.path == "" {
+		_, err := io.WriteString(s.out, line)
+		return err
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(line)
+	return err
+}
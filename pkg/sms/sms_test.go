@@ -0,0 +1,59 @@
+package sms
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStubConfig_Send(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	tests := []struct {
+		name     string
+		path     string
+		wantFile bool
+	}{
+		{
+			name: "success to stdout",
+		},
+		{
+			name:     "success to file",
+			path:     path,
+			wantFile: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			s := &StubConfig{
+				out:  &out,
+				path: tt.path,
+			}
+
+			err := s.Send(context.Background(), "+628123456789", "your code is 123456")
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			err = s.Send(context.Background(), "+628123456789", "your code is 654321")
+			if err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+
+			got := out.String()
+			if tt.wantFile {
+				raw, err := os.ReadFile(tt.path)
+				if err != nil {
+					t.Fatalf("ReadFile() error = %v", err)
+				}
+				got = string(raw)
+			}
+
+			if !strings.Contains(got, "SMS to +628123456789: your code is 123456\n") || !strings.Contains(got, "your code is 654321") {
+				t.Errorf("Send() wrote %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// AlgorithmRS256 is RSA PKCS#1 v1.5 with SHA-256, it is the default algorithm
+	AlgorithmRS256 = "RS256"
+	// AlgorithmES256 is ECDSA with the P-256 curve and SHA-256
+	AlgorithmES256 = "ES256"
+	// AlgorithmEdDSA is EdDSA with Ed25519 keys (RFC 8037)
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// SigningMethodEdDSA is the EdDSA signing method, jwt-go only ships RSA, ECDSA and HMAC
+var SigningMethodEdDSA = &signingMethodEdDSA{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+type signingMethodEdDSA struct{}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return AlgorithmEdDSA
+}
+
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+// signingMethod returns the jwt signing method of the configured algorithm
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case "", AlgorithmRS256:
+		return jwt.SigningMethodRS256, nil
+	case AlgorithmES256:
+		return jwt.SigningMethodES256, nil
+	case AlgorithmEdDSA:
+		return SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %s", algorithm)
+}
+
+// parsePrivateKey parses a PEM private key that matches the signing method
+func parsePrivateKey(method jwt.SigningMethod, content []byte) (crypto.Signer, error) {
+	switch method.Alg() {
+	case AlgorithmRS256:
+		return jwt.ParseRSAPrivateKeyFromPEM(content)
+	case AlgorithmES256:
+		key, err := jwt.ParseECPrivateKeyFromPEM(content)
+		if err != nil {
+			return nil, err
+		}
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 requires a P-256 key")
+		}
+		return key, nil
+	case AlgorithmEdDSA:
+		block, _ := pem.Decode(content)
+		if block == nil {
+			return nil, jwt.ErrKeyMustBePEMEncoded
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("EdDSA requires an Ed25519 key")
+		}
+		return edKey, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %s", method.Alg())
+}
+
+// parsePublicKey parses a PEM public key that matches the signing method
+func parsePublicKey(method jwt.SigningMethod, content []byte) (crypto.PublicKey, error) {
+	switch method.Alg() {
+	case AlgorithmRS256:
+		return jwt.ParseRSAPublicKeyFromPEM(content)
+	case AlgorithmES256:
+		key, err := jwt.ParseECPublicKeyFromPEM(content)
+		if err != nil {
+			return nil, err
+		}
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 requires a P-256 key")
+		}
+		return key, nil
+	case AlgorithmEdDSA:
+		block, _ := pem.Decode(content)
+		if block == nil {
+			return nil, jwt.ErrKeyMustBePEMEncoded
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("EdDSA requires an Ed25519 key")
+		}
+		return edKey, nil
+	}
+	return nil, fmt.Errorf("unsupported signing algorithm %s", method.Alg())
+}
+
+// publicJWK returns the RFC 7517 representation of the public key without kid, use and alg
+func publicJWK(key crypto.PublicKey) JSONWebKey {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return JSONWebKey{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return JSONWebKey{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}
+	case ed25519.PublicKey:
+		return JSONWebKey{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}
+	}
+	return JSONWebKey{}
+}
+
+// keyThumbprint returns the RFC 7638 thumbprint of the public key
+func keyThumbprint(key crypto.PublicKey) string {
+	jwk := publicJWK(key)
+
+	// only the required members, in lexicographic order and without whitespace as required by the RFC
+	var members interface{}
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+
+	thumbprint, _ := json.Marshal(members)
+	sum := sha256.Sum256(thumbprint)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,222 @@
+package token
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// privateKeySuffix is the file suffix of a key pair that can sign and verify, the file name is the kid
+	privateKeySuffix = ".pem"
+	// publicKeySuffix is the file suffix of a retired key that can only verify, the file name is the kid
+	publicKeySuffix = ".pub.pem"
+	// activeKeyFile is the file holding the kid used for signing new tokens
+	activeKeyFile = "active"
+)
+
+// JSONWebKey is the public part of a verification key as described in RFC 7517
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	// N and E are set for RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv and X are set for EC and OKP keys, Y only for EC keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is the list of verification keys published to other services
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// keySet is one loaded version of the keys
+type keySet struct {
+	method     jwt.SigningMethod
+	signKeyID  string
+	signKey    crypto.Signer
+	verifyKeys map[string]crypto.PublicKey
+}
+
+// keyring holds the current keySet and can swap it while tokens are being signed and verified
+type keyring struct {
+	mu   sync.RWMutex
+	keys keySet
+	load func() (keySet, error)
+}
+
+func newKeyring(load func() (keySet, error)) (*keyring, error) {
+	keys, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyring{
+		keys: keys,
+		load: load,
+	}, nil
+}
+
+// reload loads the keys again, the current keys are kept when loading fails
+func (k *keyring) reload() error {
+	keys, err := k.load()
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+	return nil
+}
+
+func (k *keyring) signer() (string, crypto.Signer) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys.signKeyID, k.keys.signKey
+}
+
+// verifier returns the key for kid, tokens without kid are verified with the active key
+func (k *keyring) verifier(kid string) (crypto.PublicKey, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	if kid == "" {
+		kid = k.keys.signKeyID
+	}
+	key, ok := k.keys.verifyKeys[kid]
+	return key, ok
+}
+
+func (k *keyring) jwks() JSONWebKeySet {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	kids := make([]string, 0, len(k.keys.verifyKeys))
+	for kid := range k.keys.verifyKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JSONWebKeySet{
+		Keys: make([]JSONWebKey, 0, len(kids)),
+	}
+	for _, kid := range kids {
+		key := publicJWK(k.keys.verifyKeys[kid])
+		key.Use = "sig"
+		key.Kid = kid
+		key.Alg = k.keys.method.Alg()
+		set.Keys = append(set.Keys, key)
+	}
+	return set
+}
+
+// loadKeyFiles loads a single key pair, the kid is the RFC 7638 thumbprint of the public key
+func loadKeyFiles(method jwt.SigningMethod, privateKeyLocation, publicKeyLocation string) (keySet, error) {
+	privateKey, err := os.ReadFile(privateKeyLocation)
+	if err != nil {
+		return keySet{}, fmt.Errorf("failed read private key file %s authenticator, err: %s", privateKeyLocation, err)
+	}
+
+	publicKey, err := os.ReadFile(publicKeyLocation)
+	if err != nil {
+		return keySet{}, fmt.Errorf("failed read public key file %s authenticator, err: %s", publicKeyLocation, err)
+	}
+
+	signKey, err := parsePrivateKey(method, []byte(strings.Trim(strings.TrimSpace(string(privateKey)), "\n")))
+	if err != nil {
+		return keySet{}, fmt.Errorf("failed parse private key, err: %s", err)
+	}
+
+	verifyKey, err := parsePublicKey(method, publicKey)
+	if err != nil {
+		return keySet{}, fmt.Errorf("failed parse public key, err: %s", err)
+	}
+
+	kid := keyThumbprint(verifyKey)
+	return keySet{
+		method:    method,
+		signKeyID: kid,
+		signKey:   signKey,
+		verifyKeys: map[string]crypto.PublicKey{
+			kid: verifyKey,
+		},
+	}, nil
+}
+
+// loadKeyDirectory loads every key in the directory
+// <kid>.pem is a private key that can sign, its public part is used to verify
+// <kid>.pub.pem is a public key of a retired private key that is only used to verify
+// active contains the kid used for signing, it can be omitted when there is only one private key
+// every key must belong to the configured signing method
+func loadKeyDirectory(method jwt.SigningMethod, directory string) (keySet, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return keySet{}, fmt.Errorf("failed read key directory %s, err: %s", directory, err)
+	}
+
+	keys := keySet{
+		method:     method,
+		verifyKeys: map[string]crypto.PublicKey{},
+	}
+	signKeys := map[string]crypto.Signer{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(directory, name))
+		if err != nil {
+			return keySet{}, fmt.Errorf("failed read key file %s, err: %s", name, err)
+		}
+
+		switch {
+		case strings.HasSuffix(name, publicKeySuffix):
+			kid := strings.TrimSuffix(name, publicKeySuffix)
+			verifyKey, err := parsePublicKey(method, content)
+			if err != nil {
+				return keySet{}, fmt.Errorf("failed parse public key %s, err: %s", name, err)
+			}
+			keys.verifyKeys[kid] = verifyKey
+		case strings.HasSuffix(name, privateKeySuffix):
+			kid := strings.TrimSuffix(name, privateKeySuffix)
+			signKey, err := parsePrivateKey(method, content)
+			if err != nil {
+				return keySet{}, fmt.Errorf("failed parse private key %s, err: %s", name, err)
+			}
+			signKeys[kid] = signKey
+			keys.verifyKeys[kid] = signKey.Public()
+		}
+	}
+
+	active, err := os.ReadFile(filepath.Join(directory, activeKeyFile))
+	switch {
+	case err == nil:
+		keys.signKeyID = strings.TrimSpace(string(active))
+	case os.IsNotExist(err) && len(signKeys) == 1:
+		for kid := range signKeys {
+			keys.signKeyID = kid
+		}
+	default:
+		return keySet{}, fmt.Errorf("failed read active key id in %s, err: %s", directory, err)
+	}
+
+	signKey, ok := signKeys[keys.signKeyID]
+	if !ok {
+		return keySet{}, fmt.Errorf("active key %s has no private key in %s", keys.signKeyID, directory)
+	}
+	keys.signKey = signKey
+
+	return keys, nil
+}
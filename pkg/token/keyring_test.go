@@ -0,0 +1,160 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func writeRSAKey(t *testing.T, directory, name string, public bool) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if public {
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	}
+
+	if err := os.WriteFile(filepath.Join(directory, name), pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestLoadKeyDirectory(t *testing.T) {
+	tests := []struct {
+		name          string
+		prepare       func(t *testing.T, directory string)
+		wantSignKeyID string
+		wantKeyIDs    []string
+		wantErr       bool
+	}{
+		{
+			name: "single private key is active",
+			prepare: func(t *testing.T, directory string) {
+				writeRSAKey(t, directory, "2024-01.pem", false)
+			},
+			wantSignKeyID: "2024-01",
+			wantKeyIDs:    []string{"2024-01"},
+		},
+		{
+			name: "active file selects signing key",
+			prepare: func(t *testing.T, directory string) {
+				writeRSAKey(t, directory, "2024-01.pem", false)
+				writeRSAKey(t, directory, "2024-02.pem", false)
+				writeRSAKey(t, directory, "2023-12.pub.pem", true)
+				os.WriteFile(filepath.Join(directory, "active"), []byte("2024-02\n"), 0600)
+			},
+			wantSignKeyID: "2024-02",
+			wantKeyIDs:    []string{"2023-12", "2024-01", "2024-02"},
+		},
+		{
+			name: "multiple private keys without active file",
+			prepare: func(t *testing.T, directory string) {
+				writeRSAKey(t, directory, "2024-01.pem", false)
+				writeRSAKey(t, directory, "2024-02.pem", false)
+			},
+			wantErr: true,
+		},
+		{
+			name: "active key is public only",
+			prepare: func(t *testing.T, directory string) {
+				writeRSAKey(t, directory, "2024-01.pem", false)
+				writeRSAKey(t, directory, "2023-12.pub.pem", true)
+				os.WriteFile(filepath.Join(directory, "active"), []byte("2023-12"), 0600)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := t.TempDir()
+			tt.prepare(t, directory)
+
+			got, err := loadKeyDirectory(jwt.SigningMethodRS256, directory)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadKeyDirectory() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.signKeyID != tt.wantSignKeyID {
+				t.Errorf("loadKeyDirectory() signKeyID = %v, want %v", got.signKeyID, tt.wantSignKeyID)
+			}
+
+			keyring := &keyring{keys: got}
+			jwks := keyring.jwks()
+			if len(jwks.Keys) != len(tt.wantKeyIDs) {
+				t.Fatalf("keyring.jwks() = %v keys, want %v", len(jwks.Keys), len(tt.wantKeyIDs))
+			}
+			for i, key := range jwks.Keys {
+				if key.Kid != tt.wantKeyIDs[i] {
+					t.Errorf("keyring.jwks() kid = %v, want %v", key.Kid, tt.wantKeyIDs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTokenConfig_ReloadKeys(t *testing.T) {
+	directory := t.TempDir()
+	writeRSAKey(t, directory, "old.pem", false)
+
+	method, err := NewTokenMethod(NewTokenConfig{
+		KeyDirectory: directory,
+		ExpinMinute:  15,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldToken, err := method.GenerateToken(TokenBody{UserID: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rotate: keep the old key for verification and sign with the new one
+	writeRSAKey(t, directory, "new.pem", false)
+	os.WriteFile(filepath.Join(directory, "active"), []byte("new"), 0600)
+	if err := method.ReloadKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	newToken, err := method.GenerateToken(TokenBody{UserID: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for token, userID := range map[string]int{oldToken: 1, newToken: 2} {
+		body, err := method.ValidateToken(token[len("Bearer "):])
+		if err != nil {
+			t.Fatalf("ValidateToken() error = %v", err)
+		}
+		if body.UserID != userID {
+			t.Errorf("ValidateToken() UserID = %v, want %v", body.UserID, userID)
+		}
+	}
+
+	// a broken directory must not drop the loaded keys
+	os.WriteFile(filepath.Join(directory, "active"), []byte("missing"), 0600)
+	if err := method.ReloadKeys(); err == nil {
+		t.Errorf("ReloadKeys() expected error for missing active key")
+	}
+	if _, err := method.ValidateToken(newToken[len("Bearer "):]); err != nil {
+		t.Errorf("ValidateToken() after failed reload error = %v", err)
+	}
+}
@@ -1,9 +1,13 @@
 package token
 
 import (
-	"crypto/rsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"os"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,41 +16,77 @@ import (
 
 // TokenConfig is list dependencies of token package
 type TokenConfig struct {
-	signKey       *rsa.PrivateKey
-	verifyKey     *rsa.PublicKey
-	expTimeInHour int64
+	method               jwt.SigningMethod
+	keys                 *keyring
+	expTimeInMinute      int64
+	refreshExpTimeInHour int64
+	issuer               string
+	audience             string
+	leeway               time.Duration
 }
 
 // TokenMethod is method for Token Package
 type TokenMethod interface {
 	GenerateToken(TokenBody) (string, error)
 	ValidateToken(string) (TokenBody, error)
+	GenerateRefreshToken(familyID string) (RefreshToken, error)
+	HashRefreshToken(string) string
+	JWKS() JSONWebKeySet
+	ReloadKeys() error
 }
 
 // TokenBody is list parameter that will be stored as token
 type TokenBody struct {
-	UserID int
+	UserID  int
+	TokenID string
+	Roles   []string
+	// Scopes limits the token to these permissions, a token without scopes is limited by the user permissions only
+	Scopes    []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// RefreshToken is an opaque refresh token and the values that will be stored for it
+type RefreshToken struct {
+	Token     string
+	Hash      string
+	FamilyID  string
+	ExpiresAt time.Time
 }
 
 type NewTokenConfig struct {
 	PrivateKeyLocation string
 	PublicKeyLocation  string
-	ExpinHour          int64
+	// KeyDirectory takes precedence over PrivateKeyLocation and PublicKeyLocation, see loadKeyDirectory
+	KeyDirectory string
+	// Algorithm is one of AlgorithmRS256, AlgorithmES256 or AlgorithmEdDSA, default is AlgorithmRS256
+	Algorithm        string
+	ExpinMinute      int64
+	RefreshExpinHour int64
+	// Issuer and Audience are put in iss and aud, and enforced on validation when they are not empty
+	Issuer   string
+	Audience string
+	// LeewayInSecond is the allowed clock skew when checking exp, nbf and iat
+	LeewayInSecond int64
 }
 
 // NewTokenMethod is func to generate TokenMethod interface
 func NewTokenMethod(cfg NewTokenConfig) (TokenMethod, error) {
-	privateKey, err := os.ReadFile(cfg.PrivateKeyLocation)
+	method, err := signingMethod(cfg.Algorithm)
 	if err != nil {
-		return nil, fmt.Errorf("failed read private key file %s authenticator, err: %s", cfg.PrivateKeyLocation, err)
+		return nil, err
 	}
 
-	publicKey, err := os.ReadFile(cfg.PublicKeyLocation)
-	if err != nil {
-		return nil, fmt.Errorf("failed read public key file %s authenticator, err: %s", cfg.PublicKeyLocation, err)
+	load := func() (keySet, error) {
+		return loadKeyFiles(method, cfg.PrivateKeyLocation, cfg.PublicKeyLocation)
+	}
+	if cfg.KeyDirectory != "" {
+		load = func() (keySet, error) {
+			return loadKeyDirectory(method, cfg.KeyDirectory)
+		}
 	}
 
-	return buildAuthenticator(string(privateKey), string(publicKey), cfg.ExpinHour)
+	return buildAuthenticator(method, load, cfg)
 }
 
 // the two keys PrivateKey & PublicKey from generate rsa from openssl
@@ -54,35 +94,58 @@ func NewTokenMethod(cfg NewTokenConfig) (TokenMethod, error) {
 // $ openssl rsa -in demo.rsa -pubout > demo.rsa.pub
 // PrivateKey private key generate from "openssl genrsa -out app.rsa keysize"
 // PublicKey  public key generate from "openssl rsa -in app.rsa -pubout > app.rsa.pub"
-func buildAuthenticator(privateKey, publicKey string, expInHour int64) (TokenMethod, error) {
-	signKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(strings.Trim(strings.TrimSpace(privateKey), "\n")))
+func buildAuthenticator(method jwt.SigningMethod, load func() (keySet, error), cfg NewTokenConfig) (TokenMethod, error) {
+	keys, err := newKeyring(load)
 	if err != nil {
-		return nil, fmt.Errorf("failed parse private key, err: %s", err)
-	}
-
-	verifyKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed parse public key, err: %s", err)
+		return nil, err
 	}
 
 	return TokenConfig{
-		signKey:       signKey,
-		verifyKey:     verifyKey,
-		expTimeInHour: expInHour,
+		method:               method,
+		keys:                 keys,
+		expTimeInMinute:      cfg.ExpinMinute,
+		refreshExpTimeInHour: cfg.RefreshExpinHour,
+		issuer:               cfg.Issuer,
+		audience:             cfg.Audience,
+		leeway:               time.Duration(cfg.LeewayInSecond) * time.Second,
 	}, nil
 }
 
 // GenerateToken is func to generate token from body
 func (t TokenConfig) GenerateToken(body TokenBody) (string, error) {
+	tokenID, err := randomString(16)
+	if err != nil {
+		return "", fmt.Errorf("error while generating token id, err: %s", err)
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{
 		"id":  body.UserID,
-		"exp": time.Now().Add(time.Hour * time.Duration(t.expTimeInHour)).Unix(),
+		"sub": strconv.Itoa(body.UserID),
+		"jti": tokenID,
+		"iat": numericDate(now),
+		"nbf": now.Unix(),
+		"exp": now.Add(time.Minute * time.Duration(t.expTimeInMinute)).Unix(),
+	}
+	if len(body.Roles) > 0 {
+		claims["roles"] = body.Roles
+	}
+	if len(body.Scopes) > 0 {
+		claims["scope"] = strings.Join(body.Scopes, " ")
+	}
+	if t.issuer != "" {
+		claims["iss"] = t.issuer
+	}
+	if t.audience != "" {
+		claims["aud"] = t.audience
 	}
 
-	jwtClaim := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	tokenString, err := jwtClaim.SignedString(t.signKey)
+	kid, signKey := t.keys.signer()
+	jwtClaim := jwt.NewWithClaims(t.method, claims)
+	jwtClaim.Header["kid"] = kid
+	tokenString, err := jwtClaim.SignedString(signKey)
 	if err != nil {
-		err = fmt.Errorf("error while signing token!, err: %s", err)
+		return "", fmt.Errorf("error while signing token!, err: %s", err)
 	}
 
 	return "Bearer " + tokenString, nil
@@ -96,11 +159,23 @@ func (t TokenConfig) ValidateToken(tokenString string) (TokenBody, error) {
 	}
 
 	// validate the tokenCookie
-	token, err := jwt.ParseWithClaims(tokenString, &jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if token.Method == jwt.SigningMethodES256 && token.Valid {
+	// only the configured algorithm is accepted, this rejects "none" and keys being
+	// reused for another algorithm such as HS256 signed with the public key
+	// registered claims are checked by validateClaims to support leeway
+	parser := &jwt.Parser{
+		ValidMethods:         []string{t.method.Alg()},
+		SkipClaimsValidation: true,
+	}
+	token, err := parser.ParseWithClaims(tokenString, &jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != t.method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v, token not valid", token.Header["alg"])
 		}
-		return t.verifyKey, nil
+		kid, _ := token.Header["kid"].(string)
+		verifyKey, ok := t.keys.verifier(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key id: %s", kid)
+		}
+		return verifyKey, nil
 	})
 
 	if err != nil {
@@ -112,12 +187,175 @@ func (t TokenConfig) ValidateToken(tokenString string) (TokenBody, error) {
 		return TokenBody{}, fmt.Errorf("Invalid Token")
 	}
 
-	userID, ok := (*claims)["id"].(float64)
+	err = t.validateClaims(*claims, time.Now())
+	if err != nil {
+		return TokenBody{}, fmt.Errorf("Invalid Token")
+	}
+
+	userID, ok := subject(*claims)
 	if !ok {
 		return TokenBody{}, fmt.Errorf("Invalid Token")
 	}
 
+	tokenID, _ := (*claims)["jti"].(string)
+	roles, ok := stringList((*claims)["roles"])
+	if !ok {
+		return TokenBody{}, fmt.Errorf("Invalid Token")
+	}
+	scope, _ := (*claims)["scope"].(string)
+	issuedAt, _ := (*claims)["iat"].(float64)
+	expiresAt, _ := (*claims)["exp"].(float64)
+
 	return TokenBody{
-		UserID: int(userID),
+		UserID:    userID,
+		TokenID:   tokenID,
+		Roles:     roles,
+		Scopes:    scopes(scope),
+		IssuedAt:  fromNumericDate(issuedAt),
+		ExpiresAt: time.Unix(int64(expiresAt), 0).UTC(),
 	}, nil
 }
+
+// numericDate is the time in seconds with microseconds as the fraction, iat carries it so revoking
+// every token of a user doesn't revoke the tokens issued later in the same second
+func numericDate(t time.Time) float64 {
+	return float64(t.UnixMicro()) / 1e6
+}
+
+// fromNumericDate reads a NumericDate with or without a fraction, back to microseconds
+func fromNumericDate(value float64) time.Time {
+	seconds, fraction := math.Modf(value)
+	return time.Unix(int64(seconds), int64(fraction*1e9)).Round(time.Microsecond).UTC()
+}
+
+// validateClaims is func to check exp, nbf, iat, iss and aud of the token
+func (t TokenConfig) validateClaims(claims jwt.MapClaims, now time.Time) error {
+	expiresAt, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("token has no expiration")
+	}
+	if now.After(time.Unix(int64(expiresAt), 0).Add(t.leeway)) {
+		return fmt.Errorf("token is expired")
+	}
+
+	if notBefore, ok := claims["nbf"].(float64); ok && now.Add(t.leeway).Before(time.Unix(int64(notBefore), 0)) {
+		return fmt.Errorf("token is not valid yet")
+	}
+
+	if issuedAt, ok := claims["iat"].(float64); ok && now.Add(t.leeway).Before(time.Unix(int64(issuedAt), 0)) {
+		return fmt.Errorf("token is issued in the future")
+	}
+
+	if t.issuer != "" && claims["iss"] != t.issuer {
+		return fmt.Errorf("unexpected issuer: %v", claims["iss"])
+	}
+
+	if t.audience != "" && !hasAudience(claims["aud"], t.audience) {
+		return fmt.Errorf("unexpected audience: %v", claims["aud"])
+	}
+
+	return nil
+}
+
+// hasAudience is func to check aud, which is either a single string or a list of strings
+func hasAudience(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, value := range aud {
+			if value == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// subject is func to get the user id from sub, tokens issued before sub existed only have id
+func subject(claims jwt.MapClaims) (int, bool) {
+	if sub, ok := claims["sub"].(string); ok {
+		userID, err := strconv.Atoi(sub)
+		return userID, err == nil
+	}
+
+	userID, ok := claims["id"].(float64)
+	return int(userID), ok
+}
+
+// stringList is func to read a claim holding a list of strings, a missing claim is an empty list
+func stringList(claim interface{}) ([]string, bool) {
+	if claim == nil {
+		return nil, true
+	}
+
+	values, ok := claim.([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		list = append(list, str)
+	}
+	return list, true
+}
+
+// scopes is func to split the space separated scope claim
+func scopes(scope string) []string {
+	fields := strings.Fields(scope)
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// JWKS is func to list the public keys that can verify tokens
+func (t TokenConfig) JWKS() JSONWebKeySet {
+	return t.keys.jwks()
+}
+
+// ReloadKeys is func to load the keys again, e.g. after a new key is added to the key directory
+func (t TokenConfig) ReloadKeys() error {
+	return t.keys.reload()
+}
+
+// GenerateRefreshToken is func to generate opaque refresh token, a new family is started when familyID is empty
+func (t TokenConfig) GenerateRefreshToken(familyID string) (RefreshToken, error) {
+	value, err := randomString(32)
+	if err != nil {
+		return RefreshToken{}, fmt.Errorf("error while generating refresh token, err: %s", err)
+	}
+
+	if familyID == "" {
+		familyID, err = randomString(16)
+		if err != nil {
+			return RefreshToken{}, fmt.Errorf("error while generating refresh token family, err: %s", err)
+		}
+	}
+
+	return RefreshToken{
+		Token:     value,
+		Hash:      t.HashRefreshToken(value),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().UTC().Add(time.Hour * time.Duration(t.refreshExpTimeInHour)),
+	}, nil
+}
+
+// HashRefreshToken is func to hash refresh token before it is stored or looked up
+func (t TokenConfig) HashRefreshToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -38,6 +38,21 @@ func (m *MockTokenMethod) EXPECT() *MockTokenMethodMockRecorder {
 	return m.recorder
 }
 
+// GenerateRefreshToken mocks base method.
+func (m *MockTokenMethod) GenerateRefreshToken(familyID string) (RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GenerateRefreshToken", familyID)
+	ret0, _ := ret[0].(RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GenerateRefreshToken indicates an expected call of GenerateRefreshToken.
+func (mr *MockTokenMethodMockRecorder) GenerateRefreshToken(familyID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateRefreshToken", reflect.TypeOf((*MockTokenMethod)(nil).GenerateRefreshToken), familyID)
+}
+
 // GenerateToken mocks base method.
 func (m *MockTokenMethod) GenerateToken(arg0 TokenBody) (string, error) {
 	m.ctrl.T.Helper()
@@ -53,6 +68,48 @@ func (mr *MockTokenMethodMockRecorder) GenerateToken(arg0 any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateToken", reflect.TypeOf((*MockTokenMethod)(nil).GenerateToken), arg0)
 }
 
+// HashRefreshToken mocks base method.
+func (m *MockTokenMethod) HashRefreshToken(arg0 string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HashRefreshToken", arg0)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// HashRefreshToken indicates an expected call of HashRefreshToken.
+func (mr *MockTokenMethodMockRecorder) HashRefreshToken(arg0 any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashRefreshToken", reflect.TypeOf((*MockTokenMethod)(nil).HashRefreshToken), arg0)
+}
+
+// JWKS mocks base method.
+func (m *MockTokenMethod) JWKS() JSONWebKeySet {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS")
+	ret0, _ := ret[0].(JSONWebKeySet)
+	return ret0
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockTokenMethodMockRecorder) JWKS() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockTokenMethod)(nil).JWKS))
+}
+
+// ReloadKeys mocks base method.
+func (m *MockTokenMethod) ReloadKeys() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReloadKeys")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReloadKeys indicates an expected call of ReloadKeys.
+func (mr *MockTokenMethodMockRecorder) ReloadKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReloadKeys", reflect.TypeOf((*MockTokenMethod)(nil).ReloadKeys))
+}
+
 // ValidateToken mocks base method.
 func (m *MockTokenMethod) ValidateToken(arg0 string) (TokenBody, error) {
 	m.ctrl.T.Helper()
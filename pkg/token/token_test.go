@@ -0,0 +1,484 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// writeKeyPair generates a key pair for the algorithm and returns the private key and the public key file content
+func writeKeyPair(t *testing.T, algorithm string) (crypto.Signer, []byte, NewTokenConfig) {
+	t.Helper()
+
+	var (
+		signer     crypto.Signer
+		privateDER []byte
+		blockType  string
+		err        error
+	)
+	switch algorithm {
+	case AlgorithmRS256:
+		key, _ := rsa.GenerateKey(rand.Reader, 2048)
+		signer, privateDER, blockType = key, x509.MarshalPKCS1PrivateKey(key), "RSA PRIVATE KEY"
+	case AlgorithmES256:
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		privateDER, err = x509.MarshalECPrivateKey(key)
+		signer, blockType = key, "EC PRIVATE KEY"
+	case AlgorithmEdDSA:
+		_, key, _ := ed25519.GenerateKey(rand.Reader)
+		privateDER, err = x509.MarshalPKCS8PrivateKey(key)
+		signer, blockType = key, "PRIVATE KEY"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	directory := t.TempDir()
+	cfg := NewTokenConfig{
+		PrivateKeyLocation: filepath.Join(directory, "private.pem"),
+		PublicKeyLocation:  filepath.Join(directory, "public.pem"),
+		Algorithm:          algorithm,
+		ExpinMinute:        15,
+	}
+	os.WriteFile(cfg.PrivateKeyLocation, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: privateDER}), 0600)
+	os.WriteFile(cfg.PublicKeyLocation, publicPEM, 0600)
+
+	return signer, publicPEM, cfg
+}
+
+func TestTokenConfig_Algorithms(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		wantKty   string
+	}{
+		{algorithm: AlgorithmRS256, wantKty: "RSA"},
+		{algorithm: AlgorithmES256, wantKty: "EC"},
+		{algorithm: AlgorithmEdDSA, wantKty: "OKP"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			_, _, cfg := writeKeyPair(t, tt.algorithm)
+			method, err := NewTokenMethod(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			signed, err := method.GenerateToken(TokenBody{UserID: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, _, err := new(jwt.Parser).ParseUnverified(strings.TrimPrefix(signed, "Bearer "), jwt.MapClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["alg"] != tt.algorithm {
+				t.Errorf("GenerateToken() alg = %v, want %v", parsed.Header["alg"], tt.algorithm)
+			}
+
+			body, err := method.ValidateToken(strings.TrimPrefix(signed, "Bearer "))
+			if err != nil {
+				t.Fatalf("ValidateToken() error = %v", err)
+			}
+			if body.UserID != 1 {
+				t.Errorf("ValidateToken() UserID = %v, want 1", body.UserID)
+			}
+
+			jwks := method.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != tt.wantKty || jwks.Keys[0].Alg != tt.algorithm {
+				t.Errorf("JWKS() = %+v, want kty %v alg %v", jwks.Keys, tt.wantKty, tt.algorithm)
+			}
+			if jwks.Keys[0].Kid != parsed.Header["kid"] {
+				t.Errorf("JWKS() kid = %v, want %v", jwks.Keys[0].Kid, parsed.Header["kid"])
+			}
+		})
+	}
+}
+
+func TestNewTokenMethod_KeyDoesNotMatchAlgorithm(t *testing.T) {
+	_, _, cfg := writeKeyPair(t, AlgorithmRS256)
+	cfg.Algorithm = AlgorithmES256
+	if _, err := NewTokenMethod(cfg); err == nil {
+		t.Errorf("NewTokenMethod() expected error for RSA key with ES256")
+	}
+
+	cfg.Algorithm = "HS256"
+	if _, err := NewTokenMethod(cfg); err == nil {
+		t.Errorf("NewTokenMethod() expected error for unsupported algorithm")
+	}
+}
+
+func TestTokenConfig_ValidateToken_AlgorithmConfusion(t *testing.T) {
+	signer, publicPEM, cfg := writeKeyPair(t, AlgorithmRS256)
+	method, err := NewTokenMethod(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := method.JWKS().Keys[0].Kid
+	otherSigner, _, _ := writeKeyPair(t, AlgorithmRS256)
+	ecSigner, _, _ := writeKeyPair(t, AlgorithmES256)
+	edSigner, _, _ := writeKeyPair(t, AlgorithmEdDSA)
+
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"id":  1,
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Minute).Unix(),
+		}
+	}
+	sign := func(t *testing.T, signingMethod jwt.SigningMethod, kid string, key interface{}) string {
+		t.Helper()
+		token := jwt.NewWithClaims(signingMethod, claims())
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name    string
+		token   func(t *testing.T) string
+		wantErr bool
+	}{
+		{
+			name: "valid RS256 token",
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, kid, signer)
+			},
+		},
+		{
+			name: "valid RS256 token without kid uses the active key",
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, "", signer)
+			},
+		},
+		{
+			name: "alg none",
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodNone, kid, jwt.UnsafeAllowNoneSignatureType)
+			},
+			wantErr: true,
+		},
+		{
+			name: "HS256 signed with the public key",
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodHS256, kid, publicPEM)
+			},
+			wantErr: true,
+		},
+		{
+			name: "ES256 signed with another key",
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodES256, kid, ecSigner)
+			},
+			wantErr: true,
+		},
+		{
+			name: "EdDSA signed with another key",
+			token: func(t *testing.T) string {
+				return sign(t, SigningMethodEdDSA, kid, edSigner)
+			},
+			wantErr: true,
+		},
+		{
+			name: "RS256 signed with another key",
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, kid, otherSigner)
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown kid",
+			token: func(t *testing.T) string {
+				return sign(t, jwt.SigningMethodRS256, "unknown", signer)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := method.ValidateToken(tt.token(t))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenConfig_ValidateToken_RegisteredClaims(t *testing.T) {
+	signer, _, cfg := writeKeyPair(t, AlgorithmRS256)
+	cfg.Issuer = "https://users.production"
+	cfg.Audience = "production"
+	cfg.LeewayInSecond = 30
+	method, err := NewTokenMethod(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid := method.JWKS().Keys[0].Kid
+	now := time.Now()
+
+	sign := func(t *testing.T, claims jwt.MapClaims) string {
+		t.Helper()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	validClaims := func(overrides jwt.MapClaims) jwt.MapClaims {
+		claims := jwt.MapClaims{
+			"sub": "1",
+			"iss": "https://users.production",
+			"aud": "production",
+			"iat": now.Unix(),
+			"nbf": now.Unix(),
+			"exp": now.Add(time.Minute).Unix(),
+		}
+		for key, value := range overrides {
+			if value == nil {
+				delete(claims, key)
+				continue
+			}
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			claims: validClaims(nil),
+		},
+		{
+			name:   "audience in a list",
+			claims: validClaims(jwt.MapClaims{"aud": []string{"other", "production"}}),
+		},
+		{
+			name:   "expired within leeway",
+			claims: validClaims(jwt.MapClaims{"exp": now.Add(-10 * time.Second).Unix()}),
+		},
+		{
+			name:   "not before within leeway",
+			claims: validClaims(jwt.MapClaims{"nbf": now.Add(10 * time.Second).Unix()}),
+		},
+		{
+			name:    "expired beyond leeway",
+			claims:  validClaims(jwt.MapClaims{"exp": now.Add(-time.Minute).Unix()}),
+			wantErr: true,
+		},
+		{
+			name:    "not before beyond leeway",
+			claims:  validClaims(jwt.MapClaims{"nbf": now.Add(time.Minute).Unix()}),
+			wantErr: true,
+		},
+		{
+			name:    "issued in the future",
+			claims:  validClaims(jwt.MapClaims{"iat": now.Add(time.Minute).Unix()}),
+			wantErr: true,
+		},
+		{
+			name:    "missing expiration",
+			claims:  validClaims(jwt.MapClaims{"exp": nil}),
+			wantErr: true,
+		},
+		{
+			name:    "staging issuer",
+			claims:  validClaims(jwt.MapClaims{"iss": "https://users.staging"}),
+			wantErr: true,
+		},
+		{
+			name:    "staging audience",
+			claims:  validClaims(jwt.MapClaims{"aud": "staging"}),
+			wantErr: true,
+		},
+		{
+			name:    "missing audience",
+			claims:  validClaims(jwt.MapClaims{"aud": nil}),
+			wantErr: true,
+		},
+		{
+			name:    "invalid subject",
+			claims:  validClaims(jwt.MapClaims{"sub": "abc"}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := method.ValidateToken(sign(t, tt.claims))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && body.UserID != 1 {
+				t.Errorf("ValidateToken() UserID = %v, want 1", body.UserID)
+			}
+		})
+	}
+}
+
+func TestTokenConfig_GenerateToken_RegisteredClaims(t *testing.T) {
+	_, _, cfg := writeKeyPair(t, AlgorithmRS256)
+	cfg.Issuer = "https://users.production"
+	cfg.Audience = "production"
+	method, err := NewTokenMethod(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := method.GenerateToken(TokenBody{UserID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(strings.TrimPrefix(signed, "Bearer "), claims); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sub", "iss", "aud", "iat", "nbf", "exp", "jti"} {
+		if _, ok := claims[name]; !ok {
+			t.Errorf("GenerateToken() missing claim %s", name)
+		}
+	}
+	if claims["sub"] != "7" || claims["iss"] != "https://users.production" || claims["aud"] != "production" {
+		t.Errorf("GenerateToken() claims = %v", claims)
+	}
+}
+
+func TestTokenConfig_GenerateToken_SigningError(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// NewTokenMethod refuses such keys, the config is built directly to reach the signing error
+	keys, err := newKeyring(func() (keySet, error) {
+		return keySet{
+			method:     jwt.SigningMethodRS256,
+			signKeyID:  "ec",
+			signKey:    key,
+			verifyKeys: map[string]crypto.PublicKey{"ec": key.Public()},
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	method := TokenConfig{
+		method:          jwt.SigningMethodRS256,
+		keys:            keys,
+		expTimeInMinute: 15,
+	}
+
+	signed, err := method.GenerateToken(TokenBody{UserID: 7})
+	if err == nil {
+		t.Errorf("GenerateToken() expected error for ECDSA key with RS256")
+	}
+	if signed != "" {
+		t.Errorf("GenerateToken() = %q, want empty token on error", signed)
+	}
+}
+
+func TestTokenConfig_IssuedAtPrecision(t *testing.T) {
+	_, _, cfg := writeKeyPair(t, AlgorithmRS256)
+	method, err := NewTokenMethod(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().UTC().Truncate(time.Microsecond)
+	signed, err := method.GenerateToken(TokenBody{UserID: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now().UTC()
+
+	body, err := method.ValidateToken(strings.TrimPrefix(signed, "Bearer "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.IssuedAt.Before(before) || body.IssuedAt.After(after) {
+		t.Errorf("ValidateToken() IssuedAt = %v, want between %v and %v", body.IssuedAt, before, after)
+	}
+	if !body.IssuedAt.Equal(body.IssuedAt.Truncate(time.Microsecond)) {
+		t.Errorf("ValidateToken() IssuedAt = %v, want microsecond precision", body.IssuedAt)
+	}
+}
+
+func TestTokenConfig_ValidateToken_RolesAndScopes(t *testing.T) {
+	_, _, cfg := writeKeyPair(t, AlgorithmRS256)
+	method, err := NewTokenMethod(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		roles      []string
+		scopes     []string
+		wantRoles  []string
+		wantScopes []string
+	}{
+		{
+			name:      "with roles",
+			roles:     []string{"admin", "user"},
+			wantRoles: []string{"admin", "user"},
+		},
+		{
+			name:       "with scopes",
+			roles:      []string{"user"},
+			scopes:     []string{"profile:read", "profile:write"},
+			wantRoles:  []string{"user"},
+			wantScopes: []string{"profile:read", "profile:write"},
+		},
+		{
+			name:      "without roles",
+			roles:     nil,
+			wantRoles: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := method.GenerateToken(TokenBody{UserID: 7, Roles: tt.roles, Scopes: tt.scopes})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			body, err := method.ValidateToken(strings.TrimPrefix(signed, "Bearer "))
+			if err != nil {
+				t.Fatalf("ValidateToken() error = %v", err)
+			}
+			if !reflect.DeepEqual(body.Roles, tt.wantRoles) {
+				t.Errorf("ValidateToken() roles = %v, want %v", body.Roles, tt.wantRoles)
+			}
+			if !reflect.DeepEqual(body.Scopes, tt.wantScopes) {
+				t.Errorf("ValidateToken() scopes = %v, want %v", body.Scopes, tt.wantScopes)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// secretSize is the size of the shared secret in bytes, RFC 4226 recommends 160 bits
+const secretSize = 20
+
+// encoding is the base32 form authenticator apps expect the secret in
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPConfig is list dependencies of TOTP Package
+type TOTPConfig struct {
+	issuer string
+	period int64
+	digits int
+	skew   int64
+}
+
+// TOTPMethod is list method for TOTP Package
+type TOTPMethod interface {
+	GenerateSecret() (string, error)
+	URI(secret string, account string) string
+	Validate(secret string, code string, now time.Time) (int64, bool)
+}
+
+type NewTOTPConfig struct {
+	// Issuer is the name authenticator apps show next to the account
+	Issuer string
+	// Period is how long a code is valid, 30 seconds when zero
+	Period time.Duration
+	// Digits is the length of the codes, 6 when zero
+	Digits int
+	// Skew is how many periods before and after the current one are accepted to absorb clock drift
+	Skew int
+}
+
+// NewTOTPMethod func to create TOTPMethod interface
+func NewTOTPMethod(cfg NewTOTPConfig) TOTPMethod {
+	period := int64(cfg.Period / time.Second)
+	if period <= 0 {
+		period = 30
+	}
+
+	digits := cfg.Digits
+	if digits <= 0 {
+		digits = 6
+	}
+
+	return &TOTPConfig{
+		issuer: cfg.Issuer,
+		period: period,
+		digits: digits,
+		skew:   int64(cfg.Skew),
+	}
+}
+
+// GenerateSecret func to create a random shared secret encoded in base32
+func (t *TOTPConfig) GenerateSecret() (string, error) {
+	buf := make([]byte, secretSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(buf), nil
+}
+
+// URI func to build the otpauth URI authenticator apps enroll the secret from, usually as a QR code
+func (t *TOTPConfig) URI(secret string, account string) string {
+	label := url.PathEscape(account)
+	if t.issuer != "" {
+		label = url.PathEscape(t.issuer) + ":" + label
+	}
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	if t.issuer != "" {
+		params.Set("issuer", t.issuer)
+	}
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(t.digits))
+	params.Set("period", fmt.Sprint(t.period))
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Validate func to check the code against the secret at the given time, it returns the time step
+// the code belongs to so the caller can refuse a step that was already used
+func (t *TOTPConfig) Validate(secret string, code string, now time.Time) (int64, bool) {
+	if len(code) != t.digits {
+		return 0, false
+	}
+
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return 0, false
+	}
+
+	current := now.Unix() / t.period
+	for step := current - t.skew; step <= current+t.skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(hotp(key, step, t.digits)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// hotp computes the RFC 4226 code of the counter
+func hotp(key []byte, counter int64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
@@ -0,0 +1,84 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: pkg/totp/totp.go
+//
+// Generated by this command:
+//
+//	mockgen -source=pkg/totp/totp.go -destination=pkg/totp/totp_mock.go -package=totp
+//
+
+// Package totp is a generated GoMock package.
+package totp
+
+import (
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockTOTPMethod is a mock of TOTPMethod interface.
+type MockTOTPMethod struct {
+	ctrl     *gomock.Controller
+	recorder *MockTOTPMethodMockRecorder
+}
+
+// MockTOTPMethodMockRecorder is the mock recorder for MockTOTPMethod.
+type MockTOTPMethodMockRecorder struct {
+	mock *MockTOTPMethod
+}
+
+// NewMockTOTPMethod creates a new mock instance.
+func NewMockTOTPMethod(ctrl *gomock.Controller) *MockTOTPMethod {
+	mock := &MockTOTPMethod{ctrl: ctrl}
+	mock.recorder = &MockTOTPMethodMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTOTPMethod) EXPECT() *MockTOTPMethodMockRecorder {
+	return m.recorder
+}
+
+// GenerateSecret mocks base method.
+func (m *MockTOTPMethod) GenerateSecret() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GenerateSecret")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GenerateSecret indicates an expected call of GenerateSecret.
+func (mr *MockTOTPMethodMockRecorder) GenerateSecret() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateSecret", reflect.TypeOf((*MockTOTPMethod)(nil).GenerateSecret))
+}
+
+// URI mocks base method.
+func (m *MockTOTPMethod) URI(secret, account string) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "URI", secret, account)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// URI indicates an expected call of URI.
+func (mr *MockTOTPMethodMockRecorder) URI(secret, account any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "URI", reflect.TypeOf((*MockTOTPMethod)(nil).URI), secret, account)
+}
+
+// Validate mocks base method.
+func (m *MockTOTPMethod) Validate(secret, code string, now time.Time) (int64, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate", secret, code, now)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Validate indicates an expected call of Validate.
+func (mr *MockTOTPMethodMockRecorder) Validate(secret, code, now any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockTOTPMethod)(nil).Validate), secret, code, now)
+}
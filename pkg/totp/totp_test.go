@@ -0,0 +1,143 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the base32 form of the RFC 6238 test key "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPConfig_Validate(t *testing.T) {
+	totp := NewTOTPMethod(NewTOTPConfig{
+		Digits: 8,
+		Skew:   1,
+	})
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		now      time.Time
+		wantStep int64
+		wantOk   bool
+	}{
+		{
+			name:     "rfc 6238 vector 59",
+			secret:   rfcSecret,
+			code:     "94287082",
+			now:      time.Unix(59, 0),
+			wantStep: 1,
+			wantOk:   true,
+		},
+		{
+			name:     "rfc 6238 vector 1111111109",
+			secret:   rfcSecret,
+			code:     "07081804",
+			now:      time.Unix(1111111109, 0),
+			wantStep: 37037036,
+			wantOk:   true,
+		},
+		{
+			name:     "rfc 6238 vector 1234567890",
+			secret:   rfcSecret,
+			code:     "89005924",
+			now:      time.Unix(1234567890, 0),
+			wantStep: 41152263,
+			wantOk:   true,
+		},
+		{
+			name:     "rfc 6238 vector 20000000000",
+			secret:   rfcSecret,
+			code:     "65353130",
+			now:      time.Unix(20000000000, 0),
+			wantStep: 666666666,
+			wantOk:   true,
+		},
+		{
+			name:     "success previous step within skew",
+			secret:   rfcSecret,
+			code:     "94287082",
+			now:      time.Unix(89, 0),
+			wantStep: 1,
+			wantOk:   true,
+		},
+		{
+			name:     "success lowercase secret",
+			secret:   strings.ToLower(rfcSecret),
+			code:     "94287082",
+			now:      time.Unix(59, 0),
+			wantStep: 1,
+			wantOk:   true,
+		},
+		{
+			name:   "failed outside skew",
+			secret: rfcSecret,
+			code:   "94287082",
+			now:    time.Unix(120, 0),
+		},
+		{
+			name:   "failed wrong code",
+			secret: rfcSecret,
+			code:   "12345678",
+			now:    time.Unix(59, 0),
+		},
+		{
+			name:   "failed wrong length",
+			secret: rfcSecret,
+			code:   "287082",
+			now:    time.Unix(59, 0),
+		},
+		{
+			name:   "failed invalid secret",
+			secret: "not base32!",
+			code:   "94287082",
+			now:    time.Unix(59, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, gotOk := totp.Validate(tt.secret, tt.code, tt.now)
+			if gotOk != tt.wantOk || gotStep != tt.wantStep {
+				t.Errorf("Validate() = %d, %v, want %d, %v", gotStep, gotOk, tt.wantStep, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestTOTPConfig_GenerateSecret(t *testing.T) {
+	totp := NewTOTPMethod(NewTOTPConfig{})
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() error = %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("GenerateSecret() = %q, want 32 base32 characters", secret)
+	}
+
+	other, _ := totp.GenerateSecret()
+	if secret == other {
+		t.Errorf("GenerateSecret() returned the same secret twice")
+	}
+
+	// a code generated from the secret is accepted
+	now := time.Now()
+	key, _ := encoding.DecodeString(secret)
+	code := hotp(key, now.Unix()/30, 6)
+	if _, ok := totp.Validate(secret, code, now); !ok {
+		t.Errorf("Validate() refused the current code of a generated secret")
+	}
+}
+
+func TestTOTPConfig_URI(t *testing.T) {
+	totp := NewTOTPMethod(NewTOTPConfig{
+		Issuer: "User Service",
+	})
+
+	got := totp.URI(rfcSecret, "+628123456789")
+	want := "otpauth://totp/User%20Service:+628123456789?algorithm=SHA1&digits=6&issuer=User+Service&period=30&secret=" + rfcSecret
+	if got != want {
+		t.Errorf("URI() = %s, want %s", got, want)
+	}
+}
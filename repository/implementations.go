@@ -3,7 +3,12 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 func (r *Repository) RegisterUser(ctx context.Context, input RegisterUserInput) (output RegisterUserOutput, err error) {
@@ -31,6 +36,12 @@ func (r *Repository) RegisterUser(ctx context.Context, input RegisterUserInput)
 		return RegisterUserOutput{}, err
 	}
 
+	// Grant default role.
+	_, err = tx.Exec("INSERT INTO users_role(user_id, role_id, created_at) SELECT $1, id, $2 FROM roles WHERE name = $3", userID, createdTime, RoleUser)
+	if err != nil {
+		return RegisterUserOutput{}, err
+	}
+
 	// Commit Transaction if everything is ok.
 	err = tx.Commit()
 	if err != nil {
@@ -44,28 +55,129 @@ func (r *Repository) RegisterUser(ctx context.Context, input RegisterUserInput)
 
 func (r *Repository) LoginUser(ctx context.Context, input LoginUserInput) (output LoginUserOutput, err error) {
 	// query user.
-	row := r.Db.QueryRow("SELECT id, phone_number, password FROM users WHERE phone_number = $1", input.PhoneNumber)
+	row := r.Db.QueryRow(`SELECT id, phone_number, password, deletion_requested_at, phone_verified_at,
+		EXISTS(SELECT 1 FROM users_mfa WHERE users_mfa.user_id = users.id AND users_mfa.enabled_at IS NOT NULL)
+		FROM users WHERE phone_number = $1`, input.PhoneNumber)
 
 	// scan result.
-	err = row.Scan(&output.UserID, &output.PhoneNumber, &output.Password)
+	var deletionRequestedAt, phoneVerifiedAt sql.NullTime
+	err = row.Scan(&output.UserID, &output.PhoneNumber, &output.Password, &deletionRequestedAt, &phoneVerifiedAt, &output.MFAEnabled)
 	if err != nil {
 		return LoginUserOutput{}, err
 	}
+	if deletionRequestedAt.Valid {
+		output.DeletionRequestedAt = &deletionRequestedAt.Time
+	}
+	if phoneVerifiedAt.Valid {
+		output.PhoneVerifiedAt = &phoneVerifiedAt.Time
+	}
 
 	return output, nil
 }
 
 func (r *Repository) GetUser(ctx context.Context, input GetUserInput) (output GetUserOutput, err error) {
-	row := r.Db.QueryRow("SELECT id, phone_number, full_name FROM users WHERE id = $1", input.UserID)
+	row := r.Db.QueryRow("SELECT id, phone_number, full_name, created_at, phone_verified_at FROM users WHERE id = $1", input.UserID)
 
-	err = row.Scan(&output.UserID, &output.PhoneNumber, &output.FullName)
+	var createdAt, phoneVerifiedAt sql.NullTime
+	err = row.Scan(&output.UserID, &output.PhoneNumber, &output.FullName, &createdAt, &phoneVerifiedAt)
 	if err != nil {
 		return GetUserOutput{}, err
 	}
+	output.CreatedAt = createdAt.Time
+	if phoneVerifiedAt.Valid {
+		output.PhoneVerifiedAt = &phoneVerifiedAt.Time
+	}
+
+	return output, nil
+}
+
+func (r *Repository) ListUsers(ctx context.Context, input ListUsersInput) (output ListUsersOutput, err error) {
+	var column string
+	switch input.SortBy {
+	case UserSortID, UserSortCreatedAt, UserSortFullName:
+		column = input.SortBy
+	default:
+		return ListUsersOutput{}, fmt.Errorf("unknown sort column: %s", input.SortBy)
+	}
+
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if input.PhonePrefix != "" {
+		conditions = append(conditions, "phone_number LIKE "+arg(escapeLike(input.PhonePrefix)+"%"))
+	}
+	if input.Name != "" {
+		conditions = append(conditions, "full_name ILIKE "+arg("%"+escapeLike(input.Name)+"%"))
+	}
+	if input.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= "+arg(*input.CreatedFrom))
+	}
+	if input.CreatedTo != nil {
+		conditions = append(conditions, "created_at < "+arg(*input.CreatedTo))
+	}
+
+	direction, operator := "ASC", ">"
+	if input.Descending {
+		direction, operator = "DESC", "<"
+	}
+
+	// keyset pagination, continue right after the last user of the previous page
+	if input.After != nil {
+		switch column {
+		case UserSortID:
+			conditions = append(conditions, "id "+operator+" "+arg(input.After.UserID))
+		case UserSortCreatedAt:
+			conditions = append(conditions, "(created_at, id) "+operator+" ("+arg(input.After.CreatedAt)+", "+arg(input.After.UserID)+")")
+		case UserSortFullName:
+			conditions = append(conditions, "(full_name, id) "+operator+" ("+arg(input.After.FullName)+", "+arg(input.After.UserID)+")")
+		}
+	}
+
+	query := "SELECT id, phone_number, full_name, created_at FROM users"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY "
+	if column != UserSortID {
+		query += column + " " + direction + ", "
+	}
+	query += "id " + direction + " LIMIT " + arg(input.Limit)
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return ListUsersOutput{}, err
+	}
+	defer rows.Close()
+
+	output.Users = []GetUserOutput{}
+	for rows.Next() {
+		var user GetUserOutput
+		var createdAt sql.NullTime
+		err = rows.Scan(&user.UserID, &user.PhoneNumber, &user.FullName, &createdAt)
+		if err != nil {
+			return ListUsersOutput{}, err
+		}
+		user.CreatedAt = createdAt.Time
+		output.Users = append(output.Users, user)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return ListUsersOutput{}, err
+	}
 
 	return output, nil
 }
 
+// escapeLike escapes the LIKE wildcards so the value is matched literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 func (r *Repository) UpdateUser(ctx context.Context, input UpdateUserInput) (output UpdateUserOutput, err error) {
 	tx, err := r.Db.Begin()
 	if err != nil {
@@ -81,11 +193,15 @@ func (r *Repository) UpdateUser(ctx context.Context, input UpdateUserInput) (out
 
 	// get user.
 	var userInfo UpdateUserOutput
-	row := tx.QueryRow("SELECT id, phone_number, full_name, password FROM users WHERE id = $1", input.UserID)
-	err = row.Scan(&userInfo.UserID, &userInfo.PhoneNumber, &userInfo.FullName, &userInfo.Password)
+	var phoneVerifiedAt sql.NullTime
+	row := tx.QueryRow("SELECT id, phone_number, full_name, password, phone_verified_at FROM users WHERE id = $1", input.UserID)
+	err = row.Scan(&userInfo.UserID, &userInfo.PhoneNumber, &userInfo.FullName, &userInfo.Password, &phoneVerifiedAt)
 	if err != nil {
 		return UpdateUserOutput{}, err
 	}
+	if phoneVerifiedAt.Valid {
+		userInfo.PhoneVerifiedAt = &phoneVerifiedAt.Time
+	}
 
 	// Update password.
 	if input.Password != "" {
@@ -116,9 +232,10 @@ func (r *Repository) UpdateUser(ctx context.Context, input UpdateUserInput) (out
 	}
 
 	return UpdateUserOutput{
-		UserID:      userInfo.UserID,
-		PhoneNumber: userInfo.PhoneNumber,
-		FullName:    userInfo.FullName,
+		UserID:          userInfo.UserID,
+		PhoneNumber:     userInfo.PhoneNumber,
+		FullName:        userInfo.FullName,
+		PhoneVerifiedAt: userInfo.PhoneVerifiedAt,
 	}, nil
 }
 
@@ -141,3 +258,1087 @@ func (r *Repository) IncrementLoginCount(ctx context.Context, userID int) (err e
 
 	return err
 }
+
+func (r *Repository) RecordLoginAttempt(ctx context.Context, input RecordLoginAttemptInput) (err error) {
+	var userID *int
+	if input.UserID > 0 {
+		userID = &input.UserID
+	}
+	var failureReason *string
+	if input.FailureReason != "" {
+		failureReason = &input.FailureReason
+	}
+
+	_, err = r.Db.Exec("INSERT INTO users_login_attempt(user_id, phone_number, ip_address, user_agent, auth_method, success, failure_reason, created_at) VALUES($1, $2, $3, $4, $5, $6, $7, $8)",
+		userID, input.PhoneNumber, input.IPAddress, input.UserAgent, input.AuthMethod, input.Success, failureReason, time.Now().UTC())
+	return err
+}
+
+func (r *Repository) ListLoginAttempts(ctx context.Context, input ListLoginAttemptsInput) (output ListLoginAttemptsOutput, err error) {
+	query := "SELECT id, created_at, ip_address, user_agent, auth_method, success, failure_reason FROM users_login_attempt WHERE user_id = $1"
+	args := []interface{}{input.UserID}
+	if input.BeforeID > 0 {
+		args = append(args, input.BeforeID)
+		query += " AND id < $2"
+	}
+	args = append(args, input.Limit)
+	query += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := r.Db.Query(query, args...)
+	if err != nil {
+		return ListLoginAttemptsOutput{}, err
+	}
+	defer rows.Close()
+
+	output.Attempts = []LoginAttempt{}
+	for rows.Next() {
+		var attempt LoginAttempt
+		attempt, err = scanLoginAttempt(rows)
+		if err != nil {
+			return ListLoginAttemptsOutput{}, err
+		}
+		output.Attempts = append(output.Attempts, attempt)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return ListLoginAttemptsOutput{}, err
+	}
+
+	return output, nil
+}
+
+func scanLoginAttempt(rows *sql.Rows) (attempt LoginAttempt, err error) {
+	var createdAt sql.NullTime
+	var failureReason sql.NullString
+	err = rows.Scan(&attempt.ID, &createdAt, &attempt.IPAddress, &attempt.UserAgent, &attempt.AuthMethod, &attempt.Success, &failureReason)
+	if err != nil {
+		return LoginAttempt{}, err
+	}
+	attempt.CreatedAt = createdAt.Time
+	attempt.FailureReason = failureReason.String
+	return attempt, nil
+}
+
+func (r *Repository) CreateRefreshToken(ctx context.Context, input CreateRefreshTokenInput) (err error) {
+	createdTime := time.Now().UTC()
+	_, err = r.Db.Exec("INSERT INTO users_refresh_token(user_id, family_id, token_hash, expires_at, created_at) VALUES($1, $2, $3, $4, $5)",
+		input.UserID, input.FamilyID, input.TokenHash, input.ExpiresAt, createdTime)
+	return err
+}
+
+func (r *Repository) GetRefreshToken(ctx context.Context, input GetRefreshTokenInput) (output GetRefreshTokenOutput, err error) {
+	row := r.Db.QueryRow("SELECT id, user_id, family_id, expires_at, used_at IS NOT NULL, revoked_at IS NOT NULL FROM users_refresh_token WHERE token_hash = $1", input.TokenHash)
+
+	err = row.Scan(&output.ID, &output.UserID, &output.FamilyID, &output.ExpiresAt, &output.IsUsed, &output.IsRevoked)
+	if err != nil {
+		return GetRefreshTokenOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) RotateRefreshToken(ctx context.Context, input RotateRefreshTokenInput) (err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}()
+
+	updatedAt := time.Now().UTC()
+
+	// Mark old token as used, only one request can win the rotation.
+	result, err := tx.Exec("UPDATE users_refresh_token SET used_at = $1, updated_at = $1 WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL", updatedAt, input.OldTokenID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		err = ErrRefreshTokenAlreadyUsed
+		return err
+	}
+
+	// Insert the next token of the family.
+	_, err = tx.Exec("INSERT INTO users_refresh_token(user_id, family_id, token_hash, expires_at, created_at) VALUES($1, $2, $3, $4, $5)",
+		input.NewToken.UserID, input.NewToken.FamilyID, input.NewToken.TokenHash, input.NewToken.ExpiresAt, updatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *Repository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) (err error) {
+	updatedAt := time.Now().UTC()
+	_, err = r.Db.Exec("UPDATE users_refresh_token SET revoked_at = $1, updated_at = $1 WHERE family_id = $2 AND revoked_at IS NULL", updatedAt, familyID)
+	return err
+}
+
+func (r *Repository) RevokeUserRefreshTokens(ctx context.Context, userID int) (err error) {
+	updatedAt := time.Now().UTC()
+	_, err = r.Db.Exec("UPDATE users_refresh_token SET revoked_at = $1, updated_at = $1 WHERE user_id = $2 AND revoked_at IS NULL", updatedAt, userID)
+	return err
+}
+
+func (r *Repository) RevokeOtherRefreshTokens(ctx context.Context, input RevokeOtherRefreshTokensInput) (err error) {
+	updatedAt := time.Now().UTC()
+	_, err = r.Db.Exec("UPDATE users_refresh_token SET revoked_at = $1, updated_at = $1 WHERE user_id = $2 AND family_id <> $3 AND revoked_at IS NULL",
+		updatedAt, input.UserID, input.KeepFamilyID)
+	return err
+}
+
+func (r *Repository) RevokeToken(ctx context.Context, input RevokeTokenInput) (err error) {
+	createdTime := time.Now().UTC()
+	_, err = r.Db.Exec("INSERT INTO users_revoked_token(user_id, token_id, expires_at, created_at) VALUES($1, $2, $3, $4) ON CONFLICT (token_id) DO NOTHING",
+		input.UserID, input.TokenID, input.ExpiresAt, createdTime)
+	return err
+}
+
+func (r *Repository) RevokeUserTokens(ctx context.Context, input RevokeUserTokensInput) (err error) {
+	createdTime := time.Now().UTC()
+	_, err = r.Db.Exec("INSERT INTO users_token_revocation(user_id, revoked_before, created_at) VALUES($1, $2, $3) ON CONFLICT (user_id) DO UPDATE SET revoked_before = EXCLUDED.revoked_before, updated_at = EXCLUDED.created_at",
+		input.UserID, input.RevokedBefore, createdTime)
+	return err
+}
+
+func (r *Repository) GetTokenRevocation(ctx context.Context, input GetTokenRevocationInput) (output GetTokenRevocationOutput, err error) {
+	row := r.Db.QueryRow("SELECT EXISTS(SELECT 1 FROM users_revoked_token WHERE token_id = $1), (SELECT revoked_before FROM users_token_revocation WHERE user_id = $2)", input.TokenID, input.UserID)
+
+	// revoked_before is null when the user never revoked all tokens.
+	var revokedBefore sql.NullTime
+	err = row.Scan(&output.IsTokenRevoked, &revokedBefore)
+	if err != nil {
+		return GetTokenRevocationOutput{}, err
+	}
+	output.RevokedBefore = revokedBefore.Time
+
+	return output, nil
+}
+
+func (r *Repository) GetOAuthClient(ctx context.Context, input GetOAuthClientInput) (output GetOAuthClientOutput, err error) {
+	row := r.Db.QueryRow("SELECT client_id, client_secret, name FROM oauth_client WHERE client_id = $1", input.ClientID)
+
+	err = row.Scan(&output.ClientID, &output.ClientSecret, &output.Name)
+	if err != nil {
+		return GetOAuthClientOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) GetUserRoles(ctx context.Context, input GetUserRolesInput) (output GetUserRolesOutput, err error) {
+	rows, err := r.Db.Query("SELECT roles.name FROM users_role JOIN roles ON roles.id = users_role.role_id WHERE users_role.user_id = $1 ORDER BY roles.name", input.UserID)
+	if err != nil {
+		return GetUserRolesOutput{}, err
+	}
+	defer rows.Close()
+
+	output.Roles = []string{}
+	for rows.Next() {
+		var role string
+		err = rows.Scan(&role)
+		if err != nil {
+			return GetUserRolesOutput{}, err
+		}
+		output.Roles = append(output.Roles, role)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return GetUserRolesOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) GetUserPermissions(ctx context.Context, input GetUserPermissionsInput) (output GetUserPermissionsOutput, err error) {
+	rows, err := r.Db.Query("SELECT permissions.name FROM permissions WHERE permissions.id IN (SELECT roles_permission.permission_id FROM roles_permission JOIN users_role ON users_role.role_id = roles_permission.role_id WHERE users_role.user_id = $1 UNION SELECT users_permission.permission_id FROM users_permission WHERE users_permission.user_id = $1) ORDER BY permissions.name", input.UserID)
+	if err != nil {
+		return GetUserPermissionsOutput{}, err
+	}
+	defer rows.Close()
+
+	output.Permissions = []string{}
+	for rows.Next() {
+		var permission string
+		err = rows.Scan(&permission)
+		if err != nil {
+			return GetUserPermissionsOutput{}, err
+		}
+		output.Permissions = append(output.Permissions, permission)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return GetUserPermissionsOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) SuspendUser(ctx context.Context, input SuspendUserInput) (err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}()
+
+	createdTime := time.Now().UTC()
+
+	// A new suspension replaces the active one, e.g. to change the reason or the expiry.
+	_, err = tx.Exec("UPDATE users_suspension SET lifted_at = $1, lifted_by = $2, updated_at = $1 WHERE user_id = $3 AND lifted_at IS NULL", createdTime, input.SuspendedBy, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO users_suspension(user_id, reason, suspended_by, expires_at, created_at) VALUES($1, $2, $3, $4, $5)",
+		input.UserID, input.Reason, input.SuspendedBy, input.ExpiresAt, createdTime)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	return err
+}
+
+func (r *Repository) ReactivateUser(ctx context.Context, input ReactivateUserInput) (err error) {
+	updatedAt := time.Now().UTC()
+	result, err := r.Db.Exec("UPDATE users_suspension SET lifted_at = $1, lifted_by = $2, updated_at = $1 WHERE user_id = $3 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > $1)",
+		updatedAt, input.ReactivatedBy, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrUserNotSuspended
+	}
+
+	return nil
+}
+
+func (r *Repository) GetUserSuspension(ctx context.Context, input GetUserSuspensionInput) (output GetUserSuspensionOutput, err error) {
+	row := r.Db.QueryRow("SELECT reason, expires_at FROM users_suspension WHERE user_id = $1 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > $2) ORDER BY id DESC LIMIT 1",
+		input.UserID, time.Now().UTC())
+
+	var expiresAt sql.NullTime
+	err = row.Scan(&output.Reason, &expiresAt)
+	if err == sql.ErrNoRows {
+		return GetUserSuspensionOutput{}, nil
+	}
+	if err != nil {
+		return GetUserSuspensionOutput{}, err
+	}
+
+	output.IsSuspended = true
+	if expiresAt.Valid {
+		output.ExpiresAt = &expiresAt.Time
+	}
+
+	return output, nil
+}
+
+func (r *Repository) RequestUserDeletion(ctx context.Context, input RequestUserDeletionInput) (output RequestUserDeletionOutput, err error) {
+	requestedAt := time.Now().UTC()
+	row := r.Db.QueryRow("UPDATE users SET deletion_requested_at = COALESCE(deletion_requested_at, $1), deletion_requested_by = COALESCE(deletion_requested_by, $2), updated_at = $1 WHERE id = $3 AND deleted_at IS NULL RETURNING deletion_requested_at",
+		requestedAt, input.RequestedBy, input.UserID)
+
+	err = row.Scan(&output.RequestedAt)
+	if err != nil {
+		return RequestUserDeletionOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) CancelUserDeletion(ctx context.Context, userID int) (err error) {
+	updatedAt := time.Now().UTC()
+	_, err = r.Db.Exec("UPDATE users SET deletion_requested_at = NULL, deletion_requested_by = NULL, updated_at = $1 WHERE id = $2 AND deleted_at IS NULL", updatedAt, userID)
+	return err
+}
+
+func (r *Repository) PurgeDeletedUsers(ctx context.Context, input PurgeDeletedUsersInput) (output PurgeDeletedUsersOutput, err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return PurgeDeletedUsersOutput{}, err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}()
+
+	deletedAt := time.Now().UTC()
+
+	// Anonymize the users, the phone number is replaced by a unique value that is not a valid phone number.
+	// The previous phone number is returned to find the rows that are only keyed by it.
+	rows, err := tx.Query("UPDATE users SET phone_number = 'x' || users.id, full_name = 'Deleted User', password = '', deleted_at = $1, updated_at = $1 FROM users AS previous WHERE previous.id = users.id AND users.deletion_requested_at <= $2 AND users.deleted_at IS NULL RETURNING users.id, previous.phone_number",
+		deletedAt, input.RequestedBefore)
+	if err != nil {
+		return PurgeDeletedUsersOutput{}, err
+	}
+
+	output.UserIDs = []int{}
+	var phoneNumbers, lockoutKeys, rateLimitCallers []string
+	for rows.Next() {
+		var userID int
+		var phoneNumber string
+		err = rows.Scan(&userID, &phoneNumber)
+		if err != nil {
+			rows.Close()
+			return PurgeDeletedUsersOutput{}, err
+		}
+		output.UserIDs = append(output.UserIDs, userID)
+		phoneNumbers = append(phoneNumbers, phoneNumber)
+		// the account key of the lockout package
+		lockoutKeys = append(lockoutKeys, "account:"+phoneNumber)
+		// the caller part of the rate limit keys of the policies keyed by phone number
+		rateLimitCallers = append(rateLimitCallers, "phone_number:"+phoneNumber)
+	}
+	rows.Close()
+
+	err = rows.Err()
+	if err != nil {
+		return PurgeDeletedUsersOutput{}, err
+	}
+
+	if len(output.UserIDs) > 0 {
+		_, err = tx.Exec("DELETE FROM users_login_history WHERE user_id = ANY($1)", pq.Array(output.UserIDs))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		_, err = tx.Exec("DELETE FROM users_data_export WHERE user_id = ANY($1)", pq.Array(output.UserIDs))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		_, err = tx.Exec("DELETE FROM users_login_attempt WHERE user_id = ANY($1)", pq.Array(output.UserIDs))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		_, err = tx.Exec("DELETE FROM users_otp WHERE user_id = ANY($1)", pq.Array(output.UserIDs))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		_, err = tx.Exec("DELETE FROM users_password_history WHERE user_id = ANY($1)", pq.Array(output.UserIDs))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		_, err = tx.Exec("DELETE FROM users_mfa_recovery_code WHERE user_id = ANY($1)", pq.Array(output.UserIDs))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		_, err = tx.Exec("DELETE FROM users_mfa_challenge WHERE user_id = ANY($1)", pq.Array(output.UserIDs))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		_, err = tx.Exec("DELETE FROM users_mfa WHERE user_id = ANY($1)", pq.Array(output.UserIDs))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		_, err = tx.Exec("DELETE FROM users_refresh_token WHERE user_id = ANY($1)", pq.Array(output.UserIDs))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		// the suspensions are kept for the accounts that suspended them, only the free text reason is dropped
+		_, err = tx.Exec("UPDATE users_suspension SET reason = '', updated_at = $1 WHERE user_id = ANY($2)", deletedAt, pq.Array(output.UserIDs))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		// failed logins that didn't match an account, like an otp login of an unknown number, only have the phone number
+		_, err = tx.Exec("DELETE FROM users_login_attempt WHERE user_id IS NULL AND phone_number = ANY($1)", pq.Array(phoneNumbers))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		_, err = tx.Exec("DELETE FROM login_failure WHERE key = ANY($1)", pq.Array(lockoutKeys))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+
+		// rate limit keys are "<route>|<policy key>:<caller>"
+		_, err = tx.Exec("DELETE FROM rate_limit_bucket WHERE split_part(key, '|', 2) = ANY($1)", pq.Array(rateLimitCallers))
+		if err != nil {
+			return PurgeDeletedUsersOutput{}, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return PurgeDeletedUsersOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) CountUserRecords(ctx context.Context, userID int) (count int, err error) {
+	row := r.Db.QueryRow(`SELECT
+		(SELECT COUNT(*) FROM users_login_attempt WHERE user_id = $1) +
+		(SELECT COUNT(*) FROM users_refresh_token WHERE user_id = $1) +
+		(SELECT COUNT(*) FROM users_suspension WHERE user_id = $1)`, userID)
+
+	err = row.Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (r *Repository) GetUserRecords(ctx context.Context, input GetUserRecordsInput) (output GetUserRecordsOutput, err error) {
+	output.LoginAttempts = []LoginAttempt{}
+	rows, err := r.Db.Query("SELECT id, created_at, ip_address, user_agent, auth_method, success, failure_reason FROM users_login_attempt WHERE user_id = $1 ORDER BY id", input.UserID)
+	if err != nil {
+		return GetUserRecordsOutput{}, err
+	}
+	for rows.Next() {
+		var attempt LoginAttempt
+		attempt, err = scanLoginAttempt(rows)
+		if err != nil {
+			rows.Close()
+			return GetUserRecordsOutput{}, err
+		}
+		output.LoginAttempts = append(output.LoginAttempts, attempt)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return GetUserRecordsOutput{}, err
+	}
+
+	// the token hash is left out, it is a credential and not personal data
+	output.Sessions = []SessionRecord{}
+	rows, err = r.Db.Query("SELECT family_id, created_at, expires_at, used_at, revoked_at FROM users_refresh_token WHERE user_id = $1 ORDER BY id", input.UserID)
+	if err != nil {
+		return GetUserRecordsOutput{}, err
+	}
+	for rows.Next() {
+		var record SessionRecord
+		var createdAt, usedAt, revokedAt sql.NullTime
+		err = rows.Scan(&record.FamilyID, &createdAt, &record.ExpiresAt, &usedAt, &revokedAt)
+		if err != nil {
+			rows.Close()
+			return GetUserRecordsOutput{}, err
+		}
+		record.CreatedAt = createdAt.Time
+		if usedAt.Valid {
+			record.UsedAt = &usedAt.Time
+		}
+		if revokedAt.Valid {
+			record.RevokedAt = &revokedAt.Time
+		}
+		output.Sessions = append(output.Sessions, record)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return GetUserRecordsOutput{}, err
+	}
+
+	output.Suspensions = []SuspensionRecord{}
+	rows, err = r.Db.Query("SELECT reason, suspended_by, created_at, expires_at, lifted_at, lifted_by FROM users_suspension WHERE user_id = $1 ORDER BY id", input.UserID)
+	if err != nil {
+		return GetUserRecordsOutput{}, err
+	}
+	for rows.Next() {
+		var record SuspensionRecord
+		var createdAt, expiresAt, liftedAt sql.NullTime
+		var liftedBy sql.NullInt64
+		err = rows.Scan(&record.Reason, &record.SuspendedBy, &createdAt, &expiresAt, &liftedAt, &liftedBy)
+		if err != nil {
+			rows.Close()
+			return GetUserRecordsOutput{}, err
+		}
+		record.CreatedAt = createdAt.Time
+		if expiresAt.Valid {
+			record.ExpiresAt = &expiresAt.Time
+		}
+		if liftedAt.Valid {
+			record.LiftedAt = &liftedAt.Time
+		}
+		if liftedBy.Valid {
+			value := int(liftedBy.Int64)
+			record.LiftedBy = &value
+		}
+		output.Suspensions = append(output.Suspensions, record)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return GetUserRecordsOutput{}, err
+	}
+
+	// the secret and the recovery codes are left out, they are credentials and not personal data
+	var mfa MFARecord
+	var enrolledAt, enabledAt sql.NullTime
+	err = r.Db.QueryRow("SELECT created_at, enabled_at FROM users_mfa WHERE user_id = $1", input.UserID).Scan(&enrolledAt, &enabledAt)
+	if err != nil && err != sql.ErrNoRows {
+		return GetUserRecordsOutput{}, err
+	}
+	if err == nil {
+		mfa.EnrolledAt = enrolledAt.Time
+		if enabledAt.Valid {
+			mfa.EnabledAt = &enabledAt.Time
+		}
+		output.MFA = &mfa
+	}
+
+	// a history row is written when the password it holds is replaced, the hash is left out
+	output.PasswordChanges = []time.Time{}
+	rows, err = r.Db.Query("SELECT created_at FROM users_password_history WHERE user_id = $1 ORDER BY id", input.UserID)
+	if err != nil {
+		return GetUserRecordsOutput{}, err
+	}
+	for rows.Next() {
+		var changedAt sql.NullTime
+		err = rows.Scan(&changedAt)
+		if err != nil {
+			rows.Close()
+			return GetUserRecordsOutput{}, err
+		}
+		output.PasswordChanges = append(output.PasswordChanges, changedAt.Time)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		return GetUserRecordsOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) CreateDataExport(ctx context.Context, input CreateDataExportInput) (output CreateDataExportOutput, err error) {
+	row := r.Db.QueryRow("INSERT INTO users_data_export(job_id, user_id, format, status, created_at) VALUES($1, $2, $3, $4, $5) RETURNING created_at",
+		input.JobID, input.UserID, input.Format, DataExportStatusPending, time.Now().UTC())
+
+	err = row.Scan(&output.CreatedAt)
+	if err != nil {
+		return CreateDataExportOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) GetDataExport(ctx context.Context, input GetDataExportInput) (output GetDataExportOutput, err error) {
+	// expired archives are treated as if they never existed
+	row := r.Db.QueryRow("SELECT job_id, format, status, archive, error_message, created_at, completed_at, expires_at FROM users_data_export WHERE job_id = $1 AND user_id = $2 AND (expires_at IS NULL OR expires_at > $3)",
+		input.JobID, input.UserID, time.Now().UTC())
+
+	var errorMessage sql.NullString
+	var createdAt, completedAt, expiresAt sql.NullTime
+	err = row.Scan(&output.JobID, &output.Format, &output.Status, &output.Archive, &errorMessage, &createdAt, &completedAt, &expiresAt)
+	if err != nil {
+		return GetDataExportOutput{}, err
+	}
+	output.ErrorMessage = errorMessage.String
+	output.CreatedAt = createdAt.Time
+	if completedAt.Valid {
+		output.CompletedAt = &completedAt.Time
+	}
+	if expiresAt.Valid {
+		output.ExpiresAt = &expiresAt.Time
+	}
+
+	return output, nil
+}
+
+func (r *Repository) CompleteDataExport(ctx context.Context, input CompleteDataExportInput) (err error) {
+	status := DataExportStatusCompleted
+	var errorMessage *string
+	if input.ErrorMessage != "" {
+		status = DataExportStatusFailed
+		errorMessage = &input.ErrorMessage
+	}
+
+	completedAt := time.Now().UTC()
+	_, err = r.Db.Exec("UPDATE users_data_export SET status = $1, archive = $2, error_message = $3, completed_at = $4, expires_at = $5, updated_at = $4 WHERE job_id = $6",
+		status, input.Archive, errorMessage, completedAt, input.ExpiresAt, input.JobID)
+	return err
+}
+
+func (r *Repository) FailDataExports(ctx context.Context, input FailDataExportsInput) (err error) {
+	completedAt := time.Now().UTC()
+	_, err = r.Db.Exec("UPDATE users_data_export SET status = $1, error_message = $2, completed_at = $3, expires_at = $4, updated_at = $3 WHERE status = $5 AND created_at < $6",
+		DataExportStatusFailed, input.ErrorMessage, completedAt, input.ExpiresAt, DataExportStatusPending, input.CreatedBefore)
+	return err
+}
+
+func (r *Repository) GetLoginFailure(ctx context.Context, key string) (output LoginFailure, err error) {
+	row := r.Db.QueryRow("SELECT failures, last_failure_at, locked_until FROM login_failure WHERE key = $1", key)
+
+	var lockedUntil sql.NullTime
+	err = row.Scan(&output.Failures, &output.LastFailureAt, &lockedUntil)
+	if err == sql.ErrNoRows {
+		return LoginFailure{}, nil
+	}
+	if err != nil {
+		return LoginFailure{}, err
+	}
+	if lockedUntil.Valid {
+		output.LockedUntil = &lockedUntil.Time
+	}
+
+	return output, nil
+}
+
+func (r *Repository) AddLoginFailure(ctx context.Context, input AddLoginFailureInput) (output LoginFailure, err error) {
+	// a single statement so concurrent failures are all counted
+	row := r.Db.QueryRow(`INSERT INTO login_failure(key, failures, last_failure_at, created_at) VALUES($1, 1, $2, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE WHEN login_failure.last_failure_at < $3 THEN 1 ELSE login_failure.failures + 1 END,
+			last_failure_at = $2, updated_at = $2
+		RETURNING failures, last_failure_at, locked_until`, input.Key, input.FailedAt, input.WindowStart)
+
+	var lockedUntil sql.NullTime
+	err = row.Scan(&output.Failures, &output.LastFailureAt, &lockedUntil)
+	if err != nil {
+		return LoginFailure{}, err
+	}
+	if lockedUntil.Valid {
+		output.LockedUntil = &lockedUntil.Time
+	}
+
+	return output, nil
+}
+
+func (r *Repository) LockLogin(ctx context.Context, input LockLoginInput) (err error) {
+	_, err = r.Db.Exec("UPDATE login_failure SET locked_until = $1, updated_at = $2 WHERE key = $3", input.LockedUntil, time.Now().UTC(), input.Key)
+	return err
+}
+
+func (r *Repository) ResetLoginFailures(ctx context.Context, key string) (err error) {
+	_, err = r.Db.Exec("DELETE FROM login_failure WHERE key = $1", key)
+	return err
+}
+
+func (r *Repository) PurgeLoginFailures(ctx context.Context, before time.Time) (err error) {
+	_, err = r.Db.Exec("DELETE FROM login_failure WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)", before, time.Now().UTC())
+	return err
+}
+
+func (r *Repository) TakeRateLimitToken(ctx context.Context, input TakeRateLimitTokenInput) (output TakeRateLimitTokenOutput, err error) {
+	// a single statement so concurrent requests can't take the same token,
+	// the update is skipped when taking a token would push full_at past now + period
+	row := r.Db.QueryRow(`INSERT INTO rate_limit_bucket(key, full_at) VALUES($1, $2)
+		ON CONFLICT (key) DO UPDATE SET
+			full_at = GREATEST(rate_limit_bucket.full_at, $3) + $4 * INTERVAL '1 microsecond'
+		WHERE GREATEST(rate_limit_bucket.full_at, $3) + $4 * INTERVAL '1 microsecond' <= $5
+		RETURNING full_at`, input.Key, input.Now.Add(input.Interval), input.Now, input.Interval.Microseconds(), input.Now.Add(input.Period))
+
+	err = row.Scan(&output.FullAt)
+	if err == nil {
+		output.Allowed = true
+		return output, nil
+	}
+	if err != sql.ErrNoRows {
+		return TakeRateLimitTokenOutput{}, err
+	}
+
+	// the bucket is empty, its full_at tells when a token is back
+	err = r.Db.QueryRow("SELECT full_at FROM rate_limit_bucket WHERE key = $1", input.Key).Scan(&output.FullAt)
+	if err != nil {
+		return TakeRateLimitTokenOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) PurgeRateLimitBuckets(ctx context.Context, before time.Time) (err error) {
+	_, err = r.Db.Exec("DELETE FROM rate_limit_bucket WHERE full_at < $1", before)
+	return err
+}
+
+func (r *Repository) CreateOTP(ctx context.Context, input CreateOTPInput) (err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}()
+
+	createdAt := time.Now().UTC()
+
+	// only the latest code of a purpose can be used
+	_, err = tx.Exec("UPDATE users_otp SET used_at = $1, updated_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL",
+		createdAt, input.UserID, input.Purpose)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO users_otp(user_id, purpose, phone_number, code_hash, expires_at, created_at) VALUES($1, $2, $3, $4, $5, $6)",
+		input.UserID, input.Purpose, input.PhoneNumber, input.CodeHash, input.ExpiresAt, createdAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) GetOTP(ctx context.Context, input GetOTPInput) (output GetOTPOutput, err error) {
+	row := r.Db.QueryRow(`SELECT id, phone_number, code_hash, attempts, expires_at FROM users_otp
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3
+		ORDER BY id DESC LIMIT 1`, input.UserID, input.Purpose, time.Now().UTC())
+
+	err = row.Scan(&output.ID, &output.PhoneNumber, &output.CodeHash, &output.Attempts, &output.ExpiresAt)
+	if err != nil {
+		return GetOTPOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) UseOTPAttempt(ctx context.Context, input UseOTPAttemptInput) (err error) {
+	// the attempt is counted before the code is compared so concurrent guesses can't exceed the limit
+	result, err := r.Db.Exec("UPDATE users_otp SET attempts = attempts + 1, updated_at = $1 WHERE id = $2 AND attempts < $3 AND used_at IS NULL",
+		time.Now().UTC(), input.ID, input.MaxAttempts)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrOTPAttemptsExceeded
+	}
+
+	return nil
+}
+
+func (r *Repository) ConsumeOTP(ctx context.Context, id int) (err error) {
+	result, err := r.Db.Exec("UPDATE users_otp SET used_at = $1, updated_at = $1 WHERE id = $2 AND used_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrOTPAlreadyUsed
+	}
+
+	return nil
+}
+
+func (r *Repository) VerifyPhoneNumber(ctx context.Context, input VerifyPhoneNumberInput) (err error) {
+	verifiedAt := time.Now().UTC()
+	_, err = r.Db.Exec("UPDATE users SET phone_number = $1, phone_verified_at = $2, updated_at = $2 WHERE id = $3",
+		input.PhoneNumber, verifiedAt, input.UserID)
+	return err
+}
+
+func (r *Repository) GetPasswordHistory(ctx context.Context, input GetPasswordHistoryInput) (output GetPasswordHistoryOutput, err error) {
+	rows, err := r.Db.Query("SELECT password FROM users_password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2", input.UserID, input.Limit)
+	if err != nil {
+		return GetPasswordHistoryOutput{}, err
+	}
+	defer rows.Close()
+
+	output.Passwords = []string{}
+	for rows.Next() {
+		var password string
+		err = rows.Scan(&password)
+		if err != nil {
+			return GetPasswordHistoryOutput{}, err
+		}
+		output.Passwords = append(output.Passwords, password)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return GetPasswordHistoryOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) ChangePassword(ctx context.Context, input ChangePasswordInput) (err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}()
+
+	var oldPassword string
+	row := tx.QueryRow("SELECT password FROM users WHERE id = $1 FOR UPDATE", input.UserID)
+	err = row.Scan(&oldPassword)
+	if err != nil {
+		return err
+	}
+
+	changedAt := time.Now().UTC()
+	_, err = tx.Exec("INSERT INTO users_password_history(user_id, password, created_at) VALUES($1, $2, $3)",
+		input.UserID, oldPassword, changedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE users SET password = $1, updated_at = $2 WHERE id = $3", input.Password, changedAt, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	// only the passwords that can still be checked against are kept
+	_, err = tx.Exec("DELETE FROM users_password_history WHERE user_id = $1 AND id NOT IN (SELECT id FROM users_password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2)",
+		input.UserID, input.HistorySize)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) RehashPassword(ctx context.Context, input RehashPasswordInput) (err error) {
+	// a password changed since the hash was read is left alone, and the history is untouched
+	// since the password stays the same
+	_, err = r.Db.Exec("UPDATE users SET password = $1, updated_at = $2 WHERE id = $3 AND password = $4",
+		input.Password, time.Now().UTC(), input.UserID, input.OldPassword)
+	return err
+}
+
+func (r *Repository) CreateTOTP(ctx context.Context, input CreateTOTPInput) (err error) {
+	createdAt := time.Now().UTC()
+	// a pending enrollment is replaced, an enabled one is left alone
+	result, err := r.Db.Exec(`INSERT INTO users_mfa(user_id, secret, created_at) VALUES($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = NULL, updated_at = EXCLUDED.created_at
+		WHERE users_mfa.enabled_at IS NULL`, input.UserID, input.Secret, createdAt)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+
+	return nil
+}
+
+func (r *Repository) GetTOTP(ctx context.Context, userID int) (output GetTOTPOutput, err error) {
+	row := r.Db.QueryRow("SELECT secret, enabled_at FROM users_mfa WHERE user_id = $1", userID)
+
+	var enabledAt sql.NullTime
+	err = row.Scan(&output.Secret, &enabledAt)
+	if err != nil {
+		return GetTOTPOutput{}, err
+	}
+	if enabledAt.Valid {
+		output.EnabledAt = &enabledAt.Time
+	}
+
+	return output, nil
+}
+
+func (r *Repository) EnableTOTP(ctx context.Context, input EnableTOTPInput) (err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}()
+
+	enabledAt := time.Now().UTC()
+	result, err := tx.Exec("UPDATE users_mfa SET enabled_at = $1, last_used_step = $2, updated_at = $1 WHERE user_id = $3 AND enabled_at IS NULL",
+		enabledAt, input.Step, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		err = ErrTOTPAlreadyEnabled
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM users_mfa_recovery_code WHERE user_id = $1", input.UserID)
+	if err != nil {
+		return err
+	}
+
+	for _, codeHash := range input.RecoveryCodeHashes {
+		_, err = tx.Exec("INSERT INTO users_mfa_recovery_code(user_id, code_hash, created_at) VALUES($1, $2, $3)",
+			input.UserID, codeHash, enabledAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) UseTOTPStep(ctx context.Context, input UseTOTPStepInput) (err error) {
+	// only a step newer than the last accepted one is taken, so a code works once even with concurrent requests
+	result, err := r.Db.Exec(`UPDATE users_mfa SET last_used_step = $1, updated_at = $2
+		WHERE user_id = $3 AND enabled_at IS NOT NULL AND (last_used_step IS NULL OR last_used_step < $1)`,
+		input.Step, time.Now().UTC(), input.UserID)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTOTPStepUsed
+	}
+
+	return nil
+}
+
+func (r *Repository) DisableTOTP(ctx context.Context, userID int) (err error) {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}()
+
+	_, err = tx.Exec("DELETE FROM users_mfa_recovery_code WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM users_mfa WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repository) UseRecoveryCode(ctx context.Context, input UseRecoveryCodeInput) (err error) {
+	result, err := r.Db.Exec("UPDATE users_mfa_recovery_code SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL",
+		time.Now().UTC(), input.UserID, input.CodeHash)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrRecoveryCodeInvalid
+	}
+
+	return nil
+}
+
+func (r *Repository) CreateMFAChallenge(ctx context.Context, input CreateMFAChallengeInput) (err error) {
+	_, err = r.Db.Exec("INSERT INTO users_mfa_challenge(user_id, token_hash, expires_at, created_at) VALUES($1, $2, $3, $4)",
+		input.UserID, input.TokenHash, input.ExpiresAt, time.Now().UTC())
+	return err
+}
+
+func (r *Repository) GetMFAChallenge(ctx context.Context, tokenHash string) (output GetMFAChallengeOutput, err error) {
+	row := r.Db.QueryRow(`SELECT users_mfa_challenge.id, users_mfa_challenge.user_id, users.phone_number FROM users_mfa_challenge
+		JOIN users ON users.id = users_mfa_challenge.user_id
+		WHERE users_mfa_challenge.token_hash = $1 AND users_mfa_challenge.used_at IS NULL AND users_mfa_challenge.expires_at > $2`,
+		tokenHash, time.Now().UTC())
+
+	err = row.Scan(&output.ID, &output.UserID, &output.PhoneNumber)
+	if err != nil {
+		return GetMFAChallengeOutput{}, err
+	}
+
+	return output, nil
+}
+
+func (r *Repository) UseMFAChallengeAttempt(ctx context.Context, input UseMFAChallengeAttemptInput) (err error) {
+	result, err := r.Db.Exec("UPDATE users_mfa_challenge SET attempts = attempts + 1, updated_at = $1 WHERE id = $2 AND attempts < $3 AND used_at IS NULL",
+		time.Now().UTC(), input.ID, input.MaxAttempts)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrOTPAttemptsExceeded
+	}
+
+	return nil
+}
+
+func (r *Repository) ConsumeMFAChallenge(ctx context.Context, id int) (err error) {
+	result, err := r.Db.Exec("UPDATE users_mfa_challenge SET used_at = $1, updated_at = $1 WHERE id = $2 AND used_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrOTPAlreadyUsed
+	}
+
+	return nil
+}
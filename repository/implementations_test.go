@@ -7,7 +7,9 @@ import (
 	"reflect"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/lib/pq"
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
@@ -26,6 +28,8 @@ func TestRepository_RegisterUser(t *testing.T) {
 			mockFunc: func() {
 				mockDB.ExpectBegin()
 				mockDB.ExpectQuery(regexp.QuoteMeta("INSERT INTO users(phone_number, full_name, password, created_at) VALUES($1, $2, $3, $4) RETURNING id")).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_role(user_id, role_id, created_at) SELECT $1, id, $2 FROM roles WHERE name = $3")).
+					WithArgs(1, sqlmock.AnyArg(), "user").WillReturnResult(sqlmock.NewResult(1, 1))
 				mockDB.ExpectCommit()
 			},
 			input: RegisterUserInput{
@@ -56,11 +60,25 @@ func TestRepository_RegisterUser(t *testing.T) {
 			wantOutput: RegisterUserOutput{},
 			wantErr:    true,
 		},
+		{
+			name: "error while grant default role",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(regexp.QuoteMeta("INSERT INTO users(phone_number, full_name, password, created_at) VALUES($1, $2, $3, $4) RETURNING id")).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_role(user_id, role_id, created_at) SELECT $1, id, $2 FROM roles WHERE name = $3")).
+					WillReturnError(fmt.Errorf("some error"))
+			},
+			input:      RegisterUserInput{},
+			wantOutput: RegisterUserOutput{},
+			wantErr:    true,
+		},
 		{
 			name: "error while commit transaction",
 			mockFunc: func() {
 				mockDB.ExpectBegin()
 				mockDB.ExpectQuery(regexp.QuoteMeta("INSERT INTO users(phone_number, full_name, password, created_at) VALUES($1, $2, $3, $4) RETURNING id")).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_role(user_id, role_id, created_at) SELECT $1, id, $2 FROM roles WHERE name = $3")).
+					WithArgs(1, sqlmock.AnyArg(), "user").WillReturnResult(sqlmock.NewResult(1, 1))
 				mockDB.ExpectCommit().WillReturnError(fmt.Errorf("some error"))
 			},
 			input:      RegisterUserInput{},
@@ -91,6 +109,7 @@ func TestRepository_RegisterUser(t *testing.T) {
 func TestRepository_LoginUser(t *testing.T) {
 	db, mockDB, _ := sqlmock.New()
 	defer db.Close()
+	deletionRequestedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
 	tests := []struct {
 		name       string
 		input      LoginUserInput
@@ -104,8 +123,8 @@ func TestRepository_LoginUser(t *testing.T) {
 				PhoneNumber: "+6281234567890",
 			},
 			mockFunc: func() {
-				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, password FROM users WHERE phone_number = $1")).
-					WithArgs("+6281234567890").WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "password"}).AddRow(1, "+6281234567890", "password"))
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, password, deletion_requested_at, phone_verified_at, EXISTS(SELECT 1 FROM users_mfa WHERE users_mfa.user_id = users.id AND users_mfa.enabled_at IS NOT NULL) FROM users WHERE phone_number = $1")).
+					WithArgs("+6281234567890").WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "password", "deletion_requested_at", "phone_verified_at", "mfa_enabled"}).AddRow(1, "+6281234567890", "password", nil, nil, false))
 			},
 			wantOutput: LoginUserOutput{
 				UserID:      1,
@@ -113,13 +132,31 @@ func TestRepository_LoginUser(t *testing.T) {
 				Password:    "password",
 			},
 		},
+		{
+			name: "success with deletion requested",
+			input: LoginUserInput{
+				PhoneNumber: "+6281234567890",
+			},
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, password, deletion_requested_at, phone_verified_at, EXISTS(SELECT 1 FROM users_mfa WHERE users_mfa.user_id = users.id AND users_mfa.enabled_at IS NOT NULL) FROM users WHERE phone_number = $1")).
+					WithArgs("+6281234567890").WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "password", "deletion_requested_at", "phone_verified_at", "mfa_enabled"}).AddRow(1, "+6281234567890", "password", deletionRequestedAt, deletionRequestedAt, true))
+			},
+			wantOutput: LoginUserOutput{
+				UserID:              1,
+				PhoneNumber:         "+6281234567890",
+				Password:            "password",
+				DeletionRequestedAt: &deletionRequestedAt,
+				PhoneVerifiedAt:     &deletionRequestedAt,
+				MFAEnabled:          true,
+			},
+		},
 		{
 			name: "error while query",
 			input: LoginUserInput{
 				PhoneNumber: "+6281234567890",
 			},
 			mockFunc: func() {
-				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, password FROM users WHERE phone_number = $1")).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, password, deletion_requested_at, phone_verified_at, EXISTS(SELECT 1 FROM users_mfa WHERE users_mfa.user_id = users.id AND users_mfa.enabled_at IS NOT NULL) FROM users WHERE phone_number = $1")).WillReturnError(fmt.Errorf("some error"))
 			},
 			wantOutput: LoginUserOutput{},
 			wantErr:    true,
@@ -146,6 +183,7 @@ func TestRepository_LoginUser(t *testing.T) {
 func TestRepository_GetUser(t *testing.T) {
 	db, mockDB, _ := sqlmock.New()
 	defer db.Close()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
 	tests := []struct {
 		name       string
 		mockFunc   func()
@@ -156,22 +194,24 @@ func TestRepository_GetUser(t *testing.T) {
 		{
 			name: "success",
 			mockFunc: func() {
-				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name FROM users WHERE id = $1")).
-					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name"}).AddRow(1, "+6281234567890", "John Doe"))
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, created_at, phone_verified_at FROM users WHERE id = $1")).
+					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "created_at", "phone_verified_at"}).AddRow(1, "+6281234567890", "John Doe", createdAt, createdAt))
 			},
 			input: GetUserInput{
 				UserID: 1,
 			},
 			wantOutput: GetUserOutput{
-				UserID:      1,
-				PhoneNumber: "+6281234567890",
-				FullName:    "John Doe",
+				UserID:          1,
+				PhoneNumber:     "+6281234567890",
+				FullName:        "John Doe",
+				CreatedAt:       createdAt,
+				PhoneVerifiedAt: &createdAt,
 			},
 		},
 		{
 			name: "error while query",
 			mockFunc: func() {
-				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name FROM users WHERE id = $1")).
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, created_at, phone_verified_at FROM users WHERE id = $1")).
 					WithArgs(1).WillReturnError(fmt.Errorf("some error"))
 			},
 			input:      GetUserInput{},
@@ -197,6 +237,115 @@ func TestRepository_GetUser(t *testing.T) {
 	}
 }
 
+func TestRepository_ListUsers(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	createdTo := createdAt.Add(time.Hour)
+	columns := []string{"id", "phone_number", "full_name", "created_at"}
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      ListUsersInput
+		wantOutput ListUsersOutput
+		wantErr    bool
+	}{
+		{
+			name: "success first page",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, created_at FROM users ORDER BY created_at DESC, id DESC LIMIT $1")).
+					WithArgs(2).WillReturnRows(sqlmock.NewRows(columns).AddRow(2, "+6281234567891", "Jane Doe", createdAt).AddRow(1, "+6281234567890", "John Doe", createdAt))
+			},
+			input: ListUsersInput{
+				SortBy:     UserSortCreatedAt,
+				Descending: true,
+				Limit:      2,
+			},
+			wantOutput: ListUsersOutput{
+				Users: []GetUserOutput{
+					{UserID: 2, PhoneNumber: "+6281234567891", FullName: "Jane Doe", CreatedAt: createdAt},
+					{UserID: 1, PhoneNumber: "+6281234567890", FullName: "John Doe", CreatedAt: createdAt},
+				},
+			},
+		},
+		{
+			name: "success with filters and cursor",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, created_at FROM users WHERE phone_number LIKE $1 AND full_name ILIKE $2 AND created_at >= $3 AND created_at < $4 AND (full_name, id) > ($5, $6) ORDER BY full_name ASC, id ASC LIMIT $7")).
+					WithArgs("+6281%", `%50\%%`, createdAt, createdTo, "Jane Doe", 2, 10).WillReturnRows(sqlmock.NewRows(columns).AddRow(1, "+6281234567890", "John 50% Doe", createdAt))
+			},
+			input: ListUsersInput{
+				PhonePrefix: "+6281",
+				Name:        "50%",
+				CreatedFrom: &createdAt,
+				CreatedTo:   &createdTo,
+				SortBy:      UserSortFullName,
+				After: &ListUsersCursor{
+					UserID:   2,
+					FullName: "Jane Doe",
+				},
+				Limit: 10,
+			},
+			wantOutput: ListUsersOutput{
+				Users: []GetUserOutput{
+					{UserID: 1, PhoneNumber: "+6281234567890", FullName: "John 50% Doe", CreatedAt: createdAt},
+				},
+			},
+		},
+		{
+			name: "success sort by id with cursor",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, created_at FROM users WHERE id < $1 ORDER BY id DESC LIMIT $2")).
+					WithArgs(5, 10).WillReturnRows(sqlmock.NewRows(columns))
+			},
+			input: ListUsersInput{
+				SortBy:     UserSortID,
+				Descending: true,
+				After: &ListUsersCursor{
+					UserID: 5,
+				},
+				Limit: 10,
+			},
+			wantOutput: ListUsersOutput{
+				Users: []GetUserOutput{},
+			},
+		},
+		{
+			name:       "error unknown sort column",
+			mockFunc:   func() {},
+			input:      ListUsersInput{SortBy: "password", Limit: 10},
+			wantOutput: ListUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while query",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, created_at FROM users ORDER BY id ASC LIMIT $1")).
+					WithArgs(10).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:      ListUsersInput{SortBy: UserSortID, Limit: 10},
+			wantOutput: ListUsersOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.ListUsers(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.ListUsers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.ListUsers() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
 func TestRepository_UpdateUser(t *testing.T) {
 	db, mockDB, _ := sqlmock.New()
 	defer db.Close()
@@ -211,8 +360,8 @@ func TestRepository_UpdateUser(t *testing.T) {
 			name: "success",
 			mockFunc: func() {
 				mockDB.ExpectBegin()
-				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, password FROM users WHERE id = $1")).
-					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "password"}).AddRow(1, "+6281234567890", "John Doe", "password"))
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, password, phone_verified_at FROM users WHERE id = $1")).
+					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "password", "phone_verified_at"}).AddRow(1, "+6281234567890", "John Doe", "password", nil))
 				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users SET phone_number = $1, full_name = $2, password = $3, updated_at = $4 WHERE id = $5")).WillReturnResult(sqlmock.NewResult(1, 1))
 				mockDB.ExpectCommit()
 			},
@@ -242,7 +391,7 @@ func TestRepository_UpdateUser(t *testing.T) {
 			name: "error while query",
 			mockFunc: func() {
 				mockDB.ExpectBegin()
-				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, password FROM users WHERE id = $1")).
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, password, phone_verified_at FROM users WHERE id = $1")).
 					WithArgs(1).WillReturnError(fmt.Errorf("some error"))
 			},
 			input:      UpdateUserInput{},
@@ -253,8 +402,8 @@ func TestRepository_UpdateUser(t *testing.T) {
 			name: "error while update user",
 			mockFunc: func() {
 				mockDB.ExpectBegin()
-				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, password FROM users WHERE id = $1")).
-					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "password"}).AddRow(1, "+6281234567890", "John Doe", "password"))
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, password, phone_verified_at FROM users WHERE id = $1")).
+					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "password", "phone_verified_at"}).AddRow(1, "+6281234567890", "John Doe", "password", nil))
 				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users SET phone_number = $1, full_name = $2, password = $3, updated_at = $4 WHERE id = $5")).WillReturnError(fmt.Errorf("some error"))
 			},
 			input:      UpdateUserInput{},
@@ -265,8 +414,8 @@ func TestRepository_UpdateUser(t *testing.T) {
 			name: "error while commit transaction",
 			mockFunc: func() {
 				mockDB.ExpectBegin()
-				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, password FROM users WHERE id = $1")).
-					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "password"}).AddRow(1, "+6281234567890", "John Doe", "password"))
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, phone_number, full_name, password, phone_verified_at FROM users WHERE id = $1")).
+					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name", "password", "phone_verified_at"}).AddRow(1, "+6281234567890", "John Doe", "password", nil))
 				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users SET phone_number = $1, full_name = $2, password = $3, updated_at = $4 WHERE id = $5")).WillReturnResult(sqlmock.NewResult(1, 1))
 				mockDB.ExpectCommit().WillReturnError(fmt.Errorf("some error"))
 			},
@@ -347,3 +496,3095 @@ func TestRepository_IncrementLoginCount(t *testing.T) {
 		})
 	}
 }
+
+var loginAttemptColumns = []string{"id", "created_at", "ip_address", "user_agent", "auth_method", "success", "failure_reason"}
+
+func TestRepository_RecordLoginAttempt(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	insertQuery := regexp.QuoteMeta("INSERT INTO users_login_attempt(user_id, phone_number, ip_address, user_agent, auth_method, success, failure_reason, created_at) VALUES($1, $2, $3, $4, $5, $6, $7, $8)")
+	userID := 1
+	failureReason := LoginFailureInvalidPassword
+	tests := []struct {
+		name     string
+		mockFunc func()
+		input    RecordLoginAttemptInput
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(insertQuery).WithArgs(&userID, "+628123456789", "192.0.2.1", "curl", AuthMethodPassword, true, nil, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			input: RecordLoginAttemptInput{
+				UserID:      1,
+				PhoneNumber: "+628123456789",
+				IPAddress:   "192.0.2.1",
+				UserAgent:   "curl",
+				AuthMethod:  AuthMethodPassword,
+				Success:     true,
+			},
+		},
+		{
+			name: "success failed attempt of unknown user",
+			mockFunc: func() {
+				mockDB.ExpectExec(insertQuery).WithArgs(nil, "+628123456789", "192.0.2.1", "", AuthMethodPassword, false, &failureReason, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			input: RecordLoginAttemptInput{
+				PhoneNumber:   "+628123456789",
+				IPAddress:     "192.0.2.1",
+				AuthMethod:    AuthMethodPassword,
+				FailureReason: LoginFailureInvalidPassword,
+			},
+		},
+		{
+			name: "error while insert",
+			mockFunc: func() {
+				mockDB.ExpectExec(insertQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   RecordLoginAttemptInput{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.RecordLoginAttempt(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.RecordLoginAttempt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Errorf("Repository.RecordLoginAttempt() expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRepository_ListLoginAttempts(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	selectQuery := "SELECT id, created_at, ip_address, user_agent, auth_method, success, failure_reason FROM users_login_attempt WHERE user_id = $1"
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      ListLoginAttemptsInput
+		wantOutput ListLoginAttemptsOutput
+		wantErr    bool
+	}{
+		{
+			name: "success first page",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta(selectQuery+" ORDER BY id DESC LIMIT $2")).WithArgs(1, 2).WillReturnRows(
+					sqlmock.NewRows(loginAttemptColumns).
+						AddRow(9, createdAt, "192.0.2.1", "curl", AuthMethodPassword, true, nil).
+						AddRow(8, createdAt, "192.0.2.2", "", AuthMethodPassword, false, LoginFailureInvalidPassword))
+			},
+			input: ListLoginAttemptsInput{UserID: 1, Limit: 2},
+			wantOutput: ListLoginAttemptsOutput{
+				Attempts: []LoginAttempt{
+					{ID: 9, CreatedAt: createdAt, IPAddress: "192.0.2.1", UserAgent: "curl", AuthMethod: AuthMethodPassword, Success: true},
+					{ID: 8, CreatedAt: createdAt, IPAddress: "192.0.2.2", AuthMethod: AuthMethodPassword, FailureReason: LoginFailureInvalidPassword},
+				},
+			},
+		},
+		{
+			name: "success next page",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta(selectQuery+" AND id < $2 ORDER BY id DESC LIMIT $3")).WithArgs(1, 8, 2).WillReturnRows(
+					sqlmock.NewRows(loginAttemptColumns))
+			},
+			input: ListLoginAttemptsInput{UserID: 1, BeforeID: 8, Limit: 2},
+			wantOutput: ListLoginAttemptsOutput{
+				Attempts: []LoginAttempt{},
+			},
+		},
+		{
+			name: "error while query",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta(selectQuery)).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:      ListLoginAttemptsInput{UserID: 1, Limit: 2},
+			wantOutput: ListLoginAttemptsOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.ListLoginAttempts(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.ListLoginAttempts() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.ListLoginAttempts() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_CreateRefreshToken(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name     string
+		mockFunc func()
+		input    CreateRefreshTokenInput
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_refresh_token(user_id, family_id, token_hash, expires_at, created_at) VALUES($1, $2, $3, $4, $5)")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			input: CreateRefreshTokenInput{
+				UserID:    1,
+				FamilyID:  "family",
+				TokenHash: "hash",
+				ExpiresAt: time.Now(),
+			},
+		},
+		{
+			name: "error while insert",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_refresh_token(user_id, family_id, token_hash, expires_at, created_at) VALUES($1, $2, $3, $4, $5)")).
+					WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   CreateRefreshTokenInput{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+
+			tt.mockFunc()
+			if err := r.CreateRefreshToken(context.Background(), tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.CreateRefreshToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetRefreshToken(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	expiresAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      GetRefreshTokenInput
+		wantOutput GetRefreshTokenOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, family_id, expires_at, used_at IS NOT NULL, revoked_at IS NOT NULL FROM users_refresh_token WHERE token_hash = $1")).
+					WithArgs("hash").WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "family_id", "expires_at", "used", "revoked"}).AddRow(1, 2, "family", expiresAt, true, false))
+			},
+			input: GetRefreshTokenInput{
+				TokenHash: "hash",
+			},
+			wantOutput: GetRefreshTokenOutput{
+				ID:        1,
+				UserID:    2,
+				FamilyID:  "family",
+				ExpiresAt: expiresAt,
+				IsUsed:    true,
+			},
+		},
+		{
+			name: "error while query",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT id, user_id, family_id, expires_at, used_at IS NOT NULL, revoked_at IS NOT NULL FROM users_refresh_token WHERE token_hash = $1")).
+					WithArgs("hash").WillReturnError(sql.ErrNoRows)
+			},
+			input: GetRefreshTokenInput{
+				TokenHash: "hash",
+			},
+			wantOutput: GetRefreshTokenOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetRefreshToken(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetRefreshToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetRefreshToken() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_RotateRefreshToken(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name     string
+		mockFunc func()
+		input    RotateRefreshTokenInput
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_refresh_token SET used_at = $1, updated_at = $1 WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_refresh_token(user_id, family_id, token_hash, expires_at, created_at) VALUES($1, $2, $3, $4, $5)")).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mockDB.ExpectCommit()
+			},
+			input: RotateRefreshTokenInput{
+				OldTokenID: 1,
+				NewToken: CreateRefreshTokenInput{
+					UserID:    1,
+					FamilyID:  "family",
+					TokenHash: "hash",
+				},
+			},
+		},
+		{
+			name: "error already used",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_refresh_token SET used_at = $1, updated_at = $1 WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL")).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectRollback()
+			},
+			input:   RotateRefreshTokenInput{OldTokenID: 1},
+			wantErr: ErrRefreshTokenAlreadyUsed,
+		},
+		{
+			name: "error while begin transaction",
+			mockFunc: func() {
+				mockDB.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   RotateRefreshTokenInput{},
+			wantErr: fmt.Errorf("some error"),
+		},
+		{
+			name: "error while insert new token",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_refresh_token SET used_at = $1, updated_at = $1 WHERE id = $2 AND used_at IS NULL AND revoked_at IS NULL")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_refresh_token(user_id, family_id, token_hash, expires_at, created_at) VALUES($1, $2, $3, $4, $5)")).
+					WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			input:   RotateRefreshTokenInput{OldTokenID: 1},
+			wantErr: fmt.Errorf("some error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+
+			tt.mockFunc()
+			err := r.RotateRefreshToken(context.Background(), tt.input)
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Errorf("Repository.RotateRefreshToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_RevokeRefreshTokenFamily(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name     string
+		mockFunc func()
+		familyID string
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_refresh_token SET revoked_at = $1, updated_at = $1 WHERE family_id = $2 AND revoked_at IS NULL")).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			familyID: "family",
+		},
+		{
+			name: "error while update",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_refresh_token SET revoked_at = $1, updated_at = $1 WHERE family_id = $2 AND revoked_at IS NULL")).
+					WillReturnError(fmt.Errorf("some error"))
+			},
+			familyID: "family",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+
+			tt.mockFunc()
+			if err := r.RevokeRefreshTokenFamily(context.Background(), tt.familyID); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.RevokeRefreshTokenFamily() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_RevokeUserRefreshTokens(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name     string
+		mockFunc func()
+		userID   int
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_refresh_token SET revoked_at = $1, updated_at = $1 WHERE user_id = $2 AND revoked_at IS NULL")).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			userID: 1,
+		},
+		{
+			name: "error while update",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_refresh_token SET revoked_at = $1, updated_at = $1 WHERE user_id = $2 AND revoked_at IS NULL")).
+					WillReturnError(fmt.Errorf("some error"))
+			},
+			userID:  1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+
+			tt.mockFunc()
+			if err := r.RevokeUserRefreshTokens(context.Background(), tt.userID); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.RevokeUserRefreshTokens() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_RevokeOtherRefreshTokens(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	revokeQuery := regexp.QuoteMeta("UPDATE users_refresh_token SET revoked_at = $1, updated_at = $1 WHERE user_id = $2 AND family_id <> $3 AND revoked_at IS NULL")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(revokeQuery).WithArgs(sqlmock.AnyArg(), 1, "family").WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+		},
+		{
+			name: "error while update",
+			mockFunc: func() {
+				mockDB.ExpectExec(revokeQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+
+			tt.mockFunc()
+			err := r.RevokeOtherRefreshTokens(context.Background(), RevokeOtherRefreshTokensInput{UserID: 1, KeepFamilyID: "family"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.RevokeOtherRefreshTokens() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_RevokeToken(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name     string
+		mockFunc func()
+		input    RevokeTokenInput
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_revoked_token(user_id, token_id, expires_at, created_at) VALUES($1, $2, $3, $4) ON CONFLICT (token_id) DO NOTHING")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			input: RevokeTokenInput{
+				UserID:  1,
+				TokenID: "jti",
+			},
+		},
+		{
+			name: "error while insert",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_revoked_token(user_id, token_id, expires_at, created_at) VALUES($1, $2, $3, $4) ON CONFLICT (token_id) DO NOTHING")).
+					WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   RevokeTokenInput{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+
+			tt.mockFunc()
+			if err := r.RevokeToken(context.Background(), tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.RevokeToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_RevokeUserTokens(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name     string
+		mockFunc func()
+		input    RevokeUserTokensInput
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_token_revocation(user_id, revoked_before, created_at) VALUES($1, $2, $3) ON CONFLICT (user_id) DO UPDATE SET revoked_before = EXCLUDED.revoked_before, updated_at = EXCLUDED.created_at")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			input: RevokeUserTokensInput{
+				UserID:        1,
+				RevokedBefore: time.Now(),
+			},
+		},
+		{
+			name: "error while upsert",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_token_revocation(user_id, revoked_before, created_at) VALUES($1, $2, $3) ON CONFLICT (user_id) DO UPDATE SET revoked_before = EXCLUDED.revoked_before, updated_at = EXCLUDED.created_at")).
+					WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   RevokeUserTokensInput{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+
+			tt.mockFunc()
+			if err := r.RevokeUserTokens(context.Background(), tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.RevokeUserTokens() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetTokenRevocation(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	revokedBefore := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      GetTokenRevocationInput
+		wantOutput GetTokenRevocationOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM users_revoked_token WHERE token_id = $1), (SELECT revoked_before FROM users_token_revocation WHERE user_id = $2)")).
+					WithArgs("jti", 1).WillReturnRows(sqlmock.NewRows([]string{"exists", "revoked_before"}).AddRow(true, revokedBefore))
+			},
+			input: GetTokenRevocationInput{
+				UserID:  1,
+				TokenID: "jti",
+			},
+			wantOutput: GetTokenRevocationOutput{
+				IsTokenRevoked: true,
+				RevokedBefore:  revokedBefore,
+			},
+		},
+		{
+			name: "success without revoke all",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM users_revoked_token WHERE token_id = $1), (SELECT revoked_before FROM users_token_revocation WHERE user_id = $2)")).
+					WithArgs("jti", 1).WillReturnRows(sqlmock.NewRows([]string{"exists", "revoked_before"}).AddRow(false, nil))
+			},
+			input: GetTokenRevocationInput{
+				UserID:  1,
+				TokenID: "jti",
+			},
+			wantOutput: GetTokenRevocationOutput{},
+		},
+		{
+			name: "error while query",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT EXISTS(SELECT 1 FROM users_revoked_token WHERE token_id = $1), (SELECT revoked_before FROM users_token_revocation WHERE user_id = $2)")).
+					WithArgs("jti", 1).WillReturnError(fmt.Errorf("some error"))
+			},
+			input: GetTokenRevocationInput{
+				UserID:  1,
+				TokenID: "jti",
+			},
+			wantOutput: GetTokenRevocationOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetTokenRevocation(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetTokenRevocation() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetTokenRevocation() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetOAuthClient(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      GetOAuthClientInput
+		wantOutput GetOAuthClientOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT client_id, client_secret, name FROM oauth_client WHERE client_id = $1")).
+					WithArgs("billing").WillReturnRows(sqlmock.NewRows([]string{"client_id", "client_secret", "name"}).AddRow("billing", "hash", "Billing Service"))
+			},
+			input: GetOAuthClientInput{
+				ClientID: "billing",
+			},
+			wantOutput: GetOAuthClientOutput{
+				ClientID:     "billing",
+				ClientSecret: "hash",
+				Name:         "Billing Service",
+			},
+		},
+		{
+			name: "error while query",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT client_id, client_secret, name FROM oauth_client WHERE client_id = $1")).
+					WithArgs("billing").WillReturnError(sql.ErrNoRows)
+			},
+			input: GetOAuthClientInput{
+				ClientID: "billing",
+			},
+			wantOutput: GetOAuthClientOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetOAuthClient(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetOAuthClient() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetOAuthClient() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetUserRoles(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      GetUserRolesInput
+		wantOutput GetUserRolesOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT roles.name FROM users_role JOIN roles ON roles.id = users_role.role_id WHERE users_role.user_id = $1 ORDER BY roles.name")).
+					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("admin").AddRow("user"))
+			},
+			input: GetUserRolesInput{
+				UserID: 1,
+			},
+			wantOutput: GetUserRolesOutput{
+				Roles: []string{"admin", "user"},
+			},
+		},
+		{
+			name: "success without roles",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT roles.name FROM users_role JOIN roles ON roles.id = users_role.role_id WHERE users_role.user_id = $1 ORDER BY roles.name")).
+					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+			},
+			input: GetUserRolesInput{
+				UserID: 1,
+			},
+			wantOutput: GetUserRolesOutput{
+				Roles: []string{},
+			},
+		},
+		{
+			name: "error while query",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT roles.name FROM users_role JOIN roles ON roles.id = users_role.role_id WHERE users_role.user_id = $1 ORDER BY roles.name")).
+					WithArgs(1).WillReturnError(fmt.Errorf("some error"))
+			},
+			input: GetUserRolesInput{
+				UserID: 1,
+			},
+			wantOutput: GetUserRolesOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetUserRoles(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetUserRoles() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetUserRoles() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetUserPermissions(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      GetUserPermissionsInput
+		wantOutput GetUserPermissionsOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT permissions.name FROM permissions WHERE permissions.id IN (SELECT roles_permission.permission_id FROM roles_permission JOIN users_role ON users_role.role_id = roles_permission.role_id WHERE users_role.user_id = $1 UNION SELECT users_permission.permission_id FROM users_permission WHERE users_permission.user_id = $1) ORDER BY permissions.name")).
+					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}).AddRow("profile:read").AddRow("users:read"))
+			},
+			input: GetUserPermissionsInput{
+				UserID: 1,
+			},
+			wantOutput: GetUserPermissionsOutput{
+				Permissions: []string{"profile:read", "users:read"},
+			},
+		},
+		{
+			name: "success without permissions",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT permissions.name FROM permissions WHERE permissions.id IN (SELECT roles_permission.permission_id FROM roles_permission JOIN users_role ON users_role.role_id = roles_permission.role_id WHERE users_role.user_id = $1 UNION SELECT users_permission.permission_id FROM users_permission WHERE users_permission.user_id = $1) ORDER BY permissions.name")).
+					WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"name"}))
+			},
+			input: GetUserPermissionsInput{
+				UserID: 1,
+			},
+			wantOutput: GetUserPermissionsOutput{
+				Permissions: []string{},
+			},
+		},
+		{
+			name: "error while query",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT permissions.name FROM permissions WHERE permissions.id IN (SELECT roles_permission.permission_id FROM roles_permission JOIN users_role ON users_role.role_id = roles_permission.role_id WHERE users_role.user_id = $1 UNION SELECT users_permission.permission_id FROM users_permission WHERE users_permission.user_id = $1) ORDER BY permissions.name")).
+					WithArgs(1).WillReturnError(fmt.Errorf("some error"))
+			},
+			input: GetUserPermissionsInput{
+				UserID: 1,
+			},
+			wantOutput: GetUserPermissionsOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetUserPermissions(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetUserPermissions() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetUserPermissions() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_SuspendUser(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		mockFunc func()
+		input    SuspendUserInput
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_suspension SET lifted_at = $1, lifted_by = $2, updated_at = $1 WHERE user_id = $3 AND lifted_at IS NULL")).
+					WithArgs(sqlmock.AnyArg(), 1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_suspension(user_id, reason, suspended_by, expires_at, created_at) VALUES($1, $2, $3, $4, $5)")).
+					WithArgs(2, "spam", 1, &expiresAt, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockDB.ExpectCommit()
+			},
+			input: SuspendUserInput{
+				UserID:      2,
+				Reason:      "spam",
+				SuspendedBy: 1,
+				ExpiresAt:   &expiresAt,
+			},
+		},
+		{
+			name: "error while begin transaction",
+			mockFunc: func() {
+				mockDB.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   SuspendUserInput{},
+			wantErr: true,
+		},
+		{
+			name: "error while lift active suspension",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_suspension SET lifted_at = $1, lifted_by = $2, updated_at = $1 WHERE user_id = $3 AND lifted_at IS NULL")).
+					WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			input:   SuspendUserInput{},
+			wantErr: true,
+		},
+		{
+			name: "error while insert suspension",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_suspension SET lifted_at = $1, lifted_by = $2, updated_at = $1 WHERE user_id = $3 AND lifted_at IS NULL")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_suspension(user_id, reason, suspended_by, expires_at, created_at) VALUES($1, $2, $3, $4, $5)")).
+					WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			input:   SuspendUserInput{},
+			wantErr: true,
+		},
+		{
+			name: "error while commit transaction",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_suspension SET lifted_at = $1, lifted_by = $2, updated_at = $1 WHERE user_id = $3 AND lifted_at IS NULL")).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(regexp.QuoteMeta("INSERT INTO users_suspension(user_id, reason, suspended_by, expires_at, created_at) VALUES($1, $2, $3, $4, $5)")).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mockDB.ExpectCommit().WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   SuspendUserInput{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.SuspendUser(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.SuspendUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_ReactivateUser(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name     string
+		mockFunc func()
+		input    ReactivateUserInput
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_suspension SET lifted_at = $1, lifted_by = $2, updated_at = $1 WHERE user_id = $3 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > $1)")).
+					WithArgs(sqlmock.AnyArg(), 1, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			input: ReactivateUserInput{
+				UserID:        2,
+				ReactivatedBy: 1,
+			},
+		},
+		{
+			name: "error not suspended",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_suspension SET lifted_at = $1, lifted_by = $2, updated_at = $1 WHERE user_id = $3 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > $1)")).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			input:   ReactivateUserInput{},
+			wantErr: ErrUserNotSuspended,
+		},
+		{
+			name: "error while update",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users_suspension SET lifted_at = $1, lifted_by = $2, updated_at = $1 WHERE user_id = $3 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > $1)")).
+					WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   ReactivateUserInput{},
+			wantErr: fmt.Errorf("some error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.ReactivateUser(context.Background(), tt.input)
+			if !reflect.DeepEqual(err, tt.wantErr) {
+				t.Errorf("Repository.ReactivateUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetUserSuspension(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      GetUserSuspensionInput
+		wantOutput GetUserSuspensionOutput
+		wantErr    bool
+	}{
+		{
+			name: "success suspended until reactivated",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT reason, expires_at FROM users_suspension WHERE user_id = $1 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > $2) ORDER BY id DESC LIMIT 1")).
+					WithArgs(1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"reason", "expires_at"}).AddRow("spam", nil))
+			},
+			input: GetUserSuspensionInput{
+				UserID: 1,
+			},
+			wantOutput: GetUserSuspensionOutput{
+				IsSuspended: true,
+				Reason:      "spam",
+			},
+		},
+		{
+			name: "success suspended with expiry",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT reason, expires_at FROM users_suspension WHERE user_id = $1 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > $2) ORDER BY id DESC LIMIT 1")).
+					WithArgs(1, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"reason", "expires_at"}).AddRow("spam", expiresAt))
+			},
+			input: GetUserSuspensionInput{
+				UserID: 1,
+			},
+			wantOutput: GetUserSuspensionOutput{
+				IsSuspended: true,
+				Reason:      "spam",
+				ExpiresAt:   &expiresAt,
+			},
+		},
+		{
+			name: "success not suspended",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT reason, expires_at FROM users_suspension WHERE user_id = $1 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > $2) ORDER BY id DESC LIMIT 1")).
+					WithArgs(1, sqlmock.AnyArg()).WillReturnError(sql.ErrNoRows)
+			},
+			input: GetUserSuspensionInput{
+				UserID: 1,
+			},
+			wantOutput: GetUserSuspensionOutput{},
+		},
+		{
+			name: "error while query",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("SELECT reason, expires_at FROM users_suspension WHERE user_id = $1 AND lifted_at IS NULL AND (expires_at IS NULL OR expires_at > $2) ORDER BY id DESC LIMIT 1")).
+					WithArgs(1, sqlmock.AnyArg()).WillReturnError(fmt.Errorf("some error"))
+			},
+			input: GetUserSuspensionInput{
+				UserID: 1,
+			},
+			wantOutput: GetUserSuspensionOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetUserSuspension(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetUserSuspension() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetUserSuspension() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_RequestUserDeletion(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	requestedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      RequestUserDeletionInput
+		wantOutput RequestUserDeletionOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("UPDATE users SET deletion_requested_at = COALESCE(deletion_requested_at, $1), deletion_requested_by = COALESCE(deletion_requested_by, $2), updated_at = $1 WHERE id = $3 AND deleted_at IS NULL RETURNING deletion_requested_at")).
+					WithArgs(sqlmock.AnyArg(), 1, 2).WillReturnRows(sqlmock.NewRows([]string{"deletion_requested_at"}).AddRow(requestedAt))
+			},
+			input: RequestUserDeletionInput{
+				UserID:      2,
+				RequestedBy: 1,
+			},
+			wantOutput: RequestUserDeletionOutput{
+				RequestedAt: requestedAt,
+			},
+		},
+		{
+			name: "error while query",
+			mockFunc: func() {
+				mockDB.ExpectQuery(regexp.QuoteMeta("UPDATE users SET deletion_requested_at = COALESCE(deletion_requested_at, $1), deletion_requested_by = COALESCE(deletion_requested_by, $2), updated_at = $1 WHERE id = $3 AND deleted_at IS NULL RETURNING deletion_requested_at")).
+					WillReturnError(sql.ErrNoRows)
+			},
+			input:      RequestUserDeletionInput{},
+			wantOutput: RequestUserDeletionOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.RequestUserDeletion(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.RequestUserDeletion() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.RequestUserDeletion() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_CancelUserDeletion(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	tests := []struct {
+		name     string
+		mockFunc func()
+		userID   int
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users SET deletion_requested_at = NULL, deletion_requested_by = NULL, updated_at = $1 WHERE id = $2 AND deleted_at IS NULL")).
+					WithArgs(sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			userID: 1,
+		},
+		{
+			name: "error while update",
+			mockFunc: func() {
+				mockDB.ExpectExec(regexp.QuoteMeta("UPDATE users SET deletion_requested_at = NULL, deletion_requested_by = NULL, updated_at = $1 WHERE id = $2 AND deleted_at IS NULL")).
+					WillReturnError(fmt.Errorf("some error"))
+			},
+			userID:  1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.CancelUserDeletion(context.Background(), tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.CancelUserDeletion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_PurgeDeletedUsers(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	requestedBefore := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	purgeQuery := regexp.QuoteMeta("UPDATE users SET phone_number = 'x' || users.id, full_name = 'Deleted User', password = '', deleted_at = $1, updated_at = $1 FROM users AS previous WHERE previous.id = users.id AND users.deletion_requested_at <= $2 AND users.deleted_at IS NULL RETURNING users.id, previous.phone_number")
+	deleteHistoryQuery := regexp.QuoteMeta("DELETE FROM users_login_history WHERE user_id = ANY($1)")
+	deleteExportQuery := regexp.QuoteMeta("DELETE FROM users_data_export WHERE user_id = ANY($1)")
+	deleteAttemptQuery := regexp.QuoteMeta("DELETE FROM users_login_attempt WHERE user_id = ANY($1)")
+	deleteOTPQuery := regexp.QuoteMeta("DELETE FROM users_otp WHERE user_id = ANY($1)")
+	deletePasswordQuery := regexp.QuoteMeta("DELETE FROM users_password_history WHERE user_id = ANY($1)")
+	deleteRecoveryCodeQuery := regexp.QuoteMeta("DELETE FROM users_mfa_recovery_code WHERE user_id = ANY($1)")
+	deleteChallengeQuery := regexp.QuoteMeta("DELETE FROM users_mfa_challenge WHERE user_id = ANY($1)")
+	deleteMFAQuery := regexp.QuoteMeta("DELETE FROM users_mfa WHERE user_id = ANY($1)")
+	deleteUnknownAttemptQuery := regexp.QuoteMeta("DELETE FROM users_login_attempt WHERE user_id IS NULL AND phone_number = ANY($1)")
+	deleteLockoutQuery := regexp.QuoteMeta("DELETE FROM login_failure WHERE key = ANY($1)")
+	deleteRefreshTokenQuery := regexp.QuoteMeta("DELETE FROM users_refresh_token WHERE user_id = ANY($1)")
+	clearSuspensionQuery := regexp.QuoteMeta("UPDATE users_suspension SET reason = '', updated_at = $1 WHERE user_id = ANY($2)")
+	deleteRateLimitQuery := regexp.QuoteMeta("DELETE FROM rate_limit_bucket WHERE split_part(key, '|', 2) = ANY($1)")
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      PurgeDeletedUsersInput
+		wantOutput PurgeDeletedUsersOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WithArgs(sqlmock.AnyArg(), requestedBefore).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789").AddRow(2, "+628987654321"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 2))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 5))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deletePasswordQuery).WillReturnResult(sqlmock.NewResult(0, 3))
+				mockDB.ExpectExec(deleteRecoveryCodeQuery).WillReturnResult(sqlmock.NewResult(0, 10))
+				mockDB.ExpectExec(deleteChallengeQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteMFAQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteRefreshTokenQuery).WithArgs(pq.Array([]int{1, 2})).WillReturnResult(sqlmock.NewResult(0, 4))
+				mockDB.ExpectExec(clearSuspensionQuery).WithArgs(sqlmock.AnyArg(), pq.Array([]int{1, 2})).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteUnknownAttemptQuery).WithArgs(pq.Array([]string{"+628123456789", "+628987654321"})).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mockDB.ExpectExec(deleteLockoutQuery).WithArgs(pq.Array([]string{"account:+628123456789", "account:+628987654321"})).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteRateLimitQuery).WithArgs(pq.Array([]string{"phone_number:+628123456789", "phone_number:+628987654321"})).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mockDB.ExpectCommit()
+			},
+			input: PurgeDeletedUsersInput{
+				RequestedBefore: requestedBefore,
+			},
+			wantOutput: PurgeDeletedUsersOutput{
+				UserIDs: []int{1, 2},
+			},
+		},
+		{
+			name: "success nothing to purge",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WithArgs(sqlmock.AnyArg(), requestedBefore).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}))
+				mockDB.ExpectCommit()
+			},
+			input: PurgeDeletedUsersInput{
+				RequestedBefore: requestedBefore,
+			},
+			wantOutput: PurgeDeletedUsersOutput{
+				UserIDs: []int{},
+			},
+		},
+		{
+			name: "error while begin transaction",
+			mockFunc: func() {
+				mockDB.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while anonymize users",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete login history",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete data exports",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete login attempts",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete otps",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete password history",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deletePasswordQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete mfa recovery codes",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deletePasswordQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRecoveryCodeQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete mfa",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deletePasswordQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRecoveryCodeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteChallengeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteMFAQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete refresh tokens",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deletePasswordQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRecoveryCodeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteChallengeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteMFAQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRefreshTokenQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while clear suspension reasons",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deletePasswordQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRecoveryCodeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteChallengeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteMFAQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRefreshTokenQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(clearSuspensionQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete login attempts of the phone number",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deletePasswordQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRecoveryCodeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteChallengeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteMFAQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRefreshTokenQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(clearSuspensionQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteUnknownAttemptQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete lockout",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deletePasswordQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRecoveryCodeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteChallengeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteMFAQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRefreshTokenQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(clearSuspensionQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteUnknownAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteLockoutQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while delete rate limit buckets",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deletePasswordQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRecoveryCodeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteChallengeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteMFAQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRefreshTokenQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(clearSuspensionQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteUnknownAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteLockoutQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRateLimitQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while commit transaction",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(purgeQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number"}).AddRow(1, "+628123456789"))
+				mockDB.ExpectExec(deleteHistoryQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteExportQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteOTPQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deletePasswordQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRecoveryCodeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteChallengeQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteMFAQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRefreshTokenQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(clearSuspensionQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteUnknownAttemptQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteLockoutQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(deleteRateLimitQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectCommit().WillReturnError(fmt.Errorf("some error"))
+			},
+			wantOutput: PurgeDeletedUsersOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.PurgeDeletedUsers(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.PurgeDeletedUsers() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.PurgeDeletedUsers() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Errorf("Repository.PurgeDeletedUsers() expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRepository_CountUserRecords(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	countQuery := regexp.QuoteMeta("SELECT (SELECT COUNT(*) FROM users_login_attempt WHERE user_id = $1)")
+	tests := []struct {
+		name      string
+		mockFunc  func()
+		userID    int
+		wantCount int
+		wantErr   bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(countQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+			},
+			userID:    1,
+			wantCount: 12,
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectQuery(countQuery).WithArgs(1).WillReturnError(fmt.Errorf("some error"))
+			},
+			userID:  1,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotCount, err := r.CountUserRecords(context.Background(), tt.userID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.CountUserRecords() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotCount != tt.wantCount {
+				t.Errorf("Repository.CountUserRecords() = %v, want %v", gotCount, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestRepository_GetUserRecords(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := createdAt.Add(time.Hour)
+	liftedBy := 3
+	historyQuery := regexp.QuoteMeta("SELECT id, created_at, ip_address, user_agent, auth_method, success, failure_reason FROM users_login_attempt WHERE user_id = $1 ORDER BY id")
+	sessionQuery := regexp.QuoteMeta("SELECT family_id, created_at, expires_at, used_at, revoked_at FROM users_refresh_token WHERE user_id = $1 ORDER BY id")
+	suspensionQuery := regexp.QuoteMeta("SELECT reason, suspended_by, created_at, expires_at, lifted_at, lifted_by FROM users_suspension WHERE user_id = $1 ORDER BY id")
+	mfaQuery := regexp.QuoteMeta("SELECT created_at, enabled_at FROM users_mfa WHERE user_id = $1")
+	passwordQuery := regexp.QuoteMeta("SELECT created_at FROM users_password_history WHERE user_id = $1 ORDER BY id")
+	historyRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows(loginAttemptColumns).AddRow(7, createdAt, "192.0.2.1", "curl", AuthMethodPassword, false, LoginFailureInvalidPassword)
+	}
+	sessionRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"family_id", "created_at", "expires_at", "used_at", "revoked_at"}).
+			AddRow("family", createdAt, expiresAt, createdAt, nil)
+	}
+	suspensionRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"reason", "suspended_by", "created_at", "expires_at", "lifted_at", "lifted_by"}).
+			AddRow("spam", 2, createdAt, nil, expiresAt, liftedBy)
+	}
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      GetUserRecordsInput
+		wantOutput GetUserRecordsOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(historyQuery).WithArgs(1).WillReturnRows(historyRows())
+				mockDB.ExpectQuery(sessionQuery).WithArgs(1).WillReturnRows(sessionRows())
+				mockDB.ExpectQuery(suspensionQuery).WithArgs(1).WillReturnRows(suspensionRows())
+				mockDB.ExpectQuery(mfaQuery).WithArgs(1).WillReturnRows(
+					sqlmock.NewRows([]string{"created_at", "enabled_at"}).AddRow(createdAt, expiresAt))
+				mockDB.ExpectQuery(passwordQuery).WithArgs(1).WillReturnRows(
+					sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt).AddRow(expiresAt))
+			},
+			input: GetUserRecordsInput{UserID: 1},
+			wantOutput: GetUserRecordsOutput{
+				LoginAttempts: []LoginAttempt{
+					{ID: 7, CreatedAt: createdAt, IPAddress: "192.0.2.1", UserAgent: "curl", AuthMethod: AuthMethodPassword, FailureReason: LoginFailureInvalidPassword},
+				},
+				Sessions: []SessionRecord{
+					{FamilyID: "family", CreatedAt: createdAt, ExpiresAt: expiresAt, UsedAt: &createdAt},
+				},
+				Suspensions: []SuspensionRecord{
+					{Reason: "spam", SuspendedBy: 2, CreatedAt: createdAt, LiftedAt: &expiresAt, LiftedBy: &liftedBy},
+				},
+				MFA:             &MFARecord{EnrolledAt: createdAt, EnabledAt: &expiresAt},
+				PasswordChanges: []time.Time{createdAt, expiresAt},
+			},
+		},
+		{
+			name: "success without records",
+			mockFunc: func() {
+				mockDB.ExpectQuery(historyQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows(loginAttemptColumns))
+				mockDB.ExpectQuery(sessionQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"family_id", "created_at", "expires_at", "used_at", "revoked_at"}))
+				mockDB.ExpectQuery(suspensionQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"reason", "suspended_by", "created_at", "expires_at", "lifted_at", "lifted_by"}))
+				mockDB.ExpectQuery(mfaQuery).WithArgs(1).WillReturnError(sql.ErrNoRows)
+				mockDB.ExpectQuery(passwordQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"created_at"}))
+			},
+			input: GetUserRecordsInput{UserID: 1},
+			wantOutput: GetUserRecordsOutput{
+				LoginAttempts:   []LoginAttempt{},
+				Sessions:        []SessionRecord{},
+				Suspensions:     []SuspensionRecord{},
+				PasswordChanges: []time.Time{},
+			},
+		},
+		{
+			name: "error while query login attempts",
+			mockFunc: func() {
+				mockDB.ExpectQuery(historyQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:      GetUserRecordsInput{UserID: 1},
+			wantOutput: GetUserRecordsOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while query sessions",
+			mockFunc: func() {
+				mockDB.ExpectQuery(historyQuery).WillReturnRows(historyRows())
+				mockDB.ExpectQuery(sessionQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:      GetUserRecordsInput{UserID: 1},
+			wantOutput: GetUserRecordsOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while query suspensions",
+			mockFunc: func() {
+				mockDB.ExpectQuery(historyQuery).WillReturnRows(historyRows())
+				mockDB.ExpectQuery(sessionQuery).WillReturnRows(sessionRows())
+				mockDB.ExpectQuery(suspensionQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:      GetUserRecordsInput{UserID: 1},
+			wantOutput: GetUserRecordsOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while query mfa",
+			mockFunc: func() {
+				mockDB.ExpectQuery(historyQuery).WillReturnRows(historyRows())
+				mockDB.ExpectQuery(sessionQuery).WillReturnRows(sessionRows())
+				mockDB.ExpectQuery(suspensionQuery).WillReturnRows(suspensionRows())
+				mockDB.ExpectQuery(mfaQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:      GetUserRecordsInput{UserID: 1},
+			wantOutput: GetUserRecordsOutput{},
+			wantErr:    true,
+		},
+		{
+			name: "error while query password changes",
+			mockFunc: func() {
+				mockDB.ExpectQuery(historyQuery).WillReturnRows(historyRows())
+				mockDB.ExpectQuery(sessionQuery).WillReturnRows(sessionRows())
+				mockDB.ExpectQuery(suspensionQuery).WillReturnRows(suspensionRows())
+				mockDB.ExpectQuery(mfaQuery).WillReturnRows(sqlmock.NewRows([]string{"created_at", "enabled_at"}).AddRow(createdAt, nil))
+				mockDB.ExpectQuery(passwordQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:      GetUserRecordsInput{UserID: 1},
+			wantOutput: GetUserRecordsOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetUserRecords(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetUserRecords() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetUserRecords() = %+v, want %+v", gotOutput, tt.wantOutput)
+			}
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Errorf("Repository.GetUserRecords() expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRepository_CreateDataExport(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	insertQuery := regexp.QuoteMeta("INSERT INTO users_data_export(job_id, user_id, format, status, created_at) VALUES($1, $2, $3, $4, $5) RETURNING created_at")
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      CreateDataExportInput
+		wantOutput CreateDataExportOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(insertQuery).WithArgs("job", 1, DataExportFormatZip, DataExportStatusPending, sqlmock.AnyArg()).
+					WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow(createdAt))
+			},
+			input:      CreateDataExportInput{JobID: "job", UserID: 1, Format: DataExportFormatZip},
+			wantOutput: CreateDataExportOutput{CreatedAt: createdAt},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectQuery(insertQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:      CreateDataExportInput{JobID: "job", UserID: 1, Format: DataExportFormatZip},
+			wantOutput: CreateDataExportOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.CreateDataExport(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.CreateDataExport() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.CreateDataExport() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_GetDataExport(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := createdAt.Add(24 * time.Hour)
+	selectQuery := regexp.QuoteMeta("SELECT job_id, format, status, archive, error_message, created_at, completed_at, expires_at FROM users_data_export WHERE job_id = $1 AND user_id = $2 AND (expires_at IS NULL OR expires_at > $3)")
+	columns := []string{"job_id", "format", "status", "archive", "error_message", "created_at", "completed_at", "expires_at"}
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      GetDataExportInput
+		wantOutput GetDataExportOutput
+		wantErr    bool
+	}{
+		{
+			name: "success completed",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WithArgs("job", 1, sqlmock.AnyArg()).WillReturnRows(
+					sqlmock.NewRows(columns).AddRow("job", DataExportFormatJSON, DataExportStatusCompleted, []byte("{}"), nil, createdAt, createdAt, expiresAt))
+			},
+			input: GetDataExportInput{JobID: "job", UserID: 1},
+			wantOutput: GetDataExportOutput{
+				JobID:       "job",
+				Format:      DataExportFormatJSON,
+				Status:      DataExportStatusCompleted,
+				Archive:     []byte("{}"),
+				CreatedAt:   createdAt,
+				CompletedAt: &createdAt,
+				ExpiresAt:   &expiresAt,
+			},
+		},
+		{
+			name: "success pending",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WithArgs("job", 1, sqlmock.AnyArg()).WillReturnRows(
+					sqlmock.NewRows(columns).AddRow("job", DataExportFormatJSON, DataExportStatusPending, nil, nil, createdAt, nil, nil))
+			},
+			input: GetDataExportInput{JobID: "job", UserID: 1},
+			wantOutput: GetDataExportOutput{
+				JobID:     "job",
+				Format:    DataExportFormatJSON,
+				Status:    DataExportStatusPending,
+				CreatedAt: createdAt,
+			},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WillReturnError(sql.ErrNoRows)
+			},
+			input:      GetDataExportInput{JobID: "job", UserID: 1},
+			wantOutput: GetDataExportOutput{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetDataExport(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetDataExport() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetDataExport() = %+v, want %+v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_CompleteDataExport(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updateQuery := regexp.QuoteMeta("UPDATE users_data_export SET status = $1, archive = $2, error_message = $3, completed_at = $4, expires_at = $5, updated_at = $4 WHERE job_id = $6")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		input    CompleteDataExportInput
+		wantErr  bool
+	}{
+		{
+			name: "success completed",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(DataExportStatusCompleted, []byte("{}"), nil, sqlmock.AnyArg(), expiresAt, "job").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			input: CompleteDataExportInput{JobID: "job", Archive: []byte("{}"), ExpiresAt: expiresAt},
+		},
+		{
+			name: "success failed",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(DataExportStatusFailed, []byte(nil), "some error", sqlmock.AnyArg(), expiresAt, "job").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			input: CompleteDataExportInput{JobID: "job", ErrorMessage: "some error", ExpiresAt: expiresAt},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   CompleteDataExportInput{JobID: "job", Archive: []byte("{}"), ExpiresAt: expiresAt},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.CompleteDataExport(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.CompleteDataExport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_FailDataExports(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	createdBefore := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := createdBefore.Add(24 * time.Hour)
+	updateQuery := regexp.QuoteMeta("UPDATE users_data_export SET status = $1, error_message = $2, completed_at = $3, expires_at = $4, updated_at = $3 WHERE status = $5 AND created_at < $6")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		input    FailDataExportsInput
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(DataExportStatusFailed, "some error", sqlmock.AnyArg(), expiresAt, DataExportStatusPending, createdBefore).
+					WillReturnResult(sqlmock.NewResult(0, 2))
+			},
+			input: FailDataExportsInput{CreatedBefore: createdBefore, ErrorMessage: "some error", ExpiresAt: expiresAt},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   FailDataExportsInput{CreatedBefore: createdBefore, ErrorMessage: "some error", ExpiresAt: expiresAt},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.FailDataExports(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.FailDataExports() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetLoginFailure(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	failedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	lockedUntil := failedAt.Add(15 * time.Minute)
+	selectQuery := regexp.QuoteMeta("SELECT failures, last_failure_at, locked_until FROM login_failure WHERE key = $1")
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		key        string
+		wantOutput LoginFailure
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WithArgs("account:+628123456789").WillReturnRows(
+					sqlmock.NewRows([]string{"failures", "last_failure_at", "locked_until"}).AddRow(10, failedAt, lockedUntil))
+			},
+			key:        "account:+628123456789",
+			wantOutput: LoginFailure{Failures: 10, LastFailureAt: failedAt, LockedUntil: &lockedUntil},
+		},
+		{
+			name: "success without failures",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WithArgs("account:+628123456789").WillReturnError(sql.ErrNoRows)
+			},
+			key:        "account:+628123456789",
+			wantOutput: LoginFailure{},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			key:        "account:+628123456789",
+			wantOutput: LoginFailure{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetLoginFailure(context.Background(), tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetLoginFailure() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetLoginFailure() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_AddLoginFailure(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	failedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	windowStart := failedAt.Add(-time.Hour)
+	upsertQuery := regexp.QuoteMeta("INSERT INTO login_failure(key, failures, last_failure_at, created_at) VALUES($1, 1, $2, $2) ON CONFLICT (key) DO UPDATE SET")
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		input      AddLoginFailureInput
+		wantOutput LoginFailure
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(upsertQuery).WithArgs("ip:192.0.2.1", failedAt, windowStart).WillReturnRows(
+					sqlmock.NewRows([]string{"failures", "last_failure_at", "locked_until"}).AddRow(2, failedAt, nil))
+			},
+			input:      AddLoginFailureInput{Key: "ip:192.0.2.1", FailedAt: failedAt, WindowStart: windowStart},
+			wantOutput: LoginFailure{Failures: 2, LastFailureAt: failedAt},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectQuery(upsertQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:      AddLoginFailureInput{Key: "ip:192.0.2.1", FailedAt: failedAt, WindowStart: windowStart},
+			wantOutput: LoginFailure{},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.AddLoginFailure(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.AddLoginFailure() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.AddLoginFailure() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_LockLogin(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	lockedUntil := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updateQuery := regexp.QuoteMeta("UPDATE login_failure SET locked_until = $1, updated_at = $2 WHERE key = $3")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		input    LockLoginInput
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(lockedUntil, sqlmock.AnyArg(), "account:+628123456789").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			input: LockLoginInput{Key: "account:+628123456789", LockedUntil: lockedUntil},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			input:   LockLoginInput{Key: "account:+628123456789", LockedUntil: lockedUntil},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.LockLogin(context.Background(), tt.input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.LockLogin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_ResetLoginFailures(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	deleteQuery := regexp.QuoteMeta("DELETE FROM login_failure WHERE key = $1")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		key      string
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(deleteQuery).WithArgs("account:+628123456789").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			key: "account:+628123456789",
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(deleteQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			key:     "account:+628123456789",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.ResetLoginFailures(context.Background(), tt.key); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.ResetLoginFailures() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_PurgeLoginFailures(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	before := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deleteQuery := regexp.QuoteMeta("DELETE FROM login_failure WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $2)")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(deleteQuery).WithArgs(before, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(deleteQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.PurgeLoginFailures(context.Background(), before); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.PurgeLoginFailures() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_TakeRateLimitToken(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	upsertQuery := regexp.QuoteMeta("INSERT INTO rate_limit_bucket(key, full_at) VALUES($1, $2) ON CONFLICT (key) DO UPDATE SET")
+	selectQuery := regexp.QuoteMeta("SELECT full_at FROM rate_limit_bucket WHERE key = $1")
+	input := TakeRateLimitTokenInput{
+		Key:      "POST /login|ip:192.0.2.1",
+		Now:      now,
+		Interval: 12 * time.Second,
+		Period:   time.Minute,
+	}
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		wantOutput TakeRateLimitTokenOutput
+		wantErr    bool
+	}{
+		{
+			name: "success token taken",
+			mockFunc: func() {
+				mockDB.ExpectQuery(upsertQuery).WithArgs(input.Key, now.Add(12*time.Second), now, int64(12000000), now.Add(time.Minute)).WillReturnRows(
+					sqlmock.NewRows([]string{"full_at"}).AddRow(now.Add(24 * time.Second)))
+			},
+			wantOutput: TakeRateLimitTokenOutput{Allowed: true, FullAt: now.Add(24 * time.Second)},
+		},
+		{
+			name: "success bucket empty",
+			mockFunc: func() {
+				mockDB.ExpectQuery(upsertQuery).WillReturnRows(sqlmock.NewRows([]string{"full_at"}))
+				mockDB.ExpectQuery(selectQuery).WithArgs(input.Key).WillReturnRows(
+					sqlmock.NewRows([]string{"full_at"}).AddRow(now.Add(55 * time.Second)))
+			},
+			wantOutput: TakeRateLimitTokenOutput{FullAt: now.Add(55 * time.Second)},
+		},
+		{
+			name: "error on upsert",
+			mockFunc: func() {
+				mockDB.ExpectQuery(upsertQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "error on select",
+			mockFunc: func() {
+				mockDB.ExpectQuery(upsertQuery).WillReturnRows(sqlmock.NewRows([]string{"full_at"}))
+				mockDB.ExpectQuery(selectQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.TakeRateLimitToken(context.Background(), input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.TakeRateLimitToken() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.TakeRateLimitToken() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_PurgeRateLimitBuckets(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	before := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deleteQuery := regexp.QuoteMeta("DELETE FROM rate_limit_bucket WHERE full_at < $1")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(deleteQuery).WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(deleteQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.PurgeRateLimitBuckets(context.Background(), before); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.PurgeRateLimitBuckets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_CreateOTP(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	invalidateQuery := regexp.QuoteMeta("UPDATE users_otp SET used_at = $1, updated_at = $1 WHERE user_id = $2 AND purpose = $3 AND used_at IS NULL")
+	insertQuery := regexp.QuoteMeta("INSERT INTO users_otp(user_id, purpose, phone_number, code_hash, expires_at, created_at) VALUES($1, $2, $3, $4, $5, $6)")
+	input := CreateOTPInput{
+		UserID:      1,
+		Purpose:     OTPPurposePasswordReset,
+		PhoneNumber: "+628123456789",
+		CodeHash:    "hash",
+		ExpiresAt:   expiresAt,
+	}
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(invalidateQuery).WithArgs(sqlmock.AnyArg(), 1, OTPPurposePasswordReset).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(insertQuery).WithArgs(1, OTPPurposePasswordReset, "+628123456789", "hash", expiresAt, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockDB.ExpectCommit()
+			},
+		},
+		{
+			name: "error while begin transaction",
+			mockFunc: func() {
+				mockDB.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "error while invalidate previous codes",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(invalidateQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "error while insert code",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(invalidateQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(insertQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.CreateOTP(context.Background(), input); (err != nil) != tt.wantErr {
+				t.Errorf("Repository.CreateOTP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Errorf("Repository.CreateOTP() expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRepository_GetOTP(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	selectQuery := regexp.QuoteMeta("SELECT id, phone_number, code_hash, attempts, expires_at FROM users_otp WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > $3 ORDER BY id DESC LIMIT 1")
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		wantOutput GetOTPOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WithArgs(1, OTPPurposePasswordReset, sqlmock.AnyArg()).WillReturnRows(
+					sqlmock.NewRows([]string{"id", "phone_number", "code_hash", "attempts", "expires_at"}).AddRow(3, "+628123456789", "hash", 1, expiresAt))
+			},
+			wantOutput: GetOTPOutput{ID: 3, PhoneNumber: "+628123456789", CodeHash: "hash", Attempts: 1, ExpiresAt: expiresAt},
+		},
+		{
+			name: "error no active code",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetOTP(context.Background(), GetOTPInput{UserID: 1, Purpose: OTPPurposePasswordReset})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetOTP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetOTP() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_UseOTPAttempt(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	updateQuery := regexp.QuoteMeta("UPDATE users_otp SET attempts = attempts + 1, updated_at = $1 WHERE id = $2 AND attempts < $3 AND used_at IS NULL")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(sqlmock.AnyArg(), 3, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "error attempts exceeded",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(sqlmock.AnyArg(), 3, 5).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: ErrOTPAttemptsExceeded,
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.UseOTPAttempt(context.Background(), UseOTPAttemptInput{ID: 3, MaxAttempts: 5}); err != tt.wantErr {
+				t.Errorf("Repository.UseOTPAttempt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_ConsumeOTP(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	updateQuery := regexp.QuoteMeta("UPDATE users_otp SET used_at = $1, updated_at = $1 WHERE id = $2 AND used_at IS NULL")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "error already used",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: ErrOTPAlreadyUsed,
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.ConsumeOTP(context.Background(), 3); err != tt.wantErr {
+				t.Errorf("Repository.ConsumeOTP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_VerifyPhoneNumber(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	updateQuery := regexp.QuoteMeta("UPDATE users SET phone_number = $1, phone_verified_at = $2, updated_at = $2 WHERE id = $3")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs("+628123456789", sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.VerifyPhoneNumber(context.Background(), VerifyPhoneNumberInput{UserID: 1, PhoneNumber: "+628123456789"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.VerifyPhoneNumber() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetPasswordHistory(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	selectQuery := regexp.QuoteMeta("SELECT password FROM users_password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2")
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		wantOutput GetPasswordHistoryOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WithArgs(1, 5).
+					WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("hash 2").AddRow("hash 1"))
+			},
+			wantOutput: GetPasswordHistoryOutput{Passwords: []string{"hash 2", "hash 1"}},
+		},
+		{
+			name: "success without history",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WillReturnRows(sqlmock.NewRows([]string{"password"}))
+			},
+			wantOutput: GetPasswordHistoryOutput{Passwords: []string{}},
+		},
+		{
+			name: "error while select",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetPasswordHistory(context.Background(), GetPasswordHistoryInput{UserID: 1, Limit: 5})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetPasswordHistory() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetPasswordHistory() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_ChangePassword(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	selectQuery := regexp.QuoteMeta("SELECT password FROM users WHERE id = $1 FOR UPDATE")
+	insertQuery := regexp.QuoteMeta("INSERT INTO users_password_history(user_id, password, created_at) VALUES($1, $2, $3)")
+	updateQuery := regexp.QuoteMeta("UPDATE users SET password = $1, updated_at = $2 WHERE id = $3")
+	trimQuery := regexp.QuoteMeta("DELETE FROM users_password_history WHERE user_id = $1 AND id NOT IN (SELECT id FROM users_password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2)")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(selectQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("old hash"))
+				mockDB.ExpectExec(insertQuery).WithArgs(1, "old hash", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockDB.ExpectExec(updateQuery).WithArgs("new hash", sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(trimQuery).WithArgs(1, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectCommit()
+			},
+		},
+		{
+			name: "error while begin transaction",
+			mockFunc: func() {
+				mockDB.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "error while select user",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(selectQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "error while insert history",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(selectQuery).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("old hash"))
+				mockDB.ExpectExec(insertQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "error while update user",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(selectQuery).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("old hash"))
+				mockDB.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockDB.ExpectExec(updateQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "error while trim history",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectQuery(selectQuery).WillReturnRows(sqlmock.NewRows([]string{"password"}).AddRow("old hash"))
+				mockDB.ExpectExec(insertQuery).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockDB.ExpectExec(updateQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(trimQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.ChangePassword(context.Background(), ChangePasswordInput{UserID: 1, Password: "new hash", HistorySize: 5})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.ChangePassword() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Errorf("Repository.ChangePassword() expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRepository_CreateTOTP(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	upsertQuery := regexp.QuoteMeta("INSERT INTO users_mfa(user_id, secret, created_at) VALUES($1, $2, $3) ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = NULL, updated_at = EXCLUDED.created_at WHERE users_mfa.enabled_at IS NULL")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(upsertQuery).WithArgs(1, "secret", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name: "error already enabled",
+			mockFunc: func() {
+				mockDB.ExpectExec(upsertQuery).WithArgs(1, "secret", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: ErrTOTPAlreadyEnabled,
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(upsertQuery).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.CreateTOTP(context.Background(), CreateTOTPInput{UserID: 1, Secret: "secret"}); err != tt.wantErr {
+				t.Errorf("Repository.CreateTOTP() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetTOTP(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	enabledAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	selectQuery := regexp.QuoteMeta("SELECT secret, enabled_at FROM users_mfa WHERE user_id = $1")
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		wantOutput GetTOTPOutput
+		wantErr    bool
+	}{
+		{
+			name: "success pending",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"secret", "enabled_at"}).AddRow("secret", nil))
+			},
+			wantOutput: GetTOTPOutput{Secret: "secret"},
+		},
+		{
+			name: "success enabled",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"secret", "enabled_at"}).AddRow("secret", enabledAt))
+			},
+			wantOutput: GetTOTPOutput{Secret: "secret", EnabledAt: &enabledAt},
+		},
+		{
+			name: "error not enrolled",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetTOTP(context.Background(), 1)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetTOTP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetTOTP() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_EnableTOTP(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	enableQuery := regexp.QuoteMeta("UPDATE users_mfa SET enabled_at = $1, last_used_step = $2, updated_at = $1 WHERE user_id = $3 AND enabled_at IS NULL")
+	deleteQuery := regexp.QuoteMeta("DELETE FROM users_mfa_recovery_code WHERE user_id = $1")
+	insertQuery := regexp.QuoteMeta("INSERT INTO users_mfa_recovery_code(user_id, code_hash, created_at) VALUES($1, $2, $3)")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(enableQuery).WithArgs(sqlmock.AnyArg(), int64(42), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteQuery).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(insertQuery).WithArgs(1, "hash 1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+				mockDB.ExpectExec(insertQuery).WithArgs(1, "hash 2", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+				mockDB.ExpectCommit()
+			},
+		},
+		{
+			name: "error while begin transaction",
+			mockFunc: func() {
+				mockDB.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "error already enabled",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(enableQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "error while delete previous codes",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(enableQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "error while insert code",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(enableQuery).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectExec(deleteQuery).WillReturnResult(sqlmock.NewResult(0, 0))
+				mockDB.ExpectExec(insertQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.EnableTOTP(context.Background(), EnableTOTPInput{UserID: 1, Step: 42, RecoveryCodeHashes: []string{"hash 1", "hash 2"}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.EnableTOTP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Errorf("Repository.EnableTOTP() expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRepository_UseTOTPStep(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	updateQuery := regexp.QuoteMeta("UPDATE users_mfa SET last_used_step = $1, updated_at = $2 WHERE user_id = $3 AND enabled_at IS NOT NULL AND (last_used_step IS NULL OR last_used_step < $1)")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(int64(42), sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "error step already used",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(int64(42), sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: ErrTOTPStepUsed,
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.UseTOTPStep(context.Background(), UseTOTPStepInput{UserID: 1, Step: 42}); err != tt.wantErr {
+				t.Errorf("Repository.UseTOTPStep() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_DisableTOTP(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	deleteCodeQuery := regexp.QuoteMeta("DELETE FROM users_mfa_recovery_code WHERE user_id = $1")
+	deleteMFAQuery := regexp.QuoteMeta("DELETE FROM users_mfa WHERE user_id = $1")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(deleteCodeQuery).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 10))
+				mockDB.ExpectExec(deleteMFAQuery).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mockDB.ExpectCommit()
+			},
+		},
+		{
+			name: "error while begin transaction",
+			mockFunc: func() {
+				mockDB.ExpectBegin().WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "error while delete recovery codes",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(deleteCodeQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "error while delete mfa",
+			mockFunc: func() {
+				mockDB.ExpectBegin()
+				mockDB.ExpectExec(deleteCodeQuery).WillReturnResult(sqlmock.NewResult(0, 10))
+				mockDB.ExpectExec(deleteMFAQuery).WillReturnError(fmt.Errorf("some error"))
+				mockDB.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.DisableTOTP(context.Background(), 1)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.DisableTOTP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err := mockDB.ExpectationsWereMet(); err != nil {
+				t.Errorf("Repository.DisableTOTP() expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRepository_UseRecoveryCode(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	updateQuery := regexp.QuoteMeta("UPDATE users_mfa_recovery_code SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(sqlmock.AnyArg(), 1, "hash").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "error unknown or used code",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(sqlmock.AnyArg(), 1, "hash").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: ErrRecoveryCodeInvalid,
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.UseRecoveryCode(context.Background(), UseRecoveryCodeInput{UserID: 1, CodeHash: "hash"}); err != tt.wantErr {
+				t.Errorf("Repository.UseRecoveryCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_CreateMFAChallenge(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	expiresAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	insertQuery := regexp.QuoteMeta("INSERT INTO users_mfa_challenge(user_id, token_hash, expires_at, created_at) VALUES($1, $2, $3, $4)")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(insertQuery).WithArgs(1, "hash", expiresAt, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(insertQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.CreateMFAChallenge(context.Background(), CreateMFAChallengeInput{UserID: 1, TokenHash: "hash", ExpiresAt: expiresAt})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.CreateMFAChallenge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_GetMFAChallenge(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	selectQuery := regexp.QuoteMeta("SELECT users_mfa_challenge.id, users_mfa_challenge.user_id, users.phone_number FROM users_mfa_challenge JOIN users ON users.id = users_mfa_challenge.user_id WHERE users_mfa_challenge.token_hash = $1 AND users_mfa_challenge.used_at IS NULL AND users_mfa_challenge.expires_at > $2")
+	tests := []struct {
+		name       string
+		mockFunc   func()
+		wantOutput GetMFAChallengeOutput
+		wantErr    bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WithArgs("hash", sqlmock.AnyArg()).WillReturnRows(
+					sqlmock.NewRows([]string{"id", "user_id", "phone_number"}).AddRow(3, 1, "+628123456789"))
+			},
+			wantOutput: GetMFAChallengeOutput{ID: 3, UserID: 1, PhoneNumber: "+628123456789"},
+		},
+		{
+			name: "error no active challenge",
+			mockFunc: func() {
+				mockDB.ExpectQuery(selectQuery).WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			gotOutput, err := r.GetMFAChallenge(context.Background(), "hash")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.GetMFAChallenge() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(gotOutput, tt.wantOutput) {
+				t.Errorf("Repository.GetMFAChallenge() = %v, want %v", gotOutput, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestRepository_UseMFAChallengeAttempt(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	updateQuery := regexp.QuoteMeta("UPDATE users_mfa_challenge SET attempts = attempts + 1, updated_at = $1 WHERE id = $2 AND attempts < $3 AND used_at IS NULL")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(sqlmock.AnyArg(), 3, 5).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "error attempts exceeded",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(sqlmock.AnyArg(), 3, 5).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: ErrOTPAttemptsExceeded,
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.UseMFAChallengeAttempt(context.Background(), UseMFAChallengeAttemptInput{ID: 3, MaxAttempts: 5}); err != tt.wantErr {
+				t.Errorf("Repository.UseMFAChallengeAttempt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_ConsumeMFAChallenge(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	updateQuery := regexp.QuoteMeta("UPDATE users_mfa_challenge SET used_at = $1, updated_at = $1 WHERE id = $2 AND used_at IS NULL")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "error already used",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs(sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			wantErr: ErrOTPAlreadyUsed,
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(sql.ErrConnDone)
+			},
+			wantErr: sql.ErrConnDone,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			if err := r.ConsumeMFAChallenge(context.Background(), 3); err != tt.wantErr {
+				t.Errorf("Repository.ConsumeMFAChallenge() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRepository_RehashPassword(t *testing.T) {
+	db, mockDB, _ := sqlmock.New()
+	defer db.Close()
+	updateQuery := regexp.QuoteMeta("UPDATE users SET password = $1, updated_at = $2 WHERE id = $3 AND password = $4")
+	tests := []struct {
+		name     string
+		mockFunc func()
+		wantErr  bool
+	}{
+		{
+			name: "success",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs("new hash", sqlmock.AnyArg(), 1, "old hash").WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "success password changed meanwhile",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WithArgs("new hash", sqlmock.AnyArg(), 1, "old hash").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name: "error",
+			mockFunc: func() {
+				mockDB.ExpectExec(updateQuery).WillReturnError(fmt.Errorf("some error"))
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Repository{
+				Db: db,
+			}
+			tt.mockFunc()
+			err := r.RehashPassword(context.Background(), RehashPasswordInput{UserID: 1, OldPassword: "old hash", Password: "new hash"})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Repository.RehashPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -4,12 +4,67 @@
 // interfaces using mockgen. See the Makefile for more information.
 package repository
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type RepositoryInterface interface {
 	RegisterUser(ctx context.Context, req RegisterUserInput) (RegisterUserOutput, error)
 	LoginUser(ctx context.Context, req LoginUserInput) (LoginUserOutput, error)
 	GetUser(ctx context.Context, req GetUserInput) (GetUserOutput, error)
+	ListUsers(ctx context.Context, req ListUsersInput) (ListUsersOutput, error)
 	UpdateUser(ctx context.Context, req UpdateUserInput) (UpdateUserOutput, error)
 	IncrementLoginCount(ctx context.Context, userID int) (err error)
+	RecordLoginAttempt(ctx context.Context, req RecordLoginAttemptInput) (err error)
+	ListLoginAttempts(ctx context.Context, req ListLoginAttemptsInput) (ListLoginAttemptsOutput, error)
+	CreateRefreshToken(ctx context.Context, req CreateRefreshTokenInput) (err error)
+	GetRefreshToken(ctx context.Context, req GetRefreshTokenInput) (GetRefreshTokenOutput, error)
+	RotateRefreshToken(ctx context.Context, req RotateRefreshTokenInput) (err error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) (err error)
+	RevokeUserRefreshTokens(ctx context.Context, userID int) (err error)
+	RevokeToken(ctx context.Context, req RevokeTokenInput) (err error)
+	RevokeUserTokens(ctx context.Context, req RevokeUserTokensInput) (err error)
+	GetTokenRevocation(ctx context.Context, req GetTokenRevocationInput) (GetTokenRevocationOutput, error)
+	GetOAuthClient(ctx context.Context, req GetOAuthClientInput) (GetOAuthClientOutput, error)
+	GetUserRoles(ctx context.Context, req GetUserRolesInput) (GetUserRolesOutput, error)
+	GetUserPermissions(ctx context.Context, req GetUserPermissionsInput) (GetUserPermissionsOutput, error)
+	SuspendUser(ctx context.Context, req SuspendUserInput) error
+	ReactivateUser(ctx context.Context, req ReactivateUserInput) error
+	GetUserSuspension(ctx context.Context, req GetUserSuspensionInput) (GetUserSuspensionOutput, error)
+	RequestUserDeletion(ctx context.Context, req RequestUserDeletionInput) (RequestUserDeletionOutput, error)
+	CancelUserDeletion(ctx context.Context, userID int) error
+	PurgeDeletedUsers(ctx context.Context, req PurgeDeletedUsersInput) (PurgeDeletedUsersOutput, error)
+	CountUserRecords(ctx context.Context, userID int) (int, error)
+	GetUserRecords(ctx context.Context, req GetUserRecordsInput) (GetUserRecordsOutput, error)
+	CreateDataExport(ctx context.Context, req CreateDataExportInput) (CreateDataExportOutput, error)
+	GetDataExport(ctx context.Context, req GetDataExportInput) (GetDataExportOutput, error)
+	CompleteDataExport(ctx context.Context, req CompleteDataExportInput) error
+	FailDataExports(ctx context.Context, req FailDataExportsInput) error
+	GetLoginFailure(ctx context.Context, key string) (LoginFailure, error)
+	AddLoginFailure(ctx context.Context, req AddLoginFailureInput) (LoginFailure, error)
+	LockLogin(ctx context.Context, req LockLoginInput) error
+	ResetLoginFailures(ctx context.Context, key string) error
+	PurgeLoginFailures(ctx context.Context, before time.Time) error
+	TakeRateLimitToken(ctx context.Context, req TakeRateLimitTokenInput) (TakeRateLimitTokenOutput, error)
+	PurgeRateLimitBuckets(ctx context.Context, before time.Time) error
+	CreateOTP(ctx context.Context, req CreateOTPInput) error
+	GetOTP(ctx context.Context, req GetOTPInput) (GetOTPOutput, error)
+	UseOTPAttempt(ctx context.Context, req UseOTPAttemptInput) error
+	ConsumeOTP(ctx context.Context, id int) error
+	VerifyPhoneNumber(ctx context.Context, req VerifyPhoneNumberInput) error
+	RevokeOtherRefreshTokens(ctx context.Context, req RevokeOtherRefreshTokensInput) error
+	GetPasswordHistory(ctx context.Context, req GetPasswordHistoryInput) (GetPasswordHistoryOutput, error)
+	ChangePassword(ctx context.Context, req ChangePasswordInput) error
+	RehashPassword(ctx context.Context, req RehashPasswordInput) error
+	CreateTOTP(ctx context.Context, req CreateTOTPInput) error
+	GetTOTP(ctx context.Context, userID int) (GetTOTPOutput, error)
+	EnableTOTP(ctx context.Context, req EnableTOTPInput) error
+	UseTOTPStep(ctx context.Context, req UseTOTPStepInput) error
+	DisableTOTP(ctx context.Context, userID int) error
+	UseRecoveryCode(ctx context.Context, req UseRecoveryCodeInput) error
+	CreateMFAChallenge(ctx context.Context, req CreateMFAChallengeInput) error
+	GetMFAChallenge(ctx context.Context, tokenHash string) (GetMFAChallengeOutput, error)
+	UseMFAChallengeAttempt(ctx context.Context, req UseMFAChallengeAttemptInput) error
+	ConsumeMFAChallenge(ctx context.Context, id int) error
 }
@@ -12,6 +12,7 @@ package repository
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -39,6 +40,354 @@ func (m *MockRepositoryInterface) EXPECT() *MockRepositoryInterfaceMockRecorder
 	return m.recorder
 }
 
+// AddLoginFailure mocks base method.
+func (m *MockRepositoryInterface) AddLoginFailure(ctx context.Context, req AddLoginFailureInput) (LoginFailure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddLoginFailure", ctx, req)
+	ret0, _ := ret[0].(LoginFailure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddLoginFailure indicates an expected call of AddLoginFailure.
+func (mr *MockRepositoryInterfaceMockRecorder) AddLoginFailure(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLoginFailure", reflect.TypeOf((*MockRepositoryInterface)(nil).AddLoginFailure), ctx, req)
+}
+
+// CancelUserDeletion mocks base method.
+func (m *MockRepositoryInterface) CancelUserDeletion(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelUserDeletion", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelUserDeletion indicates an expected call of CancelUserDeletion.
+func (mr *MockRepositoryInterfaceMockRecorder) CancelUserDeletion(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelUserDeletion", reflect.TypeOf((*MockRepositoryInterface)(nil).CancelUserDeletion), ctx, userID)
+}
+
+// ChangePassword mocks base method.
+func (m *MockRepositoryInterface) ChangePassword(ctx context.Context, req ChangePasswordInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockRepositoryInterfaceMockRecorder) ChangePassword(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockRepositoryInterface)(nil).ChangePassword), ctx, req)
+}
+
+// CompleteDataExport mocks base method.
+func (m *MockRepositoryInterface) CompleteDataExport(ctx context.Context, req CompleteDataExportInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteDataExport", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteDataExport indicates an expected call of CompleteDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) CompleteDataExport(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).CompleteDataExport), ctx, req)
+}
+
+// ConsumeMFAChallenge mocks base method.
+func (m *MockRepositoryInterface) ConsumeMFAChallenge(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeMFAChallenge", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConsumeMFAChallenge indicates an expected call of ConsumeMFAChallenge.
+func (mr *MockRepositoryInterfaceMockRecorder) ConsumeMFAChallenge(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeMFAChallenge", reflect.TypeOf((*MockRepositoryInterface)(nil).ConsumeMFAChallenge), ctx, id)
+}
+
+// ConsumeOTP mocks base method.
+func (m *MockRepositoryInterface) ConsumeOTP(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumeOTP", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConsumeOTP indicates an expected call of ConsumeOTP.
+func (mr *MockRepositoryInterfaceMockRecorder) ConsumeOTP(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumeOTP", reflect.TypeOf((*MockRepositoryInterface)(nil).ConsumeOTP), ctx, id)
+}
+
+// CountUserRecords mocks base method.
+func (m *MockRepositoryInterface) CountUserRecords(ctx context.Context, userID int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountUserRecords", ctx, userID)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountUserRecords indicates an expected call of CountUserRecords.
+func (mr *MockRepositoryInterfaceMockRecorder) CountUserRecords(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUserRecords", reflect.TypeOf((*MockRepositoryInterface)(nil).CountUserRecords), ctx, userID)
+}
+
+// CreateDataExport mocks base method.
+func (m *MockRepositoryInterface) CreateDataExport(ctx context.Context, req CreateDataExportInput) (CreateDataExportOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDataExport", ctx, req)
+	ret0, _ := ret[0].(CreateDataExportOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDataExport indicates an expected call of CreateDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateDataExport(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateDataExport), ctx, req)
+}
+
+// CreateMFAChallenge mocks base method.
+func (m *MockRepositoryInterface) CreateMFAChallenge(ctx context.Context, req CreateMFAChallengeInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMFAChallenge", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMFAChallenge indicates an expected call of CreateMFAChallenge.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateMFAChallenge(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMFAChallenge", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateMFAChallenge), ctx, req)
+}
+
+// CreateOTP mocks base method.
+func (m *MockRepositoryInterface) CreateOTP(ctx context.Context, req CreateOTPInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOTP", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOTP indicates an expected call of CreateOTP.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateOTP(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOTP", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateOTP), ctx, req)
+}
+
+// CreateRefreshToken mocks base method.
+func (m *MockRepositoryInterface) CreateRefreshToken(ctx context.Context, req CreateRefreshTokenInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefreshToken", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRefreshToken indicates an expected call of CreateRefreshToken.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateRefreshToken(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateRefreshToken), ctx, req)
+}
+
+// CreateTOTP mocks base method.
+func (m *MockRepositoryInterface) CreateTOTP(ctx context.Context, req CreateTOTPInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTOTP", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateTOTP indicates an expected call of CreateTOTP.
+func (mr *MockRepositoryInterfaceMockRecorder) CreateTOTP(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTOTP", reflect.TypeOf((*MockRepositoryInterface)(nil).CreateTOTP), ctx, req)
+}
+
+// DisableTOTP mocks base method.
+func (m *MockRepositoryInterface) DisableTOTP(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableTOTP", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableTOTP indicates an expected call of DisableTOTP.
+func (mr *MockRepositoryInterfaceMockRecorder) DisableTOTP(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableTOTP", reflect.TypeOf((*MockRepositoryInterface)(nil).DisableTOTP), ctx, userID)
+}
+
+// EnableTOTP mocks base method.
+func (m *MockRepositoryInterface) EnableTOTP(ctx context.Context, req EnableTOTPInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnableTOTP", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnableTOTP indicates an expected call of EnableTOTP.
+func (mr *MockRepositoryInterfaceMockRecorder) EnableTOTP(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnableTOTP", reflect.TypeOf((*MockRepositoryInterface)(nil).EnableTOTP), ctx, req)
+}
+
+// FailDataExports mocks base method.
+func (m *MockRepositoryInterface) FailDataExports(ctx context.Context, req FailDataExportsInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailDataExports", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailDataExports indicates an expected call of FailDataExports.
+func (mr *MockRepositoryInterfaceMockRecorder) FailDataExports(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailDataExports", reflect.TypeOf((*MockRepositoryInterface)(nil).FailDataExports), ctx, req)
+}
+
+// GetDataExport mocks base method.
+func (m *MockRepositoryInterface) GetDataExport(ctx context.Context, req GetDataExportInput) (GetDataExportOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDataExport", ctx, req)
+	ret0, _ := ret[0].(GetDataExportOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDataExport indicates an expected call of GetDataExport.
+func (mr *MockRepositoryInterfaceMockRecorder) GetDataExport(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDataExport", reflect.TypeOf((*MockRepositoryInterface)(nil).GetDataExport), ctx, req)
+}
+
+// GetLoginFailure mocks base method.
+func (m *MockRepositoryInterface) GetLoginFailure(ctx context.Context, key string) (LoginFailure, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLoginFailure", ctx, key)
+	ret0, _ := ret[0].(LoginFailure)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLoginFailure indicates an expected call of GetLoginFailure.
+func (mr *MockRepositoryInterfaceMockRecorder) GetLoginFailure(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginFailure", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLoginFailure), ctx, key)
+}
+
+// GetMFAChallenge mocks base method.
+func (m *MockRepositoryInterface) GetMFAChallenge(ctx context.Context, tokenHash string) (GetMFAChallengeOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMFAChallenge", ctx, tokenHash)
+	ret0, _ := ret[0].(GetMFAChallengeOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMFAChallenge indicates an expected call of GetMFAChallenge.
+func (mr *MockRepositoryInterfaceMockRecorder) GetMFAChallenge(ctx, tokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMFAChallenge", reflect.TypeOf((*MockRepositoryInterface)(nil).GetMFAChallenge), ctx, tokenHash)
+}
+
+// GetOAuthClient mocks base method.
+func (m *MockRepositoryInterface) GetOAuthClient(ctx context.Context, req GetOAuthClientInput) (GetOAuthClientOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOAuthClient", ctx, req)
+	ret0, _ := ret[0].(GetOAuthClientOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOAuthClient indicates an expected call of GetOAuthClient.
+func (mr *MockRepositoryInterfaceMockRecorder) GetOAuthClient(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOAuthClient", reflect.TypeOf((*MockRepositoryInterface)(nil).GetOAuthClient), ctx, req)
+}
+
+// GetOTP mocks base method.
+func (m *MockRepositoryInterface) GetOTP(ctx context.Context, req GetOTPInput) (GetOTPOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOTP", ctx, req)
+	ret0, _ := ret[0].(GetOTPOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOTP indicates an expected call of GetOTP.
+func (mr *MockRepositoryInterfaceMockRecorder) GetOTP(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOTP", reflect.TypeOf((*MockRepositoryInterface)(nil).GetOTP), ctx, req)
+}
+
+// GetPasswordHistory mocks base method.
+func (m *MockRepositoryInterface) GetPasswordHistory(ctx context.Context, req GetPasswordHistoryInput) (GetPasswordHistoryOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordHistory", ctx, req)
+	ret0, _ := ret[0].(GetPasswordHistoryOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordHistory indicates an expected call of GetPasswordHistory.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPasswordHistory(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordHistory", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPasswordHistory), ctx, req)
+}
+
+// GetRefreshToken mocks base method.
+func (m *MockRepositoryInterface) GetRefreshToken(ctx context.Context, req GetRefreshTokenInput) (GetRefreshTokenOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefreshToken", ctx, req)
+	ret0, _ := ret[0].(GetRefreshTokenOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefreshToken indicates an expected call of GetRefreshToken.
+func (mr *MockRepositoryInterfaceMockRecorder) GetRefreshToken(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefreshToken", reflect.TypeOf((*MockRepositoryInterface)(nil).GetRefreshToken), ctx, req)
+}
+
+// GetTOTP mocks base method.
+func (m *MockRepositoryInterface) GetTOTP(ctx context.Context, userID int) (GetTOTPOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTOTP", ctx, userID)
+	ret0, _ := ret[0].(GetTOTPOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTOTP indicates an expected call of GetTOTP.
+func (mr *MockRepositoryInterfaceMockRecorder) GetTOTP(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTOTP", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTOTP), ctx, userID)
+}
+
+// GetTokenRevocation mocks base method.
+func (m *MockRepositoryInterface) GetTokenRevocation(ctx context.Context, req GetTokenRevocationInput) (GetTokenRevocationOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTokenRevocation", ctx, req)
+	ret0, _ := ret[0].(GetTokenRevocationOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTokenRevocation indicates an expected call of GetTokenRevocation.
+func (mr *MockRepositoryInterfaceMockRecorder) GetTokenRevocation(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTokenRevocation", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTokenRevocation), ctx, req)
+}
+
 // GetUser mocks base method.
 func (m *MockRepositoryInterface) GetUser(ctx context.Context, req GetUserInput) (GetUserOutput, error) {
 	m.ctrl.T.Helper()
@@ -54,6 +403,66 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetUser(ctx, req any) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUser), ctx, req)
 }
 
+// GetUserPermissions mocks base method.
+func (m *MockRepositoryInterface) GetUserPermissions(ctx context.Context, req GetUserPermissionsInput) (GetUserPermissionsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserPermissions", ctx, req)
+	ret0, _ := ret[0].(GetUserPermissionsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserPermissions indicates an expected call of GetUserPermissions.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUserPermissions(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserPermissions", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserPermissions), ctx, req)
+}
+
+// GetUserRecords mocks base method.
+func (m *MockRepositoryInterface) GetUserRecords(ctx context.Context, req GetUserRecordsInput) (GetUserRecordsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserRecords", ctx, req)
+	ret0, _ := ret[0].(GetUserRecordsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserRecords indicates an expected call of GetUserRecords.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUserRecords(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserRecords", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserRecords), ctx, req)
+}
+
+// GetUserRoles mocks base method.
+func (m *MockRepositoryInterface) GetUserRoles(ctx context.Context, req GetUserRolesInput) (GetUserRolesOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserRoles", ctx, req)
+	ret0, _ := ret[0].(GetUserRolesOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserRoles indicates an expected call of GetUserRoles.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUserRoles(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserRoles", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserRoles), ctx, req)
+}
+
+// GetUserSuspension mocks base method.
+func (m *MockRepositoryInterface) GetUserSuspension(ctx context.Context, req GetUserSuspensionInput) (GetUserSuspensionOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserSuspension", ctx, req)
+	ret0, _ := ret[0].(GetUserSuspensionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserSuspension indicates an expected call of GetUserSuspension.
+func (mr *MockRepositoryInterfaceMockRecorder) GetUserSuspension(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserSuspension", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserSuspension), ctx, req)
+}
+
 // IncrementLoginCount mocks base method.
 func (m *MockRepositoryInterface) IncrementLoginCount(ctx context.Context, userID int) error {
 	m.ctrl.T.Helper()
@@ -68,6 +477,50 @@ func (mr *MockRepositoryInterfaceMockRecorder) IncrementLoginCount(ctx, userID a
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IncrementLoginCount", reflect.TypeOf((*MockRepositoryInterface)(nil).IncrementLoginCount), ctx, userID)
 }
 
+// ListLoginAttempts mocks base method.
+func (m *MockRepositoryInterface) ListLoginAttempts(ctx context.Context, req ListLoginAttemptsInput) (ListLoginAttemptsOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLoginAttempts", ctx, req)
+	ret0, _ := ret[0].(ListLoginAttemptsOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLoginAttempts indicates an expected call of ListLoginAttempts.
+func (mr *MockRepositoryInterfaceMockRecorder) ListLoginAttempts(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLoginAttempts", reflect.TypeOf((*MockRepositoryInterface)(nil).ListLoginAttempts), ctx, req)
+}
+
+// ListUsers mocks base method.
+func (m *MockRepositoryInterface) ListUsers(ctx context.Context, req ListUsersInput) (ListUsersOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsers", ctx, req)
+	ret0, _ := ret[0].(ListUsersOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsers indicates an expected call of ListUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) ListUsers(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).ListUsers), ctx, req)
+}
+
+// LockLogin mocks base method.
+func (m *MockRepositoryInterface) LockLogin(ctx context.Context, req LockLoginInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockLogin", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockLogin indicates an expected call of LockLogin.
+func (mr *MockRepositoryInterfaceMockRecorder) LockLogin(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockLogin", reflect.TypeOf((*MockRepositoryInterface)(nil).LockLogin), ctx, req)
+}
+
 // LoginUser mocks base method.
 func (m *MockRepositoryInterface) LoginUser(ctx context.Context, req LoginUserInput) (LoginUserOutput, error) {
 	m.ctrl.T.Helper()
@@ -83,6 +536,77 @@ func (mr *MockRepositoryInterfaceMockRecorder) LoginUser(ctx, req any) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoginUser", reflect.TypeOf((*MockRepositoryInterface)(nil).LoginUser), ctx, req)
 }
 
+// PurgeDeletedUsers mocks base method.
+func (m *MockRepositoryInterface) PurgeDeletedUsers(ctx context.Context, req PurgeDeletedUsersInput) (PurgeDeletedUsersOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeletedUsers", ctx, req)
+	ret0, _ := ret[0].(PurgeDeletedUsersOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeletedUsers indicates an expected call of PurgeDeletedUsers.
+func (mr *MockRepositoryInterfaceMockRecorder) PurgeDeletedUsers(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeletedUsers", reflect.TypeOf((*MockRepositoryInterface)(nil).PurgeDeletedUsers), ctx, req)
+}
+
+// PurgeLoginFailures mocks base method.
+func (m *MockRepositoryInterface) PurgeLoginFailures(ctx context.Context, before time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeLoginFailures", ctx, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeLoginFailures indicates an expected call of PurgeLoginFailures.
+func (mr *MockRepositoryInterfaceMockRecorder) PurgeLoginFailures(ctx, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeLoginFailures", reflect.TypeOf((*MockRepositoryInterface)(nil).PurgeLoginFailures), ctx, before)
+}
+
+// PurgeRateLimitBuckets mocks base method.
+func (m *MockRepositoryInterface) PurgeRateLimitBuckets(ctx context.Context, before time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeRateLimitBuckets", ctx, before)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PurgeRateLimitBuckets indicates an expected call of PurgeRateLimitBuckets.
+func (mr *MockRepositoryInterfaceMockRecorder) PurgeRateLimitBuckets(ctx, before any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeRateLimitBuckets", reflect.TypeOf((*MockRepositoryInterface)(nil).PurgeRateLimitBuckets), ctx, before)
+}
+
+// ReactivateUser mocks base method.
+func (m *MockRepositoryInterface) ReactivateUser(ctx context.Context, req ReactivateUserInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReactivateUser", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReactivateUser indicates an expected call of ReactivateUser.
+func (mr *MockRepositoryInterfaceMockRecorder) ReactivateUser(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReactivateUser", reflect.TypeOf((*MockRepositoryInterface)(nil).ReactivateUser), ctx, req)
+}
+
+// RecordLoginAttempt mocks base method.
+func (m *MockRepositoryInterface) RecordLoginAttempt(ctx context.Context, req RecordLoginAttemptInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordLoginAttempt", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RecordLoginAttempt indicates an expected call of RecordLoginAttempt.
+func (mr *MockRepositoryInterfaceMockRecorder) RecordLoginAttempt(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordLoginAttempt", reflect.TypeOf((*MockRepositoryInterface)(nil).RecordLoginAttempt), ctx, req)
+}
+
 // RegisterUser mocks base method.
 func (m *MockRepositoryInterface) RegisterUser(ctx context.Context, req RegisterUserInput) (RegisterUserOutput, error) {
 	m.ctrl.T.Helper()
@@ -98,6 +622,162 @@ func (mr *MockRepositoryInterfaceMockRecorder) RegisterUser(ctx, req any) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegisterUser", reflect.TypeOf((*MockRepositoryInterface)(nil).RegisterUser), ctx, req)
 }
 
+// RehashPassword mocks base method.
+func (m *MockRepositoryInterface) RehashPassword(ctx context.Context, req RehashPasswordInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RehashPassword", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RehashPassword indicates an expected call of RehashPassword.
+func (mr *MockRepositoryInterfaceMockRecorder) RehashPassword(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RehashPassword", reflect.TypeOf((*MockRepositoryInterface)(nil).RehashPassword), ctx, req)
+}
+
+// RequestUserDeletion mocks base method.
+func (m *MockRepositoryInterface) RequestUserDeletion(ctx context.Context, req RequestUserDeletionInput) (RequestUserDeletionOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequestUserDeletion", ctx, req)
+	ret0, _ := ret[0].(RequestUserDeletionOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RequestUserDeletion indicates an expected call of RequestUserDeletion.
+func (mr *MockRepositoryInterfaceMockRecorder) RequestUserDeletion(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequestUserDeletion", reflect.TypeOf((*MockRepositoryInterface)(nil).RequestUserDeletion), ctx, req)
+}
+
+// ResetLoginFailures mocks base method.
+func (m *MockRepositoryInterface) ResetLoginFailures(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetLoginFailures", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetLoginFailures indicates an expected call of ResetLoginFailures.
+func (mr *MockRepositoryInterfaceMockRecorder) ResetLoginFailures(ctx, key any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetLoginFailures", reflect.TypeOf((*MockRepositoryInterface)(nil).ResetLoginFailures), ctx, key)
+}
+
+// RevokeOtherRefreshTokens mocks base method.
+func (m *MockRepositoryInterface) RevokeOtherRefreshTokens(ctx context.Context, req RevokeOtherRefreshTokensInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeOtherRefreshTokens", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeOtherRefreshTokens indicates an expected call of RevokeOtherRefreshTokens.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeOtherRefreshTokens(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeOtherRefreshTokens", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeOtherRefreshTokens), ctx, req)
+}
+
+// RevokeRefreshTokenFamily mocks base method.
+func (m *MockRepositoryInterface) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeRefreshTokenFamily", ctx, familyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeRefreshTokenFamily indicates an expected call of RevokeRefreshTokenFamily.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeRefreshTokenFamily(ctx, familyID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeRefreshTokenFamily", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeRefreshTokenFamily), ctx, familyID)
+}
+
+// RevokeToken mocks base method.
+func (m *MockRepositoryInterface) RevokeToken(ctx context.Context, req RevokeTokenInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeToken(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeToken), ctx, req)
+}
+
+// RevokeUserRefreshTokens mocks base method.
+func (m *MockRepositoryInterface) RevokeUserRefreshTokens(ctx context.Context, userID int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserRefreshTokens", ctx, userID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserRefreshTokens indicates an expected call of RevokeUserRefreshTokens.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeUserRefreshTokens(ctx, userID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserRefreshTokens", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeUserRefreshTokens), ctx, userID)
+}
+
+// RevokeUserTokens mocks base method.
+func (m *MockRepositoryInterface) RevokeUserTokens(ctx context.Context, req RevokeUserTokensInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserTokens", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserTokens indicates an expected call of RevokeUserTokens.
+func (mr *MockRepositoryInterfaceMockRecorder) RevokeUserTokens(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserTokens", reflect.TypeOf((*MockRepositoryInterface)(nil).RevokeUserTokens), ctx, req)
+}
+
+// RotateRefreshToken mocks base method.
+func (m *MockRepositoryInterface) RotateRefreshToken(ctx context.Context, req RotateRefreshTokenInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateRefreshToken", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RotateRefreshToken indicates an expected call of RotateRefreshToken.
+func (mr *MockRepositoryInterfaceMockRecorder) RotateRefreshToken(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateRefreshToken", reflect.TypeOf((*MockRepositoryInterface)(nil).RotateRefreshToken), ctx, req)
+}
+
+// SuspendUser mocks base method.
+func (m *MockRepositoryInterface) SuspendUser(ctx context.Context, req SuspendUserInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SuspendUser", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SuspendUser indicates an expected call of SuspendUser.
+func (mr *MockRepositoryInterfaceMockRecorder) SuspendUser(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SuspendUser", reflect.TypeOf((*MockRepositoryInterface)(nil).SuspendUser), ctx, req)
+}
+
+// TakeRateLimitToken mocks base method.
+func (m *MockRepositoryInterface) TakeRateLimitToken(ctx context.Context, req TakeRateLimitTokenInput) (TakeRateLimitTokenOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeRateLimitToken", ctx, req)
+	ret0, _ := ret[0].(TakeRateLimitTokenOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeRateLimitToken indicates an expected call of TakeRateLimitToken.
+func (mr *MockRepositoryInterfaceMockRecorder) TakeRateLimitToken(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeRateLimitToken", reflect.TypeOf((*MockRepositoryInterface)(nil).TakeRateLimitToken), ctx, req)
+}
+
 // UpdateUser mocks base method.
 func (m *MockRepositoryInterface) UpdateUser(ctx context.Context, req UpdateUserInput) (UpdateUserOutput, error) {
 	m.ctrl.T.Helper()
@@ -112,3 +792,73 @@ func (mr *MockRepositoryInterfaceMockRecorder) UpdateUser(ctx, req any) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUser), ctx, req)
 }
+
+// UseMFAChallengeAttempt mocks base method.
+func (m *MockRepositoryInterface) UseMFAChallengeAttempt(ctx context.Context, req UseMFAChallengeAttemptInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseMFAChallengeAttempt", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseMFAChallengeAttempt indicates an expected call of UseMFAChallengeAttempt.
+func (mr *MockRepositoryInterfaceMockRecorder) UseMFAChallengeAttempt(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseMFAChallengeAttempt", reflect.TypeOf((*MockRepositoryInterface)(nil).UseMFAChallengeAttempt), ctx, req)
+}
+
+// UseOTPAttempt mocks base method.
+func (m *MockRepositoryInterface) UseOTPAttempt(ctx context.Context, req UseOTPAttemptInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseOTPAttempt", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseOTPAttempt indicates an expected call of UseOTPAttempt.
+func (mr *MockRepositoryInterfaceMockRecorder) UseOTPAttempt(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseOTPAttempt", reflect.TypeOf((*MockRepositoryInterface)(nil).UseOTPAttempt), ctx, req)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockRepositoryInterface) UseRecoveryCode(ctx context.Context, req UseRecoveryCodeInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockRepositoryInterfaceMockRecorder) UseRecoveryCode(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockRepositoryInterface)(nil).UseRecoveryCode), ctx, req)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockRepositoryInterface) UseTOTPStep(ctx context.Context, req UseTOTPStepInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockRepositoryInterfaceMockRecorder) UseTOTPStep(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockRepositoryInterface)(nil).UseTOTPStep), ctx, req)
+}
+
+// VerifyPhoneNumber mocks base method.
+func (m *MockRepositoryInterface) VerifyPhoneNumber(ctx context.Context, req VerifyPhoneNumberInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyPhoneNumber", ctx, req)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// VerifyPhoneNumber indicates an expected call of VerifyPhoneNumber.
+func (mr *MockRepositoryInterfaceMockRecorder) VerifyPhoneNumber(ctx, req any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyPhoneNumber", reflect.TypeOf((*MockRepositoryInterface)(nil).VerifyPhoneNumber), ctx, req)
+}
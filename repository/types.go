@@ -1,6 +1,39 @@
 // This file contains types that are used in the repository layer.
 package repository
 
+import (
+	"errors"
+	"time"
+)
+
+// Roles that can be granted to users, every registered user gets RoleUser.
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+// ErrRefreshTokenAlreadyUsed is returned when a refresh token is rotated more than once.
+var ErrRefreshTokenAlreadyUsed = errors.New("refresh token already used")
+
+// ErrUserNotSuspended is returned when reactivating a user that has no active suspension.
+var ErrUserNotSuspended = errors.New("user is not suspended")
+
+// ErrOTPAttemptsExceeded is returned when a one-time code was guessed too many times.
+var ErrOTPAttemptsExceeded = errors.New("too many attempts")
+
+// ErrOTPAlreadyUsed is returned when a one-time code is used more than once.
+var ErrOTPAlreadyUsed = errors.New("code already used")
+
+// ErrTOTPAlreadyEnabled is returned when enrolling or confirming a TOTP second factor that is already enabled.
+var ErrTOTPAlreadyEnabled = errors.New("totp already enabled")
+
+// ErrTOTPStepUsed is returned when a TOTP code of a time step that was already accepted is used again.
+var ErrTOTPStepUsed = errors.New("totp code already used")
+
+// ErrRecoveryCodeInvalid is returned when a recovery code is unknown or already used.
+var ErrRecoveryCodeInvalid = errors.New("invalid recovery code")
+
 type RegisterUserInput struct {
 	FullName    string
 	Password    string
@@ -19,6 +52,12 @@ type LoginUserOutput struct {
 	UserID      int
 	PhoneNumber string
 	Password    string
+	// DeletionRequestedAt is set while the account is waiting to be purged
+	DeletionRequestedAt *time.Time
+	// PhoneVerifiedAt is nil until the user proves ownership of the phone number
+	PhoneVerifiedAt *time.Time
+	// MFAEnabled is true when a login needs a TOTP or recovery code after the password
+	MFAEnabled bool
 }
 
 type GetUserInput struct {
@@ -26,9 +65,42 @@ type GetUserInput struct {
 }
 
 type GetUserOutput struct {
-	UserID      int
-	PhoneNumber string
-	FullName    string
+	UserID          int
+	PhoneNumber     string
+	FullName        string
+	CreatedAt       time.Time
+	PhoneVerifiedAt *time.Time
+}
+
+// Columns ListUsers can sort by, the user id is always used as tie breaker.
+const (
+	UserSortID        = "id"
+	UserSortCreatedAt = "created_at"
+	UserSortFullName  = "full_name"
+)
+
+type ListUsersInput struct {
+	// PhonePrefix and Name filter by phone number prefix and case insensitive name substring
+	PhonePrefix string
+	Name        string
+	// CreatedFrom is inclusive and CreatedTo is exclusive
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	SortBy      string
+	Descending  bool
+	// After is the last user of the previous page, nil for the first page
+	After *ListUsersCursor
+	Limit int
+}
+
+type ListUsersCursor struct {
+	UserID    int
+	CreatedAt time.Time
+	FullName  string
+}
+
+type ListUsersOutput struct {
+	Users []GetUserOutput
 }
 
 type UpdateUserInput struct {
@@ -39,8 +111,423 @@ type UpdateUserInput struct {
 }
 
 type UpdateUserOutput struct {
+	UserID          int
+	PhoneNumber     string
+	FullName        string
+	Password        string
+	PhoneVerifiedAt *time.Time
+}
+
+type CreateRefreshTokenInput struct {
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+}
+
+type GetRefreshTokenInput struct {
+	TokenHash string
+}
+
+type GetRefreshTokenOutput struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	ExpiresAt time.Time
+	IsUsed    bool
+	IsRevoked bool
+}
+
+type RotateRefreshTokenInput struct {
+	OldTokenID int
+	NewToken   CreateRefreshTokenInput
+}
+
+type RevokeTokenInput struct {
+	UserID    int
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+type RevokeUserTokensInput struct {
+	UserID        int
+	RevokedBefore time.Time
+}
+
+type GetTokenRevocationInput struct {
+	UserID  int
+	TokenID string
+}
+
+type GetTokenRevocationOutput struct {
+	IsTokenRevoked bool
+	RevokedBefore  time.Time
+}
+
+type GetOAuthClientInput struct {
+	ClientID string
+}
+
+type GetOAuthClientOutput struct {
+	ClientID     string
+	ClientSecret string
+	Name         string
+}
+
+type GetUserRolesInput struct {
+	UserID int
+}
+
+type GetUserRolesOutput struct {
+	Roles []string
+}
+
+type GetUserPermissionsInput struct {
+	UserID int
+}
+
+type GetUserPermissionsOutput struct {
+	Permissions []string
+}
+
+type SuspendUserInput struct {
 	UserID      int
+	Reason      string
+	SuspendedBy int
+	// ExpiresAt is nil for a suspension that lasts until the user is reactivated
+	ExpiresAt *time.Time
+}
+
+type ReactivateUserInput struct {
+	UserID        int
+	ReactivatedBy int
+}
+
+type GetUserSuspensionInput struct {
+	UserID int
+}
+
+type GetUserSuspensionOutput struct {
+	IsSuspended bool
+	Reason      string
+	ExpiresAt   *time.Time
+}
+
+type RequestUserDeletionInput struct {
+	UserID      int
+	RequestedBy int
+}
+
+type RequestUserDeletionOutput struct {
+	// RequestedAt is kept from the first request when the deletion is requested again
+	RequestedAt time.Time
+}
+
+type PurgeDeletedUsersInput struct {
+	RequestedBefore time.Time
+}
+
+type PurgeDeletedUsersOutput struct {
+	UserIDs []int
+}
+
+// Ways a user can log in, stored on every login attempt.
+const (
+	AuthMethodPassword = "password"
+	// AuthMethodTOTP is a password login completed with a TOTP code
+	AuthMethodTOTP = "totp"
+	// AuthMethodRecoveryCode is a password login completed with a recovery code
+	AuthMethodRecoveryCode = "recovery_code"
+	// AuthMethodSMSOTP is a passwordless login with a one-time code sent by sms
+	AuthMethodSMSOTP = "sms_otp"
+)
+
+// Reasons a login attempt failed.
+const (
+	LoginFailureUnknownUser     = "unknown_user"
+	LoginFailureInvalidPassword = "invalid_password"
+	LoginFailureSuspended       = "suspended"
+	// LoginFailureInvalidMFACode is a second factor code that didn't match
+	LoginFailureInvalidMFACode = "invalid_mfa_code"
+	// LoginFailureInvalidOTP is a one-time code sent by sms that didn't match, expired or was used
+	LoginFailureInvalidOTP = "invalid_otp"
+	// LoginFailureLocked is a login refused because the account is temporarily locked
+	LoginFailureLocked = "locked"
+	// LoginFailureThrottled is a login refused because of too many recent failures
+	LoginFailureThrottled = "throttled"
+)
+
+type RecordLoginAttemptInput struct {
+	// UserID is 0 for an attempt that isn't tied to a user, it is stored without user_id
+	// and is only found by the phone number
+	UserID        int
+	PhoneNumber   string
+	IPAddress     string
+	UserAgent     string
+	AuthMethod    string
+	Success       bool
+	FailureReason string
+}
+
+type LoginAttempt struct {
+	ID            int
+	CreatedAt     time.Time
+	IPAddress     string
+	UserAgent     string
+	AuthMethod    string
+	Success       bool
+	FailureReason string
+}
+
+type ListLoginAttemptsInput struct {
+	UserID int
+	// BeforeID continues after the last attempt of the previous page, 0 starts from the newest attempt
+	BeforeID int
+	Limit    int
+}
+
+type ListLoginAttemptsOutput struct {
+	Attempts []LoginAttempt
+}
+
+type GetUserRecordsInput struct {
+	UserID int
+}
+
+type SessionRecord struct {
+	FamilyID  string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+type SuspensionRecord struct {
+	Reason      string
+	SuspendedBy int
+	CreatedAt   time.Time
+	ExpiresAt   *time.Time
+	LiftedAt    *time.Time
+	LiftedBy    *int
+}
+
+// MFARecord is the second factor enrollment of a user, EnabledAt is nil until a first code confirms it.
+type MFARecord struct {
+	EnrolledAt time.Time
+	EnabledAt  *time.Time
+}
+
+// GetUserRecordsOutput holds the rows that belong to a user besides the profile and the roles.
+type GetUserRecordsOutput struct {
+	LoginAttempts []LoginAttempt
+	Sessions      []SessionRecord
+	Suspensions   []SuspensionRecord
+	// MFA is nil when the user never enrolled a second factor
+	MFA *MFARecord
+	// PasswordChanges are when the password was changed, only as far back as the password history goes
+	PasswordChanges []time.Time
+}
+
+// Formats and statuses of a personal data export job.
+const (
+	DataExportFormatJSON = "json"
+	DataExportFormatZip  = "zip"
+
+	DataExportStatusPending   = "pending"
+	DataExportStatusCompleted = "completed"
+	DataExportStatusFailed    = "failed"
+)
+
+type CreateDataExportInput struct {
+	JobID  string
+	UserID int
+	Format string
+}
+
+type CreateDataExportOutput struct {
+	CreatedAt time.Time
+}
+
+type GetDataExportInput struct {
+	JobID  string
+	UserID int
+}
+
+type GetDataExportOutput struct {
+	JobID        string
+	Format       string
+	Status       string
+	Archive      []byte
+	ErrorMessage string
+	CreatedAt    time.Time
+	CompletedAt  *time.Time
+	ExpiresAt    *time.Time
+}
+
+type CompleteDataExportInput struct {
+	JobID string
+	// Archive is nil when the export failed, ErrorMessage tells why
+	Archive      []byte
+	ErrorMessage string
+	ExpiresAt    time.Time
+}
+
+type FailDataExportsInput struct {
+	// CreatedBefore fails the jobs still pending that were created before it
+	CreatedBefore time.Time
+	ErrorMessage  string
+	ExpiresAt     time.Time
+}
+
+// LoginFailure is the failed login counter of an account or a client ip.
+type LoginFailure struct {
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+type AddLoginFailureInput struct {
+	Key      string
+	FailedAt time.Time
+	// WindowStart forgets the previous failures when the last one is older
+	WindowStart time.Time
+}
+
+type LockLoginInput struct {
+	Key         string
+	LockedUntil time.Time
+}
+
+type TakeRateLimitTokenInput struct {
+	Key string
+	Now time.Time
+	// Interval is the time the bucket takes to refill one token
+	Interval time.Duration
+	// Period is the time the bucket takes to refill from empty, it is the capacity times Interval
+	Period time.Duration
+}
+
+type TakeRateLimitTokenOutput struct {
+	// Allowed is false when the bucket was empty, no token is taken then
+	Allowed bool
+	FullAt  time.Time
+}
+
+// What a one-time code can be used for, a code only works for its own purpose.
+const (
+	OTPPurposePasswordReset = "password_reset"
+	// OTPPurposePhoneVerification proves ownership of a new account or of a new phone number
+	OTPPurposePhoneVerification = "phone_verification"
+	// OTPPurposeLogin logs in without the password
+	OTPPurposeLogin = "login"
+)
+
+type CreateOTPInput struct {
+	UserID  int
+	Purpose string
+	// PhoneNumber is where the code is sent, it is the number being verified for OTPPurposePhoneVerification
 	PhoneNumber string
-	FullName    string
+	CodeHash    string
+	ExpiresAt   time.Time
+}
+
+type GetOTPInput struct {
+	UserID  int
+	Purpose string
+}
+
+type GetOTPOutput struct {
+	ID          int
+	PhoneNumber string
+	CodeHash    string
+	Attempts    int
+	ExpiresAt   time.Time
+}
+
+type UseOTPAttemptInput struct {
+	ID          int
+	MaxAttempts int
+}
+
+type VerifyPhoneNumberInput struct {
+	UserID int
+	// PhoneNumber becomes the phone number of the user, it differs from the current one on a change
+	PhoneNumber string
+}
+
+type RevokeOtherRefreshTokensInput struct {
+	UserID int
+	// KeepFamilyID is the refresh token family of the current session, empty revokes every family
+	KeepFamilyID string
+}
+
+type GetPasswordHistoryInput struct {
+	UserID int
+	Limit  int
+}
+
+type GetPasswordHistoryOutput struct {
+	// Passwords are the hashes of the previous passwords, newest first
+	Passwords []string
+}
+
+type ChangePasswordInput struct {
+	UserID   int
+	Password string
+	// HistorySize is how many previous passwords are kept after the change
+	HistorySize int
+}
+
+// RehashPasswordInput replaces the hash of the same password, it is only applied
+// while the stored hash is still OldPassword.
+type RehashPasswordInput struct {
+	UserID      int
+	OldPassword string
 	Password    string
 }
+
+type CreateTOTPInput struct {
+	UserID int
+	Secret string
+}
+
+type GetTOTPOutput struct {
+	Secret string
+	// EnabledAt is nil while the enrollment waits for its first code
+	EnabledAt *time.Time
+}
+
+type EnableTOTPInput struct {
+	UserID int
+	// Step is the time step of the code that confirmed the enrollment
+	Step int64
+	// RecoveryCodeHashes replace the previous recovery codes of the user
+	RecoveryCodeHashes []string
+}
+
+type UseTOTPStepInput struct {
+	UserID int
+	Step   int64
+}
+
+type UseRecoveryCodeInput struct {
+	UserID   int
+	CodeHash string
+}
+
+type CreateMFAChallengeInput struct {
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+}
+
+type GetMFAChallengeOutput struct {
+	ID          int
+	UserID      int
+	PhoneNumber string
+}
+
+type UseMFAChallengeAttemptInput struct {
+	ID          int
+	MaxAttempts int
+}